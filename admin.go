@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"dimandocs/vector"
+)
+
+// errReindexRunning is returned when a reindex is started while another one
+// started from the admin page is still running
+var errReindexRunning = errors.New("a reindex is already running")
+
+// AdminSource describes a configured source on the admin page
+type AdminSource struct {
+	Name      string `json:"Name"`
+	Path      string `json:"Path"`
+	Remote    string `json:"Remote,omitempty"` // Repository, space, bucket or site mirrored into Path
+	Documents int    `json:"Documents"`        // Loaded documents
+	Embedded  int    `json:"Embedded"`         // Indexing status of the documents, with embeddings enabled
+	Pending   int    `json:"Pending"`
+	Failed    int    `json:"Failed"`
+}
+
+// AdminError is a document whose indexing failed
+type AdminError struct {
+	Path   string `json:"Path"`
+	Source string `json:"Source,omitempty"` // Empty for documents no longer loaded
+	Error  string `json:"Error"`
+}
+
+// ReindexState reports the reindex started from the admin page
+type ReindexState struct {
+	Running    bool          `json:"Running"`
+	Source     string        `json:"Source,omitempty"` // Empty for all sources
+	Force      bool          `json:"Force"`
+	StartedAt  *time.Time    `json:"StartedAt,omitempty"`  // Omitted if none was started
+	FinishedAt *time.Time    `json:"FinishedAt,omitempty"` // Omitted while running
+	Stats      *ReindexStats `json:"Stats,omitempty"`      // Outcome of a finished reindex
+	Error      string        `json:"Error,omitempty"`      // Why a finished reindex failed
+}
+
+// AdminStatus is the state of the server shown on the admin page
+type AdminStatus struct {
+	Documents int             `json:"Documents"`
+	Sources   []AdminSource   `json:"Sources"`
+	Index     *IndexStatsJSON `json:"Index,omitempty"` // Omitted without embeddings
+	Errors    []AdminError    `json:"Errors"`          // Documents whose indexing failed
+	Reindex   ReindexState    `json:"Reindex"`
+}
+
+// adminReindex runs one reindex at a time in the background
+type adminReindex struct {
+	mu    sync.Mutex
+	state ReindexState
+}
+
+// requireAuthConfigured answers 403 and returns false unless auth is
+// configured. Handlers that change documents or run operations use it, so
+// that visitors of a public site cannot.
+func (a *App) requireAuthConfigured(w http.ResponseWriter) bool {
+	a.mu.RLock()
+	enabled := a.Config.Auth.Enabled()
+	a.mu.RUnlock()
+	if !enabled {
+		http.Error(w, "This operation requires auth to be configured", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// AdminStatus reports the sources with the indexing status of their
+// documents, the index statistics, the documents whose indexing failed and
+// the last reindex started from the admin page
+func (a *App) AdminStatus() (AdminStatus, error) {
+	docs := a.GetDocuments()
+	a.mu.RLock()
+	dirs := slices.Clone(a.Config.Directories)
+	a.mu.RUnlock()
+
+	status := AdminStatus{Documents: len(docs), Sources: []AdminSource{}, Errors: []AdminError{}}
+	sourceIndex := make(map[string]int, len(dirs)) // By directory path
+	for _, dirConfig := range dirs {
+		source := AdminSource{Name: dirConfig.Name, Path: dirConfig.Path}
+		if remote, _ := a.remoteSource(dirConfig); remote != nil {
+			source.Remote = remote.String()
+		}
+		sourceIndex[dirConfig.Path] = len(status.Sources)
+		status.Sources = append(status.Sources, source)
+	}
+	docSource := make(map[string]int, len(docs)) // By relative path
+	for _, doc := range docs {
+		if i, ok := sourceIndex[doc.SourceDir]; ok {
+			status.Sources[i].Documents++
+			docSource[doc.RelPath] = i
+		}
+	}
+
+	if m := a.EmbeddingManager; m != nil && m.IsEnabled() {
+		stats, err := a.indexStats()
+		if err != nil {
+			return status, fmt.Errorf("failed to get index statistics: %w", err)
+		}
+		status.Index = &stats
+
+		records, err := m.ListDocuments()
+		if err != nil {
+			return status, fmt.Errorf("failed to list indexed documents: %w", err)
+		}
+		for _, record := range records {
+			var source *AdminSource
+			if i, ok := docSource[record.Path]; ok {
+				source = &status.Sources[i]
+			}
+			if record.Status == vector.StatusFailed {
+				failure := AdminError{Path: record.Path, Error: record.Error}
+				if source != nil {
+					failure.Source = source.Name
+				}
+				status.Errors = append(status.Errors, failure)
+			}
+			if source == nil {
+				continue
+			}
+			switch record.Status {
+			case vector.StatusPending:
+				source.Pending++
+			case vector.StatusFailed:
+				source.Failed++
+			default:
+				source.Embedded++
+			}
+		}
+	}
+
+	a.reindex.mu.Lock()
+	status.Reindex = a.reindex.state
+	a.reindex.mu.Unlock()
+	return status, nil
+}
+
+// StartReindex rescans and re-embeds the documents of the source with the
+// given name, or of all sources for an empty name, in the background.
+// Unchanged documents are skipped unless force is set. Only one reindex
+// runs at a time.
+func (a *App) StartReindex(source string, force bool) (ReindexState, error) {
+	var dirConfig DirectoryConfig
+	if source != "" {
+		found := false
+		a.mu.RLock()
+		for _, d := range a.Config.Directories {
+			if d.Name == source {
+				dirConfig, found = d, true
+				break
+			}
+		}
+		a.mu.RUnlock()
+		if !found {
+			return ReindexState{}, fmt.Errorf("unknown source %q", source)
+		}
+	}
+
+	r := &a.reindex
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state.Running {
+		return r.state, errReindexRunning
+	}
+	started := time.Now().UTC()
+	r.state = ReindexState{Running: true, Source: source, Force: force, StartedAt: &started}
+
+	go func() {
+		var stats ReindexStats
+		var err error
+		if source == "" {
+			stats, err = a.Reindex(context.Background(), "", force)
+		} else {
+			stats, err = a.ReindexSource(context.Background(), dirConfig, force)
+		}
+		if err != nil {
+			slog.Warn("Reindex failed", "source", source, "error", err)
+		}
+
+		finished := time.Now().UTC()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.state.Running = false
+		r.state.FinishedAt = &finished
+		if err != nil {
+			r.state.Error = err.Error()
+		} else {
+			r.state.Stats = &stats
+		}
+	}()
+	return r.state, nil
+}
+
+// handleAdminStatus serves the state shown on the admin page
+func (a *App) handleAdminStatus(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAuthConfigured(w) {
+		return
+	}
+
+	status, err := a.AdminStatus()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get status: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// handleAdminReindex starts a reindex of all sources or of one, answering
+// 202 with its state, or 409 while another one is running
+func (a *App) handleAdminReindex(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAuthConfigured(w) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Source string `json:"source"` // Empty for all sources
+		Force  bool   `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	state, err := a.StartReindex(req.Source, req.Force)
+	switch {
+	case errors.Is(err, errReindexRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		slog.Warn("Failed to encode response", "error", err)
+	}
+}
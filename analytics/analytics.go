@@ -0,0 +1,263 @@
+package analytics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EventSearch is recorded for every executed search query
+	EventSearch = "search"
+	// EventClick is recorded when a user opens a document from search results
+	EventClick = "click"
+
+	// DefaultLogPath is the default location of the analytics log
+	DefaultLogPath = "search_analytics.jsonl"
+	// DefaultReportLimit is the default number of entries per report section
+	DefaultReportLimit = 20
+)
+
+// Event represents a single analytics log entry
+type Event struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Query   string    `json:"query"`
+	Mode    string    `json:"mode,omitempty"`
+	Results int       `json:"results"`
+	Path    string    `json:"path,omitempty"`
+}
+
+// QueryStat holds aggregated statistics for a normalized query
+type QueryStat struct {
+	Query            string    `json:"query"`
+	Searches         int       `json:"searches"`
+	Clicks           int       `json:"clicks"`
+	ClickThroughRate float64   `json:"click_through_rate"`
+	LastSeen         time.Time `json:"last_seen"`
+}
+
+// DocumentStat holds click statistics for a document
+type DocumentStat struct {
+	Path   string `json:"path"`
+	Clicks int    `json:"clicks"`
+}
+
+// Report is the aggregated view over the analytics log
+type Report struct {
+	Since             time.Time      `json:"since"`
+	TotalSearches     int            `json:"total_searches"`
+	TotalClicks       int            `json:"total_clicks"`
+	TopQueries        []QueryStat    `json:"top_queries"`
+	ZeroResultQueries []QueryStat    `json:"zero_result_queries"`
+	TopDocuments      []DocumentStat `json:"top_documents"`
+}
+
+// ReportOptions configures report aggregation
+type ReportOptions struct {
+	Since time.Time // Only include events after this time (zero = all)
+	Limit int       // Maximum entries per section
+}
+
+// Recorder appends search and click events to a local JSON lines file
+type Recorder struct {
+	path string
+	file *os.File
+	mu   sync.Mutex
+}
+
+// NewRecorder opens (or creates) the analytics log at path
+func NewRecorder(path string) (*Recorder, error) {
+	if path == "" {
+		path = DefaultLogPath
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics log: %w", err)
+	}
+
+	return &Recorder{
+		path: path,
+		file: file,
+	}, nil
+}
+
+// RecordSearch logs an executed search query and its result count
+func (r *Recorder) RecordSearch(query, mode string, results int) error {
+	query = NormalizeQuery(query)
+	if query == "" {
+		return nil
+	}
+	return r.write(Event{
+		Type:    EventSearch,
+		Time:    time.Now().UTC(),
+		Query:   query,
+		Mode:    mode,
+		Results: results,
+	})
+}
+
+// RecordClick logs that a document was opened from the results of query
+func (r *Recorder) RecordClick(query, path string) error {
+	query = NormalizeQuery(query)
+	if query == "" || path == "" {
+		return nil
+	}
+	return r.write(Event{
+		Type:  EventClick,
+		Time:  time.Now().UTC(),
+		Query: query,
+		Path:  path,
+	})
+}
+
+// write appends a single event to the log
+func (r *Recorder) write(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+// Report reads the log and aggregates it into top queries, zero-result
+// queries and the most clicked documents
+func (r *Recorder) Report(opts ReportOptions) (*Report, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultReportLimit
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := os.Open(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics log: %w", err)
+	}
+	defer file.Close()
+
+	queries := make(map[string]*QueryStat)
+	zeroResults := make(map[string]*QueryStat)
+	documents := make(map[string]int)
+	report := &Report{Since: opts.Since}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue // Skip corrupted lines
+		}
+		if !opts.Since.IsZero() && event.Time.Before(opts.Since) {
+			continue
+		}
+
+		stat := queries[event.Query]
+		if stat == nil {
+			stat = &QueryStat{Query: event.Query}
+			queries[event.Query] = stat
+		}
+		if event.Time.After(stat.LastSeen) {
+			stat.LastSeen = event.Time
+		}
+
+		switch event.Type {
+		case EventSearch:
+			report.TotalSearches++
+			stat.Searches++
+			if event.Results == 0 {
+				zero := zeroResults[event.Query]
+				if zero == nil {
+					zero = &QueryStat{Query: event.Query}
+					zeroResults[event.Query] = zero
+				}
+				zero.Searches++
+				if event.Time.After(zero.LastSeen) {
+					zero.LastSeen = event.Time
+				}
+			}
+		case EventClick:
+			report.TotalClicks++
+			stat.Clicks++
+			documents[event.Path]++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read analytics log: %w", err)
+	}
+
+	for _, stat := range queries {
+		if stat.Searches > 0 {
+			stat.ClickThroughRate = float64(stat.Clicks) / float64(stat.Searches)
+		}
+	}
+
+	report.TopQueries = topQueries(queries, opts.Limit)
+	report.ZeroResultQueries = topQueries(zeroResults, opts.Limit)
+
+	for path, clicks := range documents {
+		report.TopDocuments = append(report.TopDocuments, DocumentStat{Path: path, Clicks: clicks})
+	}
+	sort.Slice(report.TopDocuments, func(i, j int) bool {
+		if report.TopDocuments[i].Clicks != report.TopDocuments[j].Clicks {
+			return report.TopDocuments[i].Clicks > report.TopDocuments[j].Clicks
+		}
+		return report.TopDocuments[i].Path < report.TopDocuments[j].Path
+	})
+	if len(report.TopDocuments) > opts.Limit {
+		report.TopDocuments = report.TopDocuments[:opts.Limit]
+	}
+
+	return report, nil
+}
+
+// Close closes the analytics log
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file != nil {
+		return r.file.Close()
+	}
+	return nil
+}
+
+// topQueries sorts query stats by search count and returns the first limit entries
+func topQueries(stats map[string]*QueryStat, limit int) []QueryStat {
+	result := make([]QueryStat, 0, len(stats))
+	for _, stat := range stats {
+		if stat.Searches == 0 {
+			continue
+		}
+		result = append(result, *stat)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Searches != result[j].Searches {
+			return result[i].Searches > result[j].Searches
+		}
+		return result[i].Query < result[j].Query
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// NormalizeQuery lowercases a query and collapses whitespace so that
+// trivially different spellings aggregate together
+func NormalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
@@ -1,20 +1,41 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
-	"log"
+	"log/slog"
+	"mime"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
-
-	"github.com/russross/blackfriday/v2"
+	"sync"
+	"time"
+
+	"dimandocs/analytics"
+	"dimandocs/chunking"
+	"dimandocs/frontmatter"
+	"dimandocs/gitignore"
+	"dimandocs/htmlmd"
+	"dimandocs/render"
+	"dimandocs/secrets"
+	"dimandocs/spelling"
+	"dimandocs/vector"
+
+	"golang.org/x/crypto/acme/autocert"
 )
 
 //go:embed frontend/dist/*
@@ -40,50 +61,283 @@ func (a *App) Initialize(configFile string) error {
 	if err := a.LoadConfig(configFile); err != nil {
 		return err
 	}
+	configureLogging(a.Config.Logging)
+
+	// Clone or pull remote git sources before they are scanned
+	if err := a.SyncSources(context.Background()); err != nil {
+		return err
+	}
+	if err := a.createUploadsDirectory(); err != nil {
+		return err
+	}
 
 	// Scan directories for documents
 	if err := a.ScanDirectories(); err != nil {
 		return err
 	}
 
+	// Build spelling vocabulary and the cross-document link graph
+	a.SetDocuments(a.Documents)
+
+	// Open search analytics log if enabled
+	if a.Config.Analytics.Enabled {
+		recorder, err := analytics.NewRecorder(a.Config.Analytics.LogPath)
+		if err != nil {
+			return err
+		}
+		a.Analytics = recorder
+	}
+
+	return nil
+}
+
+// Close releases resources held by the application
+func (a *App) Close() error {
+	if a.Analytics != nil {
+		return a.Analytics.Close()
+	}
 	return nil
 }
 
-// ScanDirectories scans all configured directories for documents
+// ScanDirectories scans all configured directories for documents. The
+// documents of the directories that could be scanned are added even if
+// others failed; the errors of all failed directories are returned.
 func (a *App) ScanDirectories() error {
-	for _, dirConfig := range a.Config.Directories {
-		if err := a.scanDirectory(dirConfig.Path, dirConfig.Name, a.FileRegexes[dirConfig.Path]); err != nil {
-			return fmt.Errorf("failed to scan directory %s: %w", dirConfig.Path, err)
-		}
+	docs, err := a.loadDirectories(a.Config.Directories)
+	a.Documents = append(a.Documents, docs...)
+	return err
+}
+
+// ReloadDirectory rescans a directory, re-indexes its changed documents and
+// drops the documents of removed files
+func (a *App) ReloadDirectory(ctx context.Context, dirConfig DirectoryConfig) error {
+	docs, err := a.loadDirectory(dirConfig.Path, dirConfig.Name, a.FileRegexes[dirConfig.Path])
+	if err != nil {
+		return fmt.Errorf("failed to scan directory %s: %w", dirConfig.Path, err)
 	}
+
+	a.replaceDocuments(ctx, docs, func(d Document) bool { return d.SourceDir == dirConfig.Path }, false)
+
+	slog.Info("Reloaded directory", "directory", dirConfig.Name, "documents", len(docs))
 	return nil
 }
 
-// scanDirectory scans a single directory for matching files
-func (a *App) scanDirectory(rootDir string, sourceName string, fileRegex *regexp.Regexp) error {
-	return filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+// ReindexStats reports the outcome of a reindex
+type ReindexStats struct {
+	Documents int // Documents in scope after the rescan
+	Removed   int // Documents in scope whose files are gone
+	Failed    int // Documents that could not be embedded
+}
+
+// Reindex rescans every directory and re-embeds the documents whose relative
+// path is scope or lies below it, or all documents for an empty scope.
+// Unchanged documents are skipped unless force is set.
+func (a *App) Reindex(ctx context.Context, scope string, force bool) (ReindexStats, error) {
+	scope = strings.Trim(scope, "/")
+	inScope := func(d Document) bool {
+		return scope == "" || d.RelPath == scope || strings.HasPrefix(d.RelPath, scope+"/")
+	}
+
+	loaded, err := a.loadDirectories(a.Config.Directories)
+	if err != nil {
+		return ReindexStats{}, err
+	}
+	var docs []Document
+	for _, d := range loaded {
+		if inScope(d) {
+			docs = append(docs, d)
+		}
+	}
+
+	removed, failed := a.replaceDocuments(ctx, docs, inScope, force)
+	slog.Info("Reindexed documents", "documents", len(docs), "removed", removed, "failed", failed)
+	return ReindexStats{Documents: len(docs), Removed: removed, Failed: failed}, nil
+}
+
+// ReindexSource rescans the directory of a source and re-embeds its changed
+// documents, or all of them if force is set
+func (a *App) ReindexSource(ctx context.Context, dirConfig DirectoryConfig, force bool) (ReindexStats, error) {
+	docs, err := a.loadDirectory(dirConfig.Path, dirConfig.Name, a.FileRegexes[dirConfig.Path])
+	if err != nil {
+		return ReindexStats{}, fmt.Errorf("failed to scan directory %s: %w", dirConfig.Path, err)
+	}
+
+	removed, failed := a.replaceDocuments(ctx, docs, func(d Document) bool { return d.SourceDir == dirConfig.Path }, force)
+	slog.Info("Reindexed source", "directory", dirConfig.Name, "documents", len(docs), "removed", removed, "failed", failed)
+	return ReindexStats{Documents: len(docs), Removed: removed, Failed: failed}, nil
+}
+
+// ReindexDocument reloads a document from its file and re-embeds it, even
+// if unchanged. A document whose file is gone is removed.
+func (a *App) ReindexDocument(ctx context.Context, doc Document) (ReindexStats, error) {
+	var docs []Document
+	reloaded, err := a.loadDocument(doc.Path, doc.SourceDir, doc.SourceName)
+	switch {
+	case err == nil:
+		docs = []Document{reloaded}
+	case !errors.Is(err, fs.ErrNotExist):
+		return ReindexStats{}, err
+	}
+
+	removed, failed := a.replaceDocuments(ctx, docs, func(d Document) bool { return d.Path == doc.Path }, true)
+	slog.Info("Reindexed document", "path", doc.RelPath, "removed", removed, "failed", failed)
+	return ReindexStats{Documents: len(docs), Removed: removed, Failed: failed}, nil
+}
+
+// DeleteDocument removes a document and its embeddings. The file is kept, so
+// the document returns when the file changes or its directory is rescanned.
+func (a *App) DeleteDocument(ctx context.Context, doc Document) {
+	a.replaceDocuments(ctx, nil, func(d Document) bool { return d.Path == doc.Path }, false)
+	slog.Info("Deleted document", "path", doc.RelPath)
+}
+
+// replaceDocuments embeds docs and replaces the current documents selected
+// by replaced with them, deleting the embeddings of those that are gone. It
+// returns the number of removed documents and of documents that failed to
+// embed.
+func (a *App) replaceDocuments(ctx context.Context, docs []Document, replaced func(Document) bool, force bool) (removed, failed int) {
+	found := make(map[string]bool)
+	for i := range docs {
+		found[docs[i].Path] = true
+	}
+
+	if m := a.EmbeddingManager; m != nil && m.IsEnabled() {
+		m.IndexDocuments(ctx, docs, force, func(doc Document, _ IndexStats, err error) {
+			if err != nil {
+				slog.Warn("Failed to index document", "path", doc.RelPath, "error", err)
+				failed++
+			}
+		})
+		for i := range docs {
+			docs[i].Summary = m.Summary(docs[i])
+		}
+		for _, d := range a.GetDocuments() {
+			if replaced(d) && !found[d.Path] {
+				if err := m.DeleteDocument(d.RelPath); err != nil {
+					slog.Warn("Failed to delete embeddings", "path", d.RelPath, "error", err)
+				}
+			}
+		}
+	}
+
+	// Merge into the documents as they are now, which may have changed
+	// while embedding
+	a.UpdateDocuments(func(current []Document) ([]Document, bool) {
+		updated := make([]Document, 0, len(current)+len(docs))
+		for _, d := range current {
+			if !replaced(d) {
+				updated = append(updated, d)
+			} else if !found[d.Path] {
+				removed++
+			}
+		}
+		return append(updated, docs...), true
+	})
+	return removed, failed
+}
+
+// scanConcurrency is the number of directories scanned, and of files read
+// per directory, at the same time
+const scanConcurrency = 8
+
+// loadDirectories loads the documents of several directories, scanning up to
+// scanConcurrency of them at once. Documents are returned in the order of
+// the directories; the errors of all directories that failed are joined.
+func (a *App) loadDirectories(dirs []DirectoryConfig) ([]Document, error) {
+	loaded := make([][]Document, len(dirs))
+	errs := make([]error, len(dirs))
+	sem := make(chan struct{}, scanConcurrency)
+	var wg sync.WaitGroup
+
+	for i, dirConfig := range dirs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			docs, err := a.loadDirectory(dirConfig.Path, dirConfig.Name, a.FileRegexes[dirConfig.Path])
+			if err != nil {
+				err = fmt.Errorf("failed to scan directory %s: %w", dirConfig.Path, err)
+			}
+			loaded[i], errs[i] = docs, err
+		}()
+	}
+	wg.Wait()
+
+	var docs []Document
+	for _, dirDocs := range loaded {
+		docs = append(docs, dirDocs...)
+	}
+	return docs, errors.Join(errs...)
+}
+
+// loadDirectory loads the documents of the matching files in a directory,
+// reading up to scanConcurrency files at once. Files that cannot be read are
+// logged and skipped; paths that cannot be walked do not stop the walk, but
+// their errors are joined and returned along with the documents found.
+func (a *App) loadDirectory(rootDir string, sourceName string, fileRegex *regexp.Regexp) ([]Document, error) {
+	if a.directoryConfig(rootDir).RespectGitignore {
+		a.loadGitignore(rootDir)
+	}
+
+	var paths []string
+	var walkErrs []error
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return err
+			if path == rootDir {
+				return err
+			}
+			walkErrs = append(walkErrs, err)
+			return nil
 		}
 
-		if a.shouldIgnorePath(path) {
+		if a.shouldIgnorePath(path, info.IsDir()) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		if !info.IsDir() {
-			filename := info.Name()
-			if fileRegex.MatchString(filename) {
-				if err := a.processFile(path, rootDir, sourceName); err != nil {
-					log.Printf("Failed to process file %s: %v", path, err)
-				}
-			}
+		if !info.IsDir() && fileRegex.MatchString(info.Name()) {
+			paths = append(paths, path)
 		}
-
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Read and parse the files in parallel, keeping the walk order
+	loaded := make([]*Document, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(scanConcurrency, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				doc, err := a.loadDocument(paths[i], rootDir, sourceName)
+				if err != nil {
+					slog.Error("Failed to process file", "path", paths[i], "error", err)
+					continue
+				}
+				loaded[i] = &doc
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	docs := make([]Document, 0, len(paths))
+	for _, doc := range loaded {
+		if doc != nil {
+			docs = append(docs, *doc)
+		}
+	}
+	return docs, errors.Join(walkErrs...)
 }
 
 // extractOverviewParagraph extracts the first paragraph after "## Overview" heading
@@ -123,14 +377,26 @@ func extractOverviewParagraph(content string) string {
 	return strings.Join(paragraphLines, " ")
 }
 
-// processFile processes a single markdown file
-func (a *App) processFile(path, rootDir, sourceName string) error {
+// loadDocument reads and parses a single markdown file
+func (a *App) loadDocument(path, rootDir, sourceName string) (Document, error) {
 	content, err := ioutil.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return Document{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	var modTime time.Time
+	statOK := false
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+		// Content read later must come from the same version of the file
+		statOK = info.Size() == int64(len(content))
 	}
 
+	dirConfig := a.directoryConfig(rootDir)
 	relPath, _ := filepath.Rel(rootDir, path)
+	// Wiki and website pages are addressed by their slug or URL path
+	if dirConfig.GitLabWiki != nil || dirConfig.Website != nil {
+		relPath = strings.TrimSuffix(relPath, ".md")
+	}
 	dirName := filepath.Dir(relPath)
 	if dirName == "." {
 		dirName = "Root"
@@ -153,9 +419,45 @@ func (a *App) processFile(path, rootDir, sourceName string) error {
 		relAbsDir = "/" + strings.TrimPrefix(relAbsDir, "../")
 	}
 
+	language := ""
+	if dirConfig.Code {
+		language = chunking.Language(path)
+	}
+
+	// HTML pages are converted to markdown with the page boilerplate stripped
+	text := string(content)
+	if language == "" && isHTMLFile(path) {
+		page, err := htmlmd.Convert(bytes.NewReader(content))
+		if err != nil {
+			slog.Warn("Failed to convert HTML page", "path", relPath, "error", err)
+		} else {
+			text = page.Markdown
+		}
+	}
+
+	// Redact or flag secrets before the content is indexed or served
+	var findings []secrets.Finding
+	if a.SecretScanner != nil {
+		text, findings = a.SecretScanner.Process(text)
+		if len(findings) > 0 {
+			slog.Warn("Found potential secrets", "path", relPath, "count", len(findings))
+		}
+	}
+
+	// Frontmatter is metadata, so it is neither rendered nor indexed
+	var meta frontmatter.Metadata
+	if language == "" {
+		meta, text, err = frontmatter.Parse(text)
+		if err != nil {
+			slog.Warn("Ignoring frontmatter", "path", relPath, "error", err)
+		}
+	}
+
 	title := dirName
-	if strings.Contains(string(content), "# ") {
-		lines := strings.Split(string(content), "\n")
+	if meta.Title != "" {
+		title = meta.Title
+	} else if language == "" && strings.Contains(text, "# ") {
+		lines := strings.Split(text, "\n")
 		for _, line := range lines {
 			if strings.HasPrefix(line, "# ") {
 				title = strings.TrimPrefix(line, "# ")
@@ -164,27 +466,189 @@ func (a *App) processFile(path, rootDir, sourceName string) error {
 		}
 	}
 
-	// Extract overview paragraph
-	overview := extractOverviewParagraph(string(content))
+	// Extract overview paragraph, falling back to the description
+	overview := ""
+	if language == "" {
+		overview = extractOverviewParagraph(text)
+	}
+	if overview == "" {
+		overview = meta.Description
+	}
 
 	doc := Document{
 		Title:      title,
 		Path:       path,
-		Content:    string(content),
 		RelPath:    relPath,
 		DirName:    dirName,
 		SourceDir:  rootDir,
 		SourceName: sourceName,
+		Collection: dirConfig.Collection,
 		AbsPath:    relAbsDir,
 		Overview:   overview,
+		Secrets:    findings,
+
+		Description: meta.Description,
+		Tags:        frontmatter.NormalizeTags(append(meta.Tags, dirConfig.Tags...)),
+		Metadata:    meta.Extra,
+
+		Language: language,
+		ModTime:  modTime,
 	}
 
-	a.Documents = append(a.Documents, doc)
-	return nil
+	// Content that is the tail of the file, after any frontmatter, is read
+	// again when needed instead of being kept in memory
+	if raw := string(content); statOK && strings.HasSuffix(raw, text) {
+		doc.content = fileContent(path, modTime, int64(len(raw)), int64(len(raw)-len(text)), int64(len(text)))
+	} else {
+		doc.SetContent(text)
+	}
+
+	return doc, nil
+}
+
+// isHTMLFile reports whether path is an HTML page
+func isHTMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return true
+	}
+	return false
+}
+
+// directoryConfig returns the configuration of the directory at rootDir
+func (a *App) directoryConfig(rootDir string) DirectoryConfig {
+	for _, dirConfig := range a.Config.Directories {
+		if dirConfig.Path == rootDir {
+			return dirConfig
+		}
+	}
+	return DirectoryConfig{}
+}
+
+// documentMarkdown returns the markdown of a document for display, with
+// source files wrapped in a code block
+func documentMarkdown(doc Document) string {
+	content := doc.Content()
+	if doc.Language == "" {
+		return content
+	}
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	return fence + doc.Language + "\n" + strings.TrimRight(content, "\n") + "\n" + fence + "\n"
+}
+
+// inlineCodeRegex matches inline code spans, which usually hold identifiers
+var inlineCodeRegex = regexp.MustCompile("`([^`\n]+)`")
+
+// buildSpellCorrector builds the spelling vocabulary from document titles,
+// headings and identifiers
+func buildSpellCorrector(docs []Document) *spelling.Corrector {
+	corrector := spelling.NewCorrector()
+	for _, doc := range docs {
+		corrector.Add(doc.Title)
+		corrector.Add(doc.DirName)
+		content := doc.Content()
+		for _, line := range strings.Split(content, "\n") {
+			if strings.HasPrefix(line, "#") {
+				corrector.Add(strings.TrimLeft(line, "# "))
+			}
+		}
+		for _, match := range inlineCodeRegex.FindAllStringSubmatch(content, -1) {
+			corrector.Add(match[1])
+		}
+	}
+	return corrector
 }
 
-// shouldIgnorePath checks if a path should be ignored
-func (a *App) shouldIgnorePath(path string) bool {
+// GetDocuments returns the current set of documents. The returned slice must
+// not be modified; use SetDocuments to publish changes.
+func (a *App) GetDocuments() []Document {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.Documents
+}
+
+// SetDocuments replaces the set of documents and rebuilds the indexes derived
+// from them. Documents are copy-on-write: callers pass a new slice rather than
+// modifying the current one, so readers can keep iterating their snapshot.
+func (a *App) SetDocuments(docs []Document) {
+	a.UpdateDocuments(func([]Document) ([]Document, bool) { return docs, true })
+}
+
+// UpdateDocuments replaces the set of documents with the one update derives
+// from the current set, unless update reports no change. Updates run one at
+// a time, so that changes made concurrently, e.g. by the watcher during a
+// reindex, are not lost.
+func (a *App) UpdateDocuments(update func(current []Document) ([]Document, bool)) {
+	a.updateMu.Lock()
+	docs, changed := update(a.GetDocuments())
+	if !changed {
+		a.updateMu.Unlock()
+		return
+	}
+	speller := buildSpellCorrector(docs)
+	links := buildLinkGraph(docs)
+
+	a.mu.Lock()
+	a.Documents = docs
+	a.Speller = speller
+	a.Links = links
+	listeners := a.listeners
+	a.mu.Unlock()
+	a.updateMu.Unlock()
+
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+// OnDocumentsChanged registers fn to be called after every SetDocuments
+func (a *App) OnDocumentsChanged(fn func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.listeners = append(a.listeners, fn)
+}
+
+// LoadSummaries attaches cached LLM summaries to the loaded documents,
+// publishing them as a new set of documents
+func (a *App) LoadSummaries() {
+	if a.EmbeddingManager == nil {
+		return
+	}
+	a.UpdateDocuments(func(current []Document) ([]Document, bool) {
+		docs := slices.Clone(current)
+		changed := false
+		for i := range docs {
+			if summary := a.EmbeddingManager.Summary(docs[i]); summary != docs[i].Summary {
+				docs[i].Summary = summary
+				changed = true
+			}
+		}
+		return docs, changed
+	})
+}
+
+// shouldIgnorePath checks if a path should be ignored. The ignore globs are
+// matched relative to the configured directory containing the path, followed
+// by the directory's .gitignore files if it respects them, then the ignore
+// regexes against the path itself; a path matching any of them is ignored.
+func (a *App) shouldIgnorePath(path string, isDir bool) bool {
+	for _, dirConfig := range a.Config.Directories {
+		rel, err := filepath.Rel(dirConfig.Path, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if a.IgnoreGlobs.Ignored(filepath.ToSlash(rel), isDir) {
+			return true
+		}
+		if tree := a.gitignoreTree(dirConfig.Path); tree != nil && tree.Ignored(path, isDir) {
+			return true
+		}
+		break
+	}
+
 	for _, regex := range a.IgnoreRegexes {
 		if regex.MatchString(path) {
 			return true
@@ -193,11 +657,51 @@ func (a *App) shouldIgnorePath(path string) bool {
 	return false
 }
 
+// pathIgnored checks if the file at path, or one of its directories below
+// rootDir, should be ignored
+func (a *App) pathIgnored(rootDir, path string) bool {
+	if a.shouldIgnorePath(path, false) {
+		return true
+	}
+	rootDir = filepath.Clean(rootDir)
+	for dir := filepath.Dir(path); dir != rootDir && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if a.shouldIgnorePath(dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadGitignore reads the .gitignore files that apply to a directory, so
+// every scan sees their current content
+func (a *App) loadGitignore(rootDir string) {
+	tree, err := gitignore.LoadTree(rootDir)
+	if err != nil {
+		slog.Warn("Failed to read .gitignore files", "path", rootDir, "error", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.gitignores == nil {
+		a.gitignores = make(map[string]*gitignore.Tree)
+	}
+	a.gitignores[rootDir] = tree
+}
+
+// gitignoreTree returns the .gitignore files of a directory, or nil if it
+// does not respect them
+func (a *App) gitignoreTree(rootDir string) *gitignore.Tree {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.gitignores[rootDir]
+}
+
 // GroupDocumentsByDirectory groups documents by their source directory
 func (a *App) GroupDocumentsByDirectory() []DirectoryGroup {
 	groupMap := make(map[string][]Document)
 
-	for _, doc := range a.Documents {
+	for _, doc := range a.GetDocuments() {
 		groupMap[doc.SourceName] = append(groupMap[doc.SourceName], doc)
 	}
 
@@ -212,15 +716,91 @@ func (a *App) GroupDocumentsByDirectory() []DirectoryGroup {
 	return groups
 }
 
-// SetupRoutes sets up HTTP routes
+// SetupRoutes sets up HTTP routes. Calling it again replaces the routes of
+// the running server.
 func (a *App) SetupRoutes() {
+	mux := http.NewServeMux()
+
 	// API routes
-	http.HandleFunc("/api/index", a.handleAPIIndex)
-	http.HandleFunc("/api/doc/", a.handleAPIDocument)
-	http.HandleFunc("/api/search", a.handleSearch)
+	mux.HandleFunc("/api/index", a.handleAPIIndex)
+	mux.HandleFunc("/api/index/stats", a.handleIndexStats)
+	mux.HandleFunc("/api/doc/", a.handleAPIDocument)
+	mux.HandleFunc("/raw/", a.handleRaw)
+	mux.HandleFunc("/assets/", a.handleAsset)
+	mux.HandleFunc("/api/documents", a.handleDocuments)
+	mux.HandleFunc("/api/documents/", a.handleDocuments)
+	mux.Handle("/api/search", a.rateLimit(http.HandlerFunc(a.handleSearch)))
+	mux.Handle("/api/semantic-search", a.rateLimit(http.HandlerFunc(a.handleSemanticSearch)))
+	mux.Handle("/api/retrieve", a.rateLimit(http.HandlerFunc(a.handleRetrieve)))
+	mux.Handle("/api/ask", a.rateLimit(http.HandlerFunc(a.handleAsk)))
+	mux.HandleFunc("/api/tags", a.handleTags)
+	mux.HandleFunc("/api/collections", a.handleCollections)
+	mux.HandleFunc("/api/analytics/click", a.handleAnalyticsClick)
+	mux.HandleFunc("/api/analytics/report", a.handleAnalyticsReport)
+	mux.HandleFunc("/api/v1/graph", a.handleGraph)
+	mux.HandleFunc("/api/link-report", a.handleLinkReport)
+	mux.HandleFunc("/api/admin/secrets", a.handleAdminSecrets)
+	mux.HandleFunc("/api/admin/status", a.handleAdminStatus)
+	mux.HandleFunc("/api/admin/reindex", a.handleAdminReindex)
+	mux.Handle("/v1/vector_stores", a.rateLimit(http.HandlerFunc(a.handleVectorStores)))
+	mux.Handle("/v1/vector_stores/", a.rateLimit(http.HandlerFunc(a.handleVectorStores)))
+
+	// OIDC login
+	if a.oidc != nil {
+		mux.HandleFunc("/auth/login", a.handleLogin)
+		mux.HandleFunc("/auth/callback", a.handleCallback)
+		mux.HandleFunc("/auth/logout", a.handleLogout)
+	}
 
 	// Static files and SPA fallback
-	http.HandleFunc("/", a.handleSPA)
+	mux.HandleFunc("/", a.handleSPA)
+
+	a.mu.RLock()
+	middleware := slices.Clone(a.middleware)
+	a.mu.RUnlock()
+	// Requests are logged with the status the client got, and
+	// authentication comes before anything is served except CORS preflights
+	middleware = append([]Middleware{a.logRequests}, middleware...)
+	middleware = append(middleware, a.cors, a.requireAuth, compress, conditionalGet)
+
+	handler := Chain(mux, middleware...)
+	a.routes.Store(&handler)
+}
+
+// Middleware wraps an HTTP handler, e.g. to log or authenticate requests
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in middleware. The first middleware is the outermost, so it
+// sees requests first and responses last.
+func Chain(h http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// Use adds middleware around the routes, outside the built-in
+// authentication, compression and caching. It takes effect when the routes
+// are set up, by Handler, Serve or Start.
+func (a *App) Use(middleware ...Middleware) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.middleware = append(a.middleware, middleware...)
+}
+
+// Handler returns the web interface and API as an HTTP handler, to embed
+// DimanDocs into other programs or to test it with httptest. OIDC login is
+// only available through Serve and Start, which discover the provider.
+func (a *App) Handler() http.Handler {
+	if a.routes.Load() == nil {
+		a.SetupRoutes()
+	}
+	return a
+}
+
+// ServeHTTP dispatches a request to the current routes
+func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*a.routes.Load()).ServeHTTP(w, r)
 }
 
 // handleAPIIndex returns index data as JSON
@@ -230,7 +810,7 @@ func (a *App) handleAPIIndex(w http.ResponseWriter, r *http.Request) {
 	data := IndexData{
 		Title:          a.Config.Title,
 		Groups:         groups,
-		TotalDocuments: len(a.Documents),
+		TotalDocuments: len(a.GetDocuments()),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -239,37 +819,112 @@ func (a *App) handleAPIIndex(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleAPIDocument returns a single document as JSON with rendered HTML
-func (a *App) handleAPIDocument(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/doc/")
+// handleAsset serves an image of a documentation directory, as linked by the
+// documents. The path is looked up in each directory in turn, skipping those
+// that ignore it.
+func (a *App) handleAsset(w http.ResponseWriter, r *http.Request) {
+	relPath := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, "/assets/")), "/")
+	if !assetExtensions[strings.ToLower(filepath.Ext(relPath))] {
+		http.NotFound(w, r)
+		return
+	}
 
-	var doc *Document
-	for _, d := range a.Documents {
-		if d.RelPath == path {
-			doc = &d
-			break
+	a.mu.RLock()
+	directories := a.Config.Directories
+	a.mu.RUnlock()
+	for _, dirConfig := range directories {
+		assetPath := filepath.Join(dirConfig.Path, filepath.FromSlash(relPath))
+		if a.pathIgnored(dirConfig.Path, assetPath) {
+			continue
+		}
+		file, err := os.Open(assetPath)
+		if err != nil {
+			continue
+		}
+		info, err := file.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			file.Close()
+			continue
 		}
+		defer file.Close()
+		// SVG images can carry scripts, which must not run on this origin
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+		http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// renderOptions returns how documents are rendered, as configured
+func (a *App) renderOptions() render.Options {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	opts := render.Options{
+		Mermaid:        a.Config.Diagrams.MermaidScript != "none",
+		PlantUMLServer: a.Config.Diagrams.PlantUMLServer,
 	}
+	if a.Config.CodeTheme != "none" {
+		opts.CodeTheme = a.Config.CodeTheme
+	}
+	return opts
+}
+
+// handleAPIDocument returns a single document as JSON with rendered HTML
+func (a *App) handleAPIDocument(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/doc/")
 
+	doc := a.findDocument(path)
 	if doc == nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	html := blackfriday.Run([]byte(doc.Content))
+	opts := a.renderOptions()
+	opts.RewriteLink = a.linkRewriter(*doc)
+	html, toc := render.MarkdownWithTOC(documentMarkdown(*doc), opts)
+	if toc == nil {
+		toc = []render.Heading{}
+	}
+
+	backlinks := []DocumentRef{}
+	for _, d := range a.GetBacklinks(doc.RelPath) {
+		backlinks = append(backlinks, DocumentRef{Title: d.Title, RelPath: d.RelPath})
+	}
 
 	data := struct {
-		Title    string `json:"Title"`
-		AppTitle string `json:"AppTitle"`
-		DirName  string `json:"DirName"`
-		AbsPath  string `json:"AbsPath"`
-		Content  string `json:"Content"`
+		Title     string           `json:"Title"`
+		AppTitle  string           `json:"AppTitle"`
+		DirName   string           `json:"DirName"`
+		AbsPath   string           `json:"AbsPath"`
+		Content   string           `json:"Content"`
+		TOC       []render.Heading `json:"TOC"`
+		Backlinks []DocumentRef    `json:"Backlinks"`
+		Secrets   int              `json:"Secrets,omitempty"` // Unredacted potential secrets (flag mode)
+		Mermaid   string           `json:"Mermaid,omitempty"` // Script rendering the mermaid diagrams in Content
+
+		Description string         `json:"Description,omitempty"`
+		Tags        []string       `json:"Tags,omitempty"`
+		Metadata    map[string]any `json:"Metadata,omitempty"`
 	}{
-		Title:    doc.Title,
-		AppTitle: a.Config.Title,
-		DirName:  doc.DirName,
-		AbsPath:  doc.AbsPath,
-		Content:  string(html),
+		Title:     doc.Title,
+		AppTitle:  a.Config.Title,
+		DirName:   doc.DirName,
+		AbsPath:   doc.AbsPath,
+		Content:   string(html),
+		TOC:       toc,
+		Backlinks: backlinks,
+
+		Description: doc.Description,
+		Tags:        doc.Tags,
+		Metadata:    doc.Metadata,
+	}
+	if a.SecretScanner != nil && a.SecretScanner.Mode() == secrets.ModeFlag {
+		data.Secrets = len(doc.Secrets)
+	}
+	if render.HasMermaid(html) {
+		a.mu.RLock()
+		data.Mermaid = a.Config.Diagrams.MermaidScript
+		a.mu.RUnlock()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -278,72 +933,460 @@ func (a *App) handleAPIDocument(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// SearchResult represents a search result with optional score
-type SearchResultJSON struct {
-	Document
-	Score       float32 `json:"Score,omitempty"`
-	ChunkText   string  `json:"ChunkText,omitempty"`
-	SectionTitle string `json:"SectionTitle,omitempty"`
-	IsVectorSearch bool `json:"IsVectorSearch"`
-}
-
-// handleSearch handles search API requests
-func (a *App) handleSearch(w http.ResponseWriter, r *http.Request) {
-	query := strings.TrimSpace(r.URL.Query().Get("q"))
-
-	if query == "" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode([]SearchResultJSON{})
+// documentSubresources are the routes below /api/documents/{relpath}
+var documentSubresources = []string{"toc", "backlinks", "history", "diff"}
+
+// handleDocuments serves the documents API: /api/documents returns the
+// grouped document list, /api/documents/{relpath} a single document with its
+// raw content, /api/documents/{relpath}/toc its table of contents,
+// /api/documents/{relpath}/backlinks the links to it, and
+// /api/documents/{relpath}/history and /diff its changes in git. POST
+// /api/documents uploads a document, see handleUpload; other DELETE and POST
+// requests change documents, see handleDocumentChange.
+func (a *App) handleDocuments(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/documents"), "/")
+	if r.Method == http.MethodPost && path == "" {
+		a.handleUpload(w, r)
+		return
+	}
+	if r.Method == http.MethodDelete || r.Method == http.MethodPost {
+		a.handleDocumentChange(w, r, path)
 		return
 	}
 
-	// Try vector search first if embedding manager is available
-	if a.EmbeddingManager != nil && a.EmbeddingManager.IsEnabled() {
-		results, err := a.vectorSearch(query)
-		if err != nil {
-			log.Printf("Vector search failed, falling back to text search: %v", err)
-		} else {
-			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(results); err != nil {
-				http.Error(w, fmt.Sprintf("Failed to encode results: %v", err), http.StatusInternalServerError)
+	// A subresource may follow the document path, unless a document has the
+	// whole path
+	subresource := ""
+	if path != "" && a.findDocument(path) == nil {
+		for _, name := range documentSubresources {
+			if docPath, ok := strings.CutSuffix(path, "/"+name); ok {
+				path, subresource = docPath, name
+				break
 			}
-			return
 		}
 	}
 
-	// Fallback to text search
-	results := a.textSearch(query)
+	var data any
+	if path == "" {
+		data = DocumentsData{
+			Groups:         a.GroupDocumentsByDirectory(),
+			TotalDocuments: len(a.GetDocuments()),
+		}
+	} else {
+		doc := a.findDocument(path)
+		if doc == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch subresource {
+		case "toc":
+			_, toc := render.MarkdownWithTOC(documentMarkdown(*doc), render.Options{})
+			if toc == nil {
+				toc = []render.Heading{}
+			}
+			data = toc
+		case "backlinks":
+			data = a.GetBacklinkDetails(doc.RelPath)
+		case "history":
+			limit := defaultHistoryLimit
+			if value := r.URL.Query().Get("limit"); value != "" {
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 1 {
+					http.Error(w, fmt.Sprintf("Invalid limit %q", value), http.StatusBadRequest)
+					return
+				}
+				limit = min(n, maxHistoryLimit)
+			}
+			history, err := a.DocumentHistory(r.Context(), *doc, limit)
+			if err != nil {
+				historyError(w, err)
+				return
+			}
+			data = history
+		case "diff":
+			diff, err := a.DocumentDiff(r.Context(), *doc, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+			if err != nil {
+				historyError(w, err)
+				return
+			}
+			data = diff
+		default:
+			backlinks := []DocumentRef{}
+			for _, d := range a.GetBacklinks(doc.RelPath) {
+				backlinks = append(backlinks, DocumentRef{Title: d.Title, RelPath: d.RelPath})
+			}
+			docData := DocumentJSON{
+				Document:  *doc,
+				Content:   doc.Content(),
+				Backlinks: backlinks,
+			}
+			if a.SecretScanner != nil && a.SecretScanner.Mode() == secrets.ModeFlag {
+				docData.Secrets = len(doc.Secrets)
+			}
+			data = docData
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(results); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to encode results: %v", err), http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
 	}
 }
 
-// vectorSearch performs semantic search using embeddings
-func (a *App) vectorSearch(query string) ([]SearchResultJSON, error) {
-	ctx := context.Background()
-	results, err := a.EmbeddingManager.Search(ctx, query, 20)
-	if err != nil {
-		return nil, err
+// handleDocumentChange serves DELETE /api/documents/{relpath}, which removes
+// a document and its embeddings, and POST /api/documents/{relpath}/reindex,
+// which reloads and re-embeds it
+func (a *App) handleDocumentChange(w http.ResponseWriter, r *http.Request, path string) {
+	if !a.requireAuthConfigured(w) {
+		return
 	}
 
-	var searchResults []SearchResultJSON
-	seenDocs := make(map[string]bool)
-
-	for _, r := range results {
-		// Find the full document
-		var doc *Document
-		for i := range a.Documents {
-			if a.Documents[i].RelPath == r.Document.Path {
-				doc = &a.Documents[i]
-				break
-			}
+	reindex := false
+	if r.Method == http.MethodPost {
+		// A document may have the whole path
+		docPath, ok := strings.CutSuffix(path, "/reindex")
+		if !ok || a.findDocument(path) != nil {
+			w.Header().Set("Allow", "GET, DELETE")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
+		path, reindex = docPath, true
+	}
 
-		if doc == nil {
-			continue
+	doc := a.findDocument(path)
+	if doc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !reindex {
+		a.DeleteDocument(r.Context(), *doc)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	stats, err := a.ReindexDocument(r.Context(), *doc)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reindex document: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// handleRaw serves the source file of a document. With the download
+// parameter set, browsers save it as a file instead of showing it.
+func (a *App) handleRaw(w http.ResponseWriter, r *http.Request) {
+	doc := a.findDocument(strings.TrimPrefix(r.URL.Path, "/raw/"))
+	if doc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The file is served as written, with its frontmatter, rather than the
+	// content pages are rendered from
+	source, err := os.ReadFile(doc.Path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to read document: %v", err), http.StatusInternalServerError)
+		return
+	}
+	text := string(source)
+	if a.SecretScanner != nil {
+		text, _ = a.SecretScanner.Process(text)
+	}
+
+	// Source files of code directories and HTML pages are served as plain
+	// text, so pages are not run as HTML from this origin
+	contentType := "text/markdown; charset=utf-8"
+	if doc.Language != "" || isHTMLFile(doc.Path) {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", contentETag(text))
+	setLastModified(w, *doc)
+	if download, _ := strconv.ParseBool(r.URL.Query().Get("download")); download {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(doc.Path)}))
+	}
+	io.WriteString(w, text)
+}
+
+// handleGraph returns the intra-corpus link graph as JSON
+func (a *App) handleGraph(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.GraphData()); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// handleLinkReport returns the broken links and anchors between documents
+func (a *App) handleLinkReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.CheckLinks()); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// IndexStatsJSON describes the semantic search index
+type IndexStatsJSON struct {
+	Provider     string     `json:"Provider"`
+	Model        string     `json:"Model"`
+	VectorStore  string     `json:"VectorStore"`
+	Metric       string     `json:"Metric"`
+	Quantization string     `json:"Quantization"` // Precision of the stored vectors
+	Documents    int        `json:"Documents"`
+	Pending      int        `json:"Pending"` // Documents being indexed or whose indexing was interrupted
+	Failed       int        `json:"Failed"`  // Documents that could not be indexed, see dimandocs db status
+	Chunks       int        `json:"Chunks"`
+	Dimension    int        `json:"Dimension"`
+	SizeBytes    int64      `json:"SizeBytes"`             // Database size on disk
+	LastIndexed  *time.Time `json:"LastIndexed,omitempty"` // Omitted if nothing was indexed yet
+
+	Directories   []string         `json:"Directories,omitempty"`   // Directories stored in the database, for those of SourceIndexes
+	SourceIndexes []IndexStatsJSON `json:"SourceIndexes,omitempty"` // Databases of directories with their own embeddings
+}
+
+// handleIndexStats reports the size and configuration of the semantic
+// search index
+func (a *App) handleIndexStats(w http.ResponseWriter, r *http.Request) {
+	if a.EmbeddingManager == nil || !a.EmbeddingManager.IsEnabled() {
+		http.Error(w, "Index statistics require embeddings to be enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	data, err := a.indexStats()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get index statistics: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// indexStats describes the semantic search index, which requires
+// embeddings to be enabled
+func (a *App) indexStats() (IndexStatsJSON, error) {
+	a.mu.RLock()
+	cfg := a.Config.Embeddings
+	a.mu.RUnlock()
+	data, err := storeStats(cfg, a.EmbeddingManager.GetVectorStore())
+	if err != nil {
+		return IndexStatsJSON{}, err
+	}
+
+	for _, index := range a.EmbeddingManager.SourceIndexes() {
+		source, err := storeStats(index.Config, index.Store)
+		if err != nil {
+			return IndexStatsJSON{}, fmt.Errorf("failed to get statistics of %s: %w", strings.Join(index.Directories, ", "), err)
 		}
+		source.Directories = index.Directories
+		data.SourceIndexes = append(data.SourceIndexes, source)
+	}
+	return data, nil
+}
+
+// storeStats describes the vector store of an embeddings config
+func storeStats(cfg EmbeddingsConfig, store vector.Store) (IndexStatsJSON, error) {
+	stats, err := store.Stats()
+	if err != nil {
+		return IndexStatsJSON{}, err
+	}
+
+	vectorStore := cfg.VectorStore.Type
+	quantization := cfg.Quantization
+	if vectorStore != "sqlite" {
+		quantization = vector.QuantizationFloat
+	}
+
+	data := IndexStatsJSON{
+		Provider:     cfg.Provider,
+		Model:        cfg.Model,
+		VectorStore:  vectorStore,
+		Metric:       cfg.Metric,
+		Quantization: quantization,
+		Documents:    stats.Documents,
+		Pending:      stats.Pending,
+		Failed:       stats.Failed,
+		Chunks:       stats.Chunks,
+		Dimension:    stats.Dimension,
+		SizeBytes:    stats.SizeBytes,
+	}
+	if !stats.LastIndexed.IsZero() {
+		data.LastIndexed = &stats.LastIndexed
+	}
+	return data, nil
+}
+
+// SearchResult represents a search result with optional score
+type SearchResultJSON struct {
+	Document
+	Score          float32 `json:"Score,omitempty"`
+	FusionScore    float32 `json:"FusionScore,omitempty"` // Normalized RRF score of hybrid search
+	ChunkText      string  `json:"ChunkText,omitempty"`
+	SectionTitle   string  `json:"SectionTitle,omitempty"`
+	Breadcrumb     string  `json:"Breadcrumb,omitempty"` // Heading hierarchy of the matching section
+	IsVectorSearch bool    `json:"IsVectorSearch"`
+	URL            string  `json:"URL"` // Deep link to the matching section
+	CorrectedQuery string  `json:"CorrectedQuery,omitempty"`
+	Snippet        string  `json:"Snippet,omitempty"` // HTML excerpt around the matches, highlighted with <mark>
+}
+
+const (
+	// defaultSearchLimit is the number of search results returned without a
+	// limit
+	defaultSearchLimit = 20
+
+	// maxSearchLimit caps the limit of search requests
+	maxSearchLimit = 100
+
+	// maxSearchOffset is the largest offset of search requests, since vector
+	// search fetches every result up to the requested page
+	maxSearchOffset = 1000
+)
+
+// handleSearch handles search API requests. Results are paged with the
+// limit and offset parameters; the X-Total-Count header holds the number of
+// results before paging, which for vector search are only those fetched for
+// the page and the pages before it.
+func (a *App) handleSearch(w http.ResponseWriter, r *http.Request) {
+	// Several q parameters run a multi-query search with fused results
+	var queries []string
+	for _, q := range r.URL.Query()["q"] {
+		if q = strings.TrimSpace(q); q != "" {
+			queries = append(queries, q)
+		}
+	}
+
+	// Tag parameters restrict results to documents having all of the tags,
+	// collection parameters to documents in any of the collections
+	tags := requestTags(r)
+	collections := requestCollections(r)
+
+	limit, offset := defaultSearchLimit, 0
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = min(n, maxSearchLimit)
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && n > 0 {
+		if n > maxSearchOffset {
+			http.Error(w, fmt.Sprintf("Invalid offset %d: the maximum is %d", n, maxSearchOffset), http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	if len(queries) == 0 {
+		// Without a query, list the documents having the tags and in the
+		// collections
+		results := []SearchResultJSON{}
+		if len(tags) > 0 || len(collections) > 0 {
+			for _, doc := range a.GetDocuments() {
+				if hasTags(doc, tags) && inCollections(doc, collections) {
+					results = append(results, SearchResultJSON{Document: doc, URL: render.DocumentURL(doc.RelPath, ""), Snippet: highlightSnippet(doc.Content(), nil)})
+				}
+			}
+		}
+		writeSearchResults(w, results, limit, offset)
+		return
+	}
+	query := strings.Join(queries, " | ")
+
+	// Search mode: "hybrid" fuses vector and keyword search, "vector" is
+	// pure similarity search and "text" skips embeddings
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = a.searchMode()
+	}
+
+	// Try vector search first if embedding manager is available
+	if mode != "text" && a.EmbeddingManager != nil && a.EmbeddingManager.IsEnabled() {
+		filter := vector.Filter{Tags: tags, Collections: collections}
+		results, err := a.vectorSearch(queries, filter, mode == "hybrid", offset+limit)
+		if err != nil {
+			slog.Warn("Vector search failed, falling back to text search", "error", err)
+		} else {
+			a.recordSearch(query, mode, len(results))
+			writeSearchResults(w, results, limit, offset)
+			return
+		}
+	}
+
+	// Fallback to text search, merging results of every query
+	results := []SearchResultJSON{}
+	seenDocs := make(map[string]bool)
+	for _, q := range queries {
+		for _, result := range a.textSearch(q) {
+			if !seenDocs[result.RelPath] {
+				seenDocs[result.RelPath] = true
+				results = append(results, result)
+			}
+		}
+	}
+	results = filterByCollections(filterByTags(results, tags), collections)
+
+	// Rank by the number of matches and highlight them
+	for i := range results {
+		terms := queryTerms(queries...)
+		if results[i].CorrectedQuery != "" {
+			terms = append(terms, strings.Fields(results[i].CorrectedQuery)...)
+		}
+		re := termsRegex(terms)
+		results[i].Score = matchScore(results[i].Document, re)
+		results[i].Snippet = highlightSnippet(results[i].Content(), re)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	a.recordSearch(query, "text", len(results))
+
+	writeSearchResults(w, results, limit, offset)
+}
+
+// writeSearchResults writes a page of search results as JSON
+func writeSearchResults(w http.ResponseWriter, results []SearchResultJSON, limit, offset int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(results)))
+	page := results[min(offset, len(results)):min(offset+limit, len(results))]
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode results: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// vectorSearch performs semantic search using embeddings. Multiple queries
+// are searched concurrently and fused with reciprocal rank fusion; in hybrid
+// mode BM25 keyword results are fused in as well. Only the chunks matching
+// filter are searched. Enough chunks are fetched for at least the given
+// number of documents in most cases.
+func (a *App) vectorSearch(queries []string, filter vector.Filter, hybrid bool, documents int) ([]SearchResultJSON, error) {
+	ctx := context.Background()
+
+	// Several chunks often come from the same document
+	depth := max(defaultSearchLimit, 2*documents)
+
+	var results []vector.SearchResult
+	var err error
+	if hybrid {
+		results, err = a.EmbeddingManager.HybridSearch(ctx, queries, filter, depth)
+	} else {
+		results, err = a.EmbeddingManager.MultiSearch(ctx, queries, filter, depth)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	searchResults := []SearchResultJSON{}
+	seenDocs := make(map[string]bool)
+	re := termsRegex(queryTerms(queries...))
+
+	for _, match := range a.resultDocuments(results) {
+		r, doc := match.Result, match.Doc
 
 		// Deduplicate by document path, keeping highest score
 		if seenDocs[doc.RelPath] {
@@ -352,30 +1395,236 @@ func (a *App) vectorSearch(query string) ([]SearchResultJSON, error) {
 		seenDocs[doc.RelPath] = true
 
 		searchResults = append(searchResults, SearchResultJSON{
-			Document:      *doc,
-			Score:         r.Score,
-			ChunkText:     r.Chunk.ChunkText,
-			SectionTitle:  r.Chunk.SectionTitle,
+			Document:       *doc,
+			Score:          r.Score,
+			FusionScore:    r.FusionScore,
+			ChunkText:      r.Chunk.ChunkText,
+			SectionTitle:   r.Chunk.SectionTitle,
+			Breadcrumb:     r.Chunk.Breadcrumb,
 			IsVectorSearch: true,
+			URL:            resultURL(r),
+			Snippet:        highlightSnippet(r.Chunk.ChunkText, re),
 		})
 	}
 
 	return searchResults, nil
 }
 
-// textSearch performs traditional text-based search
+// resultDocument is a vector search result with the document it belongs to
+type resultDocument struct {
+	Result vector.SearchResult
+	Doc    *Document
+}
+
+// resultDocuments pairs vector search results with the loaded documents they
+// belong to, skipping the chunks of documents that are no longer loaded
+func (a *App) resultDocuments(results []vector.SearchResult) []resultDocument {
+	documents := a.GetDocuments()
+	byPath := make(map[string]*Document, len(documents))
+	for i := range documents {
+		byPath[documents[i].RelPath] = &documents[i]
+	}
+
+	var matches []resultDocument
+	for _, result := range results {
+		if doc, ok := byPath[result.Document.Path]; ok {
+			matches = append(matches, resultDocument{Result: result, Doc: doc})
+		}
+	}
+	return matches
+}
+
+const (
+	// defaultSemanticSearchLimit is the number of chunks returned by the
+	// semantic search API without a limit
+	defaultSemanticSearchLimit = 10
+
+	// maxSemanticSearchLimit caps the limit of the semantic search API
+	maxSemanticSearchLimit = 50
+)
+
+// SemanticSearchResult represents a chunk found by the semantic search API
+type SemanticSearchResult struct {
+	Score        float32  `json:"Score"`
+	FusionScore  float32  `json:"FusionScore,omitempty"` // Normalized RRF score of hybrid search
+	ChunkText    string   `json:"ChunkText"`
+	SectionTitle string   `json:"SectionTitle,omitempty"`
+	Breadcrumb   string   `json:"Breadcrumb,omitempty"` // Heading hierarchy of the matching section
+	URL          string   `json:"URL"`                  // Deep link to the matching section
+	Document     Document `json:"Document"`
+}
+
+// handleSemanticSearch searches the embedded chunks and returns every
+// matching chunk with its score and document. Unlike /api/search, results are
+// not deduplicated by document and there is no text search fallback.
+func (a *App) handleSemanticSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "Missing query parameter q", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSemanticSearchLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("Invalid limit %q", value), http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxSemanticSearchLimit)
+	}
+
+	if a.EmbeddingManager == nil || !a.EmbeddingManager.IsEnabled() {
+		http.Error(w, "Semantic search requires embeddings to be enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	filter := vector.Filter{
+		Source:       r.URL.Query().Get("source"),
+		Collections:  requestCollections(r),
+		PathPrefix:   r.URL.Query().Get("path_prefix"),
+		Tags:         requestTags(r),
+		SectionTitle: r.URL.Query().Get("section"),
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = a.searchMode()
+	}
+	results, err := a.searchChunks(r.Context(), query, mode, filter, limit)
+	switch {
+	case errors.Is(err, errInvalidMode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, vector.ErrFullTextUnavailable):
+		http.Error(w, "Keyword search is unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Query   string                 `json:"Query"`
+		Mode    string                 `json:"Mode"`
+		Results []SemanticSearchResult `json:"Results"`
+	}{Query: query, Mode: mode, Results: []SemanticSearchResult{}}
+	for _, match := range a.resultDocuments(results) {
+		result, doc := match.Result, match.Doc
+		data.Results = append(data.Results, SemanticSearchResult{
+			Score:        result.Score,
+			FusionScore:  result.FusionScore,
+			ChunkText:    result.Chunk.ChunkText,
+			SectionTitle: result.Chunk.SectionTitle,
+			Breadcrumb:   result.Chunk.Breadcrumb,
+			URL:          resultURL(result),
+			Document:     *doc,
+		})
+	}
+	a.recordSearch(query, mode, len(data.Results))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode results: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// searchMode returns the default search mode of the embeddings section
+func (a *App) searchMode() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.Config.Embeddings.SearchMode
+}
+
+// searchQueries searches the embedded chunks for several queries in the
+// default search mode and fuses the results
+func (a *App) searchQueries(ctx context.Context, queries []string, filter vector.Filter, limit int) ([]vector.SearchResult, error) {
+	if a.searchMode() == "hybrid" {
+		return a.EmbeddingManager.HybridSearch(ctx, queries, filter, limit)
+	}
+	return a.EmbeddingManager.MultiSearch(ctx, queries, filter, limit)
+}
+
+// errInvalidMode is returned by searchChunks for an unknown search mode
+var errInvalidMode = errors.New("invalid mode")
+
+// searchChunks searches the embedded chunks in mode: "hybrid" fuses vector
+// and keyword search, "vector" is pure similarity search and "keyword" is
+// BM25 only
+func (a *App) searchChunks(ctx context.Context, query, mode string, filter vector.Filter, limit int) ([]vector.SearchResult, error) {
+	switch mode {
+	case "hybrid":
+		return a.EmbeddingManager.HybridSearch(ctx, []string{query}, filter, limit)
+	case "vector":
+		return a.EmbeddingManager.MultiSearch(ctx, []string{query}, filter, limit)
+	case "keyword":
+		return a.EmbeddingManager.KeywordSearch(ctx, query, filter, limit)
+	default:
+		return nil, fmt.Errorf("%w %q: expected hybrid, vector or keyword", errInvalidMode, mode)
+	}
+}
+
+// resultURL returns the deep link for a vector search result. Summary chunks
+// describe the whole document and link to its top.
+func resultURL(r vector.SearchResult) string {
+	if r.Chunk.ChunkIndex == vector.SummaryChunkIndex {
+		return render.DocumentURL(r.Document.Path, "")
+	}
+	return render.DocumentURL(r.Document.Path, r.Chunk.SectionTitle)
+}
+
+// textSearch performs traditional text-based search. If the exact phrase is
+// not found, misspelled terms are corrected against the corpus vocabulary and
+// documents containing every (corrected) term are returned.
 func (a *App) textSearch(query string) []SearchResultJSON {
-	queryLower := strings.ToLower(query)
+	results := a.matchDocuments([]string{strings.ToLower(query)})
+
+	a.mu.RLock()
+	speller := a.Speller
+	a.mu.RUnlock()
+	if len(results) > 0 || speller == nil {
+		return results
+	}
+
+	corrected, changed := speller.CorrectQuery(query)
+	results = a.matchDocuments(strings.Fields(corrected))
+	if changed {
+		for i := range results {
+			results[i].CorrectedQuery = corrected
+		}
+	}
+
+	return results
+}
+
+// matchDocuments returns documents whose title, content or overview contain
+// every one of the given lowercase terms
+func (a *App) matchDocuments(terms []string) []SearchResultJSON {
 	var results []SearchResultJSON
+	if len(terms) == 0 {
+		return results
+	}
+
+	for _, doc := range a.GetDocuments() {
+		title := strings.ToLower(doc.Title)
+		content := strings.ToLower(doc.Content())
+		overview := strings.ToLower(doc.Overview)
+
+		matched := true
+		for _, term := range terms {
+			// Search in title, content, and overview (case-insensitive)
+			if !strings.Contains(title, term) &&
+				!strings.Contains(content, term) &&
+				!strings.Contains(overview, term) {
+				matched = false
+				break
+			}
+		}
 
-	for _, doc := range a.Documents {
-		// Search in title, content, and overview (case-insensitive)
-		if strings.Contains(strings.ToLower(doc.Title), queryLower) ||
-			strings.Contains(strings.ToLower(doc.Content), queryLower) ||
-			strings.Contains(strings.ToLower(doc.Overview), queryLower) {
+		if matched {
 			results = append(results, SearchResultJSON{
-				Document:      doc,
+				Document:       doc,
 				IsVectorSearch: false,
+				URL:            render.DocumentURL(doc.RelPath, ""),
 			})
 		}
 	}
@@ -383,10 +1632,152 @@ func (a *App) textSearch(query string) []SearchResultJSON {
 	return results
 }
 
+// recordSearch logs a search query to analytics if enabled
+func (a *App) recordSearch(query, mode string, results int) {
+	if a.Analytics == nil {
+		return
+	}
+	if err := a.Analytics.RecordSearch(query, mode, results); err != nil {
+		slog.Error("Failed to record search analytics", "error", err)
+	}
+}
+
+// handleAnalyticsClick records that a search result was opened
+func (a *App) handleAnalyticsClick(w http.ResponseWriter, r *http.Request) {
+	if a.Analytics == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var click struct {
+		Query string `json:"query"`
+		Path  string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&click); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.Analytics.RecordClick(click.Query, click.Path); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to record click: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAnalyticsReport returns aggregated search analytics as JSON
+func (a *App) handleAnalyticsReport(w http.ResponseWriter, r *http.Request) {
+	if a.Analytics == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	opts := analytics.ReportOptions{}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil {
+		opts.Limit = limit
+	}
+	if days, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && days > 0 {
+		opts.Since = time.Now().UTC().AddDate(0, 0, -days)
+	}
+
+	report, err := a.Analytics.Report(opts)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to build report: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// SecretReport lists the potential secrets found in a document
+type SecretReport struct {
+	RelPath    string            `json:"path"`
+	Title      string            `json:"title"`
+	SourceName string            `json:"source"`
+	Findings   []secrets.Finding `json:"findings"`
+}
+
+// handleAdminSecrets returns the secret scanning findings for all documents
+func (a *App) handleAdminSecrets(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAuthConfigured(w) {
+		return
+	}
+	if a.SecretScanner == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	reports := []SecretReport{}
+	total := 0
+	for _, doc := range a.GetDocuments() {
+		if len(doc.Secrets) == 0 {
+			continue
+		}
+		reports = append(reports, SecretReport{
+			RelPath:    doc.RelPath,
+			Title:      doc.Title,
+			SourceName: doc.SourceName,
+			Findings:   doc.Secrets,
+		})
+		total += len(doc.Secrets)
+	}
+
+	data := struct {
+		Mode          string         `json:"mode"`
+		TotalFindings int            `json:"total_findings"`
+		Documents     []SecretReport `json:"documents"`
+	}{
+		Mode:          a.SecretScanner.Mode(),
+		TotalFindings: total,
+		Documents:     reports,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// overlayFS serves the files of top, and those of base that top lacks
+type overlayFS struct {
+	top  fs.FS
+	base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if file, err := o.top.Open(name); err == nil {
+		return file, nil
+	}
+	return o.base.Open(name)
+}
+
+// frontend returns the files of the web interface: the embedded build,
+// with the files in templates_dir taking precedence
+func (a *App) frontend() (fs.FS, error) {
+	distFS, err := fs.Sub(frontendFS, "frontend/dist")
+	if err != nil {
+		return nil, err
+	}
+	a.mu.RLock()
+	templatesDir := a.Config.TemplatesDir
+	a.mu.RUnlock()
+	if templatesDir == "" {
+		return distFS, nil
+	}
+	return overlayFS{top: os.DirFS(templatesDir), base: distFS}, nil
+}
+
 // handleSPA serves the frontend SPA
 func (a *App) handleSPA(w http.ResponseWriter, r *http.Request) {
-	// Get the sub-filesystem for frontend/dist
-	distFS, err := fs.Sub(frontendFS, "frontend/dist")
+	distFS, err := a.frontend()
 	if err != nil {
 		http.Error(w, "Failed to access frontend files", http.StatusInternalServerError)
 		return
@@ -428,17 +1819,82 @@ func (a *App) handleSPA(w http.ResponseWriter, r *http.Request) {
 	w.Write(content)
 }
 
-// Start starts the HTTP server
-func (a *App) Start() error {
+// shutdownTimeout is how long in-flight requests may take to complete when
+// the server shuts down
+const shutdownTimeout = 10 * time.Second
+
+// Start serves the web interface on the configured port until ctx is
+// cancelled, see Serve
+func (a *App) Start(ctx context.Context) error {
 	port := a.Config.Port
 	if port == "" {
 		port = "8080"
 	}
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %s: %w", port, err)
+	}
 
+	slog.Info("Starting server", "port", port)
+	return a.Serve(ctx, listener)
+}
+
+// Serve serves the web interface on listener until ctx is cancelled.
+// In-flight requests are then given shutdownTimeout to complete. Tests can
+// pass a listener on a random port.
+func (a *App) Serve(ctx context.Context, listener net.Listener) error {
+	if err := a.setupOIDC(ctx); err != nil {
+		listener.Close()
+		return err
+	}
 	a.SetupRoutes()
 
-	fmt.Printf("Starting server on port %s\n", port)
-	fmt.Printf("Found %d documents\n", len(a.Documents))
+	slog.Info("Found documents", "documents", len(a.GetDocuments()))
+
+	server := &http.Server{Handler: a}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- a.serve(server, listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	return nil
+}
 
-	return http.ListenAndServe(":"+port, nil)
+// serve serves HTTP or, when configured, HTTPS on listener
+func (a *App) serve(server *http.Server, listener net.Listener) error {
+	tlsCfg := a.Config.TLS
+	switch {
+	case tlsCfg.Autocert != nil:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(tlsCfg.Autocert.Domains...),
+			Cache:      autocert.DirCache(tlsCfg.Autocert.CacheDir),
+			Email:      tlsCfg.Autocert.Email,
+		}
+		server.TLSConfig = manager.TLSConfig()
+		if httpPort := tlsCfg.Autocert.HTTPPort; httpPort != "" {
+			go func() {
+				if err := http.ListenAndServe(":"+httpPort, manager.HTTPHandler(nil)); err != nil {
+					slog.Warn("ACME HTTP listener failed", "port", httpPort, "error", err)
+				}
+			}()
+		}
+		slog.Info("Serving HTTPS with Let's Encrypt certificates", "domains", tlsCfg.Autocert.Domains)
+		return server.ServeTLS(listener, "", "")
+	case tlsCfg.CertFile != "":
+		slog.Info("Serving HTTPS", "certificate", tlsCfg.CertFile)
+		return server.ServeTLS(listener, tlsCfg.CertFile, tlsCfg.KeyFile)
+	}
+	return server.Serve(listener)
 }
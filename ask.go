@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"dimandocs/frontmatter"
+	"dimandocs/llm"
+	"dimandocs/vector"
+)
+
+const (
+	// defaultAskLimit is the number of chunks an answer is written from
+	defaultAskLimit = 6
+
+	// maxAskLimit caps the chunks an answer is written from
+	maxAskLimit = 20
+
+	// maxAskHistory caps the earlier turns of a conversation sent to the
+	// chat model
+	maxAskHistory = 10
+)
+
+// noAnswer is the answer to questions no documentation was found for
+const noAnswer = "No relevant documentation was found for the question."
+
+// citationPattern matches the citations of an answer, such as [2] or [1, 3]
+var citationPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// AskRequest is the body of POST /api/ask
+type AskRequest struct {
+	Question    string    `json:"question"`
+	Limit       int       `json:"limit"` // Chunks to answer from
+	Source      string    `json:"source"`
+	Collections []string  `json:"collections"`
+	PathPrefix  string    `json:"path_prefix"`
+	Tags        []string  `json:"tags"`
+	Stream      bool      `json:"stream"`  // Stream the answer as server-sent events
+	History     []AskTurn `json:"history"` // Earlier turns of the conversation, oldest first
+}
+
+// AskTurn is an earlier question or answer of a conversation
+type AskTurn struct {
+	Role    string `json:"role"` // user or assistant
+	Content string `json:"content"`
+}
+
+// AskSource is a chunk an answer was written from
+type AskSource struct {
+	Number       int     `json:"Number"` // Cited as [Number] in the answer
+	Title        string  `json:"Title"`
+	RelPath      string  `json:"RelPath"`
+	SourceName   string  `json:"SourceName"`
+	SectionTitle string  `json:"SectionTitle,omitempty"`
+	Breadcrumb   string  `json:"Breadcrumb,omitempty"`
+	Score        float32 `json:"Score"`
+	URL          string  `json:"URL"`
+	Cited        bool    `json:"Cited"` // The answer cites the source
+}
+
+// AskResponse is the answer to a question with the chunks it was written
+// from
+type AskResponse struct {
+	Question string      `json:"Question"`
+	Answer   string      `json:"Answer"`
+	Sources  []AskSource `json:"Sources"`
+}
+
+// handleAsk serves POST /api/ask, which answers a question with the chat
+// model from the chunks best matching it. With stream set, or an Accept
+// header of text/event-stream, the answer is streamed as server-sent events:
+// sources once retrieved, delta for each piece of the answer, and done with
+// the whole response, or error.
+func (a *App) handleAsk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.EmbeddingManager == nil || !a.EmbeddingManager.IsEnabled() {
+		http.Error(w, "Answering questions requires embeddings to be enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req AskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		http.Error(w, "Missing question", http.StatusBadRequest)
+		return
+	}
+	limit := defaultAskLimit
+	if req.Limit != 0 {
+		if req.Limit < 1 {
+			http.Error(w, fmt.Sprintf("Invalid limit %d", req.Limit), http.StatusBadRequest)
+			return
+		}
+		limit = min(req.Limit, maxAskLimit)
+	}
+	history, err := askHistory(req.History)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.mu.RLock()
+	llmConfig := a.Config.LLM
+	a.mu.RUnlock()
+	chatModel, err := NewLLMClient(llmConfig)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Answering questions requires a chat model in the llm section: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	filter := vector.Filter{
+		Source:      req.Source,
+		Collections: req.Collections,
+		PathPrefix:  req.PathPrefix,
+		Tags:        frontmatter.NormalizeTags(req.Tags),
+	}
+	results, err := a.searchQueries(r.Context(), askQueries(question, history), filter, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	response := AskResponse{Question: question, Sources: []AskSource{}}
+	var excerpts strings.Builder
+	for _, match := range a.resultDocuments(results) {
+		result, doc := match.Result, match.Doc
+		source := AskSource{
+			Number:       len(response.Sources) + 1,
+			Title:        doc.Title,
+			RelPath:      doc.RelPath,
+			SourceName:   doc.SourceName,
+			SectionTitle: result.Chunk.SectionTitle,
+			Breadcrumb:   result.Chunk.Breadcrumb,
+			Score:        result.Score,
+			URL:          resultURL(result),
+		}
+		response.Sources = append(response.Sources, source)
+		writeExcerpt(&excerpts, source, result.Chunk.ChunkText)
+	}
+	a.recordSearch(question, "ask", len(response.Sources))
+
+	messages := []llm.Message{{Role: llm.RoleSystem, Content: llm.AnswerSystemPrompt}}
+	messages = append(messages, history...)
+	messages = append(messages, llm.Message{
+		Role:    llm.RoleUser,
+		Content: fmt.Sprintf("Question: %s\n\n# Documentation excerpts\n\n%s", question, excerpts.String()),
+	})
+	if req.Stream || acceptsEventStream(r) {
+		a.streamAnswer(w, r, chatModel, messages, response)
+		return
+	}
+
+	response.Answer = noAnswer
+	if len(response.Sources) > 0 {
+		answer, err := chatModel.Complete(r.Context(), messages)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to generate answer: %v", err), http.StatusBadGateway)
+			return
+		}
+		response.Answer = strings.TrimSpace(answer)
+		markCited(response.Sources, response.Answer)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Warn("Failed to encode response", "error", err)
+	}
+}
+
+// streamAnswer streams the answer to a question as server-sent events
+func (a *App) streamAnswer(w http.ResponseWriter, r *http.Request, chatModel llm.Client, messages []llm.Message, response AskResponse) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from buffering the events
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	send := func(event string, data any) error {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	if err := send("sources", response.Sources); err != nil {
+		return
+	}
+	if len(response.Sources) == 0 {
+		response.Answer = noAnswer
+		send("delta", map[string]string{"Text": noAnswer})
+		send("done", response)
+		return
+	}
+
+	answer, err := llm.Stream(r.Context(), chatModel, messages, func(delta string) error {
+		return send("delta", map[string]string{"Text": delta})
+	})
+	if err != nil {
+		if r.Context().Err() == nil {
+			slog.Warn("Failed to generate answer", "error", err)
+			send("error", map[string]string{"Error": fmt.Sprintf("Failed to generate answer: %v", err)})
+		}
+		return
+	}
+	response.Answer = strings.TrimSpace(answer)
+	markCited(response.Sources, response.Answer)
+	send("done", response)
+}
+
+// askHistory validates the earlier turns of a conversation and keeps the
+// latest of them
+func askHistory(turns []AskTurn) ([]llm.Message, error) {
+	var history []llm.Message
+	for i, turn := range turns {
+		if turn.Role != llm.RoleUser && turn.Role != llm.RoleAssistant {
+			return nil, fmt.Errorf("invalid role %q of history turn %d", turn.Role, i+1)
+		}
+		content := strings.TrimSpace(turn.Content)
+		if content == "" {
+			continue
+		}
+		history = append(history, llm.Message{Role: turn.Role, Content: content})
+	}
+	if len(history) > maxAskHistory {
+		history = history[len(history)-maxAskHistory:]
+	}
+	return history, nil
+}
+
+// askQueries returns the searches a question is answered from. A follow-up
+// question is also searched together with the previous question, so that
+// "and how do I configure it?" finds what "it" refers to.
+func askQueries(question string, history []llm.Message) []string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == llm.RoleUser {
+			return []string{question, history[i].Content + "\n" + question}
+		}
+	}
+	return []string{question}
+}
+
+// writeExcerpt adds a retrieved chunk to the excerpts a question is answered
+// from, numbered for citations
+func writeExcerpt(excerpts *strings.Builder, source AskSource, text string) {
+	fmt.Fprintf(excerpts, "[%d] %s", source.Number, source.Title)
+	if source.Breadcrumb != "" {
+		fmt.Fprintf(excerpts, " (%s)", source.Breadcrumb)
+	} else if source.SectionTitle != "" {
+		fmt.Fprintf(excerpts, " (%s)", source.SectionTitle)
+	}
+	fmt.Fprintf(excerpts, "\nPath: %s\n\n%s\n\n", source.RelPath, strings.TrimSpace(text))
+}
+
+// markCited marks the sources an answer cites by number
+func markCited(sources []AskSource, answer string) {
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		for _, number := range strings.Split(match[1], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(number))
+			if err == nil && n >= 1 && n <= len(sources) {
+				sources[n-1].Cited = true
+			}
+		}
+	}
+}
+
+// acceptsEventStream reports whether a request asks for server-sent events
+func acceptsEventStream(r *http.Request) bool {
+	for _, value := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(value)); err == nil && mediaType == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"dimandocs/oidc"
+	"dimandocs/tokenauth"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cookies of OIDC logins
+const (
+	sessionCookie = "dimandocs_session" // Signed identity of the signed-in user
+	loginCookie   = "dimandocs_login"   // Signed state between the redirect to the provider and the callback
+
+	// loginTimeout is how long users have to sign in at the provider
+	loginTimeout = 10 * time.Minute
+)
+
+// loginState binds a callback from the provider to the login that started it
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier
+	Next     string `json:"next"`     // Page to return to after the login
+}
+
+// requireAuth rejects requests without valid credentials when auth is
+// configured: a bearer token, sent as "Authorization: Bearer <token>" or in
+// the X-API-Key header, or the basic auth user and password
+func (a *App) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.RLock()
+		auth := a.Config.Auth
+		a.mu.RUnlock()
+
+		if !auth.Enabled() || authorized(auth, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if auth.OIDC != nil && a.oidc != nil {
+			if strings.HasPrefix(r.URL.Path, "/auth/") || a.hasSession(r, auth.OIDC) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Pages send the browser to the login, API clients get a 401
+			if r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/raw/") {
+				http.Redirect(w, r, "/auth/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+				return
+			}
+		}
+
+		// Browsers prompt for basic auth credentials
+		if auth.Username != "" {
+			w.Header().Add("WWW-Authenticate", `Basic realm="dimandocs", charset="UTF-8"`)
+		}
+		if len(auth.Tokens) > 0 {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="dimandocs"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// authorized reports whether a request carries valid credentials
+func authorized(auth AuthConfig, r *http.Request) bool {
+	if user, password, ok := r.BasicAuth(); ok && auth.Username != "" {
+		// Check both every time, so timing does not reveal which was wrong
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(auth.Username)) == 1
+		passwordOK := bcrypt.CompareHashAndPassword([]byte(auth.PasswordHash), []byte(password)) == nil
+		return userOK && passwordOK
+	}
+
+	return tokenauth.Valid(tokenauth.FromRequest(r), auth.Tokens)
+}
+
+// setupOIDC discovers the OIDC provider, when auth.oidc is configured
+func (a *App) setupOIDC(ctx context.Context) error {
+	cfg := a.oidcConfig()
+	if cfg == nil {
+		return nil
+	}
+	provider, err := oidc.Discover(ctx, oidc.Config{
+		Issuer:       cfg.Issuer,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       cfg.Scopes,
+		GroupsClaim:  cfg.GroupsClaim,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up OIDC login: %w", err)
+	}
+
+	key := cfg.SessionSecret
+	if key == "" {
+		slog.Warn("auth.oidc has no session_secret, users need to sign in again after a restart")
+		key = oidc.RandomString()
+	}
+	a.oidc = provider
+	a.sessions = oidc.NewSigner([]byte(key))
+	return nil
+}
+
+// oidcConfig returns the current auth.oidc section, which config reloads may
+// replace, or nil
+func (a *App) oidcConfig() *OIDCConfig {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.Config.Auth.OIDC
+}
+
+// hasSession reports whether a request carries the session cookie of a user
+// who may read the documentation
+func (a *App) hasSession(r *http.Request, cfg *OIDCConfig) bool {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+	var identity oidc.Identity
+	if err := a.sessions.Decode(cookie.Value, &identity); err != nil || identity.Subject == "" {
+		return false
+	}
+	return groupAllowed(identity.Groups, cfg.AllowedGroups)
+}
+
+// groupAllowed reports whether a user with the given groups has access
+func groupAllowed(groups, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, group := range groups {
+		if slices.Contains(allowed, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleLogin sends the browser to the provider's login page
+func (a *App) handleLogin(w http.ResponseWriter, r *http.Request) {
+	state := loginState{
+		State:    oidc.RandomString(),
+		Nonce:    oidc.RandomString(),
+		Verifier: oidc.RandomString(),
+		Next:     localRedirect(r.URL.Query().Get("next")),
+	}
+	value, err := a.sessions.Encode(state, loginTimeout)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start login: %v", err), http.StatusInternalServerError)
+		return
+	}
+	a.setCookie(w, loginCookie, value, loginTimeout)
+	http.Redirect(w, r, a.oidc.AuthCodeURL(state.State, state.Nonce, state.Verifier), http.StatusFound)
+}
+
+// handleCallback completes a login: the code from the provider is redeemed
+// for the user's identity, which is kept in the session cookie
+func (a *App) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		http.Error(w, fmt.Sprintf("Login failed: %s %s", errCode, query.Get("error_description")), http.StatusUnauthorized)
+		return
+	}
+
+	var state loginState
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil || a.sessions.Decode(cookie.Value, &state) != nil {
+		http.Error(w, "Login expired, please try again", http.StatusBadRequest)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		http.Error(w, "Login state does not match, please try again", http.StatusBadRequest)
+		return
+	}
+	a.setCookie(w, loginCookie, "", -1)
+
+	identity, err := a.oidc.Exchange(r.Context(), query.Get("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		slog.Warn("OIDC login failed", "error", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	cfg := a.oidcConfig()
+	if cfg == nil {
+		http.Error(w, "OIDC login is not configured", http.StatusNotFound)
+		return
+	}
+	if !groupAllowed(identity.Groups, cfg.AllowedGroups) {
+		slog.Warn("Denied access: not in any of the allowed groups", "subject", identity.Subject)
+		http.Error(w, "You are not in a group that may read this documentation", http.StatusForbidden)
+		return
+	}
+	// Only the groups that grant access are kept, so the cookie stays small
+	if len(cfg.AllowedGroups) > 0 {
+		identity.Groups = slices.DeleteFunc(identity.Groups, func(g string) bool {
+			return !slices.Contains(cfg.AllowedGroups, g)
+		})
+	} else {
+		identity.Groups = nil
+	}
+
+	duration, _ := time.ParseDuration(cfg.SessionDuration)
+	value, err := a.sessions.Encode(identity, duration)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create session: %v", err), http.StatusInternalServerError)
+		return
+	}
+	a.setCookie(w, sessionCookie, value, duration)
+	http.Redirect(w, r, state.Next, http.StatusFound)
+}
+
+// handleLogout ends the session
+func (a *App) handleLogout(w http.ResponseWriter, r *http.Request) {
+	a.setCookie(w, sessionCookie, "", -1)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// setCookie sets an HTTP-only cookie; a negative maxAge deletes it
+func (a *App) setCookie(w http.ResponseWriter, name, value string, maxAge time.Duration) {
+	cfg := a.oidcConfig()
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg != nil && strings.HasPrefix(cfg.RedirectURL, "https://"),
+		// Lax lets the cookies through on the redirect back from the provider
+		SameSite: http.SameSiteLaxMode,
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// localRedirect returns next if it is a path on this server, or "/", so the
+// login cannot be abused to redirect to other sites
+func localRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dimandocs/vector"
+)
+
+// defaultBackupKeep is the default number of scheduled backups kept
+const defaultBackupKeep = 7
+
+// backupTimeFormat timestamps backup file names, so that they sort by age
+const backupTimeFormat = "20060102-150405"
+
+// BackupScheduler backs up the vector databases periodically and deletes the
+// oldest backups beyond the configured number
+type BackupScheduler struct {
+	targets  []backupTarget
+	interval time.Duration
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// backupTarget is a vector database backed up by the scheduler
+type backupTarget struct {
+	store vector.BackupStore
+	cfg   EmbeddingsConfig
+}
+
+// NewBackupScheduler creates a backup scheduler for the vector store of the
+// embedding manager and the SQLite databases of directories with their own
+// embeddings, or returns nil when scheduled backups are not configured
+func NewBackupScheduler(embedManager *EmbeddingManager, cfg EmbeddingsConfig) *BackupScheduler {
+	if embedManager == nil || cfg.Backup.Interval == "" {
+		return nil
+	}
+	store, ok := embedManager.GetVectorStore().(vector.BackupStore)
+	if !ok {
+		slog.Warn("Scheduled backups disabled, the vector store does not support backups")
+		return nil
+	}
+	interval, err := time.ParseDuration(cfg.Backup.Interval)
+	if err != nil || interval <= 0 {
+		return nil
+	}
+
+	targets := []backupTarget{{store, cfg}}
+	for _, index := range embedManager.SourceIndexes() {
+		if store, ok := index.Store.(vector.BackupStore); ok {
+			targets = append(targets, backupTarget{store, index.Config})
+		}
+	}
+	return &BackupScheduler{targets: targets, interval: interval, done: make(chan struct{})}
+}
+
+// Start backs up the database on every interval in the background until
+// Close is called
+func (b *BackupScheduler) Start() {
+	slog.Info("Backing up vector database periodically", "interval", b.interval, "dir", b.targets[0].cfg.Backup.Dir, "databases", len(b.targets))
+	b.wg.Add(1)
+	go b.run()
+}
+
+// Close stops backing up and waits for a running backup to finish
+func (b *BackupScheduler) Close() error {
+	close(b.done)
+	b.wg.Wait()
+	return nil
+}
+
+// run backs up the database on every tick
+func (b *BackupScheduler) run() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			for _, target := range b.targets {
+				path, err := backupVectorStore(target.store, target.cfg, time.Now())
+				if err != nil {
+					slog.Warn("Failed to back up vector database", "db_path", target.cfg.DBPath, "error", err)
+					continue
+				}
+				slog.Info("Backed up vector database", "path", path)
+				if err := pruneBackups(target.cfg); err != nil {
+					slog.Warn("Failed to delete old backups", "error", err)
+				}
+			}
+		}
+	}
+}
+
+// backupVectorStore writes a timestamped backup of the store to the backup
+// directory and returns its path
+func backupVectorStore(store vector.BackupStore, cfg EmbeddingsConfig, now time.Time) (string, error) {
+	if err := os.MkdirAll(cfg.Backup.Dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	prefix, ext := backupNameParts(cfg.DBPath)
+	path := filepath.Join(cfg.Backup.Dir, prefix+now.Format(backupTimeFormat)+ext)
+	if err := store.Backup(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// pruneBackups deletes the oldest backups in the backup directory beyond the
+// number to keep
+func pruneBackups(cfg EmbeddingsConfig) error {
+	prefix, ext := backupNameParts(cfg.DBPath)
+	matches, err := filepath.Glob(filepath.Join(cfg.Backup.Dir, prefix+"*"+ext))
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), prefix), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, match)
+		}
+	}
+
+	sort.Strings(backups)
+	for len(backups) > cfg.Backup.Keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// backupNameParts returns the file name prefix and extension of backups of
+// the database at dbPath, e.g. "embeddings-" and ".db"
+func backupNameParts(dbPath string) (prefix, ext string) {
+	base := filepath.Base(dbPath)
+	ext = filepath.Ext(base)
+	if ext == "" {
+		ext = ".db"
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base)) + "-", ext
+}
@@ -0,0 +1,250 @@
+package chunking
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// languageExtensions maps source file extensions to language names, which
+// double as code fence info strings
+var languageExtensions = map[string]string{
+	".go":    "go",
+	".py":    "python",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".mjs":   "javascript",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+	".java":  "java",
+	".kt":    "kotlin",
+	".scala": "scala",
+	".swift": "swift",
+	".cs":    "csharp",
+	".rs":    "rust",
+	".c":     "c",
+	".h":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".hpp":   "cpp",
+	".rb":    "ruby",
+	".php":   "php",
+	".sh":    "bash",
+}
+
+// Symbol patterns capture the indentation of a declaration in the "indent"
+// group, its name in the "name" group and, for Go methods, the receiver type
+// in the "recv" group
+var (
+	cFamilyTypes = `^(?P<indent>\s*)(?:(?:public|private|protected|internal|static|abstract|final|sealed|partial|open|data|export|default|declare)\s+)*(?:class|interface|enum|struct|record|object|trait|union)\s+(?P<name>\w+)`
+	cFamilyFuncs = `^(?P<indent>\s*)(?:[\w<>\[\],.?*&:~@]+\s+)+\**(?P<name>[\w~]+)\s*\([^;]*$`
+
+	symbolPatterns = map[string][]*regexp.Regexp{
+		"go": compile(
+			`^(?P<indent>)func\s+(?:\(\s*(?:\w+\s+)?\*?(?P<recv>\w+)(?:\[[^\]]*\])?\s*\)\s*)?(?P<name>\w+)`,
+			`^(?P<indent>)type\s+(?P<name>\w+)`,
+		),
+		"python": compile(
+			`^(?P<indent>\s*)(?:async\s+)?def\s+(?P<name>\w+)`,
+			`^(?P<indent>\s*)class\s+(?P<name>\w+)`,
+		),
+		"javascript": compile(jsPatterns...),
+		"typescript": compile(append(jsPatterns,
+			`^(?P<indent>\s*)(?:export\s+)?(?:declare\s+)?(?:interface|enum|namespace)\s+(?P<name>\w+)`,
+			`^(?P<indent>\s*)(?:export\s+)?type\s+(?P<name>\w+)(?:<[^>]*>)?\s*=`,
+		)...),
+		"java":   compile(cFamilyTypes, cFamilyFuncs),
+		"csharp": compile(cFamilyTypes, cFamilyFuncs),
+		"kotlin": compile(cFamilyTypes, `^(?P<indent>\s*)(?:\w+\s+)*fun\s+(?:<[^>]*>\s*)?(?:[\w.]+\.)?(?P<name>\w+)`),
+		"scala":  compile(cFamilyTypes, `^(?P<indent>\s*)(?:\w+\s+)*def\s+(?P<name>\w+)`),
+		"swift":  compile(cFamilyTypes, `^(?P<indent>\s*)(?:[@\w]+\s+)*func\s+(?P<name>\w+)`),
+		"rust": compile(
+			`^(?P<indent>\s*)(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"[^"]*"\s+)?fn\s+(?P<name>\w+)`,
+			`^(?P<indent>\s*)(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|mod|union)\s+(?P<name>\w+)`,
+			`^(?P<indent>\s*)impl(?:<[^>]*>)?\s+(?:[\w:<>]+\s+for\s+)?(?P<name>\w+)`,
+		),
+		"c":   compile(`^(?P<indent>\s*)(?:typedef\s+)?(?:struct|enum|union)\s+(?P<name>\w+)[^;]*$`, cFamilyFuncs),
+		"cpp": compile(cFamilyTypes+`[^;]*$`, `^(?P<indent>\s*)namespace\s+(?P<name>\w+)`, cFamilyFuncs),
+		"ruby": compile(
+			`^(?P<indent>\s*)def\s+(?:self\.)?(?P<name>\w+[?!=]?)`,
+			`^(?P<indent>\s*)(?:class|module)\s+(?P<name>[\w:]+)`,
+		),
+		"php": compile(
+			`^(?P<indent>\s*)(?:(?:public|private|protected|static|abstract|final)\s+)*function\s+(?P<name>\w+)`,
+			`^(?P<indent>\s*)(?:(?:abstract|final|readonly)\s+)*(?:class|interface|trait|enum)\s+(?P<name>\w+)`,
+		),
+		"bash": compile(`^(?P<indent>\s*)(?:function\s+)?(?P<name>[\w-]+)\s*\(\)`),
+	}
+)
+
+var jsPatterns = []string{
+	`^(?P<indent>\s*)(?:export\s+)?(?:default\s+)?(?:async\s+)?function\*?\s*(?P<name>\w+)`,
+	`^(?P<indent>\s*)(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(?P<name>\w+)`,
+	`^(?P<indent>\s*)(?:export\s+)?(?:const|let|var)\s+(?P<name>\w+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|\w+\s*=>)`,
+	`^(?P<indent>\s+)(?:(?:public|private|protected|static|async|readonly|override|get|set)\s+)*(?P<name>\w+)\s*(?:<[^>]*>)?\([^)]*\)\s*(?::\s*[^{]+)?\{\s*$`,
+}
+
+// controlKeywords start statements that look like declarations to the C
+// family patterns, such as "return compute(" or "} else if ("
+var controlKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true,
+	"return": true, "else": true, "do": true, "try": true, "new": true,
+	"throw": true, "sizeof": true, "foreach": true, "using": true, "lock": true,
+	"case": true, "await": true, "yield": true, "delete": true, "goto": true,
+}
+
+// compile compiles symbol patterns
+func compile(patterns ...string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		compiled[i] = regexp.MustCompile(pattern)
+	}
+	return compiled
+}
+
+// Language returns the language of a source file by its extension, or ""
+// if the code chunker does not support it
+func Language(path string) string {
+	return languageExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// SourceFilePattern returns a file name pattern matching the source files
+// supported by the code chunker
+func SourceFilePattern() string {
+	var extensions []string
+	for ext := range languageExtensions {
+		extensions = append(extensions, regexp.QuoteMeta(strings.TrimPrefix(ext, ".")))
+	}
+	sort.Strings(extensions)
+	return `(?i)\.(` + strings.Join(extensions, "|") + `)$`
+}
+
+// symbol is a declaration starting a code section
+type symbol struct {
+	line   int
+	indent int
+	name   string
+}
+
+// ChunkCode splits source code into chunks on function, method and type
+// boundaries. Each chunk's section title is the qualified symbol name and its
+// breadcrumb the enclosing symbols. Code before the first symbol, such as
+// imports, forms its own section. Symbols larger than the chunk size are
+// split on blank lines like markdown sections.
+func ChunkCode(source, language string, opts Options) []Chunk {
+	if opts.MaxChunkSize <= 0 {
+		opts.MaxChunkSize = DefaultMaxChunkSize
+	}
+	if opts.OverlapSize < 0 {
+		opts.OverlapSize = DefaultOverlapSize
+	}
+	if opts.MaxTokens > 0 && opts.Tokenizer == nil {
+		opts.Tokenizer = defaultTokenizer()
+	}
+	// Code is split on symbols, not sentences or prose
+	opts.Strategy = StrategyMarkdown
+	opts.CodeContext = false
+
+	lines := strings.Split(source, "\n")
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + len(line) + 1
+	}
+
+	symbols := findSymbols(lines, language)
+
+	var chunks []Chunk
+	addSection := func(start, end int, title, crumb string) {
+		text := strings.TrimSpace(strings.Join(lines[start:end], "\n"))
+		// Named symbols are kept however small, anonymous code only if substantial
+		if text == "" || (title == "" && len(text) < MinChunkSize) {
+			return
+		}
+		chunks = append(chunks, splitLargeSection(text, title, crumb, opts, len(chunks), offsets[start])...)
+	}
+
+	first := len(lines)
+	if len(symbols) > 0 {
+		first = symbols[0].line
+	}
+	addSection(0, first, "", "")
+
+	var stack []heading
+	for i, sym := range symbols {
+		end := len(lines)
+		if i+1 < len(symbols) {
+			end = symbols[i+1].line
+		}
+
+		stack = pushHeading(stack, heading{level: sym.indent + 1, title: sym.name})
+		names := make([]string, len(stack))
+		for j, h := range stack {
+			names[j] = h.title
+		}
+		addSection(sym.line, end, strings.Join(names, "."), breadcrumb(stack))
+	}
+
+	return chunks
+}
+
+// findSymbols returns the declarations of source lines in order. A symbol
+// starts at the comments, decorators and annotations directly above it.
+func findSymbols(lines []string, language string) []symbol {
+	patterns := symbolPatterns[language]
+	var symbols []symbol
+
+	for i, line := range lines {
+		for _, re := range patterns {
+			m := re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			name := m[re.SubexpIndex("name")]
+			if controlKeywords[name] || controlKeywords[strings.Fields(line)[0]] {
+				continue
+			}
+			if recv := re.SubexpIndex("recv"); recv != -1 && m[recv] != "" {
+				name = m[recv] + "." + name
+			}
+
+			start := i
+			floor := 0
+			if len(symbols) > 0 {
+				floor = symbols[len(symbols)-1].line + 1
+			}
+			for start > floor && isLeadingComment(lines[start-1], language) {
+				start--
+			}
+
+			symbols = append(symbols, symbol{
+				line:   start,
+				indent: len(m[re.SubexpIndex("indent")]),
+				name:   name,
+			})
+			break
+		}
+	}
+
+	return symbols
+}
+
+// isLeadingComment reports whether a line is a comment, decorator or
+// annotation belonging to the declaration below it
+func isLeadingComment(line, language string) bool {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "":
+		return false
+	case strings.HasPrefix(trimmed, "//"), strings.HasPrefix(trimmed, "/*"),
+		strings.HasPrefix(trimmed, "*"), strings.HasPrefix(trimmed, "@"):
+		return true
+	case strings.HasPrefix(trimmed, "#"):
+		// In C and C++ these are preprocessor directives, in Rust attributes
+		switch language {
+		case "python", "ruby", "bash", "php", "rust":
+			return true
+		}
+	}
+	return false
+}
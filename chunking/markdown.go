@@ -1,6 +1,7 @@
 package chunking
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 	"unicode/utf8"
@@ -20,14 +21,26 @@ type Chunk struct {
 	Index        int
 	Text         string
 	SectionTitle string
+	Breadcrumb   string // Heading hierarchy, e.g. "Install > Linux > Debian"
 	StartOffset  int
 	EndOffset    int
 }
 
-// Options configures the chunking behavior
+// Options configures the chunking behavior. Sizes are measured in characters
+// unless MaxTokens is set, in which case chunks are measured in model tokens.
 type Options struct {
 	MaxChunkSize int
 	OverlapSize  int
+
+	MaxTokens     int       // Maximum chunk size in tokens, enables token mode
+	OverlapTokens int       // Overlap between chunks in tokens
+	Tokenizer     Tokenizer // Default: cl100k_base
+
+	CodeContext bool // Keep the paragraph preceding a code block in the same chunk
+
+	Strategy             string           // StrategyMarkdown (default) or StrategySemantic
+	Embedder             SentenceEmbedder // Required by StrategySemantic
+	BreakpointPercentile float64          // Default: 95
 }
 
 // DefaultOptions returns default chunking options
@@ -38,9 +51,30 @@ func DefaultOptions() Options {
 	}
 }
 
+// Fingerprint returns a string identifying the options that affect how text
+// is chunked, so that chunks can be redone when they change
+func (o Options) Fingerprint() string {
+	tokenizer := ""
+	if o.Tokenizer != nil {
+		tokenizer = fmt.Sprintf("%T", o.Tokenizer)
+		if enc, ok := o.Tokenizer.(interface{ Encoding() string }); ok {
+			tokenizer += ":" + enc.Encoding()
+		}
+	}
+	return fmt.Sprintf("size=%d,%d tokens=%d,%d,%s code_context=%t strategy=%s breakpoint=%g",
+		o.MaxChunkSize, o.OverlapSize, o.MaxTokens, o.OverlapTokens, tokenizer,
+		o.CodeContext, o.Strategy, o.BreakpointPercentile)
+}
+
 // headerRegex matches markdown headers
 var headerRegex = regexp.MustCompile(`^(#{1,6})\s+(.+)$`)
 
+// BreadcrumbSeparator separates headings in a chunk breadcrumb
+const BreadcrumbSeparator = " > "
+
+// fenceRegex matches the opening or closing line of a fenced code block
+var fenceRegex = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+
 // ChunkMarkdown splits markdown content into chunks based on headers and size limits
 func ChunkMarkdown(content string, opts Options) []Chunk {
 	if opts.MaxChunkSize <= 0 {
@@ -49,11 +83,15 @@ func ChunkMarkdown(content string, opts Options) []Chunk {
 	if opts.OverlapSize < 0 {
 		opts.OverlapSize = DefaultOverlapSize
 	}
+	if opts.MaxTokens > 0 && opts.Tokenizer == nil {
+		opts.Tokenizer = defaultTokenizer()
+	}
 
 	lines := strings.Split(content, "\n")
 	var chunks []Chunk
 	var currentSection strings.Builder
 	var currentTitle string
+	var headings []heading
 	var sectionStart int
 	chunkIndex := 0
 
@@ -64,22 +102,31 @@ func ChunkMarkdown(content string, opts Options) []Chunk {
 		}
 
 		// Split large sections into smaller chunks
-		sectionChunks := splitLargeSection(text, currentTitle, opts, chunkIndex, sectionStart)
+		sectionChunks := splitLargeSection(text, currentTitle, breadcrumb(headings), opts, chunkIndex, sectionStart)
 		chunks = append(chunks, sectionChunks...)
 		chunkIndex += len(sectionChunks)
 	}
 
 	offset := 0
+	fence := ""
 	for _, line := range lines {
 		lineLen := len(line) + 1 // +1 for newline
 
-		if matches := headerRegex.FindStringSubmatch(line); matches != nil {
+		// Lines inside code blocks, such as shell comments, are never headers
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+		} else if marker := openingFence(line); marker != "" {
+			fence = marker
+		} else if matches := headerRegex.FindStringSubmatch(line); matches != nil {
 			// Found a header, flush current section
 			flushSection(offset)
 
 			// Start new section
 			currentSection.Reset()
 			currentTitle = matches[2]
+			headings = pushHeading(headings, heading{level: len(matches[1]), title: matches[2]})
 			sectionStart = offset
 		}
 
@@ -93,20 +140,138 @@ func ChunkMarkdown(content string, opts Options) []Chunk {
 
 	// If no chunks were created (no headers), chunk the entire content
 	if len(chunks) == 0 && len(strings.TrimSpace(content)) >= MinChunkSize {
-		chunks = splitLargeSection(content, "", opts, 0, 0)
+		chunks = splitLargeSection(content, "", "", opts, 0, 0)
 	}
 
 	return chunks
 }
 
+// Section is a heading of a markdown document and the content below it,
+// including its subsections
+type Section struct {
+	Level      int
+	Title      string
+	Breadcrumb string // Heading hierarchy, e.g. "Install > Linux > Debian"
+	Start      int    // Byte offset of the heading line
+	End        int    // Byte offset after the last line of the section
+}
+
+// Sections returns the sections of markdown content in document order,
+// using the same headings as ChunkMarkdown
+func Sections(content string) []Section {
+	var sections []Section
+	var headings []heading
+	offset := 0
+	fence := ""
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if fence != "" {
+			if closesFence(trimmed, fence) {
+				fence = ""
+			}
+		} else if marker := openingFence(trimmed); marker != "" {
+			fence = marker
+		} else if matches := headerRegex.FindStringSubmatch(trimmed); matches != nil {
+			level := len(matches[1])
+			// A heading ends the open sections at the same or a deeper level
+			for i := range sections {
+				if sections[i].End == -1 && sections[i].Level >= level {
+					sections[i].End = offset
+				}
+			}
+			headings = pushHeading(headings, heading{level: level, title: matches[2]})
+			sections = append(sections, Section{
+				Level:      level,
+				Title:      matches[2],
+				Breadcrumb: breadcrumb(headings),
+				Start:      offset,
+				End:        -1,
+			})
+		}
+		offset += len(line)
+	}
+	for i := range sections {
+		if sections[i].End == -1 {
+			sections[i].End = len(content)
+		}
+	}
+	return sections
+}
+
+// sizer measures chunk sizes in characters or, with a tokenizer, in tokens
+type sizer struct {
+	limit   int
+	overlap int
+	tok     Tokenizer
+}
+
+// newSizer returns the sizer for the chunking options
+func newSizer(opts Options) sizer {
+	if opts.MaxTokens > 0 && opts.Tokenizer != nil {
+		return sizer{limit: opts.MaxTokens, overlap: opts.OverlapTokens, tok: opts.Tokenizer}
+	}
+	return sizer{limit: opts.MaxChunkSize, overlap: opts.OverlapSize}
+}
+
+// size returns the size of text in the sizer's unit
+func (z sizer) size(text string) int {
+	if z.tok != nil {
+		return z.tok.Count(text)
+	}
+	return utf8.RuneCountInString(text)
+}
+
+// tail returns the overlap text carried over from the end of a chunk
+func (z sizer) tail(text string) string {
+	if z.overlap <= 0 {
+		return ""
+	}
+	// A fragment of a code block is meaningless on its own
+	if i := strings.LastIndex(text, "\n"); i != -1 && fenceRegex.MatchString(text[i+1:]) {
+		return ""
+	}
+	if z.tok != nil {
+		return z.tok.LastTokens(text, z.overlap)
+	}
+	if len(text) <= z.overlap {
+		return ""
+	}
+	return getLastNChars(text, z.overlap)
+}
+
+// fit splits paragraphs that cannot fit in a chunk. Only token mode enforces
+// the limit strictly, since it reflects a hard model input limit.
+func (z sizer) fit(paragraphs []string) []string {
+	if z.tok == nil {
+		return paragraphs
+	}
+
+	max := z.limit - z.overlap
+	if max <= 0 {
+		max = z.limit
+	}
+
+	var fitted []string
+	for _, para := range paragraphs {
+		if z.size(para) > max {
+			fitted = append(fitted, z.tok.Split(para, max)...)
+		} else {
+			fitted = append(fitted, para)
+		}
+	}
+	return fitted
+}
+
 // splitLargeSection splits a large section into smaller chunks with overlap
-func splitLargeSection(text, title string, opts Options, startIndex, startOffset int) []Chunk {
+func splitLargeSection(text, title, crumb string, opts Options, startIndex, startOffset int) []Chunk {
+	z := newSizer(opts)
 	text = strings.TrimSpace(text)
-	if len(text) <= opts.MaxChunkSize {
+	if z.size(text) <= z.limit {
 		return []Chunk{{
 			Index:        startIndex,
 			Text:         text,
 			SectionTitle: title,
+			Breadcrumb:   crumb,
 			StartOffset:  startOffset,
 			EndOffset:    startOffset + len(text),
 		}}
@@ -118,16 +283,27 @@ func splitLargeSection(text, title string, opts Options, startIndex, startOffset
 
 	// Split by paragraphs first
 	paragraphs := splitIntoParagraphs(text)
+	if opts.CodeContext {
+		paragraphs = attachCodeContext(paragraphs)
+	}
+	paragraphs = z.fit(paragraphs)
+
+	units := paragraphUnits(paragraphs)
+	if opts.Strategy == StrategySemantic && opts.Embedder != nil {
+		units = semanticUnits(paragraphs, opts)
+	}
 
 	var currentChunk strings.Builder
 	chunkStart := currentOffset
 
-	for _, para := range paragraphs {
-		paraLen := utf8.RuneCountInString(para)
-		currentLen := utf8.RuneCountInString(currentChunk.String())
+	for _, u := range units {
+		unitLen := z.size(u.text)
+		currentLen := z.size(currentChunk.String())
 
-		// If adding this paragraph would exceed max size
-		if currentLen > 0 && currentLen+paraLen+2 > opts.MaxChunkSize {
+		// Split if adding this unit would exceed max size, or on a topic change
+		overflow := currentLen > 0 && currentLen+unitLen+2 > z.limit
+		topicBreak := u.breakBefore && len(strings.TrimSpace(currentChunk.String())) >= MinChunkSize
+		if overflow || topicBreak {
 			// Save current chunk
 			chunkText := strings.TrimSpace(currentChunk.String())
 			if len(chunkText) >= MinChunkSize {
@@ -135,27 +311,34 @@ func splitLargeSection(text, title string, opts Options, startIndex, startOffset
 					Index:        chunkIndex,
 					Text:         chunkText,
 					SectionTitle: title,
+					Breadcrumb:   crumb,
 					StartOffset:  chunkStart,
 					EndOffset:    currentOffset,
 				})
 				chunkIndex++
 			}
 
-			// Start new chunk with overlap
+			// Start new chunk, with overlap unless the topic changed
 			currentChunk.Reset()
-			chunkStart = currentOffset - getOverlapText(chunkText, opts.OverlapSize)
-
-			// Add overlap from previous chunk
-			if opts.OverlapSize > 0 && len(chunkText) > opts.OverlapSize {
-				overlapText := getLastNChars(chunkText, opts.OverlapSize)
-				currentChunk.WriteString(overlapText)
-				currentChunk.WriteString("\n\n")
+			chunkStart = currentOffset
+			if !topicBreak {
+				overlapText := z.tail(chunkText)
+				chunkStart -= len(overlapText)
+
+				// Add overlap from previous chunk
+				if overlapText != "" {
+					currentChunk.WriteString(overlapText)
+					currentChunk.WriteString("\n\n")
+				}
 			}
 		}
 
-		currentChunk.WriteString(para)
-		currentChunk.WriteString("\n\n")
-		currentOffset += len(para) + 2
+		// The separator is already in place after an overlap
+		if currentChunk.Len() > 0 && !strings.HasSuffix(currentChunk.String(), "\n\n") {
+			currentChunk.WriteString(u.sep)
+		}
+		currentChunk.WriteString(u.text)
+		currentOffset += len(u.sep) + len(u.text)
 	}
 
 	// Don't forget the last chunk
@@ -165,6 +348,7 @@ func splitLargeSection(text, title string, opts Options, startIndex, startOffset
 			Index:        chunkIndex,
 			Text:         chunkText,
 			SectionTitle: title,
+			Breadcrumb:   crumb,
 			StartOffset:  chunkStart,
 			EndOffset:    currentOffset,
 		})
@@ -173,28 +357,104 @@ func splitLargeSection(text, title string, opts Options, startIndex, startOffset
 	return chunks
 }
 
-// splitIntoParagraphs splits text into paragraphs
+// heading is an entry in the stack of enclosing headings
+type heading struct {
+	level int
+	title string
+}
+
+// pushHeading replaces headings at the same or a deeper level with h
+func pushHeading(stack []heading, h heading) []heading {
+	for len(stack) > 0 && stack[len(stack)-1].level >= h.level {
+		stack = stack[:len(stack)-1]
+	}
+	return append(stack, h)
+}
+
+// breadcrumb joins the heading stack into a breadcrumb
+func breadcrumb(stack []heading) string {
+	titles := make([]string, len(stack))
+	for i, h := range stack {
+		titles[i] = h.title
+	}
+	return strings.Join(titles, BreadcrumbSeparator)
+}
+
+// splitIntoParagraphs splits text into paragraphs on blank lines. Fenced code
+// blocks are kept whole, even when they contain blank lines.
 func splitIntoParagraphs(text string) []string {
-	// Split on double newlines
-	parts := regexp.MustCompile(`\n\s*\n`).Split(text, -1)
 	var paragraphs []string
+	var current []string
 
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part != "" {
-			paragraphs = append(paragraphs, part)
+	flush := func() {
+		if para := strings.TrimSpace(strings.Join(current, "\n")); para != "" {
+			paragraphs = append(paragraphs, para)
 		}
+		current = nil
 	}
 
+	fence := ""
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case fence != "":
+			current = append(current, line)
+			if closesFence(line, fence) {
+				fence = ""
+				flush()
+			}
+		case openingFence(line) != "":
+			flush()
+			fence = openingFence(line)
+			current = append(current, line)
+		case strings.TrimSpace(line) == "":
+			flush()
+		default:
+			current = append(current, line)
+		}
+	}
+	flush()
+
 	return paragraphs
 }
 
-// getOverlapText returns the length to subtract for overlap calculation
-func getOverlapText(text string, overlapSize int) int {
-	if len(text) <= overlapSize {
-		return len(text)
+// attachCodeContext merges each code block with the paragraph introducing it,
+// so the block is never chunked apart from its explanation
+func attachCodeContext(paragraphs []string) []string {
+	var merged []string
+	for _, para := range paragraphs {
+		n := len(merged)
+		if isCodeBlock(para) && n > 0 && !isCodeBlock(merged[n-1]) && !headerRegex.MatchString(merged[n-1]) {
+			merged[n-1] += "\n\n" + para
+			continue
+		}
+		merged = append(merged, para)
+	}
+	return merged
+}
+
+// openingFence returns the fence marker if line opens a fenced code block
+func openingFence(line string) string {
+	if m := fenceRegex.FindStringSubmatch(line); m != nil {
+		// Backtick fences cannot have backticks in the info string
+		if m[1][0] == '`' && strings.Contains(line[len(m[0]):], "`") {
+			return ""
+		}
+		return m[1]
 	}
-	return overlapSize
+	return ""
+}
+
+// closesFence reports whether line closes a code block opened with fence
+func closesFence(line, fence string) bool {
+	m := fenceRegex.FindStringSubmatch(line)
+	return m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) &&
+		strings.TrimSpace(line[len(m[0]):]) == ""
+}
+
+// isCodeBlock reports whether a paragraph is a fenced code block
+func isCodeBlock(para string) bool {
+	firstLine, _, _ := strings.Cut(para, "\n")
+	return openingFence(firstLine) != ""
 }
 
 // getLastNChars returns the last n characters of a string, breaking at word boundaries
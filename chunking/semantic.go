@@ -0,0 +1,156 @@
+package chunking
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// StrategyMarkdown splits sections on paragraphs (default)
+	StrategyMarkdown = "markdown"
+	// StrategySemantic splits sections where adjacent sentences change topic
+	StrategySemantic = "semantic"
+
+	// DefaultBreakpointPercentile is the percentile of adjacent sentence
+	// distances above which a section is split
+	DefaultBreakpointPercentile = 95.0
+)
+
+// SentenceEmbedder embeds sentences for semantic chunking
+type SentenceEmbedder func(sentences []string) ([][]float32, error)
+
+// unit is a piece of text packed into chunks
+type unit struct {
+	text        string
+	sep         string // Separator written before the unit within a chunk
+	breakBefore bool   // Topic change before the unit
+}
+
+// paragraphUnits returns one unit per paragraph
+func paragraphUnits(paragraphs []string) []unit {
+	units := make([]unit, len(paragraphs))
+	for i, para := range paragraphs {
+		units[i] = unit{text: para, sep: "\n\n"}
+	}
+	return units
+}
+
+// semanticUnits splits paragraphs into sentences and marks the sentences that
+// start a new topic, measured by the cosine distance between the embeddings of
+// adjacent sentences. Falls back to paragraphs if embedding fails.
+func semanticUnits(paragraphs []string, opts Options) []unit {
+	var units []unit
+	for _, para := range paragraphs {
+		// Code blocks are never split into sentences
+		if strings.Contains(para, "```") || strings.Contains(para, "~~~") {
+			units = append(units, unit{text: para, sep: "\n\n"})
+			continue
+		}
+		for i, sentence := range splitSentences(para) {
+			sep := ""
+			if i == 0 {
+				sep = "\n\n"
+			}
+			units = append(units, unit{text: sentence, sep: sep})
+		}
+	}
+	if len(units) < 3 {
+		return paragraphUnits(paragraphs)
+	}
+
+	texts := make([]string, len(units))
+	for i, u := range units {
+		texts[i] = strings.TrimSpace(u.text)
+	}
+	embeddings, err := opts.Embedder(texts)
+	if err != nil || len(embeddings) != len(texts) {
+		return paragraphUnits(paragraphs)
+	}
+
+	distances := make([]float64, len(units)-1)
+	for i := range distances {
+		distances[i] = 1 - cosineSimilarity(embeddings[i], embeddings[i+1])
+	}
+
+	percentile := opts.BreakpointPercentile
+	if percentile <= 0 || percentile > 100 {
+		percentile = DefaultBreakpointPercentile
+	}
+	threshold := percentileOf(distances, percentile)
+	for i, d := range distances {
+		if d > threshold {
+			units[i+1].breakBefore = true
+		}
+	}
+
+	return units
+}
+
+// splitSentences splits a paragraph after sentence terminators and line
+// breaks. Pieces keep their trailing whitespace, so joining them restores
+// the paragraph.
+func splitSentences(para string) []string {
+	var sentences []string
+	start := 0
+	for i := 0; i < len(para); {
+		r, size := utf8.DecodeRuneInString(para[i:])
+		i += size
+
+		end := -1
+		switch {
+		case r == '\n':
+			end = i
+		case r == '。' || r == '！' || r == '？':
+			end = i
+		case (r == '.' || r == '!' || r == '?') && i < len(para):
+			next, _ := utf8.DecodeRuneInString(para[i:])
+			if unicode.IsSpace(next) {
+				end = i
+			}
+		}
+		if end == -1 {
+			continue
+		}
+
+		// Keep trailing whitespace with the sentence
+		for end < len(para) && (para[end] == ' ' || para[end] == '\t' || para[end] == '\n') {
+			end++
+		}
+		if strings.TrimSpace(para[start:end]) != "" {
+			sentences = append(sentences, para[start:end])
+		}
+		start = end
+		i = end
+	}
+	if strings.TrimSpace(para[start:]) != "" {
+		sentences = append(sentences, para[start:])
+	}
+	return sentences
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// percentileOf returns the p-th percentile of values
+func percentileOf(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[int(p/100*float64(len(sorted)-1))]
+}
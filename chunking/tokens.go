@@ -0,0 +1,121 @@
+package chunking
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+const (
+	// DefaultEncoding is the tiktoken encoding used when none is configured
+	DefaultEncoding = "cl100k_base"
+	// DefaultMaxTokens is a chunk size in tokens comparable to DefaultMaxChunkSize
+	DefaultMaxTokens = 400
+)
+
+// Tokenizer counts model tokens for token-based chunking
+type Tokenizer interface {
+	// Count returns the number of tokens in text
+	Count(text string) int
+
+	// Split splits text into consecutive pieces of at most n tokens each
+	Split(text string, n int) []string
+
+	// LastTokens returns approximately the last n tokens of text
+	LastTokens(text string, n int) string
+}
+
+// TiktokenTokenizer implements Tokenizer using a tiktoken BPE encoding
+type TiktokenTokenizer struct {
+	enc      *tiktoken.Tiktoken
+	encoding string
+}
+
+var loaderOnce sync.Once
+
+// NewTiktokenTokenizer creates a tokenizer for a tiktoken encoding such as
+// "cl100k_base" or "o200k_base". Encodings are bundled, so no download is needed.
+func NewTiktokenTokenizer(encoding string) (*TiktokenTokenizer, error) {
+	if encoding == "" {
+		encoding = DefaultEncoding
+	}
+
+	loaderOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+	})
+
+	enc, err := tiktoken.GetEncoding(encoding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tokenizer encoding %q: %w", encoding, err)
+	}
+	return &TiktokenTokenizer{enc: enc, encoding: encoding}, nil
+}
+
+// Encoding returns the name of the tiktoken encoding
+func (t *TiktokenTokenizer) Encoding() string {
+	return t.encoding
+}
+
+// Count returns the number of tokens in text
+func (t *TiktokenTokenizer) Count(text string) int {
+	return len(t.enc.EncodeOrdinary(text))
+}
+
+// Split splits text into consecutive pieces of at most n tokens each
+func (t *TiktokenTokenizer) Split(text string, n int) []string {
+	tokens := t.enc.EncodeOrdinary(text)
+	if n <= 0 || len(tokens) <= n {
+		return []string{text}
+	}
+
+	var pieces []string
+	for start := 0; start < len(tokens); start += n {
+		end := start + n
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		if piece := strings.TrimSpace(t.enc.Decode(tokens[start:end])); piece != "" {
+			pieces = append(pieces, piece)
+		}
+	}
+	return pieces
+}
+
+// LastTokens returns approximately the last n tokens of text, breaking at a
+// word boundary when possible
+func (t *TiktokenTokenizer) LastTokens(text string, n int) string {
+	tokens := t.enc.EncodeOrdinary(text)
+	if n <= 0 {
+		return ""
+	}
+	if len(tokens) <= n {
+		return text
+	}
+
+	// Decoding a token suffix can start mid-character, so drop a leading
+	// partial word along with any replacement characters
+	tail := t.enc.Decode(tokens[len(tokens)-n:])
+	if idx := strings.IndexAny(tail, " \n"); idx != -1 && idx < len(tail)/2 {
+		tail = tail[idx+1:]
+	}
+	return strings.TrimLeft(tail, "�")
+}
+
+var (
+	defaultTokenizerOnce sync.Once
+	defaultTokenizerInst Tokenizer
+)
+
+// defaultTokenizer returns the shared DefaultEncoding tokenizer, or nil if it
+// cannot be loaded, in which case chunking falls back to characters
+func defaultTokenizer() Tokenizer {
+	defaultTokenizerOnce.Do(func() {
+		if tok, err := NewTiktokenTokenizer(DefaultEncoding); err == nil {
+			defaultTokenizerInst = tok
+		}
+	})
+	return defaultTokenizerInst
+}
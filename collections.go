@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// defaultCollection is the collection of directories that do not name one
+const defaultCollection = "default"
+
+// CollectionInfo represents a collection with its sources and the number of
+// documents in it
+type CollectionInfo struct {
+	Name      string   `json:"Name"`
+	Sources   []string `json:"Sources"` // Names of the directories in the collection
+	Documents int      `json:"Documents"`
+}
+
+// Collections returns the collections of the configured directories, sorted
+// by name
+func (a *App) Collections() []CollectionInfo {
+	a.mu.RLock()
+	byName := make(map[string]*CollectionInfo)
+	for _, dirConfig := range a.Config.Directories {
+		info := byName[dirConfig.Collection]
+		if info == nil {
+			info = &CollectionInfo{Name: dirConfig.Collection}
+			byName[dirConfig.Collection] = info
+		}
+		info.Sources = append(info.Sources, dirConfig.Name)
+	}
+	a.mu.RUnlock()
+
+	for _, doc := range a.GetDocuments() {
+		if info := byName[doc.Collection]; info != nil {
+			info.Documents++
+		}
+	}
+
+	collections := make([]CollectionInfo, 0, len(byName))
+	for _, info := range byName {
+		collections = append(collections, *info)
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections
+}
+
+// handleCollections returns the collections as JSON
+func (a *App) handleCollections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.Collections()); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// requestCollections returns the collections of repeated or comma-separated
+// collection parameters
+func requestCollections(r *http.Request) []string {
+	var collections []string
+	for _, value := range r.URL.Query()["collection"] {
+		collections = append(collections, splitCollections(value)...)
+	}
+	return collections
+}
+
+// splitCollections returns the non-empty names of a comma-separated list of
+// collections
+func splitCollections(value string) []string {
+	var collections []string
+	for _, collection := range strings.Split(value, ",") {
+		if collection = strings.TrimSpace(collection); collection != "" {
+			collections = append(collections, collection)
+		}
+	}
+	return collections
+}
+
+// inCollections reports whether a document is in any of the collections, or
+// whether there are none to restrict to
+func inCollections(doc Document, collections []string) bool {
+	return len(collections) == 0 || slices.Contains(collections, doc.Collection)
+}
+
+// filterByCollections keeps the search results of documents in any of the
+// collections
+func filterByCollections(results []SearchResultJSON, collections []string) []SearchResultJSON {
+	if len(collections) == 0 {
+		return results
+	}
+	filtered := []SearchResultJSON{}
+	for _, result := range results {
+		if inCollections(result.Document, collections) {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
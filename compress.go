@@ -0,0 +1,155 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressMinSize is the smallest response body in bytes that is compressed.
+// Smaller bodies barely shrink and are not worth the overhead.
+const compressMinSize = 1024
+
+// compressedResponse compresses the body of a response if its status, type
+// and size are worth it, decided when the header is written
+type compressedResponse struct {
+	http.ResponseWriter
+	encoding    string
+	encoder     io.WriteCloser // Nil if the response is sent as it is
+	wroteHeader bool
+}
+
+func (c *compressedResponse) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+
+	header := c.Header()
+	switch {
+	case shouldCompress(status, header):
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", c.encoding)
+		weakenETag(header)
+		if c.encoding == "gzip" {
+			c.encoder = gzip.NewWriter(c.ResponseWriter)
+		} else {
+			c.encoder = zlib.NewWriter(c.ResponseWriter)
+		}
+	case status == http.StatusNotModified:
+		// Match the entity tag the client got with the compressed response
+		weakenETag(header)
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// weakenETag marks the entity tag of a response as weak. A compressed body
+// differs byte for byte from the original, so the tag is only weakly valid
+// for it.
+func weakenETag(header http.Header) {
+	if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		header.Set("ETag", "W/"+etag)
+	}
+}
+
+func (c *compressedResponse) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.encoder != nil {
+		return c.encoder.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the wrapped writer, so that http.ResponseController can
+// flush streamed responses, which are not compressed
+func (c *compressedResponse) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// close flushes the compressed body
+func (c *compressedResponse) close() {
+	if c.encoder != nil {
+		c.encoder.Close()
+	}
+}
+
+// shouldCompress reports whether a response with the given status and header
+// is compressed
+func shouldCompress(status int, header http.Header) bool {
+	if status != http.StatusOK || header.Get("Content-Encoding") != "" {
+		return false
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < compressMinSize {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		// Compressed events would be held back until the encoder flushes
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"),
+		strings.HasSuffix(mediaType, "xml"),
+		mediaType == "application/javascript":
+		return true
+	}
+	return false
+}
+
+// compress compresses responses with gzip or deflate, as negotiated via the
+// Accept-Encoding header of the request
+func compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		c := &compressedResponse{ResponseWriter: w, encoding: encoding}
+		defer c.close()
+		next.ServeHTTP(c, r)
+	})
+}
+
+// acceptedEncoding returns the preferred supported encoding of an
+// Accept-Encoding header, gzip on a tie, or "" if none is acceptable
+func acceptedEncoding(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		switch name {
+		case "*":
+			name = "gzip"
+		case "gzip", "deflate":
+		default:
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == "gzip") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
@@ -1,33 +1,52 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"net/http"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
+	"time"
+
+	"dimandocs/analytics"
+	"dimandocs/chunking"
+	"dimandocs/expansion"
+	"dimandocs/gitignore"
+	"dimandocs/render"
+	"dimandocs/secrets"
+	"dimandocs/sources"
+	"dimandocs/vector"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
 )
 
 // LoadConfig loads configuration from file and compiles regex patterns
 func (a *App) LoadConfig(configFile string) error {
 	if configFile == "" {
-		configFile = "dimandocs.json"
+		configFile = defaultConfigFile()
 	}
+	a.ConfigFile = configFile
 
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	if err := json.Unmarshal(data, &a.Config); err != nil {
+	if err := unmarshalConfig(configFile, data, &a.Config); err != nil {
 		return fmt.Errorf("failed to parse config file: %w", err)
 	}
 
 	// Expand environment variables in config
-	a.Config.Embeddings.APIKey = expandEnvVars(a.Config.Embeddings.APIKey)
-	a.Config.Embeddings.BaseURL = expandEnvVars(a.Config.Embeddings.BaseURL)
-	a.Config.Embeddings.DBPath = expandEnvVars(a.Config.Embeddings.DBPath)
+	expandConfigEnv(reflect.ValueOf(&a.Config).Elem())
 
 	// Set defaults for embeddings
 	if a.Config.Embeddings.DBPath == "" {
@@ -36,19 +55,158 @@ func (a *App) LoadConfig(configFile string) error {
 	if a.Config.Embeddings.Provider == "" {
 		a.Config.Embeddings.Provider = "openai"
 	}
-	if a.Config.Embeddings.Model == "" {
+	if a.Config.Embeddings.Model == "" && a.Config.Embeddings.Provider == "openai" {
 		a.Config.Embeddings.Model = "text-embedding-3-large"
 	}
+	if a.Config.Embeddings.Metric == "" {
+		a.Config.Embeddings.Metric = vector.MetricCosine
+	}
+	if !vector.IsMetric(a.Config.Embeddings.Metric) {
+		return fmt.Errorf("unknown embeddings metric '%s': expected cosine or l2", a.Config.Embeddings.Metric)
+	}
+	if a.Config.Embeddings.Quantization == "" {
+		a.Config.Embeddings.Quantization = vector.QuantizationFloat
+	}
+	if !vector.IsQuantization(a.Config.Embeddings.Quantization) {
+		return fmt.Errorf("unknown embeddings quantization '%s': expected float, int8 or bit", a.Config.Embeddings.Quantization)
+	}
+	if store := &a.Config.Embeddings.VectorStore; store.Type == "" {
+		store.Type = "sqlite"
+	}
+	if url := a.Config.Embeddings.DatabaseURL; url != "" && a.Config.Embeddings.VectorStore.Options["url"] == "" {
+		if a.Config.Embeddings.VectorStore.Options == nil {
+			a.Config.Embeddings.VectorStore.Options = make(map[string]string)
+		}
+		a.Config.Embeddings.VectorStore.Options["url"] = url
+	}
+	switch a.Config.Embeddings.Prune {
+	case "":
+		a.Config.Embeddings.Prune = pruneAuto
+	case pruneAuto, pruneDryRun, pruneOff:
+	default:
+		return fmt.Errorf("unknown embeddings prune mode '%s': expected auto, dry_run or off", a.Config.Embeddings.Prune)
+	}
+	switch a.Config.Embeddings.SearchMode {
+	case "":
+		a.Config.Embeddings.SearchMode = "vector"
+	case "vector", "hybrid":
+	default:
+		return fmt.Errorf("unknown embeddings search mode '%s': expected vector or hybrid", a.Config.Embeddings.SearchMode)
+	}
+	if a.Config.Embeddings.Concurrency < 0 || a.Config.Embeddings.RequestsPerMinute < 0 {
+		return fmt.Errorf("embeddings concurrency and requests_per_minute must not be negative")
+	}
+	if a.Config.Embeddings.Concurrency == 0 {
+		a.Config.Embeddings.Concurrency = defaultIndexConcurrency
+	}
+	if backup := &a.Config.Embeddings.Backup; backup.Interval != "" {
+		if d, err := time.ParseDuration(backup.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid embeddings backup interval '%s'", backup.Interval)
+		}
+		if a.Config.Embeddings.VectorStore.Type != "sqlite" {
+			return fmt.Errorf("embeddings backup requires the sqlite vector store, back up PostgreSQL with pg_dump")
+		}
+	}
+	if a.Config.Embeddings.Backup.Keep < 0 {
+		return fmt.Errorf("embeddings backup keep must not be negative")
+	}
+	if a.Config.Embeddings.Backup.Keep == 0 {
+		a.Config.Embeddings.Backup.Keep = defaultBackupKeep
+	}
+	if a.Config.Embeddings.Backup.Dir == "" {
+		a.Config.Embeddings.Backup.Dir = filepath.Join(filepath.Dir(a.Config.Embeddings.DBPath), "backups")
+	}
 
 	// Auto-detect API key from environment if not specified
 	if a.Config.Embeddings.APIKey == "" {
 		a.Config.Embeddings.APIKey = getDefaultAPIKey(a.Config.Embeddings.Provider)
 	}
 
+	// Set defaults for LLM
+	if a.Config.LLM.Provider == "" {
+		a.Config.LLM.Provider = "openai"
+	}
+	if a.Config.LLM.APIKey == "" {
+		a.Config.LLM.APIKey = getDefaultAPIKey(a.Config.LLM.Provider)
+	}
+	if a.Config.Embeddings.Summaries.MaxWords <= 0 {
+		a.Config.Embeddings.Summaries.MaxWords = 80
+	}
+
+	// Set defaults for the reranker
+	reranker := &a.Config.Search.Reranker
+	switch reranker.Provider {
+	case "":
+	case "cohere", "voyage", "voyageai":
+		if reranker.APIKey == "" {
+			reranker.APIKey = getDefaultAPIKey(reranker.Provider)
+		}
+	case "llm":
+	default:
+		return fmt.Errorf("unknown search reranker provider '%s': expected cohere, voyage or llm", reranker.Provider)
+	}
+	if reranker.TopK < 0 {
+		return fmt.Errorf("invalid search reranker top_k %d: must not be negative", reranker.TopK)
+	}
+	if reranker.TopK == 0 {
+		reranker.TopK = defaultRerankTopK
+	}
+	if a.Config.Search.Expansion.Paraphrases < 0 {
+		return fmt.Errorf("invalid search expansion paraphrases %d: must not be negative", a.Config.Search.Expansion.Paraphrases)
+	}
+	if a.Config.Search.Expansion.Paraphrases == 0 {
+		a.Config.Search.Expansion.Paraphrases = expansion.DefaultParaphrases
+	}
+
+	// Set defaults for analytics
+	if a.Config.Analytics.LogPath == "" {
+		a.Config.Analytics.LogPath = analytics.DefaultLogPath
+	}
+
+	// Compile secret detection rules
+	if a.Config.Secrets.Enabled {
+		scanner, err := secrets.NewScanner(secrets.Config{
+			Mode:             a.Config.Secrets.Mode,
+			Rules:            a.Config.Secrets.Rules,
+			DisableDefaults:  a.Config.Secrets.DisableDefaults,
+			EntropyThreshold: a.Config.Secrets.EntropyThreshold,
+		})
+		if err != nil {
+			return err
+		}
+		a.SecretScanner = scanner
+	}
+
 	// Set defaults for MCP
 	if a.Config.MCP.Transport == "" {
 		a.Config.MCP.Transport = "stdio"
 	}
+	if a.Config.MCP.Transport != "stdio" && a.Config.MCP.Transport != "http" {
+		return fmt.Errorf("unknown MCP transport '%s': expected stdio or http", a.Config.MCP.Transport)
+	}
+	if a.Config.MCP.Port == 0 {
+		a.Config.MCP.Port = 8081
+	}
+	a.Config.MCP.AuthTokens = nonEmptyTokens(a.Config.MCP.AuthTokens)
+
+	// Check the web interface credentials
+	a.Config.Auth.Tokens = nonEmptyTokens(a.Config.Auth.Tokens)
+	if a.Config.Auth.Username != "" {
+		if _, err := bcrypt.Cost([]byte(a.Config.Auth.PasswordHash)); err != nil {
+			return fmt.Errorf("invalid auth password_hash: expected the bcrypt hash of the password: %w", err)
+		}
+	}
+	if oidcCfg := a.Config.Auth.OIDC; oidcCfg != nil {
+		if oidcCfg.Issuer == "" || oidcCfg.ClientID == "" || oidcCfg.RedirectURL == "" {
+			return fmt.Errorf("auth oidc requires issuer, client_id and redirect_url")
+		}
+		if oidcCfg.SessionDuration == "" {
+			oidcCfg.SessionDuration = "12h"
+		}
+		if d, err := time.ParseDuration(oidcCfg.SessionDuration); err != nil || d <= 0 {
+			return fmt.Errorf("invalid auth oidc session_duration '%s'", oidcCfg.SessionDuration)
+		}
+	}
 
 	// Compile ignore patterns
 	for _, pattern := range a.Config.IgnorePatterns {
@@ -58,12 +216,216 @@ func (a *App) LoadConfig(configFile string) error {
 		}
 		a.IgnoreRegexes = append(a.IgnoreRegexes, regex)
 	}
+	globs, err := gitignore.Compile(a.Config.Ignore)
+	if err != nil {
+		return fmt.Errorf("failed to compile ignore globs: %w", err)
+	}
+	a.IgnoreGlobs = globs
+
+	// Remote sources are read from their mirror in the cache directory
+	if a.Config.CacheDir == "" {
+		a.Config.CacheDir = ".dimandocs-cache"
+	}
+
+	// The web interface overrides must be a readable directory
+	if a.Config.TemplatesDir != "" {
+		info, err := os.Stat(a.Config.TemplatesDir)
+		if err != nil {
+			return fmt.Errorf("failed to read templates_dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("templates_dir %s is not a directory", a.Config.TemplatesDir)
+		}
+	}
+
+	if a.Config.CodeTheme == "" {
+		a.Config.CodeTheme = render.DefaultCodeTheme
+	}
+	if a.Config.CodeTheme != "none" && !render.IsCodeTheme(a.Config.CodeTheme) {
+		return fmt.Errorf("unknown code_theme '%s': expected a chroma style such as github, monokai or dracula, or none", a.Config.CodeTheme)
+	}
+
+	if a.Config.Diagrams.MermaidScript == "" {
+		a.Config.Diagrams.MermaidScript = defaultMermaidScript
+	}
+	if server := a.Config.Diagrams.PlantUMLServer; server != "" && !strings.HasPrefix(server, "http://") && !strings.HasPrefix(server, "https://") {
+		return fmt.Errorf("invalid diagrams plantuml_server '%s': expected an http or https URL", server)
+	}
+
+	// Check the logging settings
+	if _, err := parseLogLevel(a.Config.Logging.Level); err != nil {
+		return err
+	}
+	if format := a.Config.Logging.Format; format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("unknown log format '%s': expected text or json", format)
+	}
+
+	// Check the rate limits
+	rateLimit := &a.Config.RateLimit
+	if rateLimit.RequestsPerSecond < 0 || rateLimit.Burst < 0 {
+		return fmt.Errorf("invalid rate_limit: requests_per_second and burst must not be negative")
+	}
+	if rateLimit.Enabled() && rateLimit.Burst == 0 {
+		rateLimit.Burst = int(math.Ceil(rateLimit.RequestsPerSecond))
+	}
+	// Single addresses are stored as ranges of one
+	for i, proxy := range rateLimit.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			addr = addr.Unmap()
+			proxy = netip.PrefixFrom(addr, addr.BitLen()).String()
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid rate_limit trusted proxy %q: expected an IP address or CIDR range", rateLimit.TrustedProxies[i])
+		}
+		rateLimit.TrustedProxies[i] = prefix.Masked().String()
+	}
+
+	// Origins are compared without a trailing slash, methods in upper case
+	cors := &a.Config.CORS
+	for i, origin := range cors.AllowedOrigins {
+		cors.AllowedOrigins[i] = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+	}
+	if len(cors.AllowedMethods) == 0 {
+		cors.AllowedMethods = []string{http.MethodGet, http.MethodPost}
+	}
+	for i, method := range cors.AllowedMethods {
+		cors.AllowedMethods[i] = strings.ToUpper(strings.TrimSpace(method))
+	}
+	if len(cors.AllowedHeaders) == 0 {
+		cors.AllowedHeaders = []string{"Authorization", "Content-Type", "X-API-Key"}
+	}
+
+	// Check the HTTPS settings
+	tlsCfg := &a.Config.TLS
+	if (tlsCfg.CertFile == "") != (tlsCfg.KeyFile == "") {
+		return fmt.Errorf("tls requires both cert_file and key_file")
+	}
+	if tlsCfg.Autocert != nil {
+		if tlsCfg.CertFile != "" {
+			return fmt.Errorf("tls autocert cannot be combined with cert_file and key_file")
+		}
+		if len(tlsCfg.Autocert.Domains) == 0 {
+			return fmt.Errorf("tls autocert requires at least one domain")
+		}
+		if tlsCfg.Autocert.CacheDir == "" {
+			tlsCfg.Autocert.CacheDir = filepath.Join(a.Config.CacheDir, "autocert")
+		}
+	}
+	for i := range a.Config.Directories {
+		dirConfig := &a.Config.Directories[i]
+		if strings.HasPrefix(dirConfig.Path, "s3://") && dirConfig.S3 == nil {
+			dirConfig.S3 = &S3SourceConfig{}
+		}
+		switch {
+		case dirConfig.Git != nil:
+			if dirConfig.Git.URL == "" {
+				return fmt.Errorf("git source '%s' has no url", dirConfig.Name)
+			}
+			if err := validateRefreshInterval(dirConfig.Name, dirConfig.Git.RefreshInterval); err != nil {
+				return err
+			}
+			dirConfig.Path = filepath.Join(a.gitRepo(dirConfig.Git).Dir, dirConfig.Git.Subpath)
+		case dirConfig.GitHub != nil:
+			if dirConfig.GitHub.Token == "" {
+				dirConfig.GitHub.Token = os.Getenv("GITHUB_TOKEN")
+			}
+			if strings.Count(dirConfig.GitHub.Repo, "/") != 1 {
+				return fmt.Errorf("github source '%s' needs a repo in the form owner/name", dirConfig.Name)
+			}
+			if err := validateRefreshInterval(dirConfig.Name, dirConfig.GitHub.RefreshInterval); err != nil {
+				return err
+			}
+			dirConfig.Path = filepath.Join(a.githubRepo(*dirConfig).Dir, dirConfig.GitHub.Subpath)
+		case dirConfig.GitLabWiki != nil:
+			if dirConfig.GitLabWiki.Token == "" {
+				dirConfig.GitLabWiki.Token = os.Getenv("GITLAB_TOKEN")
+			}
+			if dirConfig.GitLabWiki.Project == "" {
+				return fmt.Errorf("gitlab wiki source '%s' has no project", dirConfig.Name)
+			}
+			if err := validateRefreshInterval(dirConfig.Name, dirConfig.GitLabWiki.RefreshInterval); err != nil {
+				return err
+			}
+			dirConfig.Path = a.gitlabWiki(dirConfig.GitLabWiki).Dir
+			if dirConfig.FilePattern == "" {
+				dirConfig.FilePattern = `\.md$` // Every mirrored wiki page
+			}
+		case dirConfig.Confluence != nil:
+			if dirConfig.Confluence.Token == "" {
+				dirConfig.Confluence.Token = os.Getenv("CONFLUENCE_TOKEN")
+			}
+			if dirConfig.Confluence.BaseURL == "" || dirConfig.Confluence.Space == "" {
+				return fmt.Errorf("confluence source '%s' needs a base_url and a space", dirConfig.Name)
+			}
+			if err := validateRefreshInterval(dirConfig.Name, dirConfig.Confluence.RefreshInterval); err != nil {
+				return err
+			}
+			dirConfig.Path = a.confluenceSpace(dirConfig.Confluence).Dir
+			if dirConfig.FilePattern == "" {
+				dirConfig.FilePattern = `\.md$` // Every mirrored page
+			}
+		case dirConfig.S3 != nil:
+			cfg := dirConfig.S3
+			cfg.URL = dirConfig.Path
+			if _, _, err := sources.ParseS3URL(cfg.URL); err != nil {
+				return fmt.Errorf("s3 source '%s': %w", dirConfig.Name, err)
+			}
+			cfg.Region = envOrDefault(cfg.Region, "AWS_REGION")
+			cfg.AccessKey = envOrDefault(cfg.AccessKey, "AWS_ACCESS_KEY_ID")
+			cfg.SecretKey = envOrDefault(cfg.SecretKey, "AWS_SECRET_ACCESS_KEY")
+			cfg.SessionToken = envOrDefault(cfg.SessionToken, "AWS_SESSION_TOKEN")
+			if err := validateRefreshInterval(dirConfig.Name, cfg.RefreshInterval); err != nil {
+				return err
+			}
+			dirConfig.Path = a.s3Bucket(*dirConfig).Dir
+		case dirConfig.Website != nil:
+			if !strings.HasPrefix(dirConfig.Website.URL, "http://") && !strings.HasPrefix(dirConfig.Website.URL, "https://") {
+				return fmt.Errorf("website source '%s' needs an http(s) url", dirConfig.Name)
+			}
+			if err := validateRefreshInterval(dirConfig.Name, dirConfig.Website.RefreshInterval); err != nil {
+				return err
+			}
+			dirConfig.Path = a.website(dirConfig.Website).Dir
+			if dirConfig.FilePattern == "" {
+				dirConfig.FilePattern = `\.md$` // Every crawled page
+			}
+		}
+	}
+
+	uploads := ""
+	for i := range a.Config.Directories {
+		dirConfig := &a.Config.Directories[i]
+		if dirConfig.Collection == "" {
+			dirConfig.Collection = defaultCollection
+		}
+		if !dirConfig.Uploads {
+			continue
+		}
+		if source, _ := a.remoteSource(*dirConfig); source != nil {
+			return fmt.Errorf("uploads directory '%s' must be a local directory", dirConfig.Name)
+		}
+		if uploads != "" {
+			return fmt.Errorf("directories '%s' and '%s' both store uploads, only one can", uploads, dirConfig.Name)
+		}
+		uploads = dirConfig.Name
+		if dirConfig.FilePattern == "" {
+			dirConfig.FilePattern = `\.md$` // Every uploaded document
+		}
+	}
+
+	if err := checkSourceEmbeddings(a.Config.Embeddings, a.Config.Directories); err != nil {
+		return err
+	}
 
 	// Compile file patterns for each directory
 	a.FileRegexes = make(map[string]*regexp.Regexp)
 	for _, dirConfig := range a.Config.Directories {
 		pattern := dirConfig.FilePattern
-		if pattern == "" {
+		if pattern == "" && dirConfig.Code {
+			pattern = chunking.SourceFilePattern() // Default to supported source files
+		} else if pattern == "" {
 			pattern = "^(?i)(readme\\.md)$" // Default to README.md files
 		}
 		regex, err := regexp.Compile(pattern)
@@ -76,6 +438,128 @@ func (a *App) LoadConfig(configFile string) error {
 	return nil
 }
 
+// checkSourceEmbeddings checks the embeddings of directories that have their
+// own: each needs its own database, which directories with other embeddings
+// settings must not share
+func checkSourceEmbeddings(base EmbeddingsConfig, dirs []DirectoryConfig) error {
+	type owner struct {
+		name string
+		cfg  EmbeddingsConfig
+	}
+	dbPaths := make(map[string]owner) // Of SQLite databases
+	if base.VectorStore.Type == "sqlite" {
+		dbPaths[base.DBPath] = owner{"the embeddings section", base}
+	}
+	for _, dirConfig := range dirs {
+		override := dirConfig.Embeddings
+		if override == nil {
+			continue
+		}
+		if override.DBPath == "" && override.VectorStore.Type == "" && len(override.VectorStore.Options) == 0 && override.DatabaseURL == "" {
+			return fmt.Errorf("embeddings of directory '%s' need their own db_path, vector_store or database_url", dirConfig.Name)
+		}
+		if override.RequestsPerMinute < 0 {
+			return fmt.Errorf("embeddings requests_per_minute of directory '%s' must not be negative", dirConfig.Name)
+		}
+
+		cfg := mergeEmbeddingsConfig(base, *override)
+		if !vector.IsMetric(cfg.Metric) {
+			return fmt.Errorf("unknown embeddings metric '%s' of directory '%s': expected cosine or l2", cfg.Metric, dirConfig.Name)
+		}
+		if !vector.IsQuantization(cfg.Quantization) {
+			return fmt.Errorf("unknown embeddings quantization '%s' of directory '%s': expected float, int8 or bit", cfg.Quantization, dirConfig.Name)
+		}
+		if cfg.VectorStore.Type != "sqlite" {
+			continue
+		}
+		if other, ok := dbPaths[cfg.DBPath]; ok && !reflect.DeepEqual(other.cfg, cfg) {
+			return fmt.Errorf("directory '%s' stores embeddings in %s like %s, but with other settings", dirConfig.Name, cfg.DBPath, other.name)
+		}
+		dbPaths[cfg.DBPath] = owner{fmt.Sprintf("directory '%s'", dirConfig.Name), cfg}
+	}
+	return nil
+}
+
+// defaultMermaidScript is the mermaid ES module loaded by document pages with
+// mermaid diagrams
+const defaultMermaidScript = "https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs"
+
+// defaultConfigFiles are the config files looked for when none is given
+var defaultConfigFiles = []string{"dimandocs.json", "dimandocs.yaml", "dimandocs.yml", "dimandocs.toml"}
+
+// defaultConfigFile returns the first default config file that exists
+func defaultConfigFile() string {
+	for _, name := range defaultConfigFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return defaultConfigFiles[0]
+}
+
+// unmarshalConfig decodes a JSON, YAML or TOML config file, detected by its
+// extension. YAML and TOML are converted to JSON first, so the json tags of
+// Config define the schema for every format.
+func unmarshalConfig(configFile string, data []byte, config *Config) error {
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(configFile)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+	default:
+		return json.Unmarshal(data, config)
+	}
+
+	// The web port is a string in JSON, but is usually written as a number
+	if port, ok := doc["port"]; ok {
+		if _, isString := port.(string); !isString {
+			doc["port"] = fmt.Sprint(port)
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, config)
+}
+
+// validateRefreshInterval checks the refresh interval of a remote source
+func validateRefreshInterval(name, interval string) error {
+	if interval == "" {
+		return nil
+	}
+	if _, err := time.ParseDuration(interval); err != nil {
+		return fmt.Errorf("invalid refresh interval for source '%s': %w", name, err)
+	}
+	return nil
+}
+
+// envOrDefault returns value, or the environment variable if value is empty
+func envOrDefault(value, envVar string) string {
+	if value == "" {
+		return os.Getenv(envVar)
+	}
+	return value
+}
+
+// nonEmptyTokens drops empty tokens, which come from unset environment
+// variables, rather than accept ""
+func nonEmptyTokens(tokens []string) []string {
+	var result []string
+	for _, token := range tokens {
+		if token != "" {
+			result = append(result, token)
+		}
+	}
+	return result
+}
+
 // GetWorkingDirectory gets the current working directory
 func GetWorkingDirectory() (string, error) {
 	workingDir, err := os.Getwd()
@@ -85,27 +569,90 @@ func GetWorkingDirectory() (string, error) {
 	return workingDir, nil
 }
 
+// envVarRegex matches ${VAR} and ${VAR:-default} references
+var envVarRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// envNameRegex matches an environment variable name
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // expandEnvVars expands environment variables in a string
-// Supports ${VAR} and $VAR syntax
+// Supports ${VAR} and ${VAR:-default} anywhere in the string, where the
+// default is used if VAR is unset or empty, and $VAR for the whole string
 func expandEnvVars(s string) string {
 	if s == "" {
 		return s
 	}
 
-	// Handle ${VAR} syntax
-	result := os.Expand(s, func(key string) string {
-		return os.Getenv(key)
+	// Handle $VAR syntax without braces (for simple cases)
+	if name, ok := strings.CutPrefix(s, "$"); ok && envNameRegex.MatchString(name) {
+		return os.Getenv(name)
+	}
+
+	return envVarRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		match := envVarRegex.FindStringSubmatch(ref)
+		if value := os.Getenv(match[1]); value != "" || match[2] == "" {
+			return value
+		}
+		return match[3]
 	})
+}
 
-	// Also handle $VAR syntax without braces (for simple cases)
-	if strings.HasPrefix(result, "$") && !strings.HasPrefix(result, "${") {
-		varName := strings.TrimPrefix(result, "$")
-		if val := os.Getenv(varName); val != "" {
-			return val
+// expandConfigEnv expands environment variables in every string of the
+// config, including lists and nested sections
+func expandConfigEnv(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(expandEnvVars(v.String()))
+	case reflect.Pointer:
+		if !v.IsNil() {
+			expandConfigEnv(v.Elem())
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				expandConfigEnv(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			expandConfigEnv(v.Index(i))
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() == reflect.String {
+			for _, key := range v.MapKeys() {
+				v.SetMapIndex(key, reflect.ValueOf(expandEnvVars(v.MapIndex(key).String())).Convert(v.Type().Elem()))
+			}
 		}
 	}
+}
 
-	return result
+// UnmarshalJSON decodes a vector_store block or a backend name. Options of
+// the block may be strings, numbers or booleans, and are kept as strings.
+func (c *VectorStoreConfig) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.Type); err == nil {
+		return nil
+	}
+
+	var block map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&block); err != nil {
+		return fmt.Errorf("vector_store must be a backend name or a block: %w", err)
+	}
+	c.Options = make(map[string]string, len(block))
+	for key, value := range block {
+		switch value := value.(type) {
+		case string, json.Number, bool:
+			if key == "type" {
+				c.Type = fmt.Sprint(value)
+			} else {
+				c.Options[key] = fmt.Sprint(value)
+			}
+		default:
+			return fmt.Errorf("vector_store option %s must be a string, number or boolean", key)
+		}
+	}
+	return nil
 }
 
 // getDefaultAPIKey returns the default environment variable for a provider
@@ -115,9 +662,16 @@ func getDefaultAPIKey(provider string) string {
 		return os.Getenv("OPENAI_API_KEY")
 	case "voyage", "voyageai":
 		return os.Getenv("VOYAGE_API_KEY")
+	case "tei", "huggingface":
+		return os.Getenv("HF_TOKEN")
+	case "cohere":
+		if key := os.Getenv("COHERE_API_KEY"); key != "" {
+			return key
+		}
+		return os.Getenv("CO_API_KEY")
 	case "ollama":
 		return "" // Ollama doesn't need API key
 	default:
 		return ""
 	}
-}
\ No newline at end of file
+}
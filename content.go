@@ -0,0 +1,160 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// contentCacheSize is the number of document contents kept in memory after
+// being read from their files
+const contentCacheSize = 256
+
+// documentContent locates the content of a document. Content that is a
+// byte range of the document's file is read on demand, so the documents of
+// large directories do not all stay in memory; content that differs from
+// the file, such as converted HTML pages or redacted secrets, is held.
+type documentContent struct {
+	text     string // Content held in memory, when held is set
+	held     bool
+	path     string
+	modTime  time.Time // Of the file when it was scanned, so a rescanned file is not served from the cache
+	fileSize int64     // Of the file when it was scanned
+	offset   int64     // Start of the content in the file, after any frontmatter
+	size     int64
+}
+
+// heldContent returns content held in memory
+func heldContent(text string) documentContent {
+	return documentContent{text: text, held: true}
+}
+
+// fileContent returns content read on demand from a byte range of a file
+// with the given modification time and size
+func fileContent(path string, modTime time.Time, fileSize, offset, size int64) documentContent {
+	return documentContent{path: path, modTime: modTime, fileSize: fileSize, offset: offset, size: size}
+}
+
+// Content returns the text of the document, reading it from its file unless
+// it is held in memory or recently used. Read failures, including files
+// changed since they were scanned, are logged and yield no content.
+func (d Document) Content() string {
+	if d.content.held || d.content.path == "" {
+		return d.content.text
+	}
+	text, err := contentCache.get(d.content)
+	if err != nil {
+		slog.Warn("Failed to read document content", "path", d.content.path, "error", err)
+	}
+	return text
+}
+
+// SetContent replaces the content of the document with text held in memory
+func (d *Document) SetContent(text string) {
+	d.content = heldContent(text)
+}
+
+// contentCache holds the contents most recently read from files
+var contentCache = newContentLRU(contentCacheSize)
+
+// contentLRU is a least recently used cache of document contents, keyed by
+// their location
+type contentLRU struct {
+	capacity int
+
+	mu      sync.Mutex
+	order   *list.List                        // Of *contentEntry, most recently used first
+	entries map[documentContent]*list.Element // By location
+}
+
+// contentEntry is a cached document content
+type contentEntry struct {
+	key  documentContent
+	text string
+}
+
+// newContentLRU creates a cache holding up to capacity contents
+func newContentLRU(capacity int) *contentLRU {
+	return &contentLRU{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[documentContent]*list.Element),
+	}
+}
+
+// get returns the content at a location, reading it from the file if it is
+// not cached
+func (c *contentLRU) get(key documentContent) (string, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*contentEntry).text, nil
+	}
+	c.mu.Unlock()
+
+	// Files are read without holding the lock; concurrent misses for the
+	// same content read it twice
+	text, err := readContent(key)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = c.order.PushFront(&contentEntry{key: key, text: text})
+		if c.order.Len() > c.capacity {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*contentEntry).key)
+		}
+	}
+	return text, nil
+}
+
+// errContentChanged is returned by readContent for a file changed since it
+// was scanned, whose byte range may no longer hold the scanned content
+var errContentChanged = errors.New("file changed since it was scanned")
+
+// readContent reads the byte range of a file holding a document's content.
+// The file must be unchanged since it was scanned, so that the content read
+// is the one that was parsed and checked for secrets; a changed file is
+// served again once it is rescanned.
+func readContent(loc documentContent) (string, error) {
+	f, err := os.Open(loc.path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := checkUnchanged(f, loc); err != nil {
+		return "", err
+	}
+	buf := make([]byte, loc.size)
+	if _, err := f.ReadAt(buf, loc.offset); err != nil {
+		return "", fmt.Errorf("failed to read content: %w", err)
+	}
+	// The file may have been written while it was read
+	if err := checkUnchanged(f, loc); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// checkUnchanged returns errContentChanged if the modification time or size
+// of the open file differ from those it was scanned with
+func checkUnchanged(f *os.File, loc documentContent) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if !info.ModTime().Equal(loc.modTime) || info.Size() != loc.fileSize {
+		return errContentChanged
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsMaxAge is how long in seconds browsers may cache a preflight response
+const corsMaxAge = "600"
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for an origin,
+// or "" if the origin is not allowed
+func allowedOrigin(cfg CORSConfig, origin string) string {
+	if slices.Contains(cfg.AllowedOrigins, "*") {
+		return "*"
+	}
+	if slices.Contains(cfg.AllowedOrigins, strings.TrimSuffix(origin, "/")) {
+		return origin
+	}
+	return ""
+}
+
+// cors lets browser frontends on the origins allowed by the cors config call
+// the JSON APIs. Preflight requests are answered here, before authentication,
+// since browsers send them without credentials.
+func (a *App) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.RLock()
+		cfg := a.Config.CORS
+		a.mu.RUnlock()
+		if !cfg.Enabled() || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		allowOrigin := allowedOrigin(cfg, origin)
+		if origin == "" || allowOrigin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header.Set("Access-Control-Allow-Origin", allowOrigin)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+			header.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			header.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		header.Set("Access-Control-Expose-Headers", "ETag, Retry-After")
+		next.ServeHTTP(w, r)
+	})
+}
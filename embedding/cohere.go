@@ -0,0 +1,242 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultCohereURL is the default Cohere embed API endpoint
+	DefaultCohereURL = "https://api.cohere.com/v2/embed"
+	// DefaultCohereModel is the default embedding model for Cohere
+	DefaultCohereModel = "embed-english-v3.0"
+	// CohereTimeout is the timeout for Cohere requests
+	CohereTimeout = 60 * time.Second
+	// CohereMaxBatchSize is the maximum number of texts per Cohere request
+	CohereMaxBatchSize = 96
+	// CohereMaxRetries is the maximum number of retries
+	CohereMaxRetries = 5
+
+	// Cohere input types, which produce embeddings optimized for each side of retrieval
+	cohereInputDocument = "search_document"
+	cohereInputQuery    = "search_query"
+)
+
+// CohereService implements Service using the Cohere embed API
+type CohereService struct {
+	apiKey    string
+	baseURL   string
+	model     string
+	dimension int
+	client    *http.Client
+}
+
+// CohereConfig holds configuration for Cohere embedding service
+type CohereConfig struct {
+	APIKey  string
+	BaseURL string // Default: https://api.cohere.com/v2/embed
+	Model   string // Default: embed-english-v3.0
+}
+
+// cohereRequest represents the request body for the Cohere embed API
+type cohereRequest struct {
+	Model          string   `json:"model"`
+	Texts          []string `json:"texts"`
+	InputType      string   `json:"input_type"`
+	EmbeddingTypes []string `json:"embedding_types"`
+	Truncate       string   `json:"truncate,omitempty"`
+}
+
+// cohereResponse represents the response from the Cohere embed API
+type cohereResponse struct {
+	Embeddings struct {
+		Float [][]float64 `json:"float"`
+	} `json:"embeddings"`
+}
+
+// NewCohereService creates a new Cohere embedding service
+func NewCohereService(cfg CohereConfig) (*CohereService, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("Cohere API key is required")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultCohereURL
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = DefaultCohereModel
+	}
+
+	model = cohereModelName(model)
+
+	// Determine dimension based on model
+	dimension := 1024 // default for v3 models
+	if d, ok := cohereDimensions[model]; ok {
+		dimension = d
+	}
+
+	return &CohereService{
+		apiKey:    cfg.APIKey,
+		baseURL:   baseURL,
+		model:     model,
+		dimension: dimension,
+		client: &http.Client{
+			Timeout: CohereTimeout,
+		},
+	}, nil
+}
+
+// Embed generates a document embedding for a single text
+func (s *CohereService) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := s.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("no embeddings returned")
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates document embeddings for multiple texts
+func (s *CohereService) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return s.embed(ctx, texts, cohereInputDocument)
+}
+
+// EmbedQuery generates a search query embedding for a single text
+func (s *CohereService) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := s.EmbedQueryBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("no embeddings returned")
+	}
+	return embeddings[0], nil
+}
+
+// EmbedQueryBatch generates search query embeddings for multiple texts
+func (s *CohereService) EmbedQueryBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return s.embed(ctx, texts, cohereInputQuery)
+}
+
+// embed generates embeddings of the given input type in batches
+func (s *CohereService) embed(ctx context.Context, texts []string, inputType string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	var allEmbeddings [][]float32
+
+	// Process in batches
+	for i := 0; i < len(texts); i += CohereMaxBatchSize {
+		end := i + CohereMaxBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		embeddings, err := s.embedBatchWithRetry(ctx, texts[i:end], inputType)
+		if err != nil {
+			return nil, err
+		}
+		allEmbeddings = append(allEmbeddings, embeddings...)
+	}
+
+	return allEmbeddings, nil
+}
+
+func (s *CohereService) embedBatchWithRetry(ctx context.Context, texts []string, inputType string) ([][]float32, error) {
+	reqBody := cohereRequest{
+		Model:          s.model,
+		Texts:          texts,
+		InputType:      inputType,
+		EmbeddingTypes: []string{"float"},
+		Truncate:       "END",
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var resp *http.Response
+	backoff := 10 * time.Second
+
+	for retry := 0; retry <= CohereMaxRetries; retry++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", s.baseURL, bytes.NewBuffer(jsonBody))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+		resp, err = s.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request to Cohere: %w", err)
+		}
+
+		// Check for rate limit
+		if resp.StatusCode == http.StatusTooManyRequests && retry < CohereMaxRetries {
+			resp.Body.Close()
+			slog.Warn("Cohere rate limit hit, retrying", "backoff", backoff, "attempt", retry+1, "max_attempts", CohereMaxRetries)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > 120*time.Second {
+				backoff = 120 * time.Second
+			}
+			continue
+		}
+
+		break
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Cohere API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var cohereResp cohereResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cohereResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(cohereResp.Embeddings.Float) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(cohereResp.Embeddings.Float))
+	}
+
+	// Convert to float32
+	embeddings := make([][]float32, len(cohereResp.Embeddings.Float))
+	for i, data := range cohereResp.Embeddings.Float {
+		embedding := make([]float32, len(data))
+		for j, v := range data {
+			embedding[j] = float32(v)
+		}
+		embeddings[i] = embedding
+	}
+
+	return embeddings, nil
+}
+
+// Dimension returns the embedding dimension
+func (s *CohereService) Dimension() int {
+	return s.dimension
+}
+
+// SetDimension sets the embedding dimension, replacing the one assumed for
+// the model
+func (s *CohereService) SetDimension(dim int) {
+	s.dimension = dim
+}
@@ -0,0 +1,29 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+)
+
+// DimensionProbe is the text embedded to detect the dimension of a model
+const DimensionProbe = "dimension probe"
+
+// DimensionSetter is implemented by services that assume the dimension of
+// known models, so it can be replaced by the detected or configured one
+type DimensionSetter interface {
+	// SetDimension sets the dimension of the embeddings the model returns
+	SetDimension(dim int)
+}
+
+// DetectDimension embeds a probe text and returns the dimension of the
+// embedding returned by the service
+func DetectDimension(ctx context.Context, s Service) (int, error) {
+	embeddings, err := s.EmbedBatch(ctx, []string{DimensionProbe})
+	if err != nil {
+		return 0, err
+	}
+	if len(embeddings) != 1 || len(embeddings[0]) == 0 {
+		return 0, fmt.Errorf("the model returned no embedding")
+	}
+	return len(embeddings[0]), nil
+}
@@ -0,0 +1,51 @@
+package embedding
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter spaces out embedding requests to stay below a provider's rate
+// limit when several documents are embedded concurrently. A nil Limiter
+// does not limit.
+type Limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // Earliest start of the next request
+}
+
+// NewLimiter creates a limiter allowing perMinute requests per minute, or
+// returns nil when perMinute is not positive
+func NewLimiter(perMinute int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &Limiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// Wait blocks until the next request may start or ctx is cancelled
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	wait := max(l.next.Sub(now), 0)
+	l.next = now.Add(wait + l.interval)
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
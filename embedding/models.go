@@ -0,0 +1,75 @@
+package embedding
+
+import "strings"
+
+// openAIDimensions are the native embedding dimensions of OpenAI models
+var openAIDimensions = map[string]int{
+	"text-embedding-3-large": 3072,
+	"text-embedding-3-small": 1536,
+	"text-embedding-ada-002": 1536,
+}
+
+// voyageDimensions are the embedding dimensions of Voyage AI models
+var voyageDimensions = map[string]int{
+	"voyage-3":       1024,
+	"voyage-code-3":  1024,
+	"voyage-3-lite":  512,
+	"voyage-large-2": 1536,
+	"voyage-2":       1536,
+	"voyage-code-2":  1536,
+}
+
+// cohereDimensions are the embedding dimensions of Cohere models
+var cohereDimensions = map[string]int{
+	"embed-english-v3.0":            1024,
+	"embed-multilingual-v3.0":       1024,
+	"embed-english-light-v3.0":      384,
+	"embed-multilingual-light-v3.0": 384,
+	"embed-v4.0":                    1536,
+}
+
+// ollamaDimensions are the embedding dimensions of Ollama models
+var ollamaDimensions = map[string]int{
+	"nomic-embed-text":  768,
+	"mxbai-embed-large": 1024,
+	"all-minilm":        384,
+}
+
+// cohereModelName accepts Cohere v3 model names without the ".0" version
+// suffix
+func cohereModelName(model string) string {
+	switch model {
+	case "embed-english-v3", "embed-multilingual-v3", "embed-english-light-v3", "embed-multilingual-light-v3":
+		return model + ".0"
+	}
+	return model
+}
+
+// ModelDimension returns the embedding dimension of a known model of a
+// provider. An empty model means the provider's default model. Unknown
+// models, and providers that detect the dimension at runtime, report false.
+func ModelDimension(provider, model string) (int, bool) {
+	var dimensions map[string]int
+	switch strings.ToLower(provider) {
+	case "openai", "":
+		dimensions, model = openAIDimensions, defaultString(model, string(DefaultModel))
+	case "voyage", "voyageai":
+		dimensions, model = voyageDimensions, defaultString(model, DefaultVoyageModel)
+	case "cohere":
+		dimensions, model = cohereDimensions, cohereModelName(defaultString(model, DefaultCohereModel))
+	case "ollama":
+		dimensions, model = ollamaDimensions, defaultString(model, DefaultOllamaModel)
+	default:
+		return 0, false
+	}
+	dim, ok := dimensions[model]
+	return dim, ok
+}
+
+// defaultString returns value, or def if value is empty
+func defaultString(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
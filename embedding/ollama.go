@@ -60,13 +60,8 @@ func NewOllamaService(cfg OllamaConfig) (*OllamaService, error) {
 
 	// Determine dimension based on model
 	dimension := OllamaDimension
-	switch model {
-	case "nomic-embed-text":
-		dimension = 768
-	case "mxbai-embed-large":
-		dimension = 1024
-	case "all-minilm":
-		dimension = 384
+	if d, ok := ollamaDimensions[model]; ok {
+		dimension = d
 	}
 
 	return &OllamaService{
@@ -145,3 +140,9 @@ func (s *OllamaService) EmbedBatch(ctx context.Context, texts []string) ([][]flo
 func (s *OllamaService) Dimension() int {
 	return s.dimension
 }
+
+// SetDimension sets the embedding dimension, replacing the one assumed for
+// the model
+func (s *OllamaService) SetDimension(dim int) {
+	s.dimension = dim
+}
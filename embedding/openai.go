@@ -3,7 +3,7 @@ package embedding
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -117,7 +117,7 @@ func (s *OpenAIService) EmbedBatch(ctx context.Context, texts []string) ([][]flo
 
 			// Check if it's a rate limit error (429)
 			if isRateLimitError(err) && retry < MaxRetries {
-				log.Printf("Rate limit hit, retrying in %v (attempt %d/%d)", backoff, retry+1, MaxRetries)
+				slog.Warn("Rate limit hit, retrying", "backoff", backoff, "attempt", retry+1, "max_attempts", MaxRetries)
 				select {
 				case <-ctx.Done():
 					return nil, ctx.Err()
@@ -13,3 +13,31 @@ type Service interface {
 	// Dimension returns the embedding dimension
 	Dimension() int
 }
+
+// QueryEmbedder is implemented by services whose models embed search queries
+// differently from the documents being searched
+type QueryEmbedder interface {
+	// EmbedQuery generates an embedding for a single search query
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+
+	// EmbedQueryBatch generates embeddings for multiple search queries
+	EmbedQueryBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbedQuery embeds a search query, using the query-specific embedding of
+// the service when it has one
+func EmbedQuery(ctx context.Context, s Service, text string) ([]float32, error) {
+	if qe, ok := s.(QueryEmbedder); ok {
+		return qe.EmbedQuery(ctx, text)
+	}
+	return s.Embed(ctx, text)
+}
+
+// EmbedQueryBatch embeds several search queries, using the query-specific
+// embedding of the service when it has one
+func EmbedQueryBatch(ctx context.Context, s Service, texts []string) ([][]float32, error) {
+	if qe, ok := s.(QueryEmbedder); ok {
+		return qe.EmbedQueryBatch(ctx, texts)
+	}
+	return s.EmbedBatch(ctx, texts)
+}
@@ -0,0 +1,234 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTEIURL is the default Text Embeddings Inference server address
+	DefaultTEIURL = "http://localhost:8080"
+	// TEITimeout is the timeout for TEI requests
+	TEITimeout = 60 * time.Second
+	// TEIDefaultBatchSize is the batch size used when the server does not
+	// report its max_client_batch_size
+	TEIDefaultBatchSize = 32
+	// teiProbeTimeout bounds the startup requests used for auto-detection
+	teiProbeTimeout = 30 * time.Second
+)
+
+// TEIService implements Service using a Hugging Face Text Embeddings
+// Inference server or Inference Endpoint
+type TEIService struct {
+	apiKey    string
+	baseURL   string
+	model     string
+	dimension int
+	batchSize int
+	client    *http.Client
+}
+
+// TEIConfig holds configuration for the TEI embedding service
+type TEIConfig struct {
+	APIKey    string // Optional: Hugging Face token for Inference Endpoints
+	BaseURL   string // Default: http://localhost:8080
+	Model     string // Optional: informational, the server decides the model
+	Dimension int    // Optional: detected from the server when 0
+	BatchSize int    // Optional: detected from the server when 0
+}
+
+// teiRequest represents the request body for the TEI /embed endpoint
+type teiRequest struct {
+	Inputs    []string `json:"inputs"`
+	Truncate  bool     `json:"truncate"`
+	Normalize bool     `json:"normalize"`
+}
+
+// teiInfo represents the response of the TEI /info endpoint
+type teiInfo struct {
+	ModelID            string `json:"model_id"`
+	MaxClientBatchSize int    `json:"max_client_batch_size"`
+}
+
+// NewTEIService creates a new TEI embedding service. Unless configured, the
+// embedding dimension and batch size are detected from the server.
+func NewTEIService(cfg TEIConfig) (*TEIService, error) {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultTEIURL
+	}
+	// Accept the full endpoint URL as well as the server address
+	baseURL = strings.TrimSuffix(baseURL, "/embed")
+
+	s := &TEIService{
+		apiKey:    cfg.APIKey,
+		baseURL:   baseURL,
+		model:     cfg.Model,
+		dimension: cfg.Dimension,
+		batchSize: cfg.BatchSize,
+		client: &http.Client{
+			Timeout: TEITimeout,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), teiProbeTimeout)
+	defer cancel()
+
+	// Inference Endpoints do not always expose /info, so failures are not fatal
+	if info, err := s.info(ctx); err == nil {
+		if s.model == "" {
+			s.model = info.ModelID
+		}
+		if s.batchSize <= 0 {
+			s.batchSize = info.MaxClientBatchSize
+		}
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = TEIDefaultBatchSize
+	}
+
+	if s.dimension <= 0 {
+		probe, err := s.embedBatch(ctx, []string{DimensionProbe})
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect embedding dimension: %w", err)
+		}
+		s.dimension = len(probe[0])
+	}
+
+	return s, nil
+}
+
+// Embed generates embeddings for a single text
+func (s *TEIService) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := s.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("no embeddings returned")
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for multiple texts
+func (s *TEIService) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	var allEmbeddings [][]float32
+
+	// Process in batches
+	for i := 0; i < len(texts); i += s.batchSize {
+		end := i + s.batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		embeddings, err := s.embedBatch(ctx, texts[i:end])
+		if err != nil {
+			return nil, err
+		}
+		allEmbeddings = append(allEmbeddings, embeddings...)
+	}
+
+	return allEmbeddings, nil
+}
+
+// embedBatch sends a single /embed request
+func (s *TEIService) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	jsonBody, err := json.Marshal(teiRequest{
+		Inputs:    texts,
+		Truncate:  true,
+		Normalize: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.baseURL+"/embed", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	s.authorize(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to TEI: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("TEI API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var data [][]float64
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(data))
+	}
+
+	// Convert float64 to float32
+	embeddings := make([][]float32, len(data))
+	for i, values := range data {
+		embedding := make([]float32, len(values))
+		for j, v := range values {
+			embedding[j] = float32(v)
+		}
+		embeddings[i] = embedding
+	}
+
+	return embeddings, nil
+}
+
+// info fetches model information from the /info endpoint
+func (s *TEIService) info(ctx context.Context) (*teiInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.baseURL+"/info", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	s.authorize(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to TEI: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TEI info error (status %d)", resp.StatusCode)
+	}
+
+	var info teiInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &info, nil
+}
+
+// authorize adds the bearer token for Inference Endpoints
+func (s *TEIService) authorize(req *http.Request) {
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+}
+
+// Model returns the served model name, if known
+func (s *TEIService) Model() string {
+	return s.model
+}
+
+// Dimension returns the embedding dimension
+func (s *TEIService) Dimension() int {
+	return s.dimension
+}
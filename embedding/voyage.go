@@ -6,7 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
@@ -23,6 +23,11 @@ const (
 	VoyageMaxBatchSize = 128
 	// VoyageMaxRetries is the maximum number of retries
 	VoyageMaxRetries = 5
+
+	// Voyage AI input types, which prepend a retrieval prompt for each side
+	// of retrieval
+	voyageInputDocument = "document"
+	voyageInputQuery    = "query"
 )
 
 // VoyageService implements Service using Voyage AI API
@@ -81,15 +86,8 @@ func NewVoyageService(cfg VoyageConfig) (*VoyageService, error) {
 
 	// Determine dimension based on model
 	dimension := 1024 // default for voyage-3
-	switch model {
-	case "voyage-3", "voyage-code-3":
-		dimension = 1024
-	case "voyage-3-lite":
-		dimension = 512
-	case "voyage-large-2", "voyage-2":
-		dimension = 1536
-	case "voyage-code-2":
-		dimension = 1536
+	if d, ok := voyageDimensions[model]; ok {
+		dimension = d
 	}
 
 	return &VoyageService{
@@ -103,7 +101,7 @@ func NewVoyageService(cfg VoyageConfig) (*VoyageService, error) {
 	}, nil
 }
 
-// Embed generates embeddings for a single text
+// Embed generates a document embedding for a single text
 func (s *VoyageService) Embed(ctx context.Context, text string) ([]float32, error) {
 	embeddings, err := s.EmbedBatch(ctx, []string{text})
 	if err != nil {
@@ -115,8 +113,31 @@ func (s *VoyageService) Embed(ctx context.Context, text string) ([]float32, erro
 	return embeddings[0], nil
 }
 
-// EmbedBatch generates embeddings for multiple texts with retry logic
+// EmbedBatch generates document embeddings for multiple texts
 func (s *VoyageService) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return s.embed(ctx, texts, voyageInputDocument)
+}
+
+// EmbedQuery generates a search query embedding for a single text
+func (s *VoyageService) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := s.EmbedQueryBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("no embeddings returned")
+	}
+	return embeddings[0], nil
+}
+
+// EmbedQueryBatch generates search query embeddings for multiple texts
+func (s *VoyageService) EmbedQueryBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return s.embed(ctx, texts, voyageInputQuery)
+}
+
+// embed generates embeddings of the given input type in batches with retry
+// logic
+func (s *VoyageService) embed(ctx context.Context, texts []string, inputType string) ([][]float32, error) {
 	if len(texts) == 0 {
 		return nil, nil
 	}
@@ -131,7 +152,7 @@ func (s *VoyageService) EmbedBatch(ctx context.Context, texts []string) ([][]flo
 		}
 		batch := texts[i:end]
 
-		embeddings, err := s.embedBatchWithRetry(ctx, batch)
+		embeddings, err := s.embedBatchWithRetry(ctx, batch, inputType)
 		if err != nil {
 			return nil, err
 		}
@@ -141,11 +162,11 @@ func (s *VoyageService) EmbedBatch(ctx context.Context, texts []string) ([][]flo
 	return allEmbeddings, nil
 }
 
-func (s *VoyageService) embedBatchWithRetry(ctx context.Context, texts []string) ([][]float32, error) {
+func (s *VoyageService) embedBatchWithRetry(ctx context.Context, texts []string, inputType string) ([][]float32, error) {
 	reqBody := voyageRequest{
 		Input:     texts,
 		Model:     s.model,
-		InputType: "document",
+		InputType: inputType,
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
@@ -172,7 +193,7 @@ func (s *VoyageService) embedBatchWithRetry(ctx context.Context, texts []string)
 		// Check for rate limit
 		if resp.StatusCode == http.StatusTooManyRequests && retry < VoyageMaxRetries {
 			resp.Body.Close()
-			log.Printf("Voyage AI rate limit hit, retrying in %v (attempt %d/%d)", backoff, retry+1, VoyageMaxRetries)
+			slog.Warn("Voyage AI rate limit hit, retrying", "backoff", backoff, "attempt", retry+1, "max_attempts", VoyageMaxRetries)
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
@@ -217,6 +238,12 @@ func (s *VoyageService) Dimension() int {
 	return s.dimension
 }
 
+// SetDimension sets the embedding dimension, replacing the one assumed for
+// the model
+func (s *VoyageService) SetDimension(dim int) {
+	s.dimension = dim
+}
+
 // isVoyageRateLimitError checks if the error is a rate limit error
 func isVoyageRateLimitError(err error) bool {
 	if err == nil {
@@ -1,38 +1,192 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"dimandocs/chunking"
 	"dimandocs/embedding"
+	"dimandocs/expansion"
+	"dimandocs/llm"
 	"dimandocs/mcp"
+	"dimandocs/rerank"
+	"dimandocs/secrets"
 	"dimandocs/vector"
 )
 
-// EmbeddingManager handles document embedding and vector search
+const (
+	// summaryMaxInput is the maximum number of characters sent for summarization
+	summaryMaxInput = 12000
+
+	// defaultRerankTopK is the default number of search candidates reranked
+	defaultRerankTopK = 30
+
+	// defaultIndexConcurrency is the default number of documents indexed in
+	// parallel
+	defaultIndexConcurrency = 4
+
+	// detectDimensionTimeout bounds the request detecting the embedding
+	// dimension of a model
+	detectDimensionTimeout = 30 * time.Second
+)
+
+// Handling of indexed documents whose files are gone, set by the prune
+// setting of the embeddings section
+const (
+	pruneAuto   = "auto"    // Delete them after scanning the directories
+	pruneDryRun = "dry_run" // Only log them
+	pruneOff    = "off"     // Keep them, e.g. in a database shared by several instances
+)
+
+// EmbeddingManager handles document embedding and vector search. Documents
+// are stored in the index of the embeddings section, or in that of their
+// directory if it has its own embeddings; searches fan out across them.
 type EmbeddingManager struct {
-	store   *vector.SQLiteStore
+	main      *embeddingIndex // Index of the embeddings section
+	chunking  chunking.Options
+	llm       llm.Client // Optional, for document summaries
+	summaries SummariesConfig
+	enabled   bool
+
+	concurrency int // Documents indexed in parallel by IndexDocuments
+
+	reranker   rerank.Reranker     // Optional, reorders search results
+	rerankTopK int                 // Candidates retrieved for the reranker
+	expander   *expansion.Expander // Optional, adds paraphrases of search queries
+
+	chunkingConfig ChunkingConfig // Base for per-directory overrides
+
+	mu          sync.RWMutex                          // Guards the fields below, replaced by ConfigureDirectories
+	dirChunking map[string]chunking.Options           // Per-directory overrides, by source directory
+	dirIndexes  map[string]*embeddingIndex            // Indexes of directories with their own embeddings, by source directory
+	indexDirs   map[*embeddingIndex][]DirectoryConfig // Directories stored in each searched index, nil until configured
+	searched    []*embeddingIndex                     // Indexes of the configured directories, the main one first
+	opened      []*embeddingIndex                     // Every index opened, closed by Close
+}
+
+// embeddingIndex is a vector store with the embedding model its documents
+// are embedded with
+type embeddingIndex struct {
+	cfg     EmbeddingsConfig
+	store   vector.Store
 	embed   embedding.Service
-	enabled bool
+	model   string             // Identifies the embedding model for the embedding cache
+	limiter *embedding.Limiter // Spaces out embedding requests while indexing, nil for no limit
 }
 
 // NewEmbeddingManager creates a new embedding manager
-func NewEmbeddingManager(cfg EmbeddingsConfig) (*EmbeddingManager, error) {
+func NewEmbeddingManager(cfg EmbeddingsConfig, llmCfg LLMConfig, searchCfg SearchConfig) (*EmbeddingManager, error) {
 	if !cfg.Enabled {
 		return &EmbeddingManager{enabled: false}, nil
 	}
 
+	main, err := newEmbeddingIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkOpts, err := newChunkingOptions(cfg.Chunking)
+	if err != nil {
+		main.store.Close()
+		return nil, err
+	}
+
+	manager := &EmbeddingManager{
+		main:      main,
+		chunking:  chunkOpts,
+		summaries: cfg.Summaries,
+		enabled:   true,
+
+		concurrency: cfg.Concurrency,
+
+		chunkingConfig: cfg.Chunking,
+
+		searched: []*embeddingIndex{main},
+		opened:   []*embeddingIndex{main},
+	}
+
+	// Initialize LLM for document summaries
+	if cfg.Summaries.Enabled {
+		client, err := NewLLMClient(llmCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create LLM client for summaries: %w", err)
+		}
+		manager.llm = client
+	}
+
+	if searchCfg.Reranker.Provider != "" {
+		reranker, err := NewReranker(searchCfg.Reranker, llmCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create reranker: %w", err)
+		}
+		manager.reranker = reranker
+		manager.rerankTopK = searchCfg.Reranker.TopK
+	}
+
+	// Initialize LLM for query expansion
+	if searchCfg.Expansion.Enabled {
+		client, err := NewLLMClient(llmCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create LLM client for query expansion: %w", err)
+		}
+		manager.expander = expansion.NewExpander(client, searchCfg.Expansion.Paraphrases)
+	}
+
+	return manager, nil
+}
+
+// newEmbeddingIndex opens the vector store of cfg and creates its embedding
+// service
+func newEmbeddingIndex(cfg EmbeddingsConfig) (*embeddingIndex, error) {
 	// Initialize vector store
-	store := vector.NewSQLiteStore(cfg.DBPath)
+	store, err := NewVectorStore(cfg)
+	if err != nil {
+		return nil, err
+	}
 	if err := store.Initialize(); err != nil {
 		return nil, fmt.Errorf("failed to initialize vector store: %w", err)
 	}
 
-	// Initialize embedding service
+	embedService, err := NewEmbeddingService(cfg)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	// Update vector store dimension based on embedding service
+	resolveDimension(cfg, embedService, store)
+	if err := store.SetDimension(embedService.Dimension()); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to set vector store dimension: %w", err)
+	}
+
+	return &embeddingIndex{
+		cfg:     cfg,
+		store:   store,
+		embed:   embedService,
+		model:   embeddingModelKey(cfg, embedService),
+		limiter: embedding.NewLimiter(cfg.RequestsPerMinute),
+	}, nil
+}
+
+// NewEmbeddingService creates the embedding service of the configured
+// provider
+func NewEmbeddingService(cfg EmbeddingsConfig) (embedding.Service, error) {
 	var embedService embedding.Service
 	var err error
 
@@ -48,7 +202,7 @@ func NewEmbeddingManager(cfg EmbeddingsConfig) (*EmbeddingManager, error) {
 			BaseURL: cfg.BaseURL,
 			Model:   cfg.Model,
 		})
-		log.Printf("Using Ollama embedding service (model: %s, dimension: %d)", cfg.Model, embedService.Dimension())
+		slog.Info("Using Ollama embedding service", "model", cmp.Or(cfg.Model, embedding.DefaultOllamaModel))
 	case "voyage", "voyageai":
 		embedService, err = embedding.NewVoyageService(embedding.VoyageConfig{
 			APIKey:  cfg.APIKey,
@@ -56,7 +210,28 @@ func NewEmbeddingManager(cfg EmbeddingsConfig) (*EmbeddingManager, error) {
 			Model:   cfg.Model,
 		})
 		if err == nil {
-			log.Printf("Using Voyage AI embedding service (model: %s, dimension: %d)", cfg.Model, embedService.Dimension())
+			slog.Info("Using Voyage AI embedding service", "model", cmp.Or(cfg.Model, embedding.DefaultVoyageModel))
+		}
+	case "tei", "huggingface":
+		var tei *embedding.TEIService
+		tei, err = embedding.NewTEIService(embedding.TEIConfig{
+			APIKey:    cfg.APIKey,
+			BaseURL:   cfg.BaseURL,
+			Model:     cfg.Model,
+			Dimension: cfg.Dimension,
+		})
+		if err == nil {
+			embedService = tei
+			slog.Info("Using Text Embeddings Inference service", "model", tei.Model(), "dimension", tei.Dimension())
+		}
+	case "cohere":
+		embedService, err = embedding.NewCohereService(embedding.CohereConfig{
+			APIKey:  cfg.APIKey,
+			BaseURL: cfg.BaseURL,
+			Model:   cfg.Model,
+		})
+		if err == nil {
+			slog.Info("Using Cohere embedding service", "model", cmp.Or(cfg.Model, embedding.DefaultCohereModel))
 		}
 	default:
 		return nil, fmt.Errorf("unsupported embedding provider: %s", cfg.Provider)
@@ -65,23 +240,349 @@ func NewEmbeddingManager(cfg EmbeddingsConfig) (*EmbeddingManager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding service: %w", err)
 	}
+	return embedService, nil
+}
 
-	// Update vector store dimension based on embedding service
-	store.SetDimension(embedService.Dimension())
+// NewReranker creates a search result reranker from configuration. The llm
+// provider grades results with the chat model of the llm section.
+func NewReranker(cfg RerankerConfig, llmCfg LLMConfig) (rerank.Reranker, error) {
+	switch cfg.Provider {
+	case "cohere":
+		return rerank.NewCohereReranker(rerank.CohereConfig{
+			APIKey:  cfg.APIKey,
+			BaseURL: cfg.BaseURL,
+			Model:   cfg.Model,
+		})
+	case "voyage", "voyageai":
+		return rerank.NewVoyageReranker(rerank.VoyageConfig{
+			APIKey:  cfg.APIKey,
+			BaseURL: cfg.BaseURL,
+			Model:   cfg.Model,
+		})
+	case "llm":
+		client, err := NewLLMClient(llmCfg)
+		if err != nil {
+			return nil, err
+		}
+		return rerank.NewLLMReranker(client), nil
+	default:
+		return nil, fmt.Errorf("unsupported reranker provider: %s", cfg.Provider)
+	}
+}
 
-	return &EmbeddingManager{
-		store:   store,
-		embed:   embedService,
-		enabled: true,
-	}, nil
+// newChunkingOptions returns the chunking options for the chunking config
+func newChunkingOptions(cfg ChunkingConfig) (chunking.Options, error) {
+	opts := chunking.DefaultOptions()
+	opts.CodeContext = cfg.CodeContext
+
+	switch cfg.Strategy {
+	case "", chunking.StrategyMarkdown:
+	case chunking.StrategySemantic:
+		opts.Strategy = chunking.StrategySemantic
+		opts.BreakpointPercentile = cfg.Breakpoint
+		slog.Info("Using semantic chunking for large sections")
+	default:
+		return opts, fmt.Errorf("unsupported chunking strategy: %s", cfg.Strategy)
+	}
+
+	if cfg.MaxTokens <= 0 {
+		return opts, nil
+	}
+
+	tokenizer, err := chunking.NewTiktokenTokenizer(cfg.Encoding)
+	if err != nil {
+		return opts, fmt.Errorf("failed to create tokenizer: %w", err)
+	}
+	opts.MaxTokens = cfg.MaxTokens
+	opts.OverlapTokens = cfg.OverlapTokens
+	opts.Tokenizer = tokenizer
+	slog.Info("Chunking by tokens", "max_tokens", cfg.MaxTokens, "overlap_tokens", cfg.OverlapTokens)
+	return opts, nil
+}
+
+// mergeChunkingConfig returns base with the options set in override replacing
+// its own
+func mergeChunkingConfig(base, override ChunkingConfig) ChunkingConfig {
+	if override.MaxTokens > 0 {
+		base.MaxTokens = override.MaxTokens
+	}
+	if override.OverlapTokens > 0 {
+		base.OverlapTokens = override.OverlapTokens
+	}
+	if override.Encoding != "" {
+		base.Encoding = override.Encoding
+	}
+	if override.CodeContext {
+		base.CodeContext = true
+	}
+	if override.Strategy != "" {
+		base.Strategy = override.Strategy
+	}
+	if override.Breakpoint > 0 {
+		base.Breakpoint = override.Breakpoint
+	}
+	return base
+}
+
+// mergeEmbeddingsConfig returns the embeddings config of a directory with its
+// own embeddings: base with the options set in override replacing its own.
+// Another provider does not inherit the model and credentials of base, nor
+// another vector store the connection options.
+func mergeEmbeddingsConfig(base EmbeddingsConfig, override SourceEmbeddingsConfig) EmbeddingsConfig {
+	cfg := base
+	provider := override.Provider != "" && override.Provider != base.Provider
+	if provider {
+		cfg.Provider = override.Provider
+		cfg.Model, cfg.APIKey, cfg.BaseURL, cfg.Dimension = "", "", "", 0
+	}
+	if override.Model != "" {
+		cfg.Model = override.Model
+	}
+	if override.APIKey != "" {
+		cfg.APIKey = override.APIKey
+	}
+	if override.BaseURL != "" {
+		cfg.BaseURL = override.BaseURL
+	}
+	if override.Dimension > 0 {
+		cfg.Dimension = override.Dimension
+	}
+	if provider && cfg.Model == "" && cfg.Provider == "openai" {
+		cfg.Model = "text-embedding-3-large"
+	}
+	if provider && cfg.APIKey == "" {
+		cfg.APIKey = getDefaultAPIKey(cfg.Provider)
+	}
+
+	switch {
+	case override.VectorStore.Type != "" && override.VectorStore.Type != base.VectorStore.Type:
+		cfg.VectorStore = override.VectorStore
+		cfg.DatabaseURL = ""
+	case len(override.VectorStore.Options) > 0:
+		cfg.VectorStore.Options = override.VectorStore.Options
+	}
+	if override.DBPath != "" {
+		// Backups go next to the database, unless the backup directory is set
+		if cfg.Backup.Dir == filepath.Join(filepath.Dir(base.DBPath), "backups") {
+			cfg.Backup.Dir = filepath.Join(filepath.Dir(override.DBPath), "backups")
+		}
+		cfg.DBPath = override.DBPath
+	}
+	if override.DatabaseURL != "" {
+		cfg.DatabaseURL = override.DatabaseURL
+		cfg.VectorStore.Options = maps.Clone(cfg.VectorStore.Options)
+		if cfg.VectorStore.Options == nil {
+			cfg.VectorStore.Options = make(map[string]string)
+		}
+		cfg.VectorStore.Options["url"] = override.DatabaseURL
+	}
+
+	if override.Metric != "" {
+		cfg.Metric = override.Metric
+	}
+	if override.Quantization != "" {
+		cfg.Quantization = override.Quantization
+	}
+	if override.Rescore {
+		cfg.Rescore = true
+	}
+	if override.RequestsPerMinute > 0 {
+		cfg.RequestsPerMinute = override.RequestsPerMinute
+	}
+	return cfg
+}
+
+// ConfigureDirectories sets up the chunking options of the directories that
+// override embeddings.chunking and opens the indexes of the directories with
+// their own embeddings. Directories with the same embeddings share an index.
+// Indexes stay open until Close, so that searches in flight can finish when
+// a reload removes their directory.
+func (m *EmbeddingManager) ConfigureDirectories(dirs []DirectoryConfig) error {
+	if !m.enabled {
+		return nil
+	}
+	dirChunking := make(map[string]chunking.Options)
+	dirIndexes := make(map[string]*embeddingIndex)
+	indexDirs := map[*embeddingIndex][]DirectoryConfig{m.main: nil}
+	searched := []*embeddingIndex{m.main}
+	for _, dirConfig := range dirs {
+		if dirConfig.Chunking != nil {
+			opts, err := newChunkingOptions(mergeChunkingConfig(m.chunkingConfig, *dirConfig.Chunking))
+			if err != nil {
+				return fmt.Errorf("invalid chunking for directory %s: %w", dirConfig.Name, err)
+			}
+			dirChunking[dirConfig.Path] = opts
+		}
+
+		index := m.main
+		if dirConfig.Embeddings != nil {
+			var err error
+			index, err = m.openIndex(mergeEmbeddingsConfig(m.main.cfg, *dirConfig.Embeddings))
+			if err != nil {
+				return fmt.Errorf("failed to open embeddings of directory %s: %w", dirConfig.Name, err)
+			}
+			dirIndexes[dirConfig.Path] = index
+		}
+		if _, ok := indexDirs[index]; !ok {
+			searched = append(searched, index)
+		}
+		indexDirs[index] = append(indexDirs[index], dirConfig)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dirChunking = dirChunking
+	m.dirIndexes = dirIndexes
+	m.indexDirs = indexDirs
+	m.searched = searched
+	return nil
+}
+
+// openIndex returns the open index of cfg, or opens it
+func (m *EmbeddingManager) openIndex(cfg EmbeddingsConfig) (*embeddingIndex, error) {
+	m.mu.RLock()
+	for _, index := range m.opened {
+		if reflect.DeepEqual(index.cfg, cfg) {
+			m.mu.RUnlock()
+			return index, nil
+		}
+	}
+	m.mu.RUnlock()
+
+	index, err := newEmbeddingIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Opened embeddings of a directory", "vector_store", cfg.VectorStore.Type, "db_path", cfg.DBPath, "model", index.model)
+	m.mu.Lock()
+	m.opened = append(m.opened, index)
+	m.mu.Unlock()
+	return index, nil
+}
+
+// chunkingOptions returns the chunking options for a document
+func (m *EmbeddingManager) chunkingOptions(doc Document) chunking.Options {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if opts, ok := m.dirChunking[doc.SourceDir]; ok {
+		return opts
+	}
+	return m.chunking
+}
+
+// index returns the index a document is stored in
+func (m *EmbeddingManager) index(doc Document) *embeddingIndex {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if index, ok := m.dirIndexes[doc.SourceDir]; ok {
+		return index
+	}
+	return m.main
+}
+
+// indexes returns the index of the embeddings section and those of the
+// configured directories
+func (m *EmbeddingManager) indexes() []*embeddingIndex {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.searched)
+}
+
+// searchedIndexes returns the indexes that may hold chunks matching filter:
+// those storing a directory of the filter's source and collections
+func (m *EmbeddingManager) searchedIndexes(filter vector.Filter) []*embeddingIndex {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.indexDirs == nil {
+		return []*embeddingIndex{m.main}
+	}
+	var indexes []*embeddingIndex
+	for _, index := range m.searched {
+		if slices.ContainsFunc(m.indexDirs[index], func(d DirectoryConfig) bool {
+			return (filter.Source == "" || d.Name == filter.Source) &&
+				(len(filter.Collections) == 0 || slices.Contains(filter.Collections, d.Collection))
+		}) {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// SourceIndex describes the index of directories with their own embeddings
+type SourceIndex struct {
+	Directories []string // Names of the directories stored in the index
+	Config      EmbeddingsConfig
+	Store       vector.Store
+}
+
+// SourceIndexes returns the indexes of the configured directories with their
+// own embeddings
+func (m *EmbeddingManager) SourceIndexes() []SourceIndex {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var indexes []SourceIndex
+	for _, index := range m.searched {
+		if index == m.main {
+			continue
+		}
+		source := SourceIndex{Config: index.cfg, Store: index.store}
+		for _, dirConfig := range m.indexDirs[index] {
+			source.Directories = append(source.Directories, dirConfig.Name)
+		}
+		indexes = append(indexes, source)
+	}
+	return indexes
+}
+
+// NewVectorStore creates the configured vector store backend
+func NewVectorStore(cfg EmbeddingsConfig) (vector.Store, error) {
+	if cfg.VectorStore.Type != "sqlite" && cfg.Quantization != vector.QuantizationFloat {
+		slog.Warn("Quantization is only supported by the SQLite vector store, storing floats", "quantization", cfg.Quantization)
+	}
+	return vector.Open(vectorStoreConfig(cfg))
+}
+
+// vectorStoreConfig returns the config of the vector store backend
+func vectorStoreConfig(cfg EmbeddingsConfig) vector.Config {
+	return vector.Config{
+		Type:         cfg.VectorStore.Type,
+		DBPath:       cfg.DBPath,
+		Metric:       cfg.Metric,
+		Quantization: cfg.Quantization,
+		Rescore:      cfg.Rescore,
+		Options:      cfg.VectorStore.Options,
+	}
+}
+
+// NewLLMClient creates a chat model client from configuration
+func NewLLMClient(cfg LLMConfig) (llm.Client, error) {
+	switch cfg.Provider {
+	case "openai", "":
+		return llm.NewOpenAIClient(llm.OpenAIConfig{
+			APIKey:  cfg.APIKey,
+			Model:   cfg.Model,
+			BaseURL: cfg.BaseURL,
+		})
+	case "ollama":
+		return llm.NewOllamaClient(llm.OllamaConfig{
+			BaseURL: cfg.BaseURL,
+			Model:   cfg.Model,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider: %s", cfg.Provider)
+	}
 }
 
 // Close closes the embedding manager
 func (m *EmbeddingManager) Close() error {
-	if m.store != nil {
-		return m.store.Close()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for _, index := range m.opened {
+		errs = append(errs, index.store.Close())
 	}
-	return nil
+	m.opened = nil
+	return errors.Join(errs...)
 }
 
 // IsEnabled returns whether embedding is enabled
@@ -89,61 +590,209 @@ func (m *EmbeddingManager) IsEnabled() bool {
 	return m.enabled
 }
 
+// IndexStats reports what indexing a document did
+type IndexStats struct {
+	Skipped   bool          // Up to date, nothing was re-indexed
+	Chunks    int           // Chunks stored, including the summary chunk
+	Tokens    int           // Tokens of the embedded chunk texts
+	Embedded  int           // Chunk texts sent to the embedding service; the rest came from the cache
+	EmbedTime time.Duration // Time spent generating embeddings
+}
+
 // IndexDocument indexes a document by chunking and embedding
 // If force is true, re-index even if the document hasn't changed
 func (m *EmbeddingManager) IndexDocument(ctx context.Context, doc Document, force bool) error {
+	_, err := m.IndexDocumentStats(ctx, doc, force)
+	return err
+}
+
+// IndexDocuments indexes documents with the configured number of workers,
+// calling report once per document as it completes. Calls to report are
+// serialized. Documents not started when ctx is cancelled are skipped and
+// not reported; it returns how many documents were processed.
+func (m *EmbeddingManager) IndexDocuments(ctx context.Context, docs []Document, force bool, report func(doc Document, stats IndexStats, err error)) int {
+	docs = m.resumeOrder(docs)
+	jobs := make(chan Document)
+	var reportMu sync.Mutex
+	var wg sync.WaitGroup
+	processed := 0
+
+	for range min(max(m.concurrency, 1), max(len(docs), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for doc := range jobs {
+				stats, err := m.IndexDocumentStats(ctx, doc, force)
+				reportMu.Lock()
+				processed++
+				if report != nil {
+					report(doc, stats, err)
+				}
+				reportMu.Unlock()
+			}
+		}()
+	}
+
+	for _, doc := range docs {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- doc:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return processed
+}
+
+// resumeOrder returns docs with the documents left pending or failed by an
+// earlier run first, so indexing that was interrupted resumes where it
+// stopped and failures are retried before unchanged documents are checked
+func (m *EmbeddingManager) resumeOrder(docs []Document) []Document {
 	if !m.enabled {
-		return nil
+		return docs
+	}
+	records, err := m.ListDocuments()
+	if err != nil {
+		slog.Warn("Failed to read indexing status", "error", err)
+		return docs
+	}
+	unfinished := make(map[string]bool)
+	for _, record := range records {
+		if record.Status != vector.StatusEmbedded {
+			unfinished[record.Path] = true
+		}
+	}
+	if len(unfinished) == 0 {
+		return docs
 	}
 
-	// Calculate content hash
-	hash := sha256.Sum256([]byte(doc.Content))
-	contentHash := hex.EncodeToString(hash[:])
+	ordered := make([]Document, 0, len(docs))
+	for _, doc := range docs {
+		if unfinished[doc.RelPath] {
+			ordered = append(ordered, doc)
+		}
+	}
+	for _, doc := range docs {
+		if !unfinished[doc.RelPath] {
+			ordered = append(ordered, doc)
+		}
+	}
+	return ordered
+}
+
+// IndexDocumentStats indexes a document like IndexDocument and reports what
+// was done
+func (m *EmbeddingManager) IndexDocumentStats(ctx context.Context, doc Document, force bool) (IndexStats, error) {
+	var stats IndexStats
+	if !m.enabled {
+		return stats, nil
+	}
+
+	// Calculate the hash of the content and its chunking options
+	hash := m.indexHash(doc)
+	index := m.index(doc)
 
 	// Check if document needs update (unless force is set)
 	if !force {
-		needsUpdate, err := m.store.NeedsUpdate(doc.RelPath, contentHash)
+		needsUpdate, err := index.store.NeedsUpdate(doc.RelPath, hash)
 		if err != nil {
-			return fmt.Errorf("failed to check if document needs update: %w", err)
+			return stats, fmt.Errorf("failed to check if document needs update: %w", err)
 		}
 
 		if !needsUpdate {
-			log.Printf("Document %s is up to date, skipping", doc.RelPath)
-			return nil
+			slog.Debug("Document is up to date, skipping", "path", doc.RelPath)
+			// Source, collection and tags live outside the hash
+			if record, err := index.store.GetDocument(doc.RelPath); err == nil && record != nil {
+				index.setMetadata(record.ID, doc)
+			}
+			stats.Skipped = true
+			return stats, nil
 		}
 	}
 
-	log.Printf("Indexing document: %s", doc.RelPath)
+	slog.Info("Indexing document", "path", doc.RelPath)
 
-	// Upsert document record
-	docID, err := m.store.UpsertDocument(doc.RelPath, doc.Title, contentHash)
+	// Upsert document record. The content hash is only recorded once the
+	// chunks are stored, so the document stays pending until then and
+	// indexing that is interrupted in between is redone.
+	docID, err := index.store.UpsertDocument(doc.RelPath, doc.Title, "")
 	if err != nil {
-		return fmt.Errorf("failed to upsert document: %w", err)
+		return stats, fmt.Errorf("failed to upsert document: %w", err)
 	}
+	index.setMetadata(docID, doc)
+
+	stats, err = m.embedDocument(ctx, index, doc, docID, hash)
+	if err != nil && ctx.Err() == nil {
+		// Interrupted documents stay pending rather than failed
+		if err := index.store.SetDocumentStatus(docID, vector.StatusFailed, err.Error()); err != nil {
+			slog.Warn("Failed to record indexing failure", "path", doc.RelPath, "error", err)
+		}
+	}
+	return stats, err
+}
+
+// embedDocument chunks and embeds a document and stores its chunks in index
+// under the record docID, recording hash once they are stored
+func (m *EmbeddingManager) embedDocument(ctx context.Context, index *embeddingIndex, doc Document, docID int64, hash string) (IndexStats, error) {
+	var stats IndexStats
 
 	// Chunk the document
-	chunks := chunking.ChunkMarkdown(doc.Content, chunking.DefaultOptions())
+	opts := m.chunkingOptions(doc)
+	if opts.Strategy == chunking.StrategySemantic {
+		opts.Embedder = func(sentences []string) ([][]float32, error) {
+			embeddings, _, err := index.embedChunks(ctx, sentences)
+			if err != nil {
+				slog.Warn("Semantic chunking failed, splitting on paragraphs", "path", doc.RelPath, "error", err)
+			}
+			return embeddings, err
+		}
+	}
+	var chunks []chunking.Chunk
+	if doc.Language != "" {
+		chunks = chunking.ChunkCode(doc.Content(), doc.Language, opts)
+	} else {
+		chunks = chunking.ChunkMarkdown(doc.Content(), opts)
+	}
 	if len(chunks) == 0 {
-		log.Printf("No chunks generated for document %s", doc.RelPath)
-		return nil
+		slog.Info("No chunks generated for document", "path", doc.RelPath)
+		return stats, index.recordHash(doc, hash)
 	}
 
 	// Collect chunk texts for batch embedding
 	chunkTexts := make([]string, len(chunks))
 	for i, chunk := range chunks {
-		// Prepend document title and section for better context
+		// Prepend document title and heading hierarchy for better context
 		contextText := doc.Title
-		if chunk.SectionTitle != "" {
-			contextText += " - " + chunk.SectionTitle
+		if section := sectionPath(doc.Title, chunk); section != "" {
+			contextText += " - " + section
 		}
 		contextText += "\n\n" + chunk.Text
 		chunkTexts[i] = contextText
 	}
 
-	// Generate embeddings in batch
-	embeddings, err := m.embed.EmbedBatch(ctx, chunkTexts)
+	// Add the document summary as an extra chunk for coarse retrieval
+	if summary := m.documentSummary(ctx, index, doc, documentHash(doc)); summary != "" {
+		chunks = append(chunks, chunking.Chunk{
+			Index:        vector.SummaryChunkIndex,
+			Text:         summary,
+			SectionTitle: "Summary",
+		})
+		chunkTexts = append(chunkTexts, doc.Title+" - Summary\n\n"+summary)
+	}
+
+	// Generate embeddings in batch, reusing cached vectors for unchanged text
+	start := time.Now()
+	embeddings, embedded, err := index.embedChunks(ctx, chunkTexts)
+	stats.EmbedTime = time.Since(start)
 	if err != nil {
-		return fmt.Errorf("failed to generate embeddings: %w", err)
+		return stats, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+	stats.Embedded = embedded
+	for _, text := range chunkTexts {
+		stats.Tokens += countTokens(opts.Tokenizer, text)
 	}
 
 	// Create vector chunks
@@ -154,33 +803,410 @@ func (m *EmbeddingManager) IndexDocument(ctx context.Context, doc Document, forc
 			ChunkIndex:   chunk.Index,
 			ChunkText:    chunk.Text,
 			SectionTitle: chunk.SectionTitle,
+			Breadcrumb:   chunk.Breadcrumb,
 			Embedding:    embeddings[i],
 		}
 	}
 
 	// Insert chunks
-	if err := m.store.InsertChunks(docID, vectorChunks); err != nil {
-		return fmt.Errorf("failed to insert chunks: %w", err)
+	if err := index.store.InsertChunks(docID, vectorChunks); err != nil {
+		return stats, fmt.Errorf("failed to insert chunks: %w", err)
+	}
+	if err := index.recordHash(doc, hash); err != nil {
+		return stats, err
+	}
+	stats.Chunks = len(chunks)
+
+	slog.Info("Indexed document", "path", doc.RelPath, "chunks", len(chunks))
+	return stats, nil
+}
+
+// recordHash marks a document as indexed at the given hash
+func (idx *embeddingIndex) recordHash(doc Document, hash string) error {
+	if _, err := idx.store.UpsertDocument(doc.RelPath, doc.Title, hash); err != nil {
+		return fmt.Errorf("failed to upsert document: %w", err)
 	}
+	return nil
+}
+
+// countTokens counts the tokens of text with the chunking tokenizer, or
+// estimates them when chunking by characters
+func countTokens(tokenizer chunking.Tokenizer, text string) int {
+	if tokenizer != nil {
+		return tokenizer.Count(text)
+	}
+	return chunking.EstimateTokens(text)
+}
 
-	log.Printf("Indexed %d chunks for document %s", len(chunks), doc.RelPath)
+// DeleteDocument removes a document and its chunks from the indexes
+func (m *EmbeddingManager) DeleteDocument(relPath string) error {
+	if !m.enabled {
+		return nil
+	}
+	for _, index := range m.indexes() {
+		if err := index.store.DeleteDocument(relPath); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Search performs semantic search
-func (m *EmbeddingManager) Search(ctx context.Context, query string, limit int) ([]vector.SearchResult, error) {
+// ListDocuments returns the document records of all indexes ordered by path
+func (m *EmbeddingManager) ListDocuments() ([]vector.DocumentRecord, error) {
+	var records []vector.DocumentRecord
+	for _, index := range m.indexes() {
+		indexRecords, err := index.store.ListDocuments()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, indexRecords...)
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Path < records[j].Path })
+	return records, nil
+}
+
+// PruneDocuments deletes the indexed documents that are not among docs, such
+// as files deleted or renamed while the server was not running, and returns
+// their paths. Documents are also deleted from indexes other than the one
+// of their directory, e.g. after it got its own embeddings. With dryRun, the
+// stale documents are only reported.
+func (m *EmbeddingManager) PruneDocuments(docs []Document, dryRun bool) ([]string, error) {
+	if !m.enabled {
+		return nil, nil
+	}
+
+	current := make(map[*embeddingIndex]map[string]bool)
+	for _, doc := range docs {
+		index := m.index(doc)
+		if current[index] == nil {
+			current[index] = make(map[string]bool)
+		}
+		current[index][doc.RelPath] = true
+	}
+
+	var stale []string
+	for _, index := range m.indexes() {
+		records, err := index.store.ListDocuments()
+		if err != nil {
+			return stale, err
+		}
+		for _, record := range records {
+			if current[index][record.Path] {
+				continue
+			}
+			if !dryRun {
+				if err := index.store.DeleteDocument(record.Path); err != nil {
+					return stale, fmt.Errorf("failed to delete stale document %s: %w", record.Path, err)
+				}
+			}
+			stale = append(stale, record.Path)
+		}
+	}
+	return stale, nil
+}
+
+// setMetadata stores the source, collection and tags of a document for
+// filtered search
+func (idx *embeddingIndex) setMetadata(docID int64, doc Document) {
+	if err := idx.store.SetDocumentSource(docID, doc.SourceName, doc.Collection); err != nil {
+		slog.Warn("Failed to store source", "path", doc.RelPath, "error", err)
+	}
+	tagStore, ok := idx.store.(vector.TagStore)
+	if !ok {
+		return
+	}
+	if err := tagStore.SetDocumentTags(docID, doc.Tags); err != nil {
+		slog.Warn("Failed to store tags", "path", doc.RelPath, "error", err)
+	}
+}
+
+// sectionPath returns the heading hierarchy of a chunk without a leading
+// heading that repeats the document title
+func sectionPath(title string, chunk chunking.Chunk) string {
+	if chunk.Breadcrumb == "" {
+		return chunk.SectionTitle
+	}
+	if rest, ok := strings.CutPrefix(chunk.Breadcrumb, title+chunking.BreadcrumbSeparator); ok {
+		return rest
+	}
+	return chunk.Breadcrumb
+}
+
+// embedBatch sends texts to the embedding service within the rate limit and
+// checks that it returned one embedding of the index dimension per text
+func (idx *embeddingIndex) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := idx.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	embeddings, err := idx.embed.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embeddings))
+	}
+	if err := idx.checkDimension(embeddings); err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// embedChunks embeds chunk texts, looking them up in the embedding cache
+// first so only new or changed text is sent to the embedding service. It
+// also returns how many texts were sent.
+func (idx *embeddingIndex) embedChunks(ctx context.Context, texts []string) ([][]float32, int, error) {
+	cache, ok := idx.store.(vector.EmbeddingCache)
+	if !ok {
+		embeddings, err := idx.embedBatch(ctx, texts)
+		if err != nil {
+			return nil, 0, err
+		}
+		return embeddings, len(texts), nil
+	}
+
+	hashes := make([]string, len(texts))
+	for i, text := range texts {
+		hashes[i] = textHash(text)
+	}
+
+	cached, err := cache.GetCachedEmbeddings(idx.model, hashes)
+	if err != nil {
+		slog.Warn("Failed to read embedding cache", "error", err)
+		cached = nil
+	}
+
+	// Embed only the texts missing from the cache
+	var missing []string
+	var missingIdx []int
+	for i, hash := range hashes {
+		if _, ok := cached[hash]; !ok {
+			missing = append(missing, texts[i])
+			missingIdx = append(missingIdx, i)
+		}
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for i, hash := range hashes {
+		embeddings[i] = cached[hash]
+	}
+	if len(missing) == 0 {
+		slog.Debug("Reused cached embeddings", "reused", len(texts))
+		return embeddings, 0, nil
+	}
+
+	computed, err := idx.embedBatch(ctx, missing)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fresh := make(map[string][]float32, len(computed))
+	for j, i := range missingIdx {
+		embeddings[i] = computed[j]
+		fresh[hashes[i]] = computed[j]
+	}
+	if err := cache.CacheEmbeddings(idx.model, fresh); err != nil {
+		slog.Warn("Failed to update embedding cache", "error", err)
+	}
+	if reused := len(texts) - len(missing); reused > 0 {
+		slog.Debug("Reused cached embeddings", "reused", reused, "computed", len(missing))
+	}
+
+	return embeddings, len(missing), nil
+}
+
+// embeddingModelKey identifies the model producing embeddings, so cached
+// vectors are only reused for the same provider, model and dimension
+func embeddingModelKey(cfg EmbeddingsConfig, service embedding.Service) string {
+	return fmt.Sprintf("%s:%d", embeddingModelName(cfg, service), service.Dimension())
+}
+
+// embeddingModelName identifies the model producing embeddings by provider
+// and model name
+func embeddingModelName(cfg EmbeddingsConfig, service embedding.Service) string {
+	model := cfg.Model
+	if named, ok := service.(interface{ Model() string }); ok && named.Model() != "" {
+		model = named.Model()
+	}
+	return cfg.Provider + ":" + model
+}
+
+// resolveDimension sets the dimension of services that assume the
+// dimension of known models: the configured dimension if set, otherwise the
+// one detected by embedding a probe text on first use of the model, which
+// is kept in the vector store so later starts need no request. If detection
+// fails, the assumed dimension is kept.
+func resolveDimension(cfg EmbeddingsConfig, service embedding.Service, store vector.Store) {
+	setter, ok := service.(embedding.DimensionSetter)
+	if !ok {
+		return
+	}
+	if cfg.Dimension > 0 {
+		setter.SetDimension(cfg.Dimension)
+		return
+	}
+
+	model := embeddingModelName(cfg, service)
+	key := "model_dimension:" + model
+	metadata, canStore := store.(vector.MetadataStore)
+	if canStore {
+		value, ok, err := metadata.GetMetadata(key)
+		if err != nil {
+			slog.Warn("Failed to read detected embedding dimension", "error", err)
+		}
+		if dim, err := strconv.Atoi(value); ok && err == nil && dim > 0 {
+			setter.SetDimension(dim)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), detectDimensionTimeout)
+	defer cancel()
+	dim, err := embedding.DetectDimension(ctx, service)
+	if err != nil {
+		slog.Warn("Failed to detect embedding dimension, assuming the model's usual one", "model", model, "dimension", service.Dimension(), "error", err)
+		return
+	}
+	setter.SetDimension(dim)
+	slog.Info("Detected embedding dimension", "model", model, "dimension", dim)
+	if canStore {
+		if err := metadata.SetMetadata(key, strconv.Itoa(dim)); err != nil {
+			slog.Warn("Failed to store detected embedding dimension", "error", err)
+		}
+	}
+}
+
+// checkDimension returns an error if the model returned embeddings of
+// another dimension than the index was built with, e.g. after the model
+// was updated under the same name
+func (idx *embeddingIndex) checkDimension(embeddings [][]float32) error {
+	want := idx.embed.Dimension()
+	for _, emb := range embeddings {
+		if len(emb) != want {
+			return fmt.Errorf("embedding dimension changed: the model returned %d dimensions but the index has %d; set embeddings.dimension to %d to rebuild the index", len(emb), want, len(emb))
+		}
+	}
+	return nil
+}
+
+// textHash returns the cache key of an embedded text
+func textHash(text string) string {
+	hash := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
+
+// documentSummary returns the summary for a document cached in index or
+// generates a new one with the configured LLM. Failures are logged and yield
+// no summary.
+func (m *EmbeddingManager) documentSummary(ctx context.Context, index *embeddingIndex, doc Document, contentHash string) string {
+	if m.llm == nil {
+		return ""
+	}
+
+	cache, canCache := index.store.(vector.SummaryStore)
+	var summary string
+	var ok bool
+	var err error
+	if canCache {
+		summary, ok, err = cache.GetSummary(contentHash)
+	}
+	if err != nil {
+		slog.Warn("Failed to read cached summary", "path", doc.RelPath, "error", err)
+	}
+	if ok {
+		return summary
+	}
+
+	content := doc.Content()
+	if len(content) > summaryMaxInput {
+		// Cut on a rune boundary
+		cut := summaryMaxInput
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		content = content[:cut]
+	}
+
+	summary, err = m.llm.Complete(ctx, []llm.Message{
+		{
+			Role:    llm.RoleSystem,
+			Content: "You write concise summaries of technical documentation. Describe what the document covers and who it is for. Reply with the summary only.",
+		},
+		{
+			Role:    llm.RoleUser,
+			Content: fmt.Sprintf("Summarize the following document in at most %d words.\n\nTitle: %s\n\n%s", m.summaries.MaxWords, doc.Title, content),
+		},
+	})
+	if err != nil {
+		slog.Warn("Failed to summarize document", "path", doc.RelPath, "error", err)
+		return ""
+	}
+
+	summary = strings.TrimSpace(summary)
+	if canCache {
+		if err := cache.SaveSummary(contentHash, summary); err != nil {
+			slog.Warn("Failed to cache summary", "path", doc.RelPath, "error", err)
+		}
+	}
+
+	return summary
+}
+
+// Summary returns the cached summary of a document, if one has been generated
+func (m *EmbeddingManager) Summary(doc Document) string {
+	if !m.enabled || m.llm == nil {
+		return ""
+	}
+	cache, ok := m.index(doc).store.(vector.SummaryStore)
+	if !ok {
+		return ""
+	}
+
+	summary, _, err := cache.GetSummary(documentHash(doc))
+	if err != nil {
+		slog.Warn("Failed to read summary", "path", doc.RelPath, "error", err)
+	}
+	return summary
+}
+
+// documentHash returns the content hash used to detect document changes
+func documentHash(doc Document) string {
+	// Source files are chunked differently, so the language is part of the hash
+	if doc.Language != "" {
+		return textHash(doc.Language + "\x00" + doc.Content())
+	}
+	return textHash(doc.Content())
+}
+
+// indexHash returns the hash recorded for an indexed document. It also covers
+// the document's chunking options, so changing them reindexes the document.
+func (m *EmbeddingManager) indexHash(doc Document) string {
+	return textHash(m.chunkingOptions(doc).Fingerprint() + "\x00" + documentHash(doc))
+}
+
+// Search performs semantic search over the chunks matching filter
+func (m *EmbeddingManager) Search(ctx context.Context, query string, limit int, filter vector.Filter) ([]vector.SearchResult, error) {
 	if !m.enabled {
 		return nil, fmt.Errorf("embeddings not enabled")
 	}
+	return fanOut(m.searchedIndexes(filter), limit, func(index *embeddingIndex) ([]vector.SearchResult, error) {
+		return index.search(ctx, query, limit, filter)
+	})
+}
 
+// search performs semantic search over the chunks of the index matching
+// filter
+func (idx *embeddingIndex) search(ctx context.Context, query string, limit int, filter vector.Filter) ([]vector.SearchResult, error) {
 	// Generate query embedding
-	queryEmbedding, err := m.embed.Embed(ctx, query)
+	queryEmbedding, err := embedding.EmbedQuery(ctx, idx.embed, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
+	if err := idx.checkDimension([][]float32{queryEmbedding}); err != nil {
+		return nil, err
+	}
 
 	// Search
-	results, err := m.store.Search(queryEmbedding, limit)
+	results, err := idx.store.Search(queryEmbedding, limit, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
@@ -188,19 +1214,199 @@ func (m *EmbeddingManager) Search(ctx context.Context, query string, limit int)
 	return results, nil
 }
 
-// GetVectorStore returns the vector store
+// MultiSearch embeds several queries, searches for each of them concurrently
+// and fuses the results with reciprocal rank fusion. Only chunks matching
+// filter are searched.
+func (m *EmbeddingManager) MultiSearch(ctx context.Context, queries []string, filter vector.Filter, limit int) ([]vector.SearchResult, error) {
+	if !m.enabled {
+		return nil, fmt.Errorf("embeddings not enabled")
+	}
+	depth := m.rerankDepth(limit)
+	expanded := m.expand(ctx, queries)
+	results, err := fanOut(m.searchedIndexes(filter), depth, func(index *embeddingIndex) ([]vector.SearchResult, error) {
+		return index.multiSearch(ctx, expanded, depth, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.rerank(ctx, queries, results, limit), nil
+}
+
+// multiSearch embeds the queries and searches the index for each of them,
+// fusing the results
+func (idx *embeddingIndex) multiSearch(ctx context.Context, queries []string, limit int, filter vector.Filter) ([]vector.SearchResult, error) {
+	if len(queries) == 1 {
+		return idx.search(ctx, queries[0], limit, filter)
+	}
+
+	// Generate query embeddings in one batch
+	queryEmbeddings, err := embedding.EmbedQueryBatch(ctx, idx.embed, queries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embeddings: %w", err)
+	}
+	if err := idx.checkDimension(queryEmbeddings); err != nil {
+		return nil, err
+	}
+	return vector.MultiSearch(idx.store, queryEmbeddings, limit, filter)
+}
+
+// HybridSearch embeds the queries and fuses similarity search with BM25
+// keyword search across all of them. Only chunks matching filter are
+// searched.
+func (m *EmbeddingManager) HybridSearch(ctx context.Context, queries []string, filter vector.Filter, limit int) ([]vector.SearchResult, error) {
+	if !m.enabled {
+		return nil, fmt.Errorf("embeddings not enabled")
+	}
+
+	depth := m.rerankDepth(limit)
+	expanded := m.expand(ctx, queries)
+	results, err := fanOut(m.searchedIndexes(filter), depth, func(index *embeddingIndex) ([]vector.SearchResult, error) {
+		queryEmbeddings, err := embedding.EmbedQueryBatch(ctx, index.embed, expanded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate query embeddings: %w", err)
+		}
+		if err := index.checkDimension(queryEmbeddings); err != nil {
+			return nil, err
+		}
+		return vector.HybridMultiSearch(index.store, expanded, queryEmbeddings, depth, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.rerank(ctx, queries, results, limit), nil
+}
+
+// fanOut runs search on the indexes concurrently and merges their results.
+// Scores of indexes with different models are not comparable, so results
+// are merged by their rank.
+func fanOut(indexes []*embeddingIndex, limit int, search func(index *embeddingIndex) ([]vector.SearchResult, error)) ([]vector.SearchResult, error) {
+	if len(indexes) == 1 {
+		return search(indexes[0])
+	}
+
+	lists := make([][]vector.SearchResult, len(indexes))
+	errs := make([]error, len(indexes))
+	var wg sync.WaitGroup
+	for i, index := range indexes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lists[i], errs[i] = search(index)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return vector.FuseDisjoint(lists, limit), nil
+}
+
+// expand adds paraphrases of a single query, if query expansion is
+// configured. Several queries are already variants and are kept as they are,
+// as is the query if expansion fails.
+func (m *EmbeddingManager) expand(ctx context.Context, queries []string) []string {
+	if m.expander == nil || len(queries) != 1 {
+		return queries
+	}
+	expanded, err := m.expander.Expand(ctx, queries[0])
+	if err != nil {
+		slog.Warn("Failed to expand search query", "query", queries[0], "error", err)
+		return queries
+	}
+	slog.Debug("Expanded search query", "query", queries[0], "paraphrases", expanded[1:])
+	return expanded
+}
+
+// rerankDepth returns the number of candidates to retrieve for a search
+// returning limit results
+func (m *EmbeddingManager) rerankDepth(limit int) int {
+	if m.reranker == nil {
+		return limit
+	}
+	return max(limit, m.rerankTopK)
+}
+
+// rerank reorders search candidates with the reranker, if configured, and
+// returns the limit best. If reranking fails, the retrieval order is kept.
+func (m *EmbeddingManager) rerank(ctx context.Context, queries []string, results []vector.SearchResult, limit int) []vector.SearchResult {
+	if m.reranker != nil {
+		reranked, err := rerank.Results(ctx, m.reranker, strings.Join(queries, "\n"), results, limit)
+		if err == nil {
+			return reranked
+		}
+		slog.Warn("Failed to rerank search results, keeping retrieval order", "error", err)
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Reranker returns the search result reranker and the number of candidates
+// it reranks, or nil if reranking is disabled
+func (m *EmbeddingManager) Reranker() (rerank.Reranker, int) {
+	return m.reranker, m.rerankTopK
+}
+
+// KeywordSearch performs BM25 keyword search over the chunks. Scores are
+// from 0 to 1, higher is better. Only chunks matching filter are searched.
+func (m *EmbeddingManager) KeywordSearch(ctx context.Context, query string, filter vector.Filter, limit int) ([]vector.SearchResult, error) {
+	if !m.enabled {
+		return nil, fmt.Errorf("embeddings not enabled")
+	}
+
+	// Indexes without full-text search are skipped, unless none has it
+	indexes := m.searchedIndexes(filter)
+	var keywordIndexes []*embeddingIndex
+	for _, index := range indexes {
+		if _, ok := index.store.(vector.KeywordStore); ok {
+			keywordIndexes = append(keywordIndexes, index)
+		}
+	}
+	if len(keywordIndexes) == 0 && len(indexes) > 0 {
+		return nil, vector.ErrFullTextUnavailable
+	}
+	return fanOut(keywordIndexes, limit, func(index *embeddingIndex) ([]vector.SearchResult, error) {
+		return index.store.(vector.KeywordStore).KeywordSearch(query, limit, filter)
+	})
+}
+
+// RelatedDocuments returns the documents most similar to doc in the index it
+// is stored in, see vector.RelatedDocuments
+func (m *EmbeddingManager) RelatedDocuments(doc Document, limit int) ([]vector.SearchResult, error) {
+	if !m.enabled {
+		return nil, fmt.Errorf("embeddings not enabled")
+	}
+	return vector.RelatedDocuments(m.index(doc).store, doc.RelPath, limit)
+}
+
+// GetVectorStore returns the vector store of the embeddings section
 func (m *EmbeddingManager) GetVectorStore() vector.Store {
-	return m.store
+	if m.main == nil {
+		return nil
+	}
+	return m.main.store
+}
+
+// Expander returns the search query expander, or nil if query expansion is
+// disabled
+func (m *EmbeddingManager) Expander() *expansion.Expander {
+	return m.expander
 }
 
-// GetEmbedService returns the embedding service
+// GetEmbedService returns the embedding service of the embeddings section
 func (m *EmbeddingManager) GetEmbedService() embedding.Service {
-	return m.embed
+	if m.main == nil {
+		return nil
+	}
+	return m.main.embed
 }
 
 // AppDocumentProvider implements mcp.DocumentProvider for App
 type AppDocumentProvider struct {
-	app *App
+	app       *App
+	reindexMu sync.Mutex // Allows one reindex at a time
 }
 
 // NewAppDocumentProvider creates a new document provider for the app
@@ -210,25 +1416,96 @@ func NewAppDocumentProvider(app *App) *AppDocumentProvider {
 
 // GetDocuments returns all documents
 func (p *AppDocumentProvider) GetDocuments() []mcp.DocumentInfo {
-	docs := make([]mcp.DocumentInfo, len(p.app.Documents))
-	for i, d := range p.app.Documents {
-		docs[i] = mcp.DocumentInfo{
-			Title:      d.Title,
-			Path:       d.Path,
-			RelPath:    d.RelPath,
-			SourceName: d.SourceName,
-			Overview:   d.Overview,
-		}
+	documents := p.app.GetDocuments()
+	docs := make([]mcp.DocumentInfo, len(documents))
+	for i, d := range documents {
+		docs[i] = toDocumentInfo(d)
 	}
 	return docs
 }
 
+// Reindex rescans the documents at or below path and re-embeds the changed
+// ones, or all of them if force is set
+func (p *AppDocumentProvider) Reindex(ctx context.Context, path string, force bool) (mcp.ReindexResult, error) {
+	if !p.reindexMu.TryLock() {
+		return mcp.ReindexResult{}, fmt.Errorf("a reindex is already running")
+	}
+	defer p.reindexMu.Unlock()
+
+	stats, err := p.app.Reindex(ctx, path, force)
+	if err != nil {
+		return mcp.ReindexResult{}, err
+	}
+	return mcp.ReindexResult{Documents: stats.Documents, Removed: stats.Removed, Failed: stats.Failed}, nil
+}
+
+// GetBacklinks returns the documents linking to the document at path
+func (p *AppDocumentProvider) GetBacklinks(path string) ([]mcp.DocumentInfo, error) {
+	if p.app.findDocument(path) == nil {
+		return nil, fmt.Errorf("document not found: %s", path)
+	}
+
+	var docs []mcp.DocumentInfo
+	for _, d := range p.app.GetBacklinks(path) {
+		docs = append(docs, toDocumentInfo(d))
+	}
+	return docs, nil
+}
+
+// Search runs the queries across the databases of the embeddings section and
+// of directories with their own embeddings
+func (p *AppDocumentProvider) Search(ctx context.Context, queries []string, filter vector.Filter, limit int, vectorOnly bool) ([]vector.SearchResult, error) {
+	if vectorOnly {
+		return p.app.EmbeddingManager.MultiSearch(ctx, queries, filter, limit)
+	}
+	return p.app.EmbeddingManager.HybridSearch(ctx, queries, filter, limit)
+}
+
+// RelatedDocuments returns the documents most similar to the document at
+// path, from the database it is stored in
+func (p *AppDocumentProvider) RelatedDocuments(path string, limit int) ([]vector.SearchResult, error) {
+	doc := p.app.findDocument(path)
+	if doc == nil {
+		return nil, fmt.Errorf("document not found: %s", path)
+	}
+	return p.app.EmbeddingManager.RelatedDocuments(*doc, limit)
+}
+
+// toDocumentInfo converts a document to its MCP representation
+func toDocumentInfo(d Document) mcp.DocumentInfo {
+	return mcp.DocumentInfo{
+		Title:      d.Title,
+		Path:       d.Path,
+		RelPath:    d.RelPath,
+		SourceName: d.SourceName,
+		Collection: d.Collection,
+		Overview:   d.Overview,
+		Summary:    d.Summary,
+
+		Description: d.Description,
+		Tags:        d.Tags,
+		Metadata:    d.Metadata,
+	}
+}
+
 // GetDocumentContent returns the content of a document by path
 func (p *AppDocumentProvider) GetDocumentContent(path string) (string, error) {
-	for _, doc := range p.app.Documents {
+	for _, doc := range p.app.GetDocuments() {
 		if doc.RelPath == path {
-			return doc.Content, nil
+			if len(doc.Secrets) > 0 && p.app.SecretScanner != nil && p.app.SecretScanner.Mode() == secrets.ModeFlag {
+				return secretWarning(doc.Secrets) + documentMarkdown(doc), nil
+			}
+			return documentMarkdown(doc), nil
 		}
 	}
 	return "", fmt.Errorf("document not found: %s", path)
 }
+
+// secretWarning returns a note listing the lines with unredacted secrets
+func secretWarning(findings []secrets.Finding) string {
+	lines := make([]string, len(findings))
+	for i, f := range findings {
+		lines[i] = fmt.Sprintf("%d (%s)", f.Line, f.Rule)
+	}
+	return fmt.Sprintf("> **Warning:** this document contains %d potential secret(s) on line(s) %s. Do not repeat them.\n\n", len(findings), strings.Join(lines, ", "))
+}
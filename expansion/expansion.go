@@ -0,0 +1,103 @@
+// Package expansion rewrites search queries into several paraphrases with a
+// chat model, so that terse queries also find documents using other words.
+package expansion
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"dimandocs/llm"
+)
+
+const (
+	// DefaultParaphrases is the default number of paraphrases per query
+	DefaultParaphrases = 3
+
+	// cacheSize is the number of expanded queries kept in memory, so repeated
+	// searches do not call the chat model again
+	cacheSize = 256
+)
+
+// Expander expands search queries into paraphrases
+type Expander struct {
+	client      llm.Client
+	paraphrases int
+
+	mu    sync.Mutex          // Guards cache
+	cache map[string][]string // Paraphrases by query
+}
+
+// NewExpander creates an expander generating the given number of
+// paraphrases per query with a chat model
+func NewExpander(client llm.Client, paraphrases int) *Expander {
+	if paraphrases <= 0 {
+		paraphrases = DefaultParaphrases
+	}
+	return &Expander{
+		client:      client,
+		paraphrases: paraphrases,
+		cache:       make(map[string][]string),
+	}
+}
+
+// Expand returns the query followed by its paraphrases. Paraphrases equal to
+// the query, or to each other, are dropped.
+func (e *Expander) Expand(ctx context.Context, query string) ([]string, error) {
+	key := strings.ToLower(strings.TrimSpace(query))
+	e.mu.Lock()
+	paraphrases, ok := e.cache[key]
+	e.mu.Unlock()
+
+	if !ok {
+		reply, err := e.client.Complete(ctx, []llm.Message{
+			{
+				Role:    llm.RoleSystem,
+				Content: "You rewrite search queries for a technical documentation search engine. Write alternative phrasings that use different words and spell out abbreviations, keeping the meaning. Reply with one phrasing per line and nothing else.",
+			},
+			{
+				Role:    llm.RoleUser,
+				Content: fmt.Sprintf("Write %d alternative phrasings of the query: %s", e.paraphrases, query),
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand query: %w", err)
+		}
+		paraphrases = parseParaphrases(reply, query, e.paraphrases)
+
+		e.mu.Lock()
+		if len(e.cache) >= cacheSize {
+			clear(e.cache)
+		}
+		e.cache[key] = paraphrases
+		e.mu.Unlock()
+	}
+
+	return append([]string{query}, paraphrases...), nil
+}
+
+// listMarker matches the bullet or number of a list item
+var listMarker = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s+`)
+
+// parseParaphrases reads up to limit paraphrases of query from a reply, one per
+// line, removing list markers and quotes
+func parseParaphrases(reply, query string, limit int) []string {
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(query)): true}
+	var paraphrases []string
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(listMarker.ReplaceAllString(line, ""))
+		line = strings.TrimSpace(strings.Trim(line, "\"'`"))
+		key := strings.ToLower(line)
+		if line == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		paraphrases = append(paraphrases, line)
+		if len(paraphrases) == limit {
+			break
+		}
+	}
+	return paraphrases
+}
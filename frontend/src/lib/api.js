@@ -16,17 +16,121 @@ export async function getDocument(path) {
   return response.json()
 }
 
-export async function search(query) {
+export async function getHistory(path) {
+  const response = await fetch(`${BASE_URL}/api/documents/${encodeURI(path)}/history`)
+  if (!response.ok) {
+    throw new Error(`Failed to fetch history: ${(await response.text()).trim() || response.statusText}`)
+  }
+  return response.json()
+}
+
+export async function getDiff(path, from, to) {
+  const params = new URLSearchParams()
+  if (from) params.set('from', from)
+  if (to) params.set('to', to)
+  const response = await fetch(`${BASE_URL}/api/documents/${encodeURI(path)}/diff?${params}`)
+  if (!response.ok) {
+    throw new Error(`Failed to fetch changes: ${(await response.text()).trim() || response.statusText}`)
+  }
+  return response.json()
+}
+
+export async function getAdminStatus() {
+  const response = await fetch(`${BASE_URL}/api/admin/status`)
+  if (!response.ok) {
+    throw new Error(`Failed to fetch status: ${(await response.text()).trim() || response.statusText}`)
+  }
+  return response.json()
+}
+
+export async function startReindex(source = '', force = false) {
+  const response = await fetch(`${BASE_URL}/api/admin/reindex`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ source, force })
+  })
+  if (!response.ok) {
+    throw new Error(`Failed to start reindex: ${(await response.text()).trim() || response.statusText}`)
+  }
+  return response.json()
+}
+
+export async function getTags() {
+  const response = await fetch(`${BASE_URL}/api/tags`)
+  if (!response.ok) {
+    throw new Error(`Failed to fetch tags: ${response.statusText}`)
+  }
+  return response.json()
+}
+
+export async function search(query, tags = []) {
   if (!query || query.trim() === '') {
     return []
   }
-  const response = await fetch(`${BASE_URL}/api/search?q=${encodeURIComponent(query)}`)
+  const params = new URLSearchParams({ q: query })
+  tags.forEach(tag => params.append('tag', tag))
+  const response = await fetch(`${BASE_URL}/api/search?${params}`)
   if (!response.ok) {
     throw new Error(`Search failed: ${response.statusText}`)
   }
   return response.json()
 }
 
+export function trackClick(query, path) {
+  if (!query || !path) {
+    return
+  }
+  const body = JSON.stringify({ query, path })
+  // keepalive lets the request finish while the page navigates away
+  fetch(`${BASE_URL}/api/analytics/click`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body,
+    keepalive: true
+  }).catch(() => {})
+}
+
+// askStream asks a question and streams the answer as server-sent events.
+// onSources gets the sources the answer is written from, onDelta each piece
+// of the answer; the whole response is returned once the answer is done.
+export async function askStream(body, { onSources, onDelta, signal } = {}) {
+  const response = await fetch(`${BASE_URL}/api/ask`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json', Accept: 'text/event-stream' },
+    body: JSON.stringify(body),
+    signal
+  })
+  if (!response.ok) {
+    throw new Error(`Failed to ask: ${(await response.text()).trim() || response.statusText}`)
+  }
+
+  const reader = response.body.pipeThrough(new TextDecoderStream()).getReader()
+  let buffer = ''
+  for (;;) {
+    const { value, done } = await reader.read()
+    if (done) break
+    buffer += value.replace(/\r\n/g, '\n')
+    let end
+    while ((end = buffer.indexOf('\n\n')) >= 0) {
+      const block = buffer.slice(0, end)
+      buffer = buffer.slice(end + 2)
+      let event = 'message'
+      const data = []
+      for (const line of block.split('\n')) {
+        if (line.startsWith('event:')) event = line.slice(6).trim()
+        else if (line.startsWith('data:')) data.push(line.slice(5).trimStart())
+      }
+      if (data.length === 0) continue
+      const payload = JSON.parse(data.join('\n'))
+      if (event === 'sources') onSources?.(payload)
+      else if (event === 'delta') onDelta?.(payload.Text)
+      else if (event === 'error') throw new Error(payload.Error)
+      else if (event === 'done') return payload
+    }
+  }
+  throw new Error('The answer ended unexpectedly')
+}
+
 export function debounce(fn, delay) {
   let timeoutId
   return function (...args) {
@@ -0,0 +1,126 @@
+package frontmatter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Metadata represents the fields of a document's YAML frontmatter
+type Metadata struct {
+	Title       string
+	Description string
+	Tags        []string
+	Extra       map[string]any // Custom fields other than title, description and tags
+}
+
+// Split separates a leading "---" delimited frontmatter block from the body.
+// ok is false when the content has no frontmatter.
+func Split(content string) (block, body string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimPrefix(content, "\ufeff"), "---")
+	if !found {
+		return "", content, false
+	}
+	// The opening delimiter must be on a line of its own
+	rest = strings.TrimLeft(rest, " \t")
+	if rest, found = strings.CutPrefix(rest, "\r\n"); !found {
+		if rest, found = strings.CutPrefix(rest, "\n"); !found {
+			return "", content, false
+		}
+	}
+
+	offset := 0
+	for {
+		line, next, more := strings.Cut(rest[offset:], "\n")
+		trimmed := strings.TrimRight(line, " \t\r")
+		if trimmed == "---" || trimmed == "..." {
+			block = rest[:offset]
+			if more {
+				body = next
+			}
+			return block, strings.TrimLeft(body, "\r\n"), true
+		}
+		if !more {
+			return "", content, false
+		}
+		offset += len(line) + 1
+	}
+}
+
+// Parse extracts frontmatter metadata and returns the content without it.
+// Content without frontmatter is returned unchanged with empty metadata.
+func Parse(content string) (Metadata, string, error) {
+	block, body, ok := Split(content)
+	if !ok {
+		return Metadata{}, content, nil
+	}
+
+	var fields map[string]any
+	if err := yaml.Unmarshal([]byte(block), &fields); err != nil {
+		return Metadata{}, content, fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+
+	var meta Metadata
+	for key, value := range fields {
+		switch strings.ToLower(key) {
+		case "title":
+			meta.Title = scalar(value)
+		case "description", "summary":
+			if meta.Description == "" {
+				meta.Description = scalar(value)
+			}
+		case "tags", "tag", "keywords":
+			meta.Tags = append(meta.Tags, tags(value)...)
+		default:
+			if meta.Extra == nil {
+				meta.Extra = make(map[string]any)
+			}
+			meta.Extra[key] = value
+		}
+	}
+	meta.Tags = NormalizeTags(meta.Tags)
+
+	return meta, body, nil
+}
+
+// scalar converts a YAML scalar to a string
+func scalar(value any) string {
+	if value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+// tags converts a YAML list or comma-separated string to tags
+func tags(value any) []string {
+	switch v := value.(type) {
+	case []any:
+		var list []string
+		for _, item := range v {
+			list = append(list, scalar(item))
+		}
+		return list
+	case string:
+		return strings.Split(v, ",")
+	default:
+		return []string{scalar(v)}
+	}
+}
+
+// NormalizeTags lowercases, trims, deduplicates and sorts tags
+func NormalizeTags(list []string) []string {
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, tag := range list {
+		tag = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#")))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -0,0 +1,189 @@
+// Package gitignore matches paths against .gitignore-style glob patterns.
+package gitignore
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// rule is a compiled pattern
+type rule struct {
+	pattern string
+	regex   *regexp.Regexp
+	negate  bool // Pattern starts with "!" and re-includes matching paths
+	dirOnly bool // Pattern ends with "/" and only matches directories
+}
+
+// Matcher holds an ordered list of patterns. As in .gitignore files, later
+// patterns take precedence over earlier ones.
+type Matcher struct {
+	rules []rule
+}
+
+// Compile compiles patterns in .gitignore syntax. Blank lines and lines
+// starting with "#" are skipped.
+func Compile(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, pattern := range patterns {
+		r, ok, err := compileRule(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+	return m, nil
+}
+
+// Parse compiles the patterns of a .gitignore file
+func Parse(data []byte) (*Matcher, error) {
+	return Compile(strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"))
+}
+
+// Empty reports whether the matcher has no patterns
+func (m *Matcher) Empty() bool {
+	return m == nil || len(m.rules) == 0
+}
+
+// Match returns whether the last pattern matching a slash-separated path,
+// relative to the base of the patterns, ignores it. ok is false if no pattern
+// matches. Parent directories are not considered, see Ignored.
+func (m *Matcher) Match(rel string, isDir bool) (ignored, ok bool) {
+	if m == nil {
+		return false, false
+	}
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		r := m.rules[i]
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.regex.MatchString(rel) {
+			return !r.negate, true
+		}
+	}
+	return false, false
+}
+
+// Ignored reports whether a slash-separated path, relative to the base of the
+// patterns, is ignored. A path inside an ignored directory is ignored too, and
+// cannot be re-included.
+func (m *Matcher) Ignored(rel string, isDir bool) bool {
+	if m.Empty() || rel == "" || rel == "." {
+		return false
+	}
+	for i := strings.IndexByte(rel, '/'); i >= 0; i = nextSlash(rel, i) {
+		if ignored, _ := m.Match(rel[:i], true); ignored {
+			return true
+		}
+	}
+	ignored, _ := m.Match(rel, isDir)
+	return ignored
+}
+
+// nextSlash returns the index of the next "/" in s after i, or -1
+func nextSlash(s string, i int) int {
+	j := strings.IndexByte(s[i+1:], '/')
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+// compileRule compiles a single pattern. ok is false for blank lines and
+// comments.
+func compileRule(pattern string) (r rule, ok bool, err error) {
+	r.pattern = pattern
+	// Trailing spaces are ignored unless escaped
+	for strings.HasSuffix(pattern, " ") && !strings.HasSuffix(pattern, `\ `) {
+		pattern = pattern[:len(pattern)-1]
+	}
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return r, false, nil
+	}
+
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return r, false, nil
+	}
+
+	// Patterns with a slash other than a trailing one are relative to the
+	// base; others match at any depth
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored && !strings.HasPrefix(pattern, "**") {
+		b.WriteString("(?:.*/)?")
+	}
+	if err := writeGlob(&b, pattern); err != nil {
+		return r, false, fmt.Errorf("invalid pattern %q: %w", r.pattern, err)
+	}
+	b.WriteString("$")
+
+	r.regex, err = regexp.Compile(b.String())
+	if err != nil {
+		return r, false, fmt.Errorf("invalid pattern %q: %w", r.pattern, err)
+	}
+	return r, true, nil
+}
+
+// writeGlob writes the regular expression for a glob to b
+func writeGlob(b *strings.Builder, glob string) error {
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				atStart := i == 0 || glob[i-1] == '/'
+				switch {
+				case atStart && i+2 < len(glob) && glob[i+2] == '/':
+					// "**/" matches zero or more directories
+					b.WriteString("(?:.*/)?")
+					i += 2
+				case atStart && i+2 == len(glob):
+					// A trailing "/**" matches everything inside
+					b.WriteString(".*")
+					i++
+				default:
+					b.WriteString("[^/]*")
+					i++
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package gitignore
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Tree holds the .gitignore files that apply to a directory of a
+// repository: those of the directory and its subdirectories, of its parent
+// directories up to the repository root, and .git/info/exclude
+type Tree struct {
+	root  string              // Repository root, or the directory outside a repository
+	files map[string]*Matcher // By directory relative to root, "" for root
+}
+
+// LoadTree loads the .gitignore files that apply to dir. Files that cannot be
+// read are skipped.
+func LoadTree(dir string) (*Tree, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tree{root: repositoryRoot(absDir), files: make(map[string]*Matcher)}
+
+	// Patterns of .git/info/exclude have the lowest precedence, so they come
+	// before those of the root .gitignore
+	exclude, _ := os.ReadFile(filepath.Join(t.root, ".git", "info", "exclude"))
+	rootIgnore, _ := os.ReadFile(filepath.Join(t.root, ".gitignore"))
+	if m, err := Parse(append(append(exclude, '\n'), rootIgnore...)); err == nil && !m.Empty() {
+		t.files[""] = m
+	}
+
+	// Parent directories between the root and dir
+	rel, _ := filepath.Rel(t.root, absDir)
+	if rel != "." {
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		for i := 1; i < len(parts); i++ {
+			t.load(strings.Join(parts[:i], "/"))
+		}
+	}
+
+	// dir and its subdirectories, skipping ignored ones
+	err = filepath.WalkDir(absDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != absDir && t.Ignored(path, true) {
+			return filepath.SkipDir
+		}
+		if rel, err := filepath.Rel(t.root, path); err == nil && rel != "." {
+			t.load(filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return t, err
+}
+
+// load reads the .gitignore file of a directory relative to the root
+func (t *Tree) load(rel string) {
+	data, err := os.ReadFile(filepath.Join(t.root, filepath.FromSlash(rel), ".gitignore"))
+	if err != nil {
+		return
+	}
+	if m, err := Parse(data); err == nil && !m.Empty() {
+		t.files[rel] = m
+	}
+}
+
+// repositoryRoot returns the closest directory at or above dir that contains
+// .git, or dir itself
+func repositoryRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// Ignored reports whether path is ignored by the .gitignore files. The .git
+// directory is always ignored.
+func (t *Tree) Ignored(path string, isDir bool) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(t.root, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	// A path inside an ignored directory is ignored too
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i, part := range parts {
+		if part == ".git" {
+			return true
+		}
+		if t.match(parts[:i+1], i < len(parts)-1 || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// match applies the .gitignore files from the root down to the parent of a
+// path, so deeper files take precedence
+func (t *Tree) match(parts []string, isDir bool) bool {
+	ignored := false
+	for i := range parts {
+		m := t.files[strings.Join(parts[:i], "/")]
+		if m == nil {
+			continue
+		}
+		if ign, ok := m.Match(strings.Join(parts[i:], "/"), isDir); ok {
+			ignored = ign
+		}
+	}
+	return ignored
+}
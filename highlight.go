@@ -0,0 +1,103 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// snippetLength is the length of search result snippets in bytes
+	snippetLength = 200
+
+	// titleMatchWeight is how much more a match in the title counts than one
+	// in the content when ranking text search results
+	titleMatchWeight = 5
+)
+
+// termsRegex returns a case-insensitive regex matching any of the terms,
+// longest first, or nil without terms
+func termsRegex(terms []string) *regexp.Regexp {
+	seen := make(map[string]bool)
+	var quoted []string
+	for _, term := range terms {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term == "" || seen[term] {
+			continue
+		}
+		seen[term] = true
+		quoted = append(quoted, regexp.QuoteMeta(term))
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	sort.SliceStable(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// queryTerms returns the terms to highlight for a query: the query itself,
+// for phrase matches, and its words
+func queryTerms(queries ...string) []string {
+	var terms []string
+	for _, query := range queries {
+		terms = append(terms, query)
+		terms = append(terms, strings.Fields(query)...)
+	}
+	return terms
+}
+
+// highlightSnippet returns an excerpt of text around the first match of re,
+// HTML-escaped, with every match wrapped in <mark>. Without a match, the
+// excerpt is the start of the text.
+func highlightSnippet(text string, re *regexp.Regexp) string {
+	text = strings.Join(strings.Fields(text), " ")
+
+	start := 0
+	if re != nil {
+		if loc := re.FindStringIndex(text); loc != nil {
+			// Show some context before the match
+			start = max(0, loc[0]-snippetLength/4)
+		}
+	}
+	end := min(len(text), start+snippetLength)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	excerpt := text[start:end]
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	last := 0
+	if re != nil {
+		for _, loc := range re.FindAllStringIndex(excerpt, -1) {
+			b.WriteString(html.EscapeString(excerpt[last:loc[0]]))
+			b.WriteString("<mark>" + html.EscapeString(excerpt[loc[0]:loc[1]]) + "</mark>")
+			last = loc[1]
+		}
+	}
+	b.WriteString(html.EscapeString(excerpt[last:]))
+	if end < len(text) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+// matchScore ranks a text search result by the number of matches of re,
+// weighting those in the title. Scores are from 0 to 1 like those of
+// semantic search: n weighted matches score n / (n + 1).
+func matchScore(doc Document, re *regexp.Regexp) float32 {
+	if re == nil {
+		return 0
+	}
+	title := len(re.FindAllStringIndex(doc.Title, -1))
+	content := len(re.FindAllStringIndex(doc.Content(), -1))
+	matches := float32(titleMatchWeight*title + content)
+	return matches / (matches + 1)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dimandocs/frontmatter"
+	"dimandocs/render"
+	"dimandocs/sources"
+)
+
+const (
+	// defaultHistoryLimit is the number of commits listed by default
+	defaultHistoryLimit = 50
+
+	// maxHistoryLimit is the largest number of commits listed at once
+	maxHistoryLimit = 500
+)
+
+var (
+	// errNoHistory is returned for documents outside of a git repository
+	errNoHistory = errors.New("document is not in a git repository")
+
+	// errUnknownRevision is returned for revisions git cannot resolve
+	errUnknownRevision = errors.New("unknown revision")
+)
+
+// Revision represents a commit that changed a document
+type Revision struct {
+	Commit  string    `json:"Commit"`
+	Author  string    `json:"Author"`
+	Date    time.Time `json:"Date"`
+	Subject string    `json:"Subject"`
+}
+
+// DocumentDiff represents the changes to a document between two revisions
+type DocumentDiff struct {
+	From    string `json:"From"`    // Commit, or the empty tree
+	To      string `json:"To"`      // Commit, or "" for the file on disk
+	Diff    string `json:"Diff"`    // Unified diff
+	Content string `json:"Content"` // The document at To, rendered as HTML
+}
+
+// DocumentHistory returns the commits that changed a document, newest first,
+// following renames
+func (a *App) DocumentHistory(ctx context.Context, doc Document, limit int) ([]Revision, error) {
+	dir, name := filepath.Split(doc.Path)
+	if err := checkGitRepo(ctx, dir); err != nil {
+		return nil, err
+	}
+	out, err := sources.Git(ctx, dir, "log", "--follow", "-n", fmt.Sprint(limit),
+		"--format=%H%x1f%an%x1f%aI%x1f%s", "--", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	revisions := []Revision{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[2])
+		revisions = append(revisions, Revision{Commit: fields[0], Author: fields[1], Date: date, Subject: fields[3]})
+	}
+	return revisions, nil
+}
+
+// DocumentDiff returns the changes to a document from one revision to
+// another, and the document as of the second. Without to, the file on disk
+// is compared; without from, the parent of to is (or HEAD, without either).
+// From is a commit, or the empty tree when to is the first commit.
+func (a *App) DocumentDiff(ctx context.Context, doc Document, from, to string) (DocumentDiff, error) {
+	dir, name := filepath.Split(doc.Path)
+	if err := checkGitRepo(ctx, dir); err != nil {
+		return DocumentDiff{}, err
+	}
+
+	var diff DocumentDiff
+	var err error
+	switch {
+	case from != "":
+		diff.From, err = resolveRevision(ctx, dir, from)
+	case to != "":
+		// The first commit is compared with the empty tree
+		if diff.From, err = resolveRevision(ctx, dir, to+"^"); err != nil {
+			diff.From, err = emptyTree(ctx, dir)
+		}
+	default:
+		diff.From, err = resolveRevision(ctx, dir, "HEAD")
+	}
+	if err != nil {
+		return DocumentDiff{}, err
+	}
+	args := []string{"diff", "--no-color", "--no-ext-diff", diff.From}
+	content := doc.Content()
+	if to != "" {
+		if diff.To, err = resolveRevision(ctx, dir, to); err != nil {
+			return DocumentDiff{}, err
+		}
+		args = append(args, diff.To)
+
+		// ./ makes the path relative to dir instead of the repository root
+		raw, err := sources.Git(ctx, dir, "show", diff.To+":./"+name)
+		if err != nil {
+			return DocumentDiff{}, fmt.Errorf("%w: the document does not exist in %s", errUnknownRevision, to)
+		}
+		_, content, _ = frontmatter.Split(raw)
+	}
+	out, err := sources.Git(ctx, dir, append(args, "--", name)...)
+	if err != nil {
+		return DocumentDiff{}, fmt.Errorf("failed to diff document: %w", err)
+	}
+
+	// Earlier revisions are shown like the current one, with secrets redacted
+	diff.Diff = out
+	if a.SecretScanner != nil {
+		diff.Diff, _ = a.SecretScanner.Process(diff.Diff)
+		content, _ = a.SecretScanner.Process(content)
+	}
+	version := doc
+	version.SetContent(content)
+	opts := a.renderOptions()
+	opts.RewriteLink = a.linkRewriter(doc)
+	diff.Content = string(render.Markdown(documentMarkdown(version), opts))
+	return diff, nil
+}
+
+// checkGitRepo returns errNoHistory unless dir is in a git work tree
+func checkGitRepo(ctx context.Context, dir string) error {
+	if _, err := sources.Git(ctx, dir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return errNoHistory
+	}
+	return nil
+}
+
+// resolveRevision resolves a revision such as a commit hash, a branch or
+// HEAD~2 to the hash of its commit
+func resolveRevision(ctx context.Context, dir, revision string) (string, error) {
+	// Revisions must not be mistaken for options
+	if strings.HasPrefix(revision, "-") {
+		return "", fmt.Errorf("%w %q", errUnknownRevision, revision)
+	}
+	out, err := sources.Git(ctx, dir, "rev-parse", "--verify", "--quiet", revision+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%w %q", errUnknownRevision, revision)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// emptyTree returns the hash of the empty tree in the repository's hash
+// format
+func emptyTree(ctx context.Context, dir string) (string, error) {
+	out, err := sources.Git(ctx, dir, "hash-object", "-t", "tree", os.DevNull)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash empty tree: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// historyError answers a failed history or diff request
+func historyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNoHistory):
+		http.Error(w, "Document has no history: "+err.Error(), http.StatusNotFound)
+	case errors.Is(err, errUnknownRevision):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,543 @@
+package htmlmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Document represents an HTML page converted to markdown
+type Document struct {
+	Title    string
+	Markdown string
+}
+
+// boilerplateRegex matches a class name or ID of navigation, footers and
+// other page chrome that is not part of the content: one starting with a
+// chrome keyword, such as nav-links, or one after a site-wide prefix, such
+// as site-footer. Modifiers like with-sidebar or has-comments do not match.
+var boilerplateRegex = regexp.MustCompile(`(?i)^((site|page|main|top|bottom|global|primary|secondary)[_-])?(nav|navbar|navigation|menu|footer|sidebar|breadcrumbs?|cookie|banner|advert|ads|share|social|comments?|related|pagination|skip-link)([_-].*)?$`)
+
+// contentRegex matches class names and IDs of common main content containers
+var contentRegex = regexp.MustCompile(`(?i)^(content|main|main-content|article|post|page-content|wiki-content|markdown-body|documentation|docs-content)$`)
+
+// removedElements are never part of the content
+var removedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Iframe: true, atom.Svg: true, atom.Button: true, atom.Select: true,
+	atom.Input: true, atom.Textarea: true, atom.Head: true,
+}
+
+// boilerplateRoles are ARIA landmark roles of page chrome
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true, "search": true,
+}
+
+// Convert parses an HTML page, strips boilerplate such as navigation and
+// footers, and converts the main content to markdown
+func Convert(r io.Reader) (Document, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	title := pageTitle(root)
+	content := mainContent(root)
+
+	c := &converter{}
+	c.block(content)
+	markdown := c.String()
+
+	// The content heading is usually cleaner than <title>, which tends to
+	// carry the site name
+	if h1 := find(content, atom.H1); h1 != nil {
+		if heading := collapse(textContent(h1)); heading != "" {
+			title = heading
+		}
+	}
+	// Make sure the document title survives as the first heading
+	if title != "" && !strings.HasPrefix(markdown, "# ") {
+		markdown = "# " + title + "\n\n" + markdown
+	}
+
+	return Document{Title: title, Markdown: markdown}, nil
+}
+
+// ConvertFragment converts an HTML fragment, such as exported page content,
+// to markdown as a whole, without looking for the main content
+func ConvertFragment(r io.Reader) (string, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	body := find(root, atom.Body)
+	if body == nil {
+		body = root
+	}
+	c := &converter{}
+	c.block(body)
+	return c.String(), nil
+}
+
+// pageTitle returns the title of the page from og:title or <title>
+func pageTitle(root *html.Node) string {
+	var title string
+	walk(root, func(n *html.Node) bool {
+		if n.DataAtom == atom.Meta && attr(n, "property") == "og:title" {
+			title = strings.TrimSpace(attr(n, "content"))
+			return false
+		}
+		if n.DataAtom == atom.Title && title == "" {
+			title = collapse(textContent(n))
+		}
+		return true
+	})
+	return title
+}
+
+// mainContent finds the element holding the page content: an explicit main
+// or article element, a well-known content container, or else the element
+// containing the most paragraph text
+func mainContent(root *html.Node) *html.Node {
+	body := find(root, atom.Body)
+	if body == nil {
+		body = root
+	}
+	prune(body)
+
+	if n := find(body, atom.Main); n != nil {
+		return n
+	}
+	var byRole, byName *html.Node
+	walk(body, func(n *html.Node) bool {
+		if byRole == nil && attr(n, "role") == "main" {
+			byRole = n
+		}
+		if byName == nil && (contentRegex.MatchString(attr(n, "id")) || contentRegex.MatchString(attr(n, "class"))) {
+			byName = n
+		}
+		return true
+	})
+	if byRole != nil {
+		return byRole
+	}
+	if articles := findAll(body, atom.Article); len(articles) == 1 {
+		return articles[0]
+	}
+	if byName != nil {
+		return byName
+	}
+
+	// Score containers by the paragraph text they hold, like readability
+	scores := make(map[*html.Node]int)
+	for _, p := range findAll(body, atom.P) {
+		length := len(collapse(textContent(p)))
+		if p.Parent != nil {
+			scores[p.Parent] += length
+			if p.Parent.Parent != nil {
+				scores[p.Parent.Parent] += length / 2
+			}
+		}
+	}
+	best, bestScore := body, 0
+	for n, score := range scores {
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	return best
+}
+
+// prune removes scripts, navigation, footers and similar boilerplate
+func prune(n *html.Node) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.CommentNode || (child.Type == html.ElementNode && isBoilerplate(child)) {
+			n.RemoveChild(child)
+		} else {
+			prune(child)
+		}
+		child = next
+	}
+}
+
+// isBoilerplate reports whether an element is page chrome
+func isBoilerplate(n *html.Node) bool {
+	if removedElements[n.DataAtom] || boilerplateRoles[attr(n, "role")] {
+		return true
+	}
+	if attr(n, "aria-hidden") == "true" || hasAttr(n, "hidden") {
+		return true
+	}
+	// Page headers hold site navigation; headers of articles hold titles
+	if n.DataAtom == atom.Header && ancestor(n, atom.Article) == nil && ancestor(n, atom.Main) == nil {
+		return true
+	}
+	if n.DataAtom == atom.Main || n.DataAtom == atom.Article || n.DataAtom == atom.Body {
+		return false
+	}
+	return hasBoilerplateToken(attr(n, "class")) || hasBoilerplateToken(attr(n, "id"))
+}
+
+// hasBoilerplateToken reports whether any class name or ID of an attribute
+// value names page chrome
+func hasBoilerplateToken(value string) bool {
+	for _, token := range strings.Fields(value) {
+		if boilerplateRegex.MatchString(token) {
+			return true
+		}
+	}
+	return false
+}
+
+// converter renders HTML nodes as markdown
+type converter struct {
+	out    strings.Builder
+	indent string // Indentation of the enclosing list item content
+}
+
+// String returns the converted markdown
+func (c *converter) String() string {
+	text := strings.TrimSpace(c.out.String())
+	return blankLinesRegex.ReplaceAllString(text, "\n\n") + "\n"
+}
+
+// blankLinesRegex matches runs of blank lines
+var blankLinesRegex = regexp.MustCompile(`\n{3,}`)
+
+// paragraph starts a new block
+func (c *converter) paragraph() {
+	c.out.WriteString("\n\n")
+}
+
+// block renders the children of a block-level element
+func (c *converter) block(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.node(child)
+	}
+}
+
+// node renders a single node
+func (c *converter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		text := whitespaceRegex.ReplaceAllString(n.Data, " ")
+		if current := c.out.String(); current == "" || strings.HasSuffix(current, "\n") {
+			text = strings.TrimLeft(text, " ")
+		}
+		c.out.WriteString(text)
+		return
+	case html.ElementNode:
+	default:
+		c.block(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		if text := collapse(inline(n)); text != "" {
+			c.paragraph()
+			c.out.WriteString(strings.Repeat("#", level) + " " + text)
+			c.paragraph()
+		}
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Figure, atom.Details:
+		c.paragraph()
+		c.block(n)
+		c.paragraph()
+	case atom.Br:
+		c.out.WriteString("  \n")
+	case atom.Hr:
+		c.paragraph()
+		c.out.WriteString("---")
+		c.paragraph()
+	case atom.Pre:
+		c.paragraph()
+		c.out.WriteString("```" + codeLanguage(n) + "\n")
+		c.out.WriteString(strings.TrimRight(textContent(n), "\n"))
+		c.out.WriteString("\n```")
+		c.paragraph()
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		if text := textContent(n); text != "" {
+			c.out.WriteString(codeSpan(text))
+		}
+	case atom.Strong, atom.B:
+		c.wrap(n, "**")
+	case atom.Em, atom.I:
+		c.wrap(n, "*")
+	case atom.Del, atom.S, atom.Strike:
+		c.wrap(n, "~~")
+	case atom.A:
+		text := collapse(inline(n))
+		href := attr(n, "href")
+		if text == "" {
+			return
+		}
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			c.out.WriteString(text)
+			return
+		}
+		c.out.WriteString("[" + text + "](" + href + ")")
+	case atom.Img:
+		if src := attr(n, "src"); src != "" {
+			c.out.WriteString("![" + attr(n, "alt") + "](" + src + ")")
+		}
+	case atom.Ul, atom.Ol:
+		c.list(n)
+	case atom.Blockquote:
+		inner := &converter{}
+		inner.block(n)
+		c.paragraph()
+		for _, line := range strings.Split(strings.TrimSpace(inner.String()), "\n") {
+			c.out.WriteString("> " + line + "\n")
+		}
+		c.paragraph()
+	case atom.Table:
+		c.table(n)
+	case atom.Dt:
+		c.paragraph()
+		c.out.WriteString("**" + collapse(inline(n)) + "**")
+		c.out.WriteString("\n")
+	case atom.Dd:
+		c.out.WriteString(": ")
+		c.block(n)
+		c.out.WriteString("\n")
+	default:
+		c.block(n)
+	}
+}
+
+// wrap renders inline content between markers
+func (c *converter) wrap(n *html.Node, marker string) {
+	if text := collapse(inline(n)); text != "" {
+		c.out.WriteString(marker + text + marker)
+	}
+}
+
+// list renders an ordered or unordered list. Nested lists are rendered by
+// item converters and indented to the content of their parent item.
+func (c *converter) list(n *html.Node) {
+	nested := c.indent != ""
+	if nested {
+		c.out.WriteString("\n")
+	} else {
+		c.paragraph()
+	}
+
+	number := 1
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		contentIndent := c.indent + strings.Repeat(" ", len(marker))
+
+		item := &converter{indent: contentIndent}
+		item.block(li)
+		text := strings.TrimSpace(item.out.String())
+		lines := strings.Split(text, "\n")
+		c.out.WriteString(c.indent + marker + strings.TrimSpace(lines[0]) + "\n")
+		for _, line := range lines[1:] {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			// Nested list lines already carry their indentation
+			if !strings.HasPrefix(line, contentIndent) {
+				line = contentIndent + strings.TrimLeft(line, " ")
+			}
+			c.out.WriteString(line + "\n")
+		}
+	}
+
+	if !nested {
+		c.paragraph()
+	}
+}
+
+// table renders a table as a markdown table
+func (c *converter) table(n *html.Node) {
+	var rows [][]string
+	header := false
+	walk(n, func(node *html.Node) bool {
+		if node.DataAtom != atom.Tr {
+			return true
+		}
+		var cells []string
+		for cell := node.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.DataAtom == atom.Th || cell.DataAtom == atom.Td {
+				text := strings.ReplaceAll(collapse(inline(cell)), "|", "\\|")
+				cells = append(cells, text)
+				if cell.DataAtom == atom.Th && len(rows) == 0 {
+					header = true
+				}
+			}
+		}
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+		return false
+	})
+	if len(rows) == 0 {
+		return
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	c.paragraph()
+	if !header {
+		// Markdown tables need a header row
+		rows = append([][]string{make([]string, columns)}, rows...)
+	}
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		c.out.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			c.out.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+	c.paragraph()
+}
+
+// inline renders the inline markdown of an element's children
+func inline(n *html.Node) string {
+	c := &converter{}
+	c.block(n)
+	return c.out.String()
+}
+
+// whitespaceRegex matches runs of whitespace
+var whitespaceRegex = regexp.MustCompile(`\s+`)
+
+// collapse trims text and collapses whitespace runs to single spaces
+func collapse(text string) string {
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(text, " "))
+}
+
+// codeSpan wraps text in enough backticks to hold backticks inside it
+func codeSpan(text string) string {
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return fence + " " + text + " " + fence
+	}
+	return fence + text + fence
+}
+
+// codeLanguage returns the language of a code block from its class names,
+// such as "language-go" or "lang-go"
+func codeLanguage(pre *html.Node) string {
+	classes := attr(pre, "class")
+	if code := find(pre, atom.Code); code != nil {
+		classes += " " + attr(code, "class")
+	}
+	for _, class := range strings.Fields(classes) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if lang, ok := strings.CutPrefix(class, prefix); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// textContent returns the raw text of a node and its descendants
+func textContent(n *html.Node) string {
+	var text strings.Builder
+	walk(n, func(node *html.Node) bool {
+		if node.Type == html.TextNode {
+			text.WriteString(node.Data)
+		}
+		if node.DataAtom == atom.Br {
+			text.WriteString("\n")
+		}
+		return true
+	})
+	return text.String()
+}
+
+// walk calls fn for n and its descendants; returning false skips children
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, fn)
+	}
+}
+
+// find returns the first descendant element of the given type
+func find(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walk(n, func(node *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if node.DataAtom == a {
+			found = node
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// findAll returns all descendant elements of the given type
+func findAll(n *html.Node, a atom.Atom) []*html.Node {
+	var nodes []*html.Node
+	walk(n, func(node *html.Node) bool {
+		if node.DataAtom == a {
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+	return nodes
+}
+
+// ancestor returns the closest ancestor element of the given type
+func ancestor(n *html.Node, a atom.Atom) *html.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.DataAtom == a {
+			return p
+		}
+	}
+	return nil
+}
+
+// attr returns the value of an attribute
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasAttr reports whether an element has an attribute
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
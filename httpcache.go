@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// contentETag returns the entity tag of served content
+func contentETag(text string) string {
+	return `"` + textHash(text)[:32] + `"`
+}
+
+// setLastModified sets the Last-Modified header to the modification time of
+// a document's file, if known
+func setLastModified(w http.ResponseWriter, doc Document) {
+	if !doc.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", doc.ModTime.UTC().Format(http.TimeFormat))
+	}
+}
+
+// bufferedResponse holds a response back so it can be validated before it
+// is sent
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// conditionalGet answers conditional GET and HEAD requests. Successful
+// responses without an ETag get one from a hash of their body, and are
+// replaced by 304 Not Modified if the client's copy is current.
+func conditionalGet(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferedResponse{ResponseWriter: w}
+		next.ServeHTTP(buf, r)
+		if buf.status == 0 {
+			buf.status = http.StatusOK
+		}
+
+		// Handlers such as http.FileServer answer HEAD requests without a
+		// body but with its Content-Length, which must be kept
+		bodyless := r.Method == http.MethodHead && buf.body.Len() == 0
+
+		header := w.Header()
+		if buf.status == http.StatusOK {
+			if header.Get("ETag") == "" && !bodyless {
+				sum := sha256.Sum256(buf.body.Bytes())
+				header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+			}
+			if notModified(r, header) {
+				header.Del("Content-Type")
+				header.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			if !bodyless {
+				header.Set("Content-Length", strconv.Itoa(buf.body.Len()))
+			}
+		}
+
+		w.WriteHeader(buf.status)
+		w.Write(buf.body.Bytes())
+	})
+}
+
+// notModified reports whether the client's copy of a response is current.
+// If-None-Match takes precedence over If-Modified-Since.
+func notModified(r *http.Request, header http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, header.Get("ETag"))
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(ims)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using weak
+// comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
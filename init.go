@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// initMaxDepth is how deep init looks for documentation folders
+	initMaxDepth = 3
+)
+
+// docFolderNames are folder names init recognizes as documentation
+var docFolderNames = map[string]bool{
+	"docs":          true,
+	"doc":           true,
+	"documentation": true,
+	"adr":           true,
+	"adrs":          true,
+	"wiki":          true,
+}
+
+// initSkipFolders are folders init never looks into
+var initSkipFolders = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+}
+
+// EmbeddingPreset is an embedding provider configuration offered by init
+type EmbeddingPreset struct {
+	Enabled  bool   `json:"enabled"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	APIKey   string `json:"api_key,omitempty"`
+	BaseURL  string `json:"base_url,omitempty"`
+	DBPath   string `json:"db_path"`
+}
+
+// embeddingPresets are the embedding providers offered by init
+var embeddingPresets = map[string]EmbeddingPreset{
+	"openai": {Enabled: true, Provider: "openai", Model: "text-embedding-3-large", APIKey: "${OPENAI_API_KEY}", DBPath: "embeddings.db"},
+	"ollama": {Enabled: true, Provider: "ollama", Model: "nomic-embed-text", BaseURL: "http://localhost:11434", DBPath: "embeddings.db"},
+	"voyage": {Enabled: true, Provider: "voyage", Model: "voyage-3", APIKey: "${VOYAGE_API_KEY}", DBPath: "embeddings.db"},
+	"cohere": {Enabled: true, Provider: "cohere", Model: "embed-english-v3.0", APIKey: "${COHERE_API_KEY}", DBPath: "embeddings.db"},
+	"tei":    {Enabled: true, Provider: "tei", BaseURL: "http://localhost:8080", DBPath: "embeddings.db"},
+}
+
+// EmbeddingPresetNames returns the names of the embedding presets, and
+// "none" for a configuration without semantic search
+func EmbeddingPresetNames() []string {
+	names := []string{"none"}
+	for name := range embeddingPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// InitConfig is the configuration file written by init. It holds the subset
+// of Config a new project needs, so the file stays short.
+type InitConfig struct {
+	Title          string            `json:"title"`
+	Port           string            `json:"port"`
+	Directories    []DirectoryConfig `json:"directories"`
+	IgnorePatterns []string          `json:"ignore_patterns"`
+	Embeddings     *EmbeddingPreset  `json:"embeddings,omitempty"`
+}
+
+// DetectedDirectory is a documentation directory found by init
+type DetectedDirectory struct {
+	Path        string
+	Name        string
+	FilePattern string
+	Reason      string // Why it was detected, shown when prompting
+}
+
+// DetectDocumentation looks for documentation folders and README files
+// below root. Documentation folders are indexed with all their markdown files;
+// README files elsewhere are collected by one entry for root.
+func DetectDocumentation(root string) []DetectedDirectory {
+	var folders []DetectedDirectory
+	readmes := 0
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if d.IsDir() {
+			if rel == "." {
+				return nil
+			}
+			name := strings.ToLower(d.Name())
+			if strings.HasPrefix(name, ".") || initSkipFolders[name] || strings.Count(rel, string(filepath.Separator)) >= initMaxDepth {
+				return filepath.SkipDir
+			}
+			if docFolderNames[name] {
+				folders = append(folders, DetectedDirectory{
+					Path:        "./" + filepath.ToSlash(rel),
+					Name:        folderTitle(rel),
+					FilePattern: `\.md$`,
+					Reason:      "documentation folder",
+				})
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(d.Name(), "readme.md") {
+			readmes++
+		}
+		return nil
+	})
+
+	var detected []DetectedDirectory
+	if readmes > 0 {
+		name := filepath.Base(root)
+		if abs, err := filepath.Abs(root); err == nil {
+			name = filepath.Base(abs)
+		}
+		detected = append(detected, DetectedDirectory{
+			Path:        ".",
+			Name:        name,
+			FilePattern: `^(?i)(readme\.md)$`,
+			Reason:      pluralize(readmes, "README file"),
+		})
+	}
+	return append(detected, folders...)
+}
+
+// folderTitle turns a relative folder path into a directory name, e.g.
+// "api/docs" into "Api Docs"
+func folderTitle(rel string) string {
+	words := strings.FieldsFunc(filepath.ToSlash(rel), func(r rune) bool {
+		return r == '/' || r == '-' || r == '_'
+	})
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// pluralize formats a count with a noun, e.g. "3 README files"
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// NewInitConfig builds the configuration for the chosen directories and
+// embedding preset ("none" disables semantic search)
+func NewInitConfig(title string, dirs []DetectedDirectory, preset string) InitConfig {
+	cfg := InitConfig{
+		Title: title,
+		Port:  "8090",
+		IgnorePatterns: []string{
+			".*/node_modules/.*",
+			".*/\\.git/.*",
+			".*/vendor/.*",
+		},
+	}
+	for _, dir := range dirs {
+		cfg.Directories = append(cfg.Directories, DirectoryConfig{
+			Path:        dir.Path,
+			Name:        dir.Name,
+			FilePattern: dir.FilePattern,
+		})
+	}
+	if p, ok := embeddingPresets[preset]; ok {
+		cfg.Embeddings = &p
+	}
+	return cfg
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dimandocs/frontmatter"
+	"dimandocs/render"
+)
+
+// BrokenLink represents a link between documents whose target file or
+// heading anchor does not exist
+type BrokenLink struct {
+	Source string `json:"Source"` // RelPath of the linking document
+	Line   int    `json:"Line"`   // Line of the link in the source file
+	Target string `json:"Target"` // Link target as written
+	Reason string `json:"Reason"`
+}
+
+// LinkReport represents the result of checking the links of all documents
+type LinkReport struct {
+	Documents int          `json:"Documents"` // Documents checked
+	Links     int          `json:"Links"`     // Relative links checked
+	Broken    []BrokenLink `json:"Broken"`
+}
+
+// CheckLinks checks the relative links and images of every markdown
+// document: the linked files must exist, and anchors must match a heading of
+// the linked document
+func (a *App) CheckLinks() LinkReport {
+	documents := a.GetDocuments()
+	byPath := documentsByPath(documents)
+	report := LinkReport{Broken: []BrokenLink{}}
+
+	// Anchors of each document, rendered on first use
+	anchors := make(map[string]map[string]bool)
+	hasAnchor := func(doc *Document, anchor string) bool {
+		ids, ok := anchors[doc.RelPath]
+		if !ok {
+			ids = make(map[string]bool)
+			_, toc := render.MarkdownWithTOC(documentMarkdown(*doc), render.Options{})
+			for _, heading := range toc {
+				ids[heading.ID] = true
+			}
+			anchors[doc.RelPath] = ids
+		}
+		if unescaped, err := url.PathUnescape(anchor); err == nil {
+			anchor = unescaped
+		}
+		return ids[anchor]
+	}
+
+	for i := range documents {
+		doc := &documents[i]
+		if doc.Language != "" {
+			continue
+		}
+		report.Documents++
+		offset := frontmatterLines(doc.Path)
+
+		for _, link := range extractMarkdownLinks(doc.Content()) {
+			if isExternalLink(link.Target) {
+				continue
+			}
+			broken := func(reason string) {
+				report.Broken = append(report.Broken, BrokenLink{
+					Source: doc.RelPath,
+					Line:   link.Line + offset,
+					Target: link.Target,
+					Reason: reason,
+				})
+			}
+
+			path, anchor := splitLinkTarget(link.Target)
+			if path == "" {
+				// Anchor within the document itself
+				report.Links++
+				if anchor != "" && !hasAnchor(doc, anchor) {
+					broken("no heading with this anchor")
+				}
+				continue
+			}
+			resolved := resolveLinkPath(*doc, link.Target)
+			if resolved == "" {
+				continue
+			}
+			report.Links++
+
+			info, err := os.Stat(resolved)
+			if err != nil {
+				broken("file not found")
+				continue
+			}
+			if anchor == "" || info.IsDir() {
+				continue
+			}
+			absPath, err := filepath.Abs(resolved)
+			if err != nil {
+				continue
+			}
+			// Anchors are only checked in documents, other files have no headings
+			if target, ok := byPath[absPath]; ok && target.Language == "" && !hasAnchor(target, anchor) {
+				broken("no heading with this anchor in " + target.RelPath)
+			}
+		}
+	}
+	return report
+}
+
+// frontmatterLines returns the number of lines of a file's frontmatter, which
+// are stripped from the document content
+func frontmatterLines(path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	_, body, ok := frontmatter.Split(string(content))
+	if !ok {
+		return 0
+	}
+	return strings.Count(string(content[:len(content)-len(body)]), "\n")
+}
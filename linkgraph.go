@@ -0,0 +1,351 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"dimandocs/render"
+)
+
+// markdownLinkRegex matches inline markdown links and images: [text](target "title")
+var markdownLinkRegex = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*<?([^)\s>]+)>?(?:\s+["'][^)]*["'])?\s*\)`)
+
+// schemeRegex matches URLs with a scheme such as http: or mailto:
+var schemeRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// markdownLink represents a link found in markdown content
+type markdownLink struct {
+	Text    string
+	Target  string
+	Line    int // 1-based line number
+	IsImage bool
+}
+
+// DocumentLink represents a resolved link between two documents
+type DocumentLink struct {
+	Source string `json:"source"` // RelPath of the linking document
+	Target string `json:"target"` // RelPath of the linked document
+	Text   string `json:"text"`
+	Line   int    `json:"line"`
+	Anchor string `json:"anchor,omitempty"`
+}
+
+// LinkGraph holds intra-corpus links between documents, keyed by RelPath
+type LinkGraph struct {
+	Links     map[string][]DocumentLink // Outgoing links per document
+	Backlinks map[string][]DocumentLink // Incoming links per document
+}
+
+// GraphNode represents a document in the link graph API response
+type GraphNode struct {
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	SourceName string `json:"source"`
+}
+
+// GraphEdge represents a link in the link graph API response
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Count  int    `json:"count"`
+}
+
+// GraphData represents the link graph API response
+type GraphData struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// extractMarkdownLinks returns all inline links in content, skipping fenced
+// code blocks and inline code
+func extractMarkdownLinks(content string) []markdownLink {
+	var links []markdownLink
+	inFence := false
+
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		line = inlineCodeRegex.ReplaceAllString(line, "")
+		for _, match := range markdownLinkRegex.FindAllStringSubmatch(line, -1) {
+			links = append(links, markdownLink{
+				Text:    match[2],
+				Target:  match[3],
+				Line:    i + 1,
+				IsImage: match[1] == "!",
+			})
+		}
+	}
+
+	return links
+}
+
+// isExternalLink reports whether a link target points outside the corpus
+func isExternalLink(target string) bool {
+	return schemeRegex.MatchString(target) || strings.HasPrefix(target, "//")
+}
+
+// splitLinkTarget splits a link target into its unescaped path and anchor
+func splitLinkTarget(target string) (string, string) {
+	path, anchor, _ := strings.Cut(target, "#")
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	return path, anchor
+}
+
+// resolveLinkPath resolves a relative link target from a document to a file
+// path on disk. Links to directories resolve to their README.md.
+func resolveLinkPath(doc Document, target string) string {
+	path, _ := splitLinkTarget(target)
+	if path == "" || strings.HasPrefix(path, "/") {
+		return ""
+	}
+
+	resolved := filepath.Clean(filepath.Join(filepath.Dir(doc.Path), filepath.FromSlash(path)))
+	if info, err := os.Stat(resolved); err == nil && info.IsDir() {
+		for _, name := range []string{"README.md", "readme.md", "index.md"} {
+			candidate := filepath.Join(resolved, name)
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate
+			}
+		}
+	}
+	return resolved
+}
+
+// assetExtensions are the file types served by the /assets/ route, for the
+// images documents show
+var assetExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+	".webp": true, ".avif": true, ".bmp": true, ".ico": true,
+}
+
+// linkRewriter returns the link rewriting of a document for rendering:
+// relative links to other documents point at their page, and relative images
+// at the /assets/ route. Other links are left as they are.
+func (a *App) linkRewriter(doc Document) func(dest string, image bool) string {
+	var byPath map[string]*Document
+	return func(dest string, image bool) string {
+		if isExternalLink(dest) || strings.HasPrefix(dest, "#") {
+			return dest
+		}
+		resolved := resolveLinkPath(doc, dest)
+		if resolved == "" {
+			return dest
+		}
+		absPath, err := filepath.Abs(resolved)
+		if err != nil {
+			return dest
+		}
+
+		if !image {
+			if byPath == nil {
+				byPath = documentsByPath(a.GetDocuments())
+			}
+			if target, ok := byPath[absPath]; ok {
+				link := render.DocumentURL(target.RelPath, "")
+				if _, anchor := splitLinkTarget(dest); anchor != "" {
+					link += "#" + anchor
+				}
+				return link
+			}
+		}
+		if asset, ok := assetURL(doc.SourceDir, absPath); ok {
+			return asset
+		}
+		return dest
+	}
+}
+
+// assetURL returns the /assets/ route of an image file within a directory
+func assetURL(rootDir, absPath string) (string, bool) {
+	if !assetExtensions[strings.ToLower(filepath.Ext(absPath))] {
+		return "", false
+	}
+	absRoot, err := filepath.Abs(rootDir)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/assets/" + strings.Join(segments, "/"), true
+}
+
+// documentsByPath indexes documents by their absolute file path
+func documentsByPath(docs []Document) map[string]*Document {
+	byPath := make(map[string]*Document, len(docs))
+	for i := range docs {
+		if absPath, err := filepath.Abs(docs[i].Path); err == nil {
+			byPath[absPath] = &docs[i]
+		}
+	}
+	return byPath
+}
+
+// buildLinkGraph extracts links between documents and builds the backlinks index
+func buildLinkGraph(docs []Document) *LinkGraph {
+	graph := &LinkGraph{
+		Links:     make(map[string][]DocumentLink),
+		Backlinks: make(map[string][]DocumentLink),
+	}
+	byPath := documentsByPath(docs)
+
+	for _, doc := range docs {
+		for _, link := range extractMarkdownLinks(doc.Content()) {
+			if link.IsImage || isExternalLink(link.Target) {
+				continue
+			}
+
+			resolved := resolveLinkPath(doc, link.Target)
+			if resolved == "" {
+				continue
+			}
+			absPath, err := filepath.Abs(resolved)
+			if err != nil {
+				continue
+			}
+			target, ok := byPath[absPath]
+			if !ok || target.RelPath == doc.RelPath {
+				continue
+			}
+
+			_, anchor := splitLinkTarget(link.Target)
+			docLink := DocumentLink{
+				Source: doc.RelPath,
+				Target: target.RelPath,
+				Text:   link.Text,
+				Line:   link.Line,
+				Anchor: anchor,
+			}
+			graph.Links[doc.RelPath] = append(graph.Links[doc.RelPath], docLink)
+			graph.Backlinks[target.RelPath] = append(graph.Backlinks[target.RelPath], docLink)
+		}
+	}
+
+	return graph
+}
+
+// GetBacklinks returns the documents linking to the document at relPath
+func (a *App) GetBacklinks(relPath string) []Document {
+	a.mu.RLock()
+	links := a.Links
+	a.mu.RUnlock()
+	if links == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var docs []Document
+	for _, link := range links.Backlinks[relPath] {
+		if seen[link.Source] {
+			continue
+		}
+		seen[link.Source] = true
+		if doc := a.findDocument(link.Source); doc != nil {
+			docs = append(docs, *doc)
+		}
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].Title < docs[j].Title
+	})
+	return docs
+}
+
+// GraphData returns the link graph as nodes and aggregated edges
+func (a *App) GraphData() GraphData {
+	a.mu.RLock()
+	documents, links := a.Documents, a.Links
+	a.mu.RUnlock()
+
+	data := GraphData{
+		Nodes: make([]GraphNode, 0, len(documents)),
+		Edges: []GraphEdge{},
+	}
+
+	for _, doc := range documents {
+		data.Nodes = append(data.Nodes, GraphNode{
+			ID:         doc.RelPath,
+			Title:      doc.Title,
+			SourceName: doc.SourceName,
+		})
+	}
+
+	if links == nil {
+		return data
+	}
+
+	edgeIndex := make(map[[2]string]int)
+	for _, doc := range documents {
+		for _, link := range links.Links[doc.RelPath] {
+			key := [2]string{link.Source, link.Target}
+			if i, ok := edgeIndex[key]; ok {
+				data.Edges[i].Count++
+				continue
+			}
+			edgeIndex[key] = len(data.Edges)
+			data.Edges = append(data.Edges, GraphEdge{
+				Source: link.Source,
+				Target: link.Target,
+				Count:  1,
+			})
+		}
+	}
+
+	return data
+}
+
+// GetBacklinkDetails returns every link to the document at relPath, in the
+// order of the linking documents and their lines
+func (a *App) GetBacklinkDetails(relPath string) []Backlink {
+	a.mu.RLock()
+	links := a.Links
+	a.mu.RUnlock()
+
+	backlinks := []Backlink{}
+	if links == nil {
+		return backlinks
+	}
+	titles := make(map[string]string)
+	for _, doc := range a.GetDocuments() {
+		titles[doc.RelPath] = doc.Title
+	}
+	for _, link := range links.Backlinks[relPath] {
+		backlinks = append(backlinks, Backlink{
+			Title:   titles[link.Source],
+			RelPath: link.Source,
+			Text:    link.Text,
+			Line:    link.Line,
+			Anchor:  link.Anchor,
+		})
+	}
+	return backlinks
+}
+
+// findDocument returns the document with the given RelPath, or nil
+func (a *App) findDocument(relPath string) *Document {
+	documents := a.GetDocuments()
+	for i := range documents {
+		if documents[i].RelPath == relPath {
+			return &documents[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultOllamaURL is the default Ollama API endpoint
+	DefaultOllamaURL = "http://localhost:11434"
+	// DefaultOllamaModel is the default chat model for Ollama
+	DefaultOllamaModel = "llama3.2"
+	// OllamaTimeout is the timeout for Ollama chat requests
+	OllamaTimeout = 5 * time.Minute
+)
+
+// OllamaClient implements Client using the Ollama chat API
+type OllamaClient struct {
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+// OllamaConfig holds configuration for the Ollama chat client
+type OllamaConfig struct {
+	BaseURL string // Default: http://localhost:11434
+	Model   string // Default: llama3.2
+}
+
+// ollamaChatRequest represents the request body for the Ollama chat API
+type ollamaChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+}
+
+// ollamaChatResponse represents a response from the Ollama chat API
+type ollamaChatResponse struct {
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+}
+
+// NewOllamaClient creates a new Ollama chat client
+func NewOllamaClient(cfg OllamaConfig) (*OllamaClient, error) {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = DefaultOllamaModel
+	}
+
+	return &OllamaClient{
+		baseURL: baseURL,
+		model:   model,
+		client: &http.Client{
+			Timeout: OllamaTimeout,
+		},
+	}, nil
+}
+
+// Complete generates a response for the given conversation
+func (c *OllamaClient) Complete(ctx context.Context, messages []Message) (string, error) {
+	reqBody := ollamaChatRequest{
+		Model:    c.model,
+		Messages: messages,
+		Stream:   false,
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var chatResp ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return chatResp.Message.Content, nil
+}
+
+// Stream generates a response for the given conversation, passing each piece
+// of it to onDelta as Ollama streams it
+func (c *OllamaClient) Stream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	reqBody := ollamaChatRequest{
+		Model:    c.model,
+		Messages: messages,
+		Stream:   true,
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	// The response is a JSON object per line, the last one marked done
+	var response strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var chatResp ollamaChatResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chatResp); err != nil {
+			return "", fmt.Errorf("failed to decode response: %w", err)
+		}
+		if delta := chatResp.Message.Content; delta != "" {
+			response.WriteString(delta)
+			if err := onDelta(delta); err != nil {
+				return "", err
+			}
+		}
+		if chatResp.Done {
+			return response.String(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	return "", fmt.Errorf("ollama response ended before it was done")
+}
@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// DefaultOpenAIModel is the default chat model for OpenAI
+	DefaultOpenAIModel = openai.GPT4oMini
+)
+
+// OpenAIClient implements Client using the OpenAI chat completions API
+type OpenAIClient struct {
+	client *openai.Client
+	model  string
+}
+
+// OpenAIConfig holds configuration for the OpenAI chat client
+type OpenAIConfig struct {
+	APIKey  string
+	Model   string
+	BaseURL string // Optional: for proxies or compatible APIs
+}
+
+// NewOpenAIClient creates a new OpenAI chat client
+func NewOpenAIClient(cfg OpenAIConfig) (*OpenAIClient, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("OpenAI API key is required")
+	}
+
+	config := openai.DefaultConfig(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		config.BaseURL = cfg.BaseURL
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+
+	return &OpenAIClient{
+		client: openai.NewClientWithConfig(config),
+		model:  model,
+	}, nil
+}
+
+// Complete generates a response for the given conversation
+func (c *OpenAIClient) Complete(ctx context.Context, messages []Message) (string, error) {
+	req := openai.ChatCompletionRequest{
+		Model:    c.model,
+		Messages: toOpenAIMessages(messages),
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create chat completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no completion choices returned")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+// Stream generates a response for the given conversation, passing each piece
+// of it to onDelta as OpenAI streams it
+func (c *OpenAIClient) Stream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error) {
+	req := openai.ChatCompletionRequest{
+		Model:    c.model,
+		Messages: toOpenAIMessages(messages),
+	}
+
+	stream, err := c.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create chat completion: %w", err)
+	}
+	defer stream.Close()
+
+	var response strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return response.String(), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to receive chat completion: %w", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		response.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return "", err
+		}
+	}
+}
+
+// toOpenAIMessages converts messages to the OpenAI request format
+func toOpenAIMessages(messages []Message) []openai.ChatCompletionMessage {
+	result := make([]openai.ChatCompletionMessage, len(messages))
+	for i, m := range messages {
+		result[i] = openai.ChatCompletionMessage{
+			Role:    m.Role,
+			Content: m.Content,
+		}
+	}
+	return result
+}
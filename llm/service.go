@@ -0,0 +1,57 @@
+package llm
+
+import "context"
+
+const (
+	// RoleSystem is the role for system instructions
+	RoleSystem = "system"
+	// RoleUser is the role for user messages
+	RoleUser = "user"
+	// RoleAssistant is the role for model responses
+	RoleAssistant = "assistant"
+)
+
+// AnswerSystemPrompt instructs a model to answer questions about
+// documentation from the numbered excerpts it is given, citing them by number
+const AnswerSystemPrompt = "You answer questions about technical documentation. " +
+	"Use only the numbered documentation excerpts you are given. " +
+	"Cite the excerpts you rely on by their number in square brackets, e.g. [2]. " +
+	"If the excerpts do not contain the answer, say that the documentation does not cover it. " +
+	"Never repeat secrets or credentials."
+
+// Message represents a single chat message
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Client defines the interface for chat completion models
+type Client interface {
+	// Complete generates a response for the given conversation
+	Complete(ctx context.Context, messages []Message) (string, error)
+}
+
+// StreamClient is implemented by clients that can stream their response
+type StreamClient interface {
+	Client
+	// Stream generates a response like Complete, passing each piece of it to
+	// onDelta as it arrives, and returns the whole response. An error of
+	// onDelta stops the generation.
+	Stream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error)
+}
+
+// Stream generates a response with the client, streaming it if the client
+// supports it, and otherwise passing the whole response to onDelta at once
+func Stream(ctx context.Context, client Client, messages []Message, onDelta func(delta string) error) (string, error) {
+	if s, ok := client.(StreamClient); ok {
+		return s.Stream(ctx, messages, onDelta)
+	}
+	response, err := client.Complete(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	if err := onDelta(response); err != nil {
+		return "", err
+	}
+	return response, nil
+}
@@ -1,13 +1,24 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
 
+	"dimandocs/frontmatter"
+	"dimandocs/llm"
 	"dimandocs/mcp"
+	"dimandocs/vector"
 )
 
 var (
@@ -17,104 +28,346 @@ var (
 )
 
 func main() {
-	// Check for subcommands
+	// Dispatch subcommands; without one, the web server is started
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
+		case "serve":
+			runServeCommand(os.Args[2:])
+			return
 		case "index":
 			runIndexCommand(os.Args[2:])
 			return
+		case "search":
+			runSearchCommand(os.Args[2:])
+			return
+		case "mcp":
+			runMCPCommand(os.Args[2:])
+			return
+		case "validate":
+			runValidateCommand(os.Args[2:])
+			return
+		case "check-links":
+			runCheckLinksCommand(os.Args[2:])
+			return
+		case "db":
+			runDBCommand(os.Args[2:])
+			return
+		case "init":
+			runInitCommand(os.Args[2:])
+			return
+		case "version", "--version", "-version":
+			printVersion()
+			return
 		case "help", "--help", "-h":
 			printUsage()
 			return
 		}
 	}
 
-	// Parse command line flags for main command
-	showVersion := flag.Bool("version", false, "Show version information")
-	mcpMode := flag.Bool("mcp", false, "Run in MCP server mode (stdio transport)")
-	flag.Parse()
+	runServeCommand(os.Args[1:])
+}
 
-	// Show version and exit
-	if *showVersion {
-		fmt.Printf("DimanDocs %s\n", Version)
-		fmt.Printf("Build Time: %s\n", BuildTime)
-		os.Exit(0)
+// runServeCommand handles the "serve" subcommand: the web interface, with
+// MCP over HTTP next to it when configured
+func runServeCommand(args []string) {
+	serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+	mcpMode := serveFlags.Bool("mcp", false, "Deprecated: use the mcp command")
+	serveFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs serve [config_file]\n\n")
+		fmt.Fprintf(os.Stderr, "Index documents and start the web interface.\n")
 	}
+	serveFlags.Parse(args)
 
-	// Get config file from command line args
-	configFile := ""
-	if flag.NArg() > 0 {
-		configFile = flag.Arg(0)
+	if *mcpMode {
+		runMCPCommand(serveFlags.Args())
+		return
 	}
 
-	// Create and initialize application
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	app := loadApp(serveFlags.Arg(0))
+	defer app.Close()
+
+	embedManager := startEmbeddings(ctx, app)
+	if embedManager != nil {
+		defer embedManager.Close()
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	stop := startUpdates(app)
+	defer stop()
+
+	// Serve MCP over HTTP next to the web interface, so remote agents can
+	// connect to a deployed instance
+	mcpDone := make(chan struct{})
+	if app.Config.MCP.Enabled && app.Config.MCP.Transport == "http" {
+		if embedManager == nil {
+			fatal("MCP server requires embeddings to be enabled in config")
+		}
+		mcpServer := newMCPServer(app, embedManager)
+		go func() {
+			defer close(mcpDone)
+			slog.Info("Starting MCP server over HTTP", "port", app.Config.MCP.Port)
+			if err := mcpServer.ServeHTTPTransport(ctx, fmt.Sprintf(":%d", app.Config.MCP.Port)); err != nil {
+				fatal("MCP server error", "error", err)
+			}
+		}()
+	} else {
+		close(mcpDone)
+	}
+
+	if err := app.Start(ctx); err != nil {
+		fatal("Failed to start server", "error", err)
+	}
+	<-mcpDone
+	slog.Info("Server stopped")
+}
+
+// runMCPCommand handles the "mcp" subcommand: the MCP server only, without
+// the web interface
+func runMCPCommand(args []string) {
+	mcpFlags := flag.NewFlagSet("mcp", flag.ExitOnError)
+	stdio := mcpFlags.Bool("stdio", false, "Serve over stdio (default unless the config sets \"transport\": \"http\")")
+	httpMode := mcpFlags.Bool("http", false, "Serve over HTTP")
+	port := mcpFlags.Int("port", 0, "HTTP port (default: mcp.port from config, or 8081)")
+	mcpFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs mcp [options] [config_file]\n\n")
+		fmt.Fprintf(os.Stderr, "Index documents and run the MCP server.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		mcpFlags.PrintDefaults()
+	}
+	mcpFlags.Parse(args)
+
+	if *stdio && *httpMode {
+		fatal("--stdio and --http cannot be combined")
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	app := loadApp(mcpFlags.Arg(0))
+	defer app.Close()
+
+	switch {
+	case *stdio:
+		app.Config.MCP.Transport = "stdio"
+	case *httpMode:
+		app.Config.MCP.Transport = "http"
+	}
+	if *port > 0 {
+		app.Config.MCP.Port = *port
+	}
+
+	embedManager := startEmbeddings(ctx, app)
+	if embedManager == nil {
+		fatal("MCP mode requires embeddings to be enabled in config")
+	}
+	defer embedManager.Close()
+	if ctx.Err() != nil {
+		return
+	}
+
+	stop := startUpdates(app)
+	defer stop()
+
+	mcpServer := newMCPServer(app, embedManager)
+	var err error
+	if app.Config.MCP.Transport == "http" {
+		slog.Info("Starting MCP server over HTTP", "port", app.Config.MCP.Port)
+		err = mcpServer.ServeHTTPTransport(ctx, fmt.Sprintf(":%d", app.Config.MCP.Port))
+	} else {
+		slog.Info("Starting MCP server over stdio")
+		err = mcpServer.ServeStdio(ctx)
+	}
+	if err != nil {
+		fatal("MCP server error", "error", err)
+	}
+}
+
+// loadApp creates and initializes the application from the config file
+func loadApp(configFile string) *App {
 	app := NewApp()
 	if err := app.Initialize(configFile); err != nil {
-		log.Fatalf("Failed to initialize application: %v", err)
+		fatal("Failed to initialize application", "error", err)
 	}
+	return app
+}
 
-	// Initialize embedding manager if enabled
-	var embedManager *EmbeddingManager
-	if app.Config.Embeddings.Enabled {
-		var err error
-		embedManager, err = NewEmbeddingManager(app.Config.Embeddings)
-		if err != nil {
-			log.Fatalf("Failed to initialize embedding manager: %v", err)
+// signalContext returns a context that is cancelled on SIGINT or SIGTERM, to
+// shut down gracefully. A second signal terminates the process immediately.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case sig := <-signals:
+			slog.Info("Shutting down, repeat the signal to exit immediately", "signal", sig.String())
+			cancel()
+		case <-ctx.Done():
 		}
-		defer embedManager.Close()
+	}()
+	return ctx, cancel
+}
+
+// startEmbeddings creates the embedding manager and indexes all documents,
+// or returns nil when embeddings are disabled. Indexing stops early when ctx
+// is cancelled.
+func startEmbeddings(ctx context.Context, app *App) *EmbeddingManager {
+	if !app.Config.Embeddings.Enabled {
+		return nil
+	}
+
+	embedManager, err := NewEmbeddingManager(app.Config.Embeddings, app.Config.LLM, app.Config.Search)
+	if err != nil {
+		fatal("Failed to initialize embedding manager", "error", err)
+	}
+	if err := embedManager.ConfigureDirectories(app.Config.Directories); err != nil {
+		fatal("Failed to initialize embedding manager", "error", err)
+	}
 
-		// Set embedding manager on app for vector search in web interface
-		app.EmbeddingManager = embedManager
+	// Set embedding manager on app for vector search in web interface
+	app.EmbeddingManager = embedManager
 
-		// Index all documents
-		ctx := context.Background()
-		for _, doc := range app.Documents {
-			if err := embedManager.IndexDocument(ctx, doc, false); err != nil {
-				log.Printf("Warning: failed to index document %s: %v", doc.RelPath, err)
-			}
+	// Index all documents
+	embedManager.IndexDocuments(ctx, app.Documents, false, func(doc Document, _ IndexStats, err error) {
+		if err != nil {
+			slog.Warn("Failed to index document", "path", doc.RelPath, "error", err)
 		}
-		log.Printf("Embedding indexing complete")
+	})
+	if ctx.Err() != nil {
+		slog.Info("Embedding indexing interrupted")
+		return embedManager
 	}
+	slog.Info("Embedding indexing complete")
+	pruneStale(embedManager, app.Documents, app.Config.Embeddings.Prune)
 
-	// MCP mode - run as MCP server
-	if *mcpMode {
-		if !app.Config.Embeddings.Enabled {
-			log.Fatal("MCP mode requires embeddings to be enabled in config")
-		}
+	// Attach generated summaries for list views
+	app.LoadSummaries()
+	return embedManager
+}
 
-		docProvider := NewAppDocumentProvider(app)
+// pruneStale deletes the indexed documents whose files are gone, or only
+// logs them, according to the prune mode
+func pruneStale(embedManager *EmbeddingManager, docs []Document, mode string) {
+	if mode == pruneOff {
+		return
+	}
+	dryRun := mode == pruneDryRun
+	stale, err := embedManager.PruneDocuments(docs, dryRun)
+	if err != nil {
+		slog.Warn("Failed to prune stale documents", "error", err)
+	}
+	for _, path := range stale {
+		if dryRun {
+			slog.Info("Stale document not pruned (dry run)", "path", path)
+		} else {
+			slog.Info("Pruned stale document", "path", path)
+		}
+	}
+}
 
-		mcpServer, err := mcp.NewServer(mcp.Config{
-			Name:         "dimandocs",
-			Version:      Version,
-			VectorStore:  embedManager.GetVectorStore(),
-			EmbedService: embedManager.GetEmbedService(),
-			DocProvider:  docProvider,
-		})
+// startUpdates watches the directories for changes, if enabled, pulls
+// remote sources periodically, backs up the vector database on schedule and
+// reloads the config on SIGHUP or, with watching enabled, when the file
+// changes. The returned function stops all of them.
+func startUpdates(app *App) func() {
+	var watcher *DocumentWatcher
+	if app.Config.Watch {
+		var err error
+		watcher, err = NewDocumentWatcher(app)
 		if err != nil {
-			log.Fatalf("Failed to create MCP server: %v", err)
+			fatal("Failed to start file watcher", "error", err)
 		}
+		watcher.Start()
+		app.OnConfigReloaded(watcher.WatchDirectories)
+		slog.Info("Watching directories for changes")
+	}
+
+	refresher := NewSourceRefresher(app)
+	refresher.Start()
+	app.OnConfigReloaded(refresher.Restart)
+
+	reloader, err := NewConfigReloader(app, app.Config.Watch)
+	if err != nil {
+		fatal("Failed to start config reloader", "error", err)
+	}
+	reloader.Start()
 
-		log.Println("Starting MCP server over stdio...")
-		if err := mcpServer.ServeStdio(); err != nil {
-			log.Fatalf("MCP server error: %v", err)
+	backups := NewBackupScheduler(app.EmbeddingManager, app.Config.Embeddings)
+	if backups != nil {
+		backups.Start()
+	}
+
+	return func() {
+		reloader.Close()
+		if backups != nil {
+			backups.Close()
+		}
+		refresher.Close()
+		if watcher != nil {
+			watcher.Close()
 		}
-		return
 	}
+}
 
-	// Normal mode - start HTTP server
-	if err := app.Start(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// newMCPServer creates the MCP server for the app
+func newMCPServer(app *App, embedManager *EmbeddingManager) *mcp.Server {
+	// The answer_question tool is offered when a chat model is available
+	var chatModel llm.Client
+	if client, err := NewLLMClient(app.Config.LLM); err != nil {
+		slog.Warn("answer_question MCP tool disabled", "error", err)
+	} else {
+		chatModel = client
 	}
+
+	reranker, rerankTopK := embedManager.Reranker()
+	mcpServer, err := mcp.NewServer(mcp.Config{
+		Name:         "dimandocs",
+		Version:      Version,
+		VectorStore:  embedManager.GetVectorStore(),
+		EmbedService: embedManager.GetEmbedService(),
+		DocProvider:  NewAppDocumentProvider(app),
+		BaseURL:      app.Config.MCP.BaseURL,
+		Secrets:      app.SecretScanner,
+		AuthTokens:   app.Config.MCP.AuthTokens,
+		AllowReindex: mcpAllowsReindex(app.Config.MCP),
+		SearchMode:   app.Config.Embeddings.SearchMode,
+		LLM:          chatModel,
+		Reranker:     reranker,
+		RerankTopK:   rerankTopK,
+		Expander:     embedManager.Expander(),
+	})
+	if err != nil {
+		fatal("Failed to create MCP server", "error", err)
+	}
+	// Keep the listed resources in step with watcher and refresher updates
+	app.OnDocumentsChanged(mcpServer.SyncResources)
+	if app.Config.MCP.Transport == "http" && len(app.Config.MCP.AuthTokens) == 0 {
+		slog.Warn("MCP HTTP transport has no auth_tokens, anyone reaching the port can read the documentation", "port", app.Config.MCP.Port)
+	}
+	return mcpServer
+}
+
+// mcpAllowsReindex reports whether MCP clients may reindex. Over HTTP this
+// requires auth tokens, so that not anyone reaching the port can re-embed
+// the documentation at the provider's expense.
+func mcpAllowsReindex(cfg MCPConfig) bool {
+	return cfg.AllowReindex && (cfg.Transport != "http" || len(cfg.AuthTokens) > 0)
 }
 
 // runIndexCommand handles the "index" subcommand
 func runIndexCommand(args []string) {
 	indexFlags := flag.NewFlagSet("index", flag.ExitOnError)
 	force := indexFlags.Bool("force", false, "Force re-indexing of all documents, ignoring cache")
+	pruneDryRunFlag := indexFlags.Bool("prune-dry-run", false, "List indexed documents whose files are gone instead of deleting them")
 	indexFlags.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: dimandocs index [options] [config_file]\n\n")
-		fmt.Fprintf(os.Stderr, "Index documents for semantic search.\n\n")
+		fmt.Fprintf(os.Stderr, "Index documents for semantic search. Exits with status 1 if any document fails.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		indexFlags.PrintDefaults()
 	}
@@ -129,62 +382,718 @@ func runIndexCommand(args []string) {
 	// Create and initialize application
 	app := NewApp()
 	if err := app.Initialize(configFile); err != nil {
-		log.Fatalf("Failed to initialize application: %v", err)
+		fatal("Failed to initialize application", "error", err)
 	}
 
 	// Check if embeddings are enabled
 	if !app.Config.Embeddings.Enabled {
-		log.Fatal("Embeddings are not enabled in config. Add 'embeddings' section to dimandocs.json")
+		fatal("Embeddings are not enabled in config. Add 'embeddings' section to dimandocs.json")
 	}
 
 	// Initialize embedding manager
-	embedManager, err := NewEmbeddingManager(app.Config.Embeddings)
+	embedManager, err := NewEmbeddingManager(app.Config.Embeddings, app.Config.LLM, app.Config.Search)
 	if err != nil {
-		log.Fatalf("Failed to initialize embedding manager: %v", err)
+		fatal("Failed to initialize embedding manager", "error", err)
+	}
+	if err := embedManager.ConfigureDirectories(app.Config.Directories); err != nil {
+		fatal("Failed to initialize embedding manager", "error", err)
 	}
 	defer embedManager.Close()
 
-	// Index all documents
+	// Index all documents, reporting progress per document as it completes.
+	// An interrupt stops after the documents being indexed, which keeps the
+	// index consistent.
+	ctx, cancel := signalContext()
+	defer cancel()
+	var done, indexed, skipped, failed, chunks, tokens int
+	var embedTime time.Duration
+
+	processed := embedManager.IndexDocuments(ctx, app.Documents, *force, func(doc Document, stats IndexStats, err error) {
+		done++
+		progress := fmt.Sprintf("[%d/%d] %s", done, len(app.Documents), doc.RelPath)
+		embedTime += stats.EmbedTime
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("%s: failed: %v\n", progress, err)
+		case stats.Skipped:
+			skipped++
+			fmt.Printf("%s: up to date\n", progress)
+		default:
+			indexed++
+			chunks += stats.Chunks
+			tokens += stats.Tokens
+			fmt.Printf("%s: %d chunks, %d tokens, %d embedded in %s\n",
+				progress, stats.Chunks, stats.Tokens, stats.Embedded, stats.EmbedTime.Round(time.Millisecond))
+		}
+	})
+	if remaining := len(app.Documents) - processed; remaining > 0 {
+		fmt.Printf("Interrupted, %d documents not processed\n", remaining)
+		failed += remaining
+	}
+
+	fmt.Printf("\nIndexed %d documents (%d chunks, %d tokens, %s embedding), %d skipped as up to date, %d failed\n",
+		indexed, chunks, tokens, embedTime.Round(time.Millisecond), skipped, failed)
+
+	// Remove documents of deleted or renamed files, unless indexing was
+	// interrupted
+	if ctx.Err() == nil && (app.Config.Embeddings.Prune != pruneOff || *pruneDryRunFlag) {
+		dryRun := *pruneDryRunFlag || app.Config.Embeddings.Prune == pruneDryRun
+		stale, err := embedManager.PruneDocuments(app.Documents, dryRun)
+		for _, path := range stale {
+			fmt.Printf("stale: %s\n", path)
+		}
+		switch {
+		case err != nil:
+			fmt.Printf("Failed to prune stale documents: %v\n", err)
+			failed++
+		case dryRun:
+			fmt.Printf("%d stale documents would be pruned (dry run)\n", len(stale))
+		default:
+			fmt.Printf("Pruned %d stale documents\n", len(stale))
+		}
+	}
+
+	// Fail CI pipelines when documents could not be indexed
+	if failed > 0 {
+		embedManager.Close()
+		os.Exit(1)
+	}
+}
+
+// runSearchCommand handles the "search" subcommand: searches the existing
+// index without scanning documents or starting a server
+func runSearchCommand(args []string) {
+	searchFlags := flag.NewFlagSet("search", flag.ExitOnError)
+	limit := searchFlags.Int("limit", 10, "Maximum number of results")
+	jsonOutput := searchFlags.Bool("json", false, "Print results as JSON")
+	mode := searchFlags.String("mode", "", "Search mode: vector or hybrid (default: search_mode of the embeddings section)")
+	source := searchFlags.String("source", "", "Only search documents of this source directory name")
+	collections := searchFlags.String("collection", "", "Only search documents in any of these comma-separated collections")
+	pathPrefix := searchFlags.String("path-prefix", "", "Only search documents whose path starts with this prefix")
+	tags := searchFlags.String("tag", "", "Only search documents having all of these comma-separated tags")
+	section := searchFlags.String("section", "", "Only search sections with this title")
+	searchFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs search [options] \"query\" [config_file]\n\n")
+		fmt.Fprintf(os.Stderr, "Search the indexed documents without starting a server.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		searchFlags.PrintDefaults()
+	}
+	positional := parseInterspersed(searchFlags, args)
+
+	if len(positional) == 0 || strings.TrimSpace(positional[0]) == "" {
+		searchFlags.Usage()
+		os.Exit(2)
+	}
+	query := strings.TrimSpace(positional[0])
+	configFile := ""
+	if len(positional) > 1 {
+		configFile = positional[1]
+	}
+	if *limit < 1 {
+		*limit = 1
+	}
+	if *mode != "" && *mode != "hybrid" && *mode != "vector" {
+		fatal("Unknown search mode: expected vector or hybrid", "mode", *mode)
+	}
+
+	app := NewApp()
+	if err := app.LoadConfig(configFile); err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	configureLogging(app.Config.Logging)
+	if !app.Config.Embeddings.Enabled {
+		fatal("Embeddings are not enabled in config. Add 'embeddings' section to dimandocs.json")
+	}
+	if *mode == "" {
+		*mode = app.Config.Embeddings.SearchMode
+	}
+
+	embedManager, err := NewEmbeddingManager(app.Config.Embeddings, app.Config.LLM, app.Config.Search)
+	if err != nil {
+		fatal("Failed to initialize embedding manager", "error", err)
+	}
+	defer embedManager.Close()
+	if err := embedManager.ConfigureDirectories(app.Config.Directories); err != nil {
+		fatal("Failed to initialize embedding manager", "error", err)
+	}
+
 	ctx := context.Background()
-	indexed := 0
-	skipped := 0
+	filter := vector.Filter{
+		Source:       *source,
+		Collections:  splitCollections(*collections),
+		PathPrefix:   *pathPrefix,
+		Tags:         frontmatter.NormalizeTags(strings.Split(*tags, ",")),
+		SectionTitle: *section,
+	}
+	var results []vector.SearchResult
+	if *mode == "vector" {
+		results, err = embedManager.MultiSearch(ctx, []string{query}, filter, *limit)
+	} else {
+		results, err = embedManager.HybridSearch(ctx, []string{query}, filter, *limit)
+	}
+	if err != nil {
+		fatal("Search failed", "error", err)
+	}
+
+	hits := make([]SearchHitJSON, len(results))
+	for i, r := range results {
+		text := r.Chunk.ChunkText
+		if app.SecretScanner != nil {
+			text, _ = app.SecretScanner.Process(text)
+		}
+		hits[i] = SearchHitJSON{
+			Title:        r.Document.Title,
+			RelPath:      r.Document.Path,
+			Score:        r.Score,
+			FusionScore:  r.FusionScore,
+			ChunkText:    text,
+			SectionTitle: r.Chunk.SectionTitle,
+			Breadcrumb:   r.Chunk.Breadcrumb,
+			URL:          resultURL(r),
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(hits); err != nil {
+			fatal("Failed to encode results", "error", err)
+		}
+		return
+	}
+
+	if len(hits) == 0 {
+		fmt.Println("No results found.")
+		return
+	}
+	for i, hit := range hits {
+		fmt.Printf("%d. %s (%s) [score: %.4f]\n", i+1, hit.Title, hit.RelPath, hit.Score)
+		if hit.Breadcrumb != "" {
+			fmt.Printf("   %s\n", hit.Breadcrumb)
+		}
+		fmt.Printf("   %s\n\n", snippet(hit.ChunkText, 200))
+	}
+}
+
+// SearchHitJSON represents a search result printed by the search command
+type SearchHitJSON struct {
+	Title        string  `json:"Title"`
+	RelPath      string  `json:"RelPath"`
+	Score        float32 `json:"Score"`
+	FusionScore  float32 `json:"FusionScore,omitempty"` // Normalized RRF score of hybrid search
+	ChunkText    string  `json:"ChunkText"`
+	SectionTitle string  `json:"SectionTitle,omitempty"`
+	Breadcrumb   string  `json:"Breadcrumb,omitempty"`
+	URL          string  `json:"URL"` // Web interface path of the matching section
+}
+
+// parseInterspersed parses flags given before, between or after the
+// positional arguments, and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// runValidateCommand handles the "validate" subcommand: checks the config
+// without syncing sources or indexing, and exits with status 1 on errors
+func runValidateCommand(args []string) {
+	validateFlags := flag.NewFlagSet("validate", flag.ExitOnError)
+	jsonOutput := validateFlags.Bool("json", false, "Print the issues as JSON")
+	validateFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs validate [options] [config_file]\n\n")
+		fmt.Fprintf(os.Stderr, "Check the configuration for errors and likely mistakes.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		validateFlags.PrintDefaults()
+	}
+	positional := parseInterspersed(validateFlags, args)
+	configFile := ""
+	if len(positional) > 0 {
+		configFile = positional[0]
+	}
 
-	for _, doc := range app.Documents {
-		if err := embedManager.IndexDocument(ctx, doc, *force); err != nil {
-			log.Printf("Warning: failed to index document %s: %v", doc.RelPath, err)
+	app := NewApp()
+	var issues []ConfigIssue
+	if err := app.LoadConfig(configFile); err != nil {
+		issues = []ConfigIssue{{Severity: SeverityError, Field: "config", Message: err.Error()}}
+	} else {
+		issues = app.ValidateConfig()
+	}
+
+	errorCount := 0
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			errorCount++
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if issues == nil {
+			issues = []ConfigIssue{}
+		}
+		if err := encoder.Encode(issues); err != nil {
+			fatal("Failed to encode issues", "error", err)
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Printf("%-7s %s: %s\n", issue.Severity, issue.Field, issue.Message)
+		}
+		if len(issues) == 0 {
+			fmt.Println("Configuration is valid")
 		} else {
-			indexed++
+			fmt.Printf("\n%d errors, %d warnings\n", errorCount, len(issues)-errorCount)
+		}
+	}
+
+	if errorCount > 0 {
+		os.Exit(1)
+	}
+}
+
+// runDBCommand handles the "db" subcommand, which groups operations on the
+// vector database
+func runDBCommand(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs db <command> [options] [config_file]\n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		fmt.Fprintf(os.Stderr, "  status    List documents whose indexing failed or did not finish\n")
+		fmt.Fprintf(os.Stderr, "  maintain  Remove orphan chunks, vacuum and analyze the vector database\n")
+		fmt.Fprintf(os.Stderr, "  backup    Copy the vector database to a file while it is in use\n")
+		fmt.Fprintf(os.Stderr, "  restore   Replace the vector database with a backup\n")
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "status":
+		runDBStatusCommand(args[1:])
+	case "maintain":
+		runDBMaintainCommand(args[1:])
+	case "backup":
+		runDBBackupCommand(args[1:])
+	case "restore":
+		runDBRestoreCommand(args[1:])
+	case "help", "--help", "-h":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown db command: %s\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+}
+
+// runDBStatusCommand handles "db status": lists the documents whose last
+// indexing failed, with the error, and those left pending by an interrupted
+// run. Both are indexed first on the next run.
+func runDBStatusCommand(args []string) {
+	statusFlags := flag.NewFlagSet("db status", flag.ExitOnError)
+	source := statusFlags.String("source", "", "Operate on the database of this directory with its own embeddings")
+	statusFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs db status [--source name] [config_file]\n\n")
+		fmt.Fprintf(os.Stderr, "List documents whose indexing failed or did not finish. Exits with status 1 if any failed.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		statusFlags.PrintDefaults()
+	}
+	positional := parseInterspersed(statusFlags, args)
+	configFile := ""
+	if len(positional) > 0 {
+		configFile = positional[0]
+	}
+
+	store, _ := openVectorStore(configFile, *source, false)
+	defer store.Close()
+
+	records, err := store.ListDocuments()
+	if err != nil {
+		fatal("Failed to list documents", "error", err)
+	}
+	var embedded, pending, failed int
+	for _, record := range records {
+		switch record.Status {
+		case vector.StatusPending:
+			pending++
+			fmt.Printf("pending: %s\n", record.Path)
+		case vector.StatusFailed:
+			failed++
+			fmt.Printf("failed: %s: %s\n", record.Path, record.Error)
+		default:
+			embedded++
+		}
+	}
+	fmt.Printf("%d documents embedded, %d pending, %d failed\n", embedded, pending, failed)
+
+	if failed > 0 {
+		store.Close()
+		os.Exit(1)
+	}
+}
+
+// runDBMaintainCommand handles "db maintain": removes chunks left behind by
+// deleted documents and compacts the vector database
+func runDBMaintainCommand(args []string) {
+	maintainFlags := flag.NewFlagSet("db maintain", flag.ExitOnError)
+	source := maintainFlags.String("source", "", "Operate on the database of this directory with its own embeddings")
+	maintainFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs db maintain [--source name] [config_file]\n\n")
+		fmt.Fprintf(os.Stderr, "Remove orphan chunks, vacuum and analyze the vector database, and report the reclaimed space.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		maintainFlags.PrintDefaults()
+	}
+	positional := parseInterspersed(maintainFlags, args)
+	configFile := ""
+	if len(positional) > 0 {
+		configFile = positional[0]
+	}
+
+	store, _ := openVectorStore(configFile, *source, false)
+	defer store.Close()
+
+	maintainer, ok := store.(vector.Maintainer)
+	if !ok {
+		fatal("The vector store does not support maintenance")
+	}
+	start := time.Now()
+	stats, err := maintainer.Maintain()
+	if err != nil {
+		fatal("Maintenance failed", "error", err)
+	}
+
+	fmt.Printf("Removed %d orphan chunks\n", stats.OrphanChunks)
+	fmt.Printf("Database size: %s -> %s (%s reclaimed) in %s\n",
+		formatBytes(stats.SizeBefore), formatBytes(stats.SizeAfter), formatBytes(stats.Reclaimed()),
+		time.Since(start).Round(time.Millisecond))
+}
+
+// runDBBackupCommand handles "db backup": copies the vector database to a
+// file with the SQLite online backup API, so the server may keep running
+func runDBBackupCommand(args []string) {
+	backupFlags := flag.NewFlagSet("db backup", flag.ExitOnError)
+	out := backupFlags.String("out", "", "Backup file (default: a timestamped file in embeddings.backup.dir)")
+	source := backupFlags.String("source", "", "Operate on the database of this directory with its own embeddings")
+	backupFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs db backup [--out file.db] [--source name] [config_file]\n\n")
+		fmt.Fprintf(os.Stderr, "Copy the vector database to a file while it is in use.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		backupFlags.PrintDefaults()
+	}
+	positional := parseInterspersed(backupFlags, args)
+	configFile := ""
+	if len(positional) > 0 {
+		configFile = positional[0]
+	}
+
+	store, cfg := openVectorStore(configFile, *source, false)
+	defer store.Close()
+	backupStore, ok := store.(vector.BackupStore)
+	if !ok {
+		fatal("The vector store does not support backups, back up PostgreSQL with pg_dump")
+	}
+
+	start := time.Now()
+	path := *out
+	var err error
+	if path == "" {
+		path, err = backupVectorStore(backupStore, cfg, start)
+	} else {
+		err = backupStore.Backup(path)
+	}
+	if err != nil {
+		fatal("Backup failed", "error", err)
+	}
+
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	fmt.Printf("Backed up %s to %s (%s) in %s\n", cfg.DBPath, path, formatBytes(size), time.Since(start).Round(time.Millisecond))
+}
+
+// runDBRestoreCommand handles "db restore": replaces the vector database
+// with a backup
+func runDBRestoreCommand(args []string) {
+	restoreFlags := flag.NewFlagSet("db restore", flag.ExitOnError)
+	from := restoreFlags.String("from", "", "Backup file to restore (required)")
+	source := restoreFlags.String("source", "", "Operate on the database of this directory with its own embeddings")
+	restoreFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs db restore --from file.db [--source name] [config_file]\n\n")
+		fmt.Fprintf(os.Stderr, "Replace the vector database with a backup. Stop the server first.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		restoreFlags.PrintDefaults()
+	}
+	positional := parseInterspersed(restoreFlags, args)
+	if *from == "" {
+		restoreFlags.Usage()
+		os.Exit(2)
+	}
+	configFile := ""
+	if len(positional) > 0 {
+		configFile = positional[0]
+	}
+
+	store, cfg := openVectorStore(configFile, *source, true)
+	defer store.Close()
+	backupStore, ok := store.(vector.BackupStore)
+	if !ok {
+		fatal("The vector store does not support backups, restore PostgreSQL with pg_restore")
+	}
+
+	start := time.Now()
+	if err := backupStore.Restore(*from); err != nil {
+		fatal("Restore failed", "error", err)
+	}
+	fmt.Printf("Restored %s from %s in %s\n", cfg.DBPath, *from, time.Since(start).Round(time.Millisecond))
+}
+
+// openVectorStore loads the config and opens its vector store, or that of
+// the named directory with its own embeddings, without an embedding service,
+// for commands that operate on the database only. It also returns the
+// embeddings config. Unless create is set, a missing SQLite database is an
+// error.
+func openVectorStore(configFile, source string, create bool) (vector.Store, EmbeddingsConfig) {
+	app := NewApp()
+	if err := app.LoadConfig(configFile); err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	configureLogging(app.Config.Logging)
+	cfg := app.Config.Embeddings
+	if !cfg.Enabled {
+		fatal("Embeddings are not enabled in config. Add 'embeddings' section to dimandocs.json")
+	}
+	if source != "" {
+		i := slices.IndexFunc(app.Config.Directories, func(d DirectoryConfig) bool { return d.Name == source })
+		if i < 0 || app.Config.Directories[i].Embeddings == nil {
+			fatal("No directory with its own embeddings has this name", "source", source)
+		}
+		cfg = mergeEmbeddingsConfig(cfg, *app.Config.Directories[i].Embeddings)
+	}
+
+	// Opening a missing SQLite database would create an empty one
+	if !create && cfg.VectorStore.Type == "sqlite" {
+		if _, err := os.Stat(cfg.DBPath); err != nil {
+			fatal("Vector database not found, run the index command first", "path", cfg.DBPath)
+		}
+	}
+
+	store, err := NewVectorStore(cfg)
+	if err != nil {
+		fatal("Failed to create vector store", "error", err)
+	}
+	if err := store.Initialize(); err != nil {
+		fatal("Failed to open vector store", "error", err)
+	}
+	return store, cfg
+}
+
+// formatBytes formats a size in bytes with a binary unit, e.g. 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// runCheckLinksCommand handles the "check-links" subcommand: it reports
+// relative links whose target file or heading anchor does not exist, and
+// exits with status 1 if there are any
+func runCheckLinksCommand(args []string) {
+	checkFlags := flag.NewFlagSet("check-links", flag.ExitOnError)
+	jsonOutput := checkFlags.Bool("json", false, "Print the report as JSON")
+	checkFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs check-links [options] [config_file]\n\n")
+		fmt.Fprintf(os.Stderr, "Check the links between documents for missing files and heading anchors.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		checkFlags.PrintDefaults()
+	}
+	positional := parseInterspersed(checkFlags, args)
+	configFile := ""
+	if len(positional) > 0 {
+		configFile = positional[0]
+	}
+
+	app := NewApp()
+	if err := app.Initialize(configFile); err != nil {
+		fatal("Failed to initialize application", "error", err)
+	}
+	report := app.CheckLinks()
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fatal("Failed to encode report", "error", err)
+		}
+	} else {
+		for _, link := range report.Broken {
+			fmt.Printf("%s:%d: %s: %s\n", link.Source, link.Line, link.Target, link.Reason)
+		}
+		if len(report.Broken) > 0 {
+			fmt.Println()
 		}
+		fmt.Printf("%d broken links, %d links checked in %d documents\n", len(report.Broken), report.Links, report.Documents)
 	}
 
-	if *force {
-		log.Printf("Force indexing complete: %d documents indexed", indexed)
+	if len(report.Broken) > 0 {
+		os.Exit(1)
+	}
+}
+
+// runInitCommand handles the "init" subcommand: writes a config for the
+// documentation found below the current directory
+func runInitCommand(args []string) {
+	initFlags := flag.NewFlagSet("init", flag.ExitOnError)
+	output := initFlags.String("output", "dimandocs.json", "Config file to write")
+	provider := initFlags.String("provider", "", "Embedding provider: "+strings.Join(EmbeddingPresetNames(), ", ")+" (default: openai with OPENAI_API_KEY set, none otherwise)")
+	title := initFlags.String("title", "", "Title of the documentation browser")
+	yes := initFlags.Bool("yes", false, "Do not prompt, accept every detected directory")
+	force := initFlags.Bool("force", false, "Overwrite an existing config file")
+	initFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: dimandocs init [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Create a config for the documentation below the current directory.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		initFlags.PrintDefaults()
+	}
+	initFlags.Parse(args)
+
+	if _, err := os.Stat(*output); err == nil && !*force {
+		fatal("Config file already exists, use --force to overwrite it", "path", *output)
+	}
+
+	// Prompt only when a user is at the terminal
+	interactive := !*yes
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		interactive = false
+	}
+	reader := bufio.NewReader(os.Stdin)
+
+	detected := DetectDocumentation(".")
+	var dirs []DetectedDirectory
+	if len(detected) == 0 {
+		fmt.Println("No README files or documentation folders found, adding the current directory.")
+		dirs = []DetectedDirectory{{Path: ".", Name: "Documentation", FilePattern: `\.md$`}}
 	} else {
-		log.Printf("Indexing complete: %d documents processed (%d skipped as up-to-date)", indexed, skipped)
+		fmt.Println("Found documentation:")
+		for _, dir := range detected {
+			fmt.Printf("  %s (%s)\n", dir.Path, dir.Reason)
+		}
+		for _, dir := range detected {
+			if !interactive || confirm(reader, fmt.Sprintf("Include %s?", dir.Path)) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	if *provider == "" {
+		*provider = "none"
+		if os.Getenv("OPENAI_API_KEY") != "" {
+			*provider = "openai"
+		}
+		if interactive {
+			*provider = prompt(reader, "Embedding provider for semantic search ("+strings.Join(EmbeddingPresetNames(), ", ")+")", *provider)
+		}
+	}
+	if !slices.Contains(EmbeddingPresetNames(), *provider) {
+		fatal("Unknown provider: expected one of "+strings.Join(EmbeddingPresetNames(), ", "), "provider", *provider)
+	}
+
+	if *title == "" {
+		*title = "Documentation Browser"
+		if interactive {
+			*title = prompt(reader, "Title", *title)
+		}
+	}
+
+	data, err := json.MarshalIndent(NewInitConfig(*title, dirs, *provider), "", "  ")
+	if err != nil {
+		fatal("Failed to encode config", "error", err)
+	}
+	if err := os.WriteFile(*output, append(data, '\n'), 0644); err != nil {
+		fatal("Failed to write config", "error", err)
+	}
+
+	fmt.Printf("Wrote %s with %d directories. Check it with: dimandocs validate %s\n", *output, len(dirs), *output)
+}
+
+// prompt asks a question on stdout and returns the answer, or def for an
+// empty answer
+func prompt(reader *bufio.Reader, question, def string) string {
+	fmt.Printf("%s [%s]: ", question, def)
+	answer, _ := reader.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer != "" {
+		return answer
 	}
+	return def
+}
+
+// confirm asks a yes/no question that defaults to yes
+func confirm(reader *bufio.Reader, question string) bool {
+	answer := strings.ToLower(prompt(reader, question, "Y/n"))
+	return answer != "n" && answer != "no"
+}
+
+// snippet returns text on a single line, shortened to at most maxLen bytes
+func snippet(text string, maxLen int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= maxLen {
+		return text
+	}
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "..."
+}
+
+// printVersion prints the version information
+func printVersion() {
+	fmt.Printf("DimanDocs %s\n", Version)
+	fmt.Printf("Build Time: %s\n", BuildTime)
 }
 
 // printUsage prints the main usage information
 func printUsage() {
 	fmt.Printf("DimanDocs %s - Documentation browser with semantic search\n\n", Version)
 	fmt.Println("Usage:")
-	fmt.Println("  dimandocs [options] [config_file]    Start web server")
-	fmt.Println("  dimandocs --mcp [config_file]        Start MCP server for Claude")
-	fmt.Println("  dimandocs index [options] [config]   Index documents for search")
-	fmt.Println("  dimandocs help                       Show this help")
+	fmt.Println("  dimandocs <command> [options] [config_file]")
 	fmt.Println("")
 	fmt.Println("Commands:")
+	fmt.Println("  serve       Start the web interface (default when no command is given)")
 	fmt.Println("  index       Index documents for semantic search")
 	fmt.Println("              Use --force to re-index all documents")
-	fmt.Println("")
-	fmt.Println("Options:")
-	fmt.Println("  --version   Show version information")
-	fmt.Println("  --mcp       Run as MCP server (stdio transport)")
+	fmt.Println("  search      Search the indexed documents from the command line")
+	fmt.Println("              Use --limit N to change the number of results, --json for JSON output")
+	fmt.Println("  mcp         Run the MCP server only, without the web interface")
+	fmt.Println("              Use --stdio or --http to override the configured transport")
+	fmt.Println("  init        Create a config for the documentation in the current directory")
+	fmt.Println("  validate    Check the configuration for errors and likely mistakes")
+	fmt.Println("  check-links Check the links between documents for missing files and anchors")
+	fmt.Println("  db          Inspect, maintain, back up or restore the vector database")
+	fmt.Println("              (db status, db maintain, db backup --out file.db, db restore --from file.db)")
+	fmt.Println("  version     Show version information")
+	fmt.Println("  help        Show this help")
 	fmt.Println("")
 	fmt.Println("Examples:")
 	fmt.Println("  dimandocs                           Start with dimandocs.json")
-	fmt.Println("  dimandocs myconfig.json             Start with custom config")
-	fmt.Println("  dimandocs --mcp dimandocs.json      Run MCP server")
-	fmt.Println("  dimandocs index                     Index documents")
+	fmt.Println("  dimandocs serve myconfig.json       Start with custom config")
 	fmt.Println("  dimandocs index --force             Force re-index all")
+	fmt.Println("  dimandocs search \"how to deploy\"    Search the index")
+	fmt.Println("  dimandocs search \"auth\" --json      Search, printing JSON for scripts")
+	fmt.Println("  dimandocs mcp --stdio               Run MCP server for Claude")
 }
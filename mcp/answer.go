@@ -0,0 +1,73 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"dimandocs/frontmatter"
+	"dimandocs/llm"
+	"dimandocs/vector"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// handleAnswerQuestion handles the answer_question tool
+func (s *Server) handleAnswerQuestion(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	question := strings.TrimSpace(request.GetString("question", ""))
+	if question == "" {
+		return mcp.NewToolResultError("question parameter is required"), nil
+	}
+
+	limit := request.GetInt("limit", 6)
+	if limit > 20 {
+		limit = 20
+	}
+	if limit < 1 {
+		limit = 1
+	}
+
+	results, err := s.search(ctx, []string{question}, searchOptions{
+		limit: limit,
+		filter: vector.Filter{
+			Source:      request.GetString("source", ""),
+			Collections: request.GetStringSlice("collections", nil),
+			PathPrefix:  request.GetString("path_prefix", ""),
+			Tags:        frontmatter.NormalizeTags(request.GetStringSlice("tags", nil)),
+		},
+	})
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if len(results) == 0 {
+		return mcp.NewToolResultText("No relevant documentation was found for the question."), nil
+	}
+
+	answer, err := s.llm.Complete(ctx, []llm.Message{
+		{Role: llm.RoleSystem, Content: llm.AnswerSystemPrompt},
+		{
+			Role:    llm.RoleUser,
+			Content: fmt.Sprintf("Question: %s\n\n# Documentation excerpts\n\n%s", question, s.formatResults(results)),
+		},
+	})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to generate answer: %v", err)), nil
+	}
+
+	var output strings.Builder
+	output.WriteString(strings.TrimSpace(answer))
+	output.WriteString("\n\n## Sources\n\n")
+	for i, r := range results {
+		section := r.Chunk.Breadcrumb
+		if section == "" {
+			section = r.Chunk.SectionTitle
+		}
+		output.WriteString(fmt.Sprintf("[%d] %s", i+1, r.Document.Path))
+		if section != "" {
+			output.WriteString(fmt.Sprintf(" (%s)", section))
+		}
+		output.WriteString(fmt.Sprintf(" - score: %.4f - %s\n", r.Score, s.resultURL(r)))
+	}
+
+	return mcp.NewToolResultText(output.String()), nil
+}
@@ -0,0 +1,82 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"dimandocs/tokenauth"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// HTTP endpoints of the MCP server
+const (
+	StreamableHTTPPath = "/mcp"     // Streamable HTTP transport
+	SSEPath            = "/sse"     // Legacy SSE transport: event stream
+	SSEMessagePath     = "/message" // Legacy SSE transport: client messages
+)
+
+// Handler returns an HTTP handler serving the MCP server over the streamable
+// HTTP transport, and over the legacy SSE transport for older clients. With
+// auth tokens configured, every request must carry one of them.
+func (s *Server) Handler() http.Handler {
+	streamable := server.NewStreamableHTTPServer(s.mcpServer,
+		server.WithEndpointPath(StreamableHTTPPath),
+	)
+	sse := server.NewSSEServer(s.mcpServer,
+		server.WithSSEEndpoint(SSEPath),
+		server.WithMessageEndpoint(SSEMessagePath),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle(StreamableHTTPPath, streamable)
+	mux.Handle(SSEPath, sse.SSEHandler())
+	mux.Handle(SSEMessagePath, sse.MessageHandler())
+	if len(s.authTokens) == 0 {
+		return mux
+	}
+	return s.requireToken(mux)
+}
+
+// requireToken rejects requests without one of the configured tokens, sent
+// as "Authorization: Bearer <token>" or in the X-API-Key header
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tokenauth.Valid(tokenauth.FromRequest(r), s.authTokens) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dimandocs"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ShutdownTimeout is how long in-flight requests may take to complete when
+// the HTTP transport shuts down
+const ShutdownTimeout = 10 * time.Second
+
+// ServeHTTPTransport serves the MCP server over HTTP on addr, e.g. ":8081",
+// until ctx is cancelled. In-flight requests are then given ShutdownTimeout
+// to complete.
+func (s *Server) ServeHTTPTransport(ctx context.Context, addr string) error {
+	httpServer := &http.Server{Addr: addr, Handler: s.Handler()}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down MCP server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dimandocs/frontmatter"
+	"dimandocs/vector"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// defaultPromptChunks is the number of chunks answer_from_docs retrieves
+	defaultPromptChunks = 8
+	// defaultSummaryWords is the summary length summarize_doc asks for
+	defaultSummaryWords = 150
+)
+
+// registerPrompts registers MCP prompts, which assemble documentation into
+// ready-to-send prompts for clients that support them
+func (s *Server) registerPrompts(srv *server.MCPServer) {
+	// Prompt: answer_from_docs - answer a question from retrieved chunks
+	answerPrompt := mcp.NewPrompt("answer_from_docs",
+		mcp.WithPromptDescription("Answer a question using the most relevant documentation excerpts, with citations"),
+		mcp.WithArgument("question",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("The question to answer"),
+		),
+		mcp.WithArgument("tags",
+			mcp.ArgumentDescription("Optional: comma-separated tags the excerpts' documents must have"),
+		),
+		mcp.WithArgument("source",
+			mcp.ArgumentDescription("Optional: only use documents of this source directory name"),
+		),
+		mcp.WithArgument("collections",
+			mcp.ArgumentDescription("Optional: comma-separated collections the excerpts' documents may be in"),
+		),
+		mcp.WithArgument("limit",
+			mcp.ArgumentDescription(fmt.Sprintf("Optional: number of excerpts to include (default: %d, max: 20)", defaultPromptChunks)),
+		),
+	)
+	srv.AddPrompt(answerPrompt, s.handleAnswerPrompt)
+
+	// Prompt: summarize_doc - summarize a single document
+	summarizePrompt := mcp.NewPrompt("summarize_doc",
+		mcp.WithPromptDescription("Summarize a document from the documentation"),
+		mcp.WithArgument("path",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("The relative path of the document"),
+		),
+		mcp.WithArgument("max_words",
+			mcp.ArgumentDescription(fmt.Sprintf("Optional: maximum summary length in words (default: %d)", defaultSummaryWords)),
+		),
+	)
+	srv.AddPrompt(summarizePrompt, s.handleSummarizePrompt)
+}
+
+// handleAnswerPrompt handles the answer_from_docs prompt
+func (s *Server) handleAnswerPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args := request.Params.Arguments
+	question := strings.TrimSpace(args["question"])
+	if question == "" {
+		return nil, fmt.Errorf("question argument is required")
+	}
+
+	limit := intArgument(args["limit"], defaultPromptChunks)
+	if limit > 20 {
+		limit = 20
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(args["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	var collections []string
+	for _, collection := range strings.Split(args["collections"], ",") {
+		if collection = strings.TrimSpace(collection); collection != "" {
+			collections = append(collections, collection)
+		}
+	}
+
+	results, err := s.search(ctx, []string{question}, searchOptions{
+		limit: limit,
+		filter: vector.Filter{
+			Source:      args["source"],
+			Collections: collections,
+			Tags:        frontmatter.NormalizeTags(tags),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("Answer the question below using only the documentation excerpts that follow. ")
+	prompt.WriteString("Cite every document you rely on by its link. ")
+	prompt.WriteString("If the excerpts do not contain the answer, say so instead of guessing.\n\n")
+	prompt.WriteString(fmt.Sprintf("Question: %s\n\n# Documentation excerpts\n\n", question))
+	if len(results) == 0 {
+		prompt.WriteString("No relevant documentation was found.\n")
+	} else {
+		prompt.WriteString(s.formatResults(results))
+	}
+
+	return mcp.NewGetPromptResult(
+		fmt.Sprintf("Answer from %d documentation excerpts", len(results)),
+		[]mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(prompt.String()))},
+	), nil
+}
+
+// handleSummarizePrompt handles the summarize_doc prompt
+func (s *Server) handleSummarizePrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args := request.Params.Arguments
+	path := strings.TrimSpace(args["path"])
+	if path == "" {
+		return nil, fmt.Errorf("path argument is required")
+	}
+
+	content, err := s.documentContent(path)
+	if err != nil {
+		return nil, err
+	}
+
+	maxWords := intArgument(args["max_words"], defaultSummaryWords)
+	prompt := fmt.Sprintf("Summarize the document below in at most %d words. "+
+		"Focus on its purpose and the key facts a reader needs; do not invent details.\n\n"+
+		"# Document: %s\n\n%s", maxWords, path, content)
+
+	return mcp.NewGetPromptResult(
+		"Summarize "+path,
+		[]mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(prompt))},
+	), nil
+}
+
+// intArgument parses a positive integer prompt argument, falling back to def
+func intArgument(value string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 1 {
+		return def
+	}
+	return n
+}
@@ -3,9 +3,22 @@ package mcp
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 
+	"dimandocs/chunking"
 	"dimandocs/embedding"
+	"dimandocs/expansion"
+	"dimandocs/frontmatter"
+	"dimandocs/llm"
+	"dimandocs/render"
+	"dimandocs/rerank"
+	"dimandocs/secrets"
 	"dimandocs/vector"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -16,6 +29,36 @@ import (
 type DocumentProvider interface {
 	GetDocuments() []DocumentInfo
 	GetDocumentContent(path string) (string, error)
+	GetBacklinks(path string) ([]DocumentInfo, error)
+}
+
+// Reindexer is implemented by document providers that can rescan the
+// documentation and re-embed changed documents
+type Reindexer interface {
+	// Reindex rescans the documents at or below path (all for "") and
+	// re-embeds the changed ones, or all of them if force is set
+	Reindex(ctx context.Context, path string, force bool) (ReindexResult, error)
+}
+
+// Searcher is implemented by document providers that search the documents
+// themselves, such as across the databases of directories with their own
+// embeddings. The vector store of the config is then only used to check that
+// semantic search is available.
+type Searcher interface {
+	// Search runs the queries, with query expansion and reranking, and
+	// returns the limit best results matching filter. With vectorOnly set,
+	// keyword matching is skipped.
+	Search(ctx context.Context, queries []string, filter vector.Filter, limit int, vectorOnly bool) ([]vector.SearchResult, error)
+	// RelatedDocuments returns the documents most similar to the document at
+	// path
+	RelatedDocuments(path string, limit int) ([]vector.SearchResult, error)
+}
+
+// ReindexResult reports the outcome of a reindex
+type ReindexResult struct {
+	Documents int // Documents in scope after the rescan
+	Removed   int // Documents whose files are gone
+	Failed    int // Documents that could not be embedded
 }
 
 // DocumentInfo represents basic document information
@@ -24,7 +67,13 @@ type DocumentInfo struct {
 	Path       string
 	RelPath    string
 	SourceName string
+	Collection string
 	Overview   string
+	Summary    string
+
+	Description string         // From frontmatter
+	Tags        []string       // From frontmatter
+	Metadata    map[string]any // Custom frontmatter fields
 }
 
 // Server represents the MCP server for DimanDocs
@@ -33,8 +82,23 @@ type Server struct {
 	vectorStore  vector.Store
 	embedService embedding.Service
 	docProvider  DocumentProvider
+	baseURL      string
+	secrets      *secrets.Scanner
+	authTokens   []string
+	allowReindex bool
+	searchMode   string
+	llm          llm.Client
+	reranker     rerank.Reranker
+	rerankTopK   int
+	expander     *expansion.Expander
+
+	resourcesMu sync.Mutex        // Serializes SyncResources
+	resources   map[string]string // Listed document resources: URI to description
 }
 
+// resourcePageSize is the number of entries per page of list results
+const resourcePageSize = 100
+
 // Config holds MCP server configuration
 type Config struct {
 	Name         string
@@ -42,6 +106,15 @@ type Config struct {
 	VectorStore  vector.Store
 	EmbedService embedding.Service
 	DocProvider  DocumentProvider
+	BaseURL      string              // Optional: web UI address used to build absolute document links
+	Secrets      *secrets.Scanner    // Optional: redacts or flags secrets in search results
+	AuthTokens   []string            // Optional: tokens accepted by the HTTP transport
+	AllowReindex bool                // Offer the reindex_docs tool to clients
+	SearchMode   string              // Default mode of search_docs: "vector" (default) or "hybrid"
+	LLM          llm.Client          // Optional: chat model for the answer_question tool
+	Reranker     rerank.Reranker     // Optional: reorders search results
+	RerankTopK   int                 // Candidates retrieved for the reranker
+	Expander     *expansion.Expander // Optional: adds paraphrases of single queries
 }
 
 // NewServer creates a new MCP server
@@ -52,11 +125,23 @@ func NewServer(cfg Config) (*Server, error) {
 	if cfg.Version == "" {
 		cfg.Version = "1.0.0"
 	}
+	if cfg.SearchMode == "" {
+		cfg.SearchMode = "vector"
+	}
 
 	s := &Server{
 		vectorStore:  cfg.VectorStore,
 		embedService: cfg.EmbedService,
 		docProvider:  cfg.DocProvider,
+		baseURL:      strings.TrimSuffix(cfg.BaseURL, "/"),
+		secrets:      cfg.Secrets,
+		authTokens:   cfg.AuthTokens,
+		allowReindex: cfg.AllowReindex,
+		searchMode:   cfg.SearchMode,
+		llm:          cfg.LLM,
+		reranker:     cfg.Reranker,
+		rerankTopK:   cfg.RerankTopK,
+		expander:     cfg.Expander,
 	}
 
 	// Create MCP server
@@ -64,16 +149,22 @@ func NewServer(cfg Config) (*Server, error) {
 		cfg.Name,
 		cfg.Version,
 		server.WithToolCapabilities(true),
-		server.WithResourceCapabilities(true, false),
+		server.WithResourceCapabilities(true, true),
+		server.WithPromptCapabilities(false),
+		server.WithPaginationLimit(resourcePageSize),
 	)
 
 	// Register tools
 	s.registerTools(mcpServer)
 
+	// Register prompts
+	s.registerPrompts(mcpServer)
+
 	// Register resources
+	s.mcpServer = mcpServer
 	s.registerResources(mcpServer)
+	s.SyncResources()
 
-	s.mcpServer = mcpServer
 	return s, nil
 }
 
@@ -81,14 +172,38 @@ func NewServer(cfg Config) (*Server, error) {
 func (s *Server) registerTools(srv *server.MCPServer) {
 	// Tool: search_docs - semantic search across documentation
 	searchTool := mcp.NewTool("search_docs",
-		mcp.WithDescription("Search documentation using semantic similarity. Returns relevant document chunks based on the query."),
+		mcp.WithDescription("Search documentation using semantic similarity. Returns relevant document chunks based on the query. Pass several phrasings in 'queries' to improve recall; their results are fused and deduplicated."),
 		mcp.WithString("query",
-			mcp.Required(),
 			mcp.Description("The search query to find relevant documentation"),
 		),
+		mcp.WithArray("queries",
+			mcp.Description("Optional: several alternative queries searched concurrently and fused with reciprocal rank fusion"),
+			mcp.WithStringItems(),
+		),
 		mcp.WithNumber("limit",
 			mcp.Description("Maximum number of results to return (default: 5, max: 20)"),
 		),
+		mcp.WithString("mode",
+			mcp.Description(fmt.Sprintf("Search mode (default: '%s'): 'hybrid' combines semantic and keyword matching, which helps with exact identifiers and function names; 'vector' is pure semantic search", s.searchMode)),
+			mcp.Enum("hybrid", "vector"),
+		),
+		mcp.WithArray("tags",
+			mcp.Description("Optional: only search documents having all of these tags (see list_documents for document tags)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("source",
+			mcp.Description("Optional: only search documents of this source directory name (see list_documents)"),
+		),
+		mcp.WithArray("collections",
+			mcp.Description("Optional: only search documents in any of these collections (see list_collections); all collections are searched by default"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("path_prefix",
+			mcp.Description("Optional: only search documents whose relative path starts with this prefix, e.g. 'guides/'"),
+		),
+		mcp.WithString("section",
+			mcp.Description("Optional: only search sections with this exact heading title, e.g. 'Configuration'"),
+		),
 	)
 	srv.AddTool(searchTool, s.handleSearchDocs)
 
@@ -102,26 +217,116 @@ func (s *Server) registerTools(srv *server.MCPServer) {
 	)
 	srv.AddTool(getDocTool, s.handleGetDocument)
 
+	// Tool: get_section - retrieve a single section of a document
+	getSectionTool := mcp.NewTool("get_section",
+		mcp.WithDescription("Get a single section of a document by its heading, instead of the full document. Use the section shown in search results to fetch more context around a match."),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("The relative path of the document"),
+		),
+		mcp.WithString("heading",
+			mcp.Required(),
+			mcp.Description("The section heading, a heading path such as 'Install > Linux' (as shown in search results), or an anchor such as '#install'"),
+		),
+		mcp.WithBoolean("include_subsections",
+			mcp.Description("Include the subsections below the heading (default: true)"),
+		),
+	)
+	srv.AddTool(getSectionTool, s.handleGetSection)
+
 	// Tool: list_documents - list all available documents
 	listDocsTool := mcp.NewTool("list_documents",
 		mcp.WithDescription("List all available documents in the documentation."),
 		mcp.WithString("source",
 			mcp.Description("Optional: filter documents by source directory name"),
 		),
+		mcp.WithString("collection",
+			mcp.Description("Optional: filter documents by collection (see list_collections)"),
+		),
+		mcp.WithArray("tags",
+			mcp.Description("Optional: only list documents having all of these tags"),
+			mcp.WithStringItems(),
+		),
 	)
 	srv.AddTool(listDocsTool, s.handleListDocuments)
+
+	// Tool: list_collections - list the collections of documents
+	listCollectionsTool := mcp.NewTool("list_collections",
+		mcp.WithDescription("List the collections the documentation is divided into, such as the docs of different teams or products, with their sources and document counts. Pass collections to search_docs to search within some of them."),
+	)
+	srv.AddTool(listCollectionsTool, s.handleListCollections)
+
+	// Tool: get_backlinks - list documents linking to a document
+	backlinksTool := mcp.NewTool("get_backlinks",
+		mcp.WithDescription("List documents that link to a specific document. Useful for navigating related documentation structurally."),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("The relative path of the document"),
+		),
+	)
+	srv.AddTool(backlinksTool, s.handleGetBacklinks)
+
+	// Tool: related_documents - find semantically similar documents
+	relatedTool := mcp.NewTool("related_documents",
+		mcp.WithDescription("Find the documents most similar in content to a specific document, for 'see also' style navigation. Unlike get_backlinks, this does not depend on explicit links."),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("The relative path of the document"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of documents to return (default: 5, max: 20)"),
+		),
+	)
+	srv.AddTool(relatedTool, s.handleRelatedDocuments)
+
+	// Tool: reindex_docs - rescan and re-embed documents. Re-embedding costs
+	// provider requests, so it is only offered when allowed.
+	if _, ok := s.docProvider.(Reindexer); ok && s.allowReindex {
+		reindexTool := mcp.NewTool("reindex_docs",
+			mcp.WithDescription("Administrative: rescan the documentation and re-embed changed documents, e.g. after editing docs. Added and removed files are picked up. Only use when the index is known to be stale."),
+			mcp.WithString("path",
+				mcp.Description("Optional: only reindex the document with this relative path, or the documents below this directory"),
+			),
+			mcp.WithBoolean("force",
+				mcp.Description("Re-embed documents even if their content is unchanged (default: false)"),
+			),
+		)
+		srv.AddTool(reindexTool, s.handleReindexDocs)
+	}
+
+	// Tool: answer_question - answer a question from retrieved chunks
+	if s.llm != nil {
+		answerTool := mcp.NewTool("answer_question",
+			mcp.WithDescription("Answer a question about the documentation. Retrieves the most relevant chunks and has a language model write an answer citing them, returned with the cited documents. Use search_docs instead to read the excerpts yourself."),
+			mcp.WithString("question",
+				mcp.Required(),
+				mcp.Description("The question to answer"),
+			),
+			mcp.WithNumber("limit",
+				mcp.Description("Number of chunks to answer from (default: 6, max: 20)"),
+			),
+			mcp.WithArray("tags",
+				mcp.Description("Optional: only use documents having all of these tags"),
+				mcp.WithStringItems(),
+			),
+			mcp.WithString("source",
+				mcp.Description("Optional: only use documents of this source directory name"),
+			),
+			mcp.WithArray("collections",
+				mcp.Description("Optional: only use documents in any of these collections"),
+				mcp.WithStringItems(),
+			),
+			mcp.WithString("path_prefix",
+				mcp.Description("Optional: only use documents whose relative path starts with this prefix"),
+			),
+		)
+		srv.AddTool(answerTool, s.handleAnswerQuestion)
+	}
 }
 
 // registerResources registers MCP resources
 func (s *Server) registerResources(srv *server.MCPServer) {
-	// Resource: docs://index - document index
-	indexResource := mcp.NewResource(
-		"docs://index",
-		"Documentation Index",
-		mcp.WithResourceDescription("List of all documentation files with their titles and paths"),
-		mcp.WithMIMEType("application/json"),
-	)
-	srv.AddResource(indexResource, s.handleIndexResource)
+	// Resources: docs://index and one per document, see SyncResources
 
 	// Resource template: docs://{path} - individual document
 	docTemplate := mcp.NewResourceTemplate(
@@ -133,11 +338,60 @@ func (s *Server) registerResources(srv *server.MCPServer) {
 	srv.AddResourceTemplate(docTemplate, s.handleDocumentResource)
 }
 
+// SyncResources lists every document as a docs://{path} resource. Clients are
+// sent a list_changed notification when documents were added, removed or
+// renamed since the last sync.
+func (s *Server) SyncResources() {
+	s.resourcesMu.Lock()
+	defer s.resourcesMu.Unlock()
+
+	docs := s.docProvider.GetDocuments()
+	listed := make(map[string]string, len(docs))
+	for _, doc := range docs {
+		listed["docs://"+doc.RelPath] = fmt.Sprintf("%s (%s)", doc.Title, doc.SourceName)
+	}
+	if s.resources != nil && maps.Equal(listed, s.resources) {
+		return
+	}
+	s.resources = listed
+
+	// Resources are listed sorted by name, so paths make stable, unique names
+	// for paginating
+	resources := []server.ServerResource{{
+		Resource: mcp.NewResource(
+			"docs://index",
+			"Documentation Index",
+			mcp.WithResourceDescription("List of all documentation files with their titles and paths"),
+			mcp.WithMIMEType("text/markdown"),
+		),
+		Handler: s.handleIndexResource,
+	}}
+	for _, doc := range docs {
+		uri := "docs://" + doc.RelPath
+		resources = append(resources, server.ServerResource{
+			Resource: mcp.NewResource(uri, doc.RelPath,
+				mcp.WithResourceDescription(listed[uri]),
+				mcp.WithMIMEType("text/markdown"),
+			),
+			Handler: s.handleDocumentResource,
+		})
+	}
+	s.mcpServer.SetResources(resources...)
+}
+
 // handleSearchDocs handles the search_docs tool
 func (s *Server) handleSearchDocs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	query := request.GetString("query", "")
-	if query == "" {
-		return mcp.NewToolResultError("query parameter is required"), nil
+	var queries []string
+	if query := strings.TrimSpace(request.GetString("query", "")); query != "" {
+		queries = append(queries, query)
+	}
+	for _, q := range request.GetStringSlice("queries", nil) {
+		if q = strings.TrimSpace(q); q != "" {
+			queries = append(queries, q)
+		}
+	}
+	if len(queries) == 0 {
+		return mcp.NewToolResultError("query or queries parameter is required"), nil
 	}
 
 	limit := request.GetInt("limit", 5)
@@ -148,35 +402,117 @@ func (s *Server) handleSearchDocs(ctx context.Context, request mcp.CallToolReque
 		limit = 1
 	}
 
-	// Generate embedding for query
-	queryEmbedding, err := s.embedService.Embed(ctx, query)
+	results, err := s.search(ctx, queries, searchOptions{
+		limit:      limit,
+		vectorOnly: request.GetString("mode", s.searchMode) == "vector",
+		filter: vector.Filter{
+			Source:       request.GetString("source", ""),
+			Collections:  request.GetStringSlice("collections", nil),
+			PathPrefix:   request.GetString("path_prefix", ""),
+			Tags:         frontmatter.NormalizeTags(request.GetStringSlice("tags", nil)),
+			SectionTitle: request.GetString("section", ""),
+		},
+	})
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("failed to generate query embedding: %v", err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if len(results) == 0 {
+		return mcp.NewToolResultText("No results found for the query."), nil
+	}
+
+	return mcp.NewToolResultText(s.formatResults(results)), nil
+}
+
+// searchOptions scopes and limits a search
+type searchOptions struct {
+	limit      int
+	vectorOnly bool // Skip keyword matching
+	filter     vector.Filter
+}
+
+// search embeds the queries and searches the vector store, fusing the
+// results when there are several queries
+func (s *Server) search(ctx context.Context, queries []string, opts searchOptions) ([]vector.SearchResult, error) {
+	if searcher, ok := s.docProvider.(Searcher); ok {
+		results, err := searcher.Search(ctx, queries, opts.filter, opts.limit, opts.vectorOnly)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search: %w", err)
+		}
+		return results, nil
+	}
+
+	// Add paraphrases of a single query
+	expanded := queries
+	if s.expander != nil && len(queries) == 1 {
+		if paraphrased, err := s.expander.Expand(ctx, queries[0]); err != nil {
+			slog.Warn("Failed to expand search query", "query", queries[0], "error", err)
+		} else {
+			expanded = paraphrased
+		}
 	}
 
-	// Search vector store
-	results, err := s.vectorStore.Search(queryEmbedding, limit)
+	// Generate embeddings for all queries
+	queryEmbeddings, err := embedding.EmbedQueryBatch(ctx, s.embedService, expanded)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("failed to search: %v", err)), nil
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 
-	if len(results) == 0 {
-		return mcp.NewToolResultText("No results found for the query."), nil
+	// Retrieve more candidates when they are reranked
+	depth := opts.limit
+	if s.reranker != nil {
+		depth = max(depth, s.rerankTopK)
 	}
 
-	// Format results
+	// Search vector store, fusing results when there are several queries
+	var results []vector.SearchResult
+	if opts.vectorOnly {
+		results, err = vector.MultiSearch(s.vectorStore, queryEmbeddings, depth, opts.filter)
+	} else {
+		results, err = vector.HybridMultiSearch(s.vectorStore, expanded, queryEmbeddings, depth, opts.filter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	if s.reranker != nil {
+		reranked, err := rerank.Results(ctx, s.reranker, strings.Join(queries, "\n"), results, opts.limit)
+		if err == nil {
+			return reranked, nil
+		}
+		slog.Warn("Failed to rerank search results, keeping retrieval order", "error", err)
+	}
+	if len(results) > opts.limit {
+		results = results[:opts.limit]
+	}
+	return results, nil
+}
+
+// formatResults renders search results as markdown, with secrets redacted
+// or flagged
+func (s *Server) formatResults(results []vector.SearchResult) string {
 	var output strings.Builder
 	for i, r := range results {
 		output.WriteString(fmt.Sprintf("## Result %d (score: %.4f)\n", i+1, r.Score))
 		output.WriteString(fmt.Sprintf("**Document:** %s\n", r.Document.Title))
 		output.WriteString(fmt.Sprintf("**Path:** %s\n", r.Document.Path))
-		if r.Chunk.SectionTitle != "" {
+		if r.Chunk.Breadcrumb != "" {
+			output.WriteString(fmt.Sprintf("**Section:** %s\n", r.Chunk.Breadcrumb))
+		} else if r.Chunk.SectionTitle != "" {
 			output.WriteString(fmt.Sprintf("**Section:** %s\n", r.Chunk.SectionTitle))
 		}
-		output.WriteString(fmt.Sprintf("\n%s\n\n---\n\n", r.Chunk.ChunkText))
+		output.WriteString(fmt.Sprintf("**Link:** %s\n", s.resultURL(r)))
+		text := r.Chunk.ChunkText
+		if s.secrets != nil {
+			var findings []secrets.Finding
+			text, findings = s.secrets.Process(text)
+			if len(findings) > 0 && s.secrets.Mode() == secrets.ModeFlag {
+				output.WriteString(fmt.Sprintf("**Warning:** contains %d potential secret(s), do not repeat them\n", len(findings)))
+			}
+		}
+		output.WriteString(fmt.Sprintf("\n%s\n\n---\n\n", text))
 	}
-
-	return mcp.NewToolResultText(output.String()), nil
+	return output.String()
 }
 
 // handleGetDocument handles the get_document tool
@@ -186,17 +522,87 @@ func (s *Server) handleGetDocument(ctx context.Context, request mcp.CallToolRequ
 		return mcp.NewToolResultError("path parameter is required"), nil
 	}
 
+	content, err := s.documentContent(path)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return mcp.NewToolResultText(content), nil
+}
+
+// documentContent returns the content of a document with its frontmatter
+// metadata as a header
+func (s *Server) documentContent(path string) (string, error) {
+	content, err := s.docProvider.GetDocumentContent(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get document: %w", err)
+	}
+
+	for _, doc := range s.docProvider.GetDocuments() {
+		if doc.RelPath == path {
+			return formatMetadata(doc) + content, nil
+		}
+	}
+	return content, nil
+}
+
+// handleGetSection handles the get_section tool
+func (s *Server) handleGetSection(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path := request.GetString("path", "")
+	query := strings.TrimSpace(request.GetString("heading", ""))
+	if path == "" || query == "" {
+		return mcp.NewToolResultError("path and heading parameters are required"), nil
+	}
+
 	content, err := s.docProvider.GetDocumentContent(path)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to get document: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(content), nil
+	sections := chunking.Sections(content)
+	matches := findSections(sections, query)
+	if len(matches) == 0 {
+		var output strings.Builder
+		output.WriteString(fmt.Sprintf("No section %q in %s.", query, path))
+		if len(sections) > 0 {
+			output.WriteString(" Available sections:\n\n")
+			for _, section := range sections {
+				output.WriteString(fmt.Sprintf("- %s\n", section.Breadcrumb))
+			}
+		}
+		return mcp.NewToolResultError(output.String()), nil
+	}
+
+	section := sections[matches[0]]
+	end := section.End
+	if !request.GetBool("include_subsections", true) && matches[0]+1 < len(sections) {
+		if next := sections[matches[0]+1]; next.Start < end {
+			end = next.Start
+		}
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("**Section:** %s\n", section.Breadcrumb))
+	output.WriteString(fmt.Sprintf("**Link:** %s\n", s.baseURL+render.DocumentURL(path, section.Title)))
+	if len(matches) > 1 {
+		output.WriteString("**Other matching sections:**")
+		for _, i := range matches[1:] {
+			output.WriteString(fmt.Sprintf(" %q", sections[i].Breadcrumb))
+		}
+		output.WriteString("\n")
+	}
+	output.WriteString("\n")
+	output.WriteString(strings.TrimSpace(content[section.Start:end]))
+	output.WriteString("\n")
+
+	return mcp.NewToolResultText(output.String()), nil
 }
 
 // handleListDocuments handles the list_documents tool
 func (s *Server) handleListDocuments(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	sourceFilter := request.GetString("source", "")
+	collectionFilter := request.GetString("collection", "")
+	tagFilter := frontmatter.NormalizeTags(request.GetStringSlice("tags", nil))
 
 	docs := s.docProvider.GetDocuments()
 	var output strings.Builder
@@ -205,8 +611,19 @@ func (s *Server) handleListDocuments(ctx context.Context, request mcp.CallToolRe
 		if sourceFilter != "" && doc.SourceName != sourceFilter {
 			continue
 		}
+		if collectionFilter != "" && doc.Collection != collectionFilter {
+			continue
+		}
+		if !containsAll(doc.Tags, tagFilter) {
+			continue
+		}
 		output.WriteString(fmt.Sprintf("- **%s** (%s)\n", doc.Title, doc.RelPath))
-		if doc.Overview != "" {
+		if len(doc.Tags) > 0 {
+			output.WriteString(fmt.Sprintf("  Tags: %s\n", strings.Join(doc.Tags, ", ")))
+		}
+		if doc.Summary != "" {
+			output.WriteString(fmt.Sprintf("  %s\n", doc.Summary))
+		} else if doc.Overview != "" {
 			output.WriteString(fmt.Sprintf("  %s\n", truncateString(doc.Overview, 150)))
 		}
 	}
@@ -218,6 +635,116 @@ func (s *Server) handleListDocuments(ctx context.Context, request mcp.CallToolRe
 	return mcp.NewToolResultText(output.String()), nil
 }
 
+// handleListCollections handles the list_collections tool
+func (s *Server) handleListCollections(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	documents := make(map[string]int)
+	sources := make(map[string][]string)
+	for _, doc := range s.docProvider.GetDocuments() {
+		documents[doc.Collection]++
+		if !slices.Contains(sources[doc.Collection], doc.SourceName) {
+			sources[doc.Collection] = append(sources[doc.Collection], doc.SourceName)
+		}
+	}
+	if len(documents) == 0 {
+		return mcp.NewToolResultText("No collections found."), nil
+	}
+
+	var output strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(documents)) {
+		output.WriteString(fmt.Sprintf("- **%s**: %d documents from %s\n", name, documents[name], strings.Join(sources[name], ", ")))
+	}
+	return mcp.NewToolResultText(output.String()), nil
+}
+
+// handleGetBacklinks handles the get_backlinks tool
+func (s *Server) handleGetBacklinks(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path := request.GetString("path", "")
+	if path == "" {
+		return mcp.NewToolResultError("path parameter is required"), nil
+	}
+
+	docs, err := s.docProvider.GetBacklinks(path)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to get backlinks: %v", err)), nil
+	}
+
+	if len(docs) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No documents link to %s.", path)), nil
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Documents linking to %s:\n\n", path))
+	for _, doc := range docs {
+		output.WriteString(fmt.Sprintf("- **%s** (%s)\n", doc.Title, doc.RelPath))
+	}
+
+	return mcp.NewToolResultText(output.String()), nil
+}
+
+// handleRelatedDocuments handles the related_documents tool
+func (s *Server) handleRelatedDocuments(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path := request.GetString("path", "")
+	if path == "" {
+		return mcp.NewToolResultError("path parameter is required"), nil
+	}
+
+	limit := request.GetInt("limit", 5)
+	if limit > 20 {
+		limit = 20
+	}
+	if limit < 1 {
+		limit = 1
+	}
+
+	var results []vector.SearchResult
+	var err error
+	if searcher, ok := s.docProvider.(Searcher); ok {
+		results, err = searcher.RelatedDocuments(path, limit)
+	} else {
+		results, err = vector.RelatedDocuments(s.vectorStore, path, limit)
+	}
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to find related documents: %v", err)), nil
+	}
+
+	if len(results) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No documents related to %s.", path)), nil
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Documents related to %s:\n\n", path))
+	for _, r := range results {
+		output.WriteString(fmt.Sprintf("- **%s** (%s, similarity: %.4f)\n", r.Document.Title, r.Document.Path, r.Score))
+		if r.Chunk.Breadcrumb != "" && r.Chunk.ChunkIndex != vector.SummaryChunkIndex {
+			output.WriteString(fmt.Sprintf("  Closest section: %s\n", r.Chunk.Breadcrumb))
+		}
+		output.WriteString(fmt.Sprintf("  Link: %s\n", s.resultURL(r)))
+	}
+
+	return mcp.NewToolResultText(output.String()), nil
+}
+
+// handleReindexDocs handles the reindex_docs tool
+func (s *Server) handleReindexDocs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path := request.GetString("path", "")
+	force := request.GetBool("force", false)
+
+	result, err := s.docProvider.(Reindexer).Reindex(ctx, path, force)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to reindex: %v", err)), nil
+	}
+
+	scope := "all documents"
+	if path != "" {
+		scope = path
+	}
+	output := fmt.Sprintf("Reindexed %s: %d documents, %d removed, %d failed to embed.", scope, result.Documents, result.Removed, result.Failed)
+	if force {
+		output += " All documents were re-embedded."
+	}
+	return mcp.NewToolResultText(output), nil
+}
+
 // handleIndexResource handles the docs://index resource
 func (s *Server) handleIndexResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 	docs := s.docProvider.GetDocuments()
@@ -259,9 +786,97 @@ func (s *Server) handleDocumentResource(ctx context.Context, request mcp.ReadRes
 	}, nil
 }
 
-// ServeStdio starts the MCP server over stdio
-func (s *Server) ServeStdio() error {
-	return server.ServeStdio(s.mcpServer)
+// ServeStdio serves the MCP server over stdio until ctx is cancelled
+func (s *Server) ServeStdio(ctx context.Context) error {
+	err := server.NewStdioServer(s.mcpServer).Listen(ctx, os.Stdin, os.Stdout)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// resultURL returns the deep link to the section a search result came from
+func (s *Server) resultURL(r vector.SearchResult) string {
+	section := r.Chunk.SectionTitle
+	if r.Chunk.ChunkIndex == vector.SummaryChunkIndex {
+		section = ""
+	}
+	return s.baseURL + render.DocumentURL(r.Document.Path, section)
+}
+
+// findSections returns the indexes of the sections matching a heading query:
+// a heading path matching the end of a section's breadcrumb, compared
+// case-insensitively, or an anchor such as "#install"
+func findSections(sections []chunking.Section, query string) []int {
+	var parts []string
+	for _, part := range strings.Split(query, ">") {
+		parts = append(parts, strings.ToLower(strings.TrimSpace(part)))
+	}
+	anchor := render.Anchor(strings.TrimPrefix(query, "#"))
+
+	var matches []int
+	for i, section := range sections {
+		crumbs := strings.Split(strings.ToLower(section.Breadcrumb), chunking.BreadcrumbSeparator)
+		matched := len(parts) <= len(crumbs)
+		for j := 0; matched && j < len(parts); j++ {
+			matched = strings.TrimSpace(crumbs[len(crumbs)-len(parts)+j]) == parts[j]
+		}
+		if matched {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 && anchor != "" {
+		for i, section := range sections {
+			if render.Anchor(section.Title) == anchor {
+				matches = append(matches, i)
+			}
+		}
+	}
+	return matches
+}
+
+// containsAll reports whether tags contains every wanted tag
+func containsAll(tags, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, t := range tags {
+			if t == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// formatMetadata renders a document's frontmatter metadata as a header for
+// the document content
+func formatMetadata(doc DocumentInfo) string {
+	var output strings.Builder
+	if doc.Description != "" {
+		output.WriteString(fmt.Sprintf("**Description:** %s\n", doc.Description))
+	}
+	if len(doc.Tags) > 0 {
+		output.WriteString(fmt.Sprintf("**Tags:** %s\n", strings.Join(doc.Tags, ", ")))
+	}
+
+	keys := make([]string, 0, len(doc.Metadata))
+	for key := range doc.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		output.WriteString(fmt.Sprintf("**%s:** %v\n", key, doc.Metadata[key]))
+	}
+
+	if output.Len() == 0 {
+		return ""
+	}
+	output.WriteString("\n")
+	return output.String()
 }
 
 // truncateString truncates a string to maxLen and adds "..." if truncated
@@ -1,31 +1,311 @@
 package main
 
 import (
+	"net/http"
 	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dimandocs/analytics"
+	"dimandocs/gitignore"
+	"dimandocs/oidc"
+	"dimandocs/secrets"
+	"dimandocs/spelling"
 )
 
 // DirectoryConfig represents a directory configuration with path, name, and file pattern
 type DirectoryConfig struct {
-	Path        string `json:"path"`
-	Name        string `json:"name"`
-	FilePattern string `json:"file_pattern"`
+	Path             string   `json:"path"`
+	Name             string   `json:"name"`
+	FilePattern      string   `json:"file_pattern"`
+	Collection       string   `json:"collection,omitempty"`        // Collection searches can be scoped to, default: "default"
+	Tags             []string `json:"tags,omitempty"`              // Applied to every document in the directory
+	Code             bool     `json:"code,omitempty"`              // Index source files with the code-aware chunker
+	RespectGitignore bool     `json:"respect_gitignore,omitempty"` // Skip paths ignored by the repository's .gitignore files
+	Uploads          bool     `json:"uploads,omitempty"`           // Store documents uploaded through POST /api/documents
+
+	Chunking   *ChunkingConfig         `json:"chunking,omitempty"`   // Overrides embeddings.chunking for this directory
+	Embeddings *SourceEmbeddingsConfig `json:"embeddings,omitempty"` // Own embedding database, and optionally model, for this directory
+
+	Git    *GitSourceConfig    `json:"git,omitempty"`    // Read the directory from a remote git repository
+	GitHub *GitHubSourceConfig `json:"github,omitempty"` // Read the directory through the GitHub API
+
+	GitLabWiki *GitLabWikiSourceConfig `json:"gitlab_wiki,omitempty"` // Read the pages of a GitLab project wiki
+	Confluence *ConfluenceSourceConfig `json:"confluence,omitempty"`  // Read the pages of a Confluence space
+	S3         *S3SourceConfig         `json:"s3,omitempty"`          // Options of an s3:// path
+	Website    *WebsiteSourceConfig    `json:"website,omitempty"`     // Crawl the pages of a website
+}
+
+// GitSourceConfig represents a remote git repository checked out into the
+// cache directory
+type GitSourceConfig struct {
+	URL             string `json:"url"` // Supports ${ENV_VAR} syntax
+	Branch          string `json:"branch,omitempty"`
+	Subpath         string `json:"subpath,omitempty"`          // Directory within the repository
+	RefreshInterval string `json:"refresh_interval,omitempty"` // Pull interval, e.g. "15m"
+}
+
+// GitHubSourceConfig represents a GitHub repository whose matching files are
+// fetched through the REST API into the cache directory
+type GitHubSourceConfig struct {
+	Repo            string `json:"repo"` // "owner/name"
+	Branch          string `json:"branch,omitempty"`
+	Subpath         string `json:"subpath,omitempty"`          // Directory within the repository
+	Token           string `json:"token,omitempty"`            // Supports ${ENV_VAR} syntax, default: GITHUB_TOKEN
+	BaseURL         string `json:"base_url,omitempty"`         // GitHub Enterprise API URL
+	RefreshInterval string `json:"refresh_interval,omitempty"` // Refresh interval, e.g. "15m"
+}
+
+// ConfluenceSourceConfig represents a Confluence space whose pages are
+// fetched through the REST API and converted to markdown
+type ConfluenceSourceConfig struct {
+	BaseURL         string `json:"base_url"`                   // e.g. https://example.atlassian.net/wiki
+	Space           string `json:"space"`                      // Space key
+	Email           string `json:"email,omitempty"`            // Confluence Cloud account, supports ${ENV_VAR}
+	Token           string `json:"token,omitempty"`            // Supports ${ENV_VAR} syntax, default: CONFLUENCE_TOKEN
+	RefreshInterval string `json:"refresh_interval,omitempty"` // Refresh interval, e.g. "1h"
+}
+
+// S3SourceConfig represents the connection options of an S3 or S3-compatible
+// bucket, read from an "s3://bucket/prefix" directory path
+type S3SourceConfig struct {
+	URL             string `json:"-"`                          // The s3:// path
+	Endpoint        string `json:"endpoint,omitempty"`         // S3-compatible endpoint, e.g. http://localhost:9000 for MinIO
+	Region          string `json:"region,omitempty"`           // Default: AWS_REGION or us-east-1
+	AccessKey       string `json:"access_key,omitempty"`       // Supports ${ENV_VAR} syntax, default: AWS_ACCESS_KEY_ID
+	SecretKey       string `json:"secret_key,omitempty"`       // Supports ${ENV_VAR} syntax, default: AWS_SECRET_ACCESS_KEY
+	SessionToken    string `json:"session_token,omitempty"`    // Supports ${ENV_VAR} syntax, default: AWS_SESSION_TOKEN
+	RefreshInterval string `json:"refresh_interval,omitempty"` // Refresh interval, e.g. "5m"
+}
+
+// WebsiteSourceConfig represents a website whose pages are crawled,
+// converted to markdown and mirrored into the cache directory
+type WebsiteSourceConfig struct {
+	URL             string `json:"url"`                        // Base URL; only pages below it are crawled
+	Sitemap         string `json:"sitemap,omitempty"`          // Default: sitemap.xml below the base URL or at the site root
+	MaxDepth        int    `json:"max_depth,omitempty"`        // Link depth without a sitemap (default: 3)
+	MaxPages        int    `json:"max_pages,omitempty"`        // Default: 500
+	RefreshInterval string `json:"refresh_interval,omitempty"` // Refresh interval, e.g. "24h"
+}
+
+// GitLabWikiSourceConfig represents a GitLab project wiki whose pages are
+// fetched through the API into the cache directory
+type GitLabWikiSourceConfig struct {
+	Project         string `json:"project"`                    // Project ID or path, e.g. "group/project"
+	BaseURL         string `json:"base_url,omitempty"`         // Default: https://gitlab.com
+	Token           string `json:"token,omitempty"`            // Supports ${ENV_VAR} syntax, default: GITLAB_TOKEN
+	RefreshInterval string `json:"refresh_interval,omitempty"` // Refresh interval, e.g. "15m"
+}
+
+// SummariesConfig represents LLM document summary configuration
+type SummariesConfig struct {
+	Enabled  bool `json:"enabled"`
+	MaxWords int  `json:"max_words,omitempty"` // Target summary length (default: 80)
+}
+
+// ChunkingConfig represents document chunking configuration
+type ChunkingConfig struct {
+	MaxTokens     int     `json:"max_tokens,omitempty"`            // Measure chunks in tokens instead of characters
+	OverlapTokens int     `json:"overlap_tokens,omitempty"`        // Overlap between chunks in tokens
+	Encoding      string  `json:"encoding,omitempty"`              // tiktoken encoding (default: cl100k_base)
+	CodeContext   bool    `json:"code_context,omitempty"`          // Keep code blocks with the paragraph introducing them
+	Strategy      string  `json:"strategy,omitempty"`              // "markdown" (default) or "semantic"
+	Breakpoint    float64 `json:"breakpoint_percentile,omitempty"` // Semantic split sensitivity (default: 95)
 }
 
 // EmbeddingsConfig represents embedding service configuration
 type EmbeddingsConfig struct {
-	Enabled  bool   `json:"enabled"`
+	Enabled           bool              `json:"enabled"`
+	Provider          string            `json:"provider"` // "openai", "ollama", "voyage", "cohere" or "tei"
+	Model             string            `json:"model"`
+	APIKey            string            `json:"api_key"` // Supports ${ENV_VAR} syntax
+	BaseURL           string            `json:"base_url,omitempty"`
+	Dimension         int               `json:"dimension,omitempty"`           // Embedding dimension, for providers that cannot infer it from the model
+	DBPath            string            `json:"db_path"`                       // Path to embeddings database
+	VectorStore       VectorStoreConfig `json:"vector_store,omitempty"`        // Backend and its connection options, or just the backend name
+	DatabaseURL       string            `json:"database_url,omitempty"`        // Shorthand for the url option of vector_store, supports ${ENV_VAR}
+	Metric            string            `json:"metric,omitempty"`              // Distance metric: "cosine" (default) or "l2"
+	Quantization      string            `json:"quantization,omitempty"`        // Precision of SQLite index vectors: "float" (default), "int8" or "bit"
+	Rescore           bool              `json:"rescore,omitempty"`             // Keep float vectors to rescore quantized search candidates
+	Prune             string            `json:"prune,omitempty"`               // Stale document handling: "auto" (default), "dry_run" or "off"
+	SearchMode        string            `json:"search_mode,omitempty"`         // Default search mode: "vector" (default) or "hybrid"
+	Concurrency       int               `json:"concurrency,omitempty"`         // Documents indexed in parallel (default: 4)
+	RequestsPerMinute int               `json:"requests_per_minute,omitempty"` // Embedding requests per minute while indexing, 0 for no limit
+	Backup            BackupConfig      `json:"backup,omitempty"`
+	Chunking          ChunkingConfig    `json:"chunking,omitempty"`
+	Summaries         SummariesConfig   `json:"summaries,omitempty"` // Requires the llm section
+}
+
+// SourceEmbeddingsConfig represents the embedding database of a directory
+// that is indexed separately from the embeddings section, e.g. a large
+// corpus embedded with an older model. Options left out are those of the
+// embeddings section, except that setting another provider or vector store
+// starts from its defaults.
+type SourceEmbeddingsConfig struct {
+	Provider          string            `json:"provider,omitempty"`
+	Model             string            `json:"model,omitempty"`
+	APIKey            string            `json:"api_key,omitempty"` // Supports ${ENV_VAR} syntax
+	BaseURL           string            `json:"base_url,omitempty"`
+	Dimension         int               `json:"dimension,omitempty"`
+	DBPath            string            `json:"db_path,omitempty"` // One of db_path, vector_store and database_url is required
+	VectorStore       VectorStoreConfig `json:"vector_store,omitempty"`
+	DatabaseURL       string            `json:"database_url,omitempty"`
+	Metric            string            `json:"metric,omitempty"`
+	Quantization      string            `json:"quantization,omitempty"`
+	Rescore           bool              `json:"rescore,omitempty"`
+	RequestsPerMinute int               `json:"requests_per_minute,omitempty"`
+}
+
+// BackupConfig represents scheduled backups of the SQLite vector database
+type BackupConfig struct {
+	Interval string `json:"interval,omitempty"` // Backup interval, e.g. "24h"; empty disables scheduled backups
+	Dir      string `json:"dir,omitempty"`      // Backup directory (default: "backups" next to db_path)
+	Keep     int    `json:"keep,omitempty"`     // Backups kept, older ones are deleted (default: 7)
+}
+
+// VectorStoreConfig selects the vector store backend and its connection
+// options. It is written as a block, {"type": "milvus", "url": "..."}, or as
+// just the backend name.
+type VectorStoreConfig struct {
+	Type    string            // "sqlite" (default), "postgres", "milvus", "weaviate", "elasticsearch", "opensearch" or a compiled-in backend
+	Options map[string]string // Connection options, supports ${ENV_VAR}
+}
+
+// LLMConfig represents chat model configuration used for summaries and answers
+type LLMConfig struct {
 	Provider string `json:"provider"` // "openai" or "ollama"
 	Model    string `json:"model"`
 	APIKey   string `json:"api_key"` // Supports ${ENV_VAR} syntax
 	BaseURL  string `json:"base_url,omitempty"`
-	DBPath   string `json:"db_path"` // Path to embeddings database
+}
+
+// SearchConfig represents semantic search configuration
+type SearchConfig struct {
+	Reranker  RerankerConfig  `json:"reranker,omitempty"`
+	Expansion ExpansionConfig `json:"expansion,omitempty"` // Requires the llm section
+}
+
+// ExpansionConfig represents the expansion of search queries into
+// paraphrases that are searched as well
+type ExpansionConfig struct {
+	Enabled     bool `json:"enabled"`
+	Paraphrases int  `json:"paraphrases,omitempty"` // Paraphrases per query (default: 3)
+}
+
+// RerankerConfig represents the reranking of semantic search results
+type RerankerConfig struct {
+	Provider string `json:"provider,omitempty"` // "cohere", "voyage" or "llm" (uses the llm section), empty disables reranking
+	Model    string `json:"model,omitempty"`
+	APIKey   string `json:"api_key,omitempty"` // Supports ${ENV_VAR} syntax
+	BaseURL  string `json:"base_url,omitempty"`
+	TopK     int    `json:"top_k,omitempty"` // Candidates retrieved and reranked per search (default: 30)
 }
 
 // MCPConfig represents MCP server configuration
 type MCPConfig struct {
 	Enabled   bool   `json:"enabled"`
-	Transport string `json:"transport"` // "stdio" or "http"
-	Port      int    `json:"port,omitempty"`
+	Transport string `json:"transport"`          // "stdio" or "http"
+	Port      int    `json:"port,omitempty"`     // HTTP transport port (default: 8081)
+	BaseURL   string `json:"base_url,omitempty"` // Web UI address for absolute document links
+
+	AuthTokens   []string `json:"auth_tokens,omitempty"`   // Bearer tokens accepted by the HTTP transport, support ${ENV_VAR}
+	AllowReindex bool     `json:"allow_reindex,omitempty"` // Offer the reindex_docs tool; over HTTP only with auth_tokens
+}
+
+// AnalyticsConfig represents search analytics configuration
+type AnalyticsConfig struct {
+	Enabled bool   `json:"enabled"`
+	LogPath string `json:"log_path,omitempty"` // Path to the JSON lines event log
+}
+
+// SecretsConfig represents secret detection configuration
+type SecretsConfig struct {
+	Enabled          bool           `json:"enabled"`
+	Mode             string         `json:"mode,omitempty"`  // "redact" (default) or "flag"
+	Rules            []secrets.Rule `json:"rules,omitempty"` // Additional regex rules
+	DisableDefaults  bool           `json:"disable_default_rules,omitempty"`
+	EntropyThreshold float64        `json:"entropy_threshold,omitempty"` // Bits per character, negative disables
+}
+
+// LoggingConfig represents log output
+type LoggingConfig struct {
+	Level    string `json:"level,omitempty"`    // debug, info (default), warn or error
+	Format   string `json:"format,omitempty"`   // text (default) or json
+	Requests bool   `json:"requests,omitempty"` // Log HTTP requests at info level instead of debug
+}
+
+// TLSConfig represents HTTPS serving, with a certificate from files or from
+// Let's Encrypt
+type TLSConfig struct {
+	CertFile string          `json:"cert_file,omitempty"`
+	KeyFile  string          `json:"key_file,omitempty"`
+	Autocert *AutocertConfig `json:"autocert,omitempty"`
+}
+
+// AutocertConfig represents certificates obtained automatically from Let's
+// Encrypt
+type AutocertConfig struct {
+	Domains  []string `json:"domains"`             // Host names to obtain certificates for
+	Email    string   `json:"email,omitempty"`     // Contact for expiry notices
+	CacheDir string   `json:"cache_dir,omitempty"` // Default: autocert in cache_dir
+	HTTPPort string   `json:"http_port,omitempty"` // Port answering HTTP-01 challenges and redirecting to HTTPS
+}
+
+// OIDCConfig represents login through an OpenID Connect provider
+type OIDCConfig struct {
+	Issuer          string   `json:"issuer"`                     // e.g. https://accounts.google.com
+	ClientID        string   `json:"client_id"`                  // Client registered at the provider
+	ClientSecret    string   `json:"client_secret,omitempty"`    // Supports ${ENV_VAR}
+	RedirectURL     string   `json:"redirect_url"`               // e.g. https://docs.example.com/auth/callback
+	Scopes          []string `json:"scopes,omitempty"`           // Default: openid, profile, email
+	GroupsClaim     string   `json:"groups_claim,omitempty"`     // ID token claim with the user's groups (default: groups)
+	AllowedGroups   []string `json:"allowed_groups,omitempty"`   // Users need one of them; empty allows every user
+	SessionSecret   string   `json:"session_secret,omitempty"`   // Signs session cookies; random per start if empty
+	SessionDuration string   `json:"session_duration,omitempty"` // Default: 12h
+}
+
+// AuthConfig represents access control for the web interface and API
+type AuthConfig struct {
+	Username     string      `json:"username,omitempty"`      // Basic auth user
+	PasswordHash string      `json:"password_hash,omitempty"` // bcrypt hash of the basic auth password
+	Tokens       []string    `json:"tokens,omitempty"`        // Bearer tokens, support ${ENV_VAR}
+	OIDC         *OIDCConfig `json:"oidc,omitempty"`          // Browser login through an OpenID Connect provider
+}
+
+// Enabled reports whether requests need credentials
+func (c AuthConfig) Enabled() bool {
+	return c.Username != "" || len(c.Tokens) > 0 || c.OIDC != nil
+}
+
+// DiagramsConfig represents the rendering of diagram code blocks
+type DiagramsConfig struct {
+	MermaidScript  string `json:"mermaid_script,omitempty"`  // URL of the mermaid ES module, "none" shows the source (default: jsDelivr)
+	PlantUMLServer string `json:"plantuml_server,omitempty"` // PlantUML server rendering plantuml blocks, e.g. https://www.plantuml.com/plantuml
+}
+
+// CORSConfig represents cross-origin access to the JSON APIs from browsers
+type CORSConfig struct {
+	AllowedOrigins []string `json:"allowed_origins,omitempty"` // e.g. https://portal.example.com, or * for any
+	AllowedMethods []string `json:"allowed_methods,omitempty"` // Default: GET, POST
+	AllowedHeaders []string `json:"allowed_headers,omitempty"` // Default: Authorization, Content-Type, X-API-Key
+}
+
+// Enabled reports whether any origin is allowed
+func (c CORSConfig) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// RateLimitConfig represents per-client limits on the search endpoints
+type RateLimitConfig struct {
+	RequestsPerSecond float64  `json:"requests_per_second,omitempty"` // Sustained rate per client address, 0 disables
+	Burst             int      `json:"burst,omitempty"`               // Requests allowed at once, default: requests_per_second rounded up
+	TrustedProxies    []string `json:"trusted_proxies,omitempty"`     // Addresses or CIDR ranges whose X-Forwarded-For and X-Real-IP headers are used
+}
+
+// Enabled reports whether search requests are limited
+func (c RateLimitConfig) Enabled() bool {
+	return c.RequestsPerSecond > 0
 }
 
 // Config represents the application configuration
@@ -34,21 +314,64 @@ type Config struct {
 	Port           string            `json:"port"`
 	Title          string            `json:"title"`
 	IgnorePatterns []string          `json:"ignore_patterns"`
+	Ignore         []string          `json:"ignore,omitempty"`        // .gitignore-style globs, relative to each directory
+	Watch          bool              `json:"watch,omitempty"`         // Reload and re-index documents when files change
+	CacheDir       string            `json:"cache_dir,omitempty"`     // Checkouts of remote sources (default: .dimandocs-cache)
+	TemplatesDir   string            `json:"templates_dir,omitempty"` // Files overriding the embedded web interface
+	CodeTheme      string            `json:"code_theme,omitempty"`    // Chroma style for code blocks, "none" disables highlighting (default: github)
 	Embeddings     EmbeddingsConfig  `json:"embeddings,omitempty"`
+	LLM            LLMConfig         `json:"llm,omitempty"`
+	Search         SearchConfig      `json:"search,omitempty"`
 	MCP            MCPConfig         `json:"mcp,omitempty"`
+	Analytics      AnalyticsConfig   `json:"analytics,omitempty"`
+	Secrets        SecretsConfig     `json:"secrets,omitempty"`
+	Auth           AuthConfig        `json:"auth,omitempty"`
+	TLS            TLSConfig         `json:"tls,omitempty"`
+	Logging        LoggingConfig     `json:"logging,omitempty"`
+	RateLimit      RateLimitConfig   `json:"rate_limit,omitempty"`
+	CORS           CORSConfig        `json:"cors,omitempty"`
+	Diagrams       DiagramsConfig    `json:"diagrams,omitempty"`
 }
 
 // Document represents a parsed markdown document
 type Document struct {
 	Title      string `json:"Title"`
 	Path       string `json:"-"`
-	Content    string `json:"-"`
 	RelPath    string `json:"RelPath"`
 	DirName    string `json:"DirName"`
 	SourceDir  string `json:"-"`
 	SourceName string `json:"SourceName"`
+	Collection string `json:"Collection"` // Collection of the source directory
 	AbsPath    string `json:"AbsPath"`
 	Overview   string `json:"Overview"`
+	Summary    string `json:"Summary,omitempty"` // LLM-generated, when summaries are enabled
+
+	Description string         `json:"Description,omitempty"` // From frontmatter
+	Tags        []string       `json:"Tags,omitempty"`        // From frontmatter
+	Metadata    map[string]any `json:"Metadata,omitempty"`    // Custom frontmatter fields
+
+	Language string `json:"Language,omitempty"` // Source language, for files of code directories
+
+	ModTime time.Time `json:"-"` // Modification time of the file
+
+	Secrets []secrets.Finding `json:"-"` // Detected secrets, when secret scanning is enabled
+
+	content documentContent // Read with Content
+}
+
+// DocumentRef is a lightweight reference to a document
+type DocumentRef struct {
+	Title   string `json:"Title"`
+	RelPath string `json:"RelPath"`
+}
+
+// Backlink represents a link to a document, in the backlinks API
+type Backlink struct {
+	Title   string `json:"Title"`            // Title of the linking document
+	RelPath string `json:"RelPath"`          // Path of the linking document
+	Text    string `json:"Text"`             // Link text
+	Line    int    `json:"Line"`             // Line of the link in the linking document
+	Anchor  string `json:"Anchor,omitempty"` // Heading anchor the link points at
 }
 
 // DirectoryGroup represents a group of documents from the same directory
@@ -62,9 +385,27 @@ type App struct {
 	Config           Config
 	Documents        []Document
 	IgnoreRegexes    []*regexp.Regexp
+	IgnoreGlobs      *gitignore.Matcher
 	FileRegexes      map[string]*regexp.Regexp
 	WorkingDir       string
-	EmbeddingManager *EmbeddingManager // Optional, for vector search
+	EmbeddingManager *EmbeddingManager   // Optional, for vector search
+	Analytics        *analytics.Recorder // Optional, for search analytics
+	Speller          *spelling.Corrector // Vocabulary for keyword search corrections
+	Links            *LinkGraph          // Intra-corpus links and backlinks
+	SecretScanner    *secrets.Scanner    // Optional, for secret detection
+	ConfigFile       string              // The loaded config file, re-read by ReloadConfig
+
+	mu              sync.RWMutex                 // Guards Documents, Speller, Links, listeners, middleware, gitignores and config reloads
+	listeners       []func()                     // Called after the documents change
+	updateMu        sync.Mutex                   // Serializes document updates, so none is lost
+	reloadListeners []func()                     // Called after the config is reloaded
+	gitignores      map[string]*gitignore.Tree   // By directory, for directories respecting .gitignore
+	middleware      []Middleware                 // Added by Use
+	routes          atomic.Pointer[http.Handler] // Routes wrapped in middleware, replaced by SetupRoutes
+	oidc            *oidc.Provider               // Set up by Start when auth.oidc is configured
+	sessions        *oidc.Signer                 // Signs the session cookies of OIDC logins
+	limiter         rateLimiter                  // Buckets of rate_limit by client address
+	reindex         adminReindex                 // Reindex started from the admin page
 }
 
 // IndexData represents data for the API index response
@@ -72,4 +413,19 @@ type IndexData struct {
 	Title          string           `json:"Title"`
 	Groups         []DirectoryGroup `json:"Groups"`
 	TotalDocuments int              `json:"TotalDocuments"`
-}
\ No newline at end of file
+}
+
+// DocumentsData represents the document list of the documents API
+type DocumentsData struct {
+	Groups         []DirectoryGroup `json:"Groups"`
+	TotalDocuments int              `json:"TotalDocuments"`
+}
+
+// DocumentJSON represents a single document of the documents API, with its
+// raw content
+type DocumentJSON struct {
+	Document
+	Content   string        `json:"Content"`
+	Backlinks []DocumentRef `json:"Backlinks"`
+	Secrets   int           `json:"Secrets,omitempty"` // Unredacted potential secrets (flag mode)
+}
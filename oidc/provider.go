@@ -0,0 +1,152 @@
+// Package oidc signs users in through an OpenID Connect provider, such as
+// Okta, Google or Keycloak, with the authorization code flow.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// DefaultGroupsClaim is the ID token claim holding the user's groups
+const DefaultGroupsClaim = "groups"
+
+// Config holds the client registration at the provider
+type Config struct {
+	Issuer       string   // e.g. https://accounts.google.com
+	ClientID     string   // Required
+	ClientSecret string   // Optional for public clients
+	RedirectURL  string   // Callback URL registered at the provider
+	Scopes       []string // Default: openid, profile, email
+	GroupsClaim  string   // Default: DefaultGroupsClaim
+	HTTPClient   *http.Client
+}
+
+// Identity is a signed-in user, from the claims of the ID token
+type Identity struct {
+	Subject string   `json:"sub"`
+	Email   string   `json:"email,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+}
+
+// Provider is a discovered OpenID Connect provider
+type Provider struct {
+	oauth       oauth2.Config
+	verifier    *gooidc.IDTokenVerifier
+	client      *http.Client
+	groupsClaim string
+}
+
+// Discover reads the provider metadata from the issuer's
+// /.well-known/openid-configuration
+func Discover(ctx context.Context, cfg Config) (*Provider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("issuer, client_id and redirect_url are required")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{gooidc.ScopeOpenID, "profile", "email"}
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = DefaultGroupsClaim
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	// The provider keeps the client for fetching its signing keys later
+	provider, err := gooidc.NewProvider(gooidc.ClientContext(ctx, client), cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover provider: %w", err)
+	}
+
+	// Public clients identify themselves in the form, confidential ones
+	// authenticate with basic auth
+	endpoint := provider.Endpoint()
+	endpoint.AuthStyle = oauth2.AuthStyleInHeader
+	if cfg.ClientSecret == "" {
+		endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+
+	return &Provider{
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     endpoint,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		},
+		verifier:    provider.Verifier(&gooidc.Config{ClientID: cfg.ClientID}),
+		client:      client,
+		groupsClaim: cfg.GroupsClaim,
+	}, nil
+}
+
+// AuthCodeURL returns the provider's login page URL. state and nonce bind
+// the callback and ID token to this login, and verifier is the PKCE code
+// verifier passed to Exchange later.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange redeems an authorization code and returns the identity from the
+// verified ID token
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to redeem code: %w", err)
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return Identity{}, fmt.Errorf("token response has no id_token, is the openid scope requested?")
+	}
+
+	// The verifier checks the signature, issuer, audience and expiry
+	idToken, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return Identity{}, fmt.Errorf("ID token nonce does not match the login")
+	}
+	if idToken.Subject == "" {
+		return Identity{}, fmt.Errorf("ID token has no subject")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+	identity := Identity{Subject: idToken.Subject}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	switch groups := claims[p.groupsClaim].(type) {
+	case string:
+		identity.Groups = []string{groups}
+	case []any:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// RandomString returns a random URL-safe string for states, nonces, PKCE
+// verifiers and keys
+func RandomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
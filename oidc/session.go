@@ -0,0 +1,75 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Signer encodes values into tamper-proof, expiring strings for cookies.
+// The values are signed, not encrypted, so clients can read them.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner creates a signer with a secret key
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// signed is the payload of an encoded value
+type signed struct {
+	Expires int64           `json:"exp"`
+	Value   json.RawMessage `json:"v"`
+}
+
+// Encode signs v, valid for ttl
+func (s *Signer) Encode(v any, ttl time.Duration) (string, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(signed{Expires: time.Now().Add(ttl).Unix(), Value: value})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// Decode checks the signature and expiry of an encoded value and decodes it
+// into v
+func (s *Signer) Decode(encoded string, v any) error {
+	payload, sig, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return fmt.Errorf("malformed value")
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, s.mac(payload)) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return fmt.Errorf("malformed value: %w", err)
+	}
+	var value signed
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("malformed value: %w", err)
+	}
+	if time.Now().Unix() > value.Expires {
+		return fmt.Errorf("expired")
+	}
+	return json.Unmarshal(value.Value, v)
+}
+
+// mac returns the signature of an encoded payload
+func (s *Signer) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+
+	"dimandocs/frontmatter"
+	"dimandocs/vector"
+)
+
+// The /v1/vector_stores endpoints follow the schema of OpenAI's vector store
+// API, so that RAG tooling written against it can search the documents. Each
+// collection is a vector store whose ID is the collection name.
+
+const (
+	// defaultVectorStoreResults is the number of results of a vector store
+	// search without max_num_results, as in OpenAI's API
+	defaultVectorStoreResults = 10
+	// maxVectorStoreResults caps max_num_results, as in OpenAI's API
+	maxVectorStoreResults = 50
+)
+
+// VectorStore is a collection in the schema of OpenAI's vector store object
+type VectorStore struct {
+	ID           string                `json:"id"`
+	Object       string                `json:"object"`
+	CreatedAt    int64                 `json:"created_at"` // Unix time of the oldest indexed document
+	Name         string                `json:"name"`
+	UsageBytes   int64                 `json:"usage_bytes"` // Always 0, the size is not known per collection
+	FileCounts   VectorStoreFileCounts `json:"file_counts"`
+	Status       string                `json:"status"`
+	LastActiveAt int64                 `json:"last_active_at"` // Unix time of the latest indexed document
+	Metadata     map[string]string     `json:"metadata"`
+}
+
+// VectorStoreFileCounts counts the documents of a collection by indexing
+// status
+type VectorStoreFileCounts struct {
+	InProgress int `json:"in_progress"`
+	Completed  int `json:"completed"`
+	Failed     int `json:"failed"`
+	Cancelled  int `json:"cancelled"`
+	Total      int `json:"total"`
+}
+
+// VectorStoreSearchRequest is the body of POST
+// /v1/vector_stores/{id}/search
+type VectorStoreSearchRequest struct {
+	Query          json.RawMessage `json:"query"` // A string or an array of strings
+	MaxNumResults  int             `json:"max_num_results"`
+	Filters        json.RawMessage `json:"filters"`
+	RewriteQuery   bool            `json:"rewrite_query"` // Ignored, queries are expanded if search.expansion is configured
+	RankingOptions struct {
+		Ranker         string  `json:"ranker"` // Ignored, results are reranked if search.reranker is configured
+		ScoreThreshold float32 `json:"score_threshold"`
+	} `json:"ranking_options"`
+}
+
+// VectorStoreSearchResult is a matching chunk in the schema of OpenAI's
+// vector store search results
+type VectorStoreSearchResult struct {
+	FileID     string                     `json:"file_id"` // Relative path of the document
+	Filename   string                     `json:"filename"`
+	Score      float32                    `json:"score"`
+	Attributes map[string]any             `json:"attributes"`
+	Content    []VectorStoreSearchContent `json:"content"`
+}
+
+// VectorStoreSearchContent is the text of a vector store search result
+type VectorStoreSearchContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// vectorStoreFilter is a comparison or compound filter of a vector store
+// search
+type vectorStoreFilter struct {
+	Type    string            `json:"type"`
+	Key     string            `json:"key"`
+	Value   any               `json:"value"`
+	Filters []json.RawMessage `json:"filters"`
+}
+
+// VectorStores returns the collections as vector stores, counting their
+// documents by the indexing status recorded in the vector database
+func (a *App) VectorStores() ([]VectorStore, error) {
+	collections := a.Collections()
+	stores := make([]VectorStore, len(collections))
+	byName := make(map[string]*VectorStore, len(collections))
+	for i, info := range collections {
+		stores[i] = VectorStore{
+			ID:       info.Name,
+			Object:   "vector_store",
+			Name:     info.Name,
+			Status:   "completed",
+			Metadata: map[string]string{"sources": strings.Join(info.Sources, ",")},
+		}
+		stores[i].FileCounts.Total = info.Documents
+		byName[info.Name] = &stores[i]
+	}
+
+	docCollection := make(map[string]string) // By relative path
+	for _, doc := range a.GetDocuments() {
+		docCollection[doc.RelPath] = doc.Collection
+	}
+	records, err := a.EmbeddingManager.ListDocuments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexed documents: %w", err)
+	}
+	for _, record := range records {
+		store := byName[docCollection[record.Path]]
+		if store == nil {
+			continue
+		}
+		switch record.Status {
+		case vector.StatusPending:
+			store.FileCounts.InProgress++
+			store.Status = "in_progress"
+		case vector.StatusFailed:
+			store.FileCounts.Failed++
+		default:
+			store.FileCounts.Completed++
+		}
+		if updated := record.UpdatedAt.Unix(); store.CreatedAt == 0 || updated < store.CreatedAt {
+			store.CreatedAt = updated
+		}
+		store.LastActiveAt = max(store.LastActiveAt, record.UpdatedAt.Unix())
+	}
+	return stores, nil
+}
+
+// handleVectorStores serves GET /v1/vector_stores, GET /v1/vector_stores/{id}
+// and POST /v1/vector_stores/{id}/search
+func (a *App) handleVectorStores(w http.ResponseWriter, r *http.Request) {
+	if a.EmbeddingManager == nil || !a.EmbeddingManager.IsEnabled() {
+		openAIError(w, http.StatusServiceUnavailable, "Vector stores require embeddings to be enabled")
+		return
+	}
+
+	id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/vector_stores"), "/"), "/")
+	method := http.MethodGet
+	if action == "search" {
+		method = http.MethodPost
+	} else if action != "" {
+		openAIError(w, http.StatusNotFound, fmt.Sprintf("Unknown endpoint %s", r.URL.Path))
+		return
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		openAIError(w, http.StatusMethodNotAllowed, fmt.Sprintf("Method %s is not allowed, use %s", r.Method, method))
+		return
+	}
+
+	stores, err := a.VectorStores()
+	if err != nil {
+		openAIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if id == "" {
+		data := struct {
+			Object  string        `json:"object"`
+			Data    []VectorStore `json:"data"`
+			FirstID *string       `json:"first_id"`
+			LastID  *string       `json:"last_id"`
+			HasMore bool          `json:"has_more"`
+		}{Object: "list", Data: stores}
+		if len(stores) > 0 {
+			data.FirstID, data.LastID = &stores[0].ID, &stores[len(stores)-1].ID
+		}
+		writeOpenAIJSON(w, data)
+		return
+	}
+
+	var store *VectorStore
+	for i := range stores {
+		if stores[i].ID == id {
+			store = &stores[i]
+		}
+	}
+	if store == nil {
+		openAIError(w, http.StatusNotFound, fmt.Sprintf("No vector store found with id '%s'.", id))
+		return
+	}
+	if action == "" {
+		writeOpenAIJSON(w, store)
+		return
+	}
+	a.searchVectorStore(w, r, store.ID)
+}
+
+// searchVectorStore answers a vector store search with the chunks of the
+// collection best matching the queries
+func (a *App) searchVectorStore(w http.ResponseWriter, r *http.Request, collection string) {
+	var req VectorStoreSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		openAIError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	queries, err := vectorStoreQueries(req.Query)
+	if err != nil {
+		openAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := defaultVectorStoreResults
+	if req.MaxNumResults != 0 {
+		if req.MaxNumResults < 1 || req.MaxNumResults > maxVectorStoreResults {
+			openAIError(w, http.StatusBadRequest, fmt.Sprintf("max_num_results must be between 1 and %d", maxVectorStoreResults))
+			return
+		}
+		limit = req.MaxNumResults
+	}
+	filter := vector.Filter{Collections: []string{collection}}
+	if len(req.Filters) > 0 && string(req.Filters) != "null" {
+		if err := applyVectorStoreFilter(req.Filters, &filter); err != nil {
+			openAIError(w, http.StatusBadRequest, fmt.Sprintf("Invalid filters: %v", err))
+			return
+		}
+	}
+
+	results, err := a.searchQueries(r.Context(), queries, filter, limit)
+	if err != nil {
+		openAIError(w, http.StatusInternalServerError, fmt.Sprintf("Search failed: %v", err))
+		return
+	}
+
+	data := struct {
+		Object      string                    `json:"object"`
+		SearchQuery []string                  `json:"search_query"`
+		Data        []VectorStoreSearchResult `json:"data"`
+		HasMore     bool                      `json:"has_more"`
+		NextPage    *string                   `json:"next_page"`
+	}{Object: "vector_store.search_results.page", SearchQuery: queries, Data: []VectorStoreSearchResult{}}
+	for _, match := range a.resultDocuments(results) {
+		result, doc := match.Result, match.Doc
+		if result.Score < req.RankingOptions.ScoreThreshold {
+			continue
+		}
+		data.Data = append(data.Data, VectorStoreSearchResult{
+			FileID:   doc.RelPath,
+			Filename: path.Base(doc.RelPath),
+			Score:    result.Score,
+			Attributes: map[string]any{
+				"title":      doc.Title,
+				"path":       doc.RelPath,
+				"source":     doc.SourceName,
+				"collection": doc.Collection,
+				"section":    result.Chunk.SectionTitle,
+				"url":        resultURL(result),
+			},
+			Content: []VectorStoreSearchContent{{Type: "text", Text: result.Chunk.ChunkText}},
+		})
+	}
+	a.recordSearch(strings.Join(queries, " | "), a.searchMode(), len(data.Data))
+	writeOpenAIJSON(w, data)
+}
+
+// vectorStoreQueries returns the non-empty queries of a query that is a
+// string or an array of strings
+func vectorStoreQueries(raw json.RawMessage) ([]string, error) {
+	var queries []string
+	var query string
+	if err := json.Unmarshal(raw, &query); err == nil {
+		queries = []string{query}
+	} else if err := json.Unmarshal(raw, &queries); err != nil {
+		return nil, errors.New("query must be a string or an array of strings")
+	}
+
+	var nonEmpty []string
+	for _, query := range queries {
+		if query = strings.TrimSpace(query); query != "" {
+			nonEmpty = append(nonEmpty, query)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil, errors.New("query is required")
+	}
+	return nonEmpty, nil
+}
+
+// applyVectorStoreFilter narrows filter by a vector store search filter. It
+// supports eq comparisons of the source, section, path_prefix and tag keys,
+// and and-combinations of them.
+func applyVectorStoreFilter(raw json.RawMessage, filter *vector.Filter) error {
+	var f vectorStoreFilter
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return err
+	}
+	switch f.Type {
+	case "and":
+		for _, nested := range f.Filters {
+			if err := applyVectorStoreFilter(nested, filter); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "eq":
+	default:
+		return fmt.Errorf("unsupported filter type %q, expected eq or and", f.Type)
+	}
+
+	value, ok := f.Value.(string)
+	if !ok {
+		return fmt.Errorf("value of %s must be a string", f.Key)
+	}
+	var field *string
+	switch f.Key {
+	case "tag":
+		filter.Tags = append(filter.Tags, frontmatter.NormalizeTags([]string{value})...)
+		return nil
+	case "source":
+		field = &filter.Source
+	case "section":
+		field = &filter.SectionTitle
+	case "path_prefix":
+		field = &filter.PathPrefix
+	default:
+		return fmt.Errorf("unsupported filter key %q, expected source, section, path_prefix or tag", f.Key)
+	}
+	if *field != "" && *field != value {
+		return fmt.Errorf("%s can only be compared with one value", f.Key)
+	}
+	*field = value
+	return nil
+}
+
+// writeOpenAIJSON writes a response of the vector store API
+func writeOpenAIJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Warn("Failed to encode response", "error", err)
+	}
+}
+
+// openAIError answers with an error object in the schema of OpenAI's API
+func openAIError(w http.ResponseWriter, status int, message string) {
+	errorType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errorType = "server_error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	data := map[string]any{"error": map[string]any{"message": message, "type": errorType, "param": nil, "code": nil}}
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Warn("Failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval is how often the buckets of idle clients are dropped
+const rateLimitSweepInterval = time.Minute
+
+// tokenBucket holds the requests a client may still make
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter keeps a token bucket per client address
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// take takes a token from the bucket of a client. If there is none, it
+// returns how long until the next one is available.
+func (l *rateLimiter) take(client string, cfg RateLimitConfig, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	burst := float64(cfg.Burst)
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(cfg, now)
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: burst, updated: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.updated).Seconds()*cfg.RequestsPerSecond)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / cfg.RequestsPerSecond * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled, which are the same as new ones
+func (l *rateLimiter) sweep(cfg RateLimitConfig, now time.Time) {
+	l.lastSweep = now
+	refill := time.Duration(float64(cfg.Burst) / cfg.RequestsPerSecond * float64(time.Second))
+	for client, b := range l.buckets {
+		if now.Sub(b.updated) >= refill {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// rateLimit limits the requests of each client address as configured by
+// rate_limit, answering 429 Too Many Requests beyond it
+func (a *App) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.RLock()
+		cfg := a.Config.RateLimit
+		a.mu.RUnlock()
+		if !cfg.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := clientAddress(r, cfg.TrustedProxies)
+		if ok, wait := a.limiter.take(client, cfg, time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests, try again later", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientAddress returns the address of the client making a request. Requests
+// from trusted proxies are attributed to the address they forwarded: the last
+// one in X-Forwarded-For that is not itself a trusted proxy, or else X-Real-IP.
+func clientAddress(r *http.Request, trustedProxies []string) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !isTrustedProxy(peer, trustedProxies) {
+		return peer
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				// A malformed hop cannot be told apart from the proxies
+				return peer
+			}
+			if !isTrustedProxy(hop, trustedProxies) || i == 0 {
+				return hop
+			}
+		}
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		if _, err := netip.ParseAddr(realIP); err == nil {
+			return realIP
+		}
+	}
+	return peer
+}
+
+// isTrustedProxy reports whether addr lies in one of the trusted proxy ranges
+func isTrustedProxy(addr string, trustedProxies []string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, proxy := range trustedProxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil && prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ReloadConfig re-reads the config file and applies it to the running app:
+// patterns are recompiled, added and removed directories are rescanned and
+// the routes are set up again. Settings that need a restart, such as the port
+// or the embedding provider, keep their current value. If the new config
+// cannot be loaded, the current one stays in effect.
+func (a *App) ReloadConfig(ctx context.Context) error {
+	next := NewApp()
+	next.WorkingDir = a.WorkingDir
+	if err := next.LoadConfig(a.ConfigFile); err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	if err := next.SyncSources(ctx); err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	if err := next.createUploadsDirectory(); err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	a.mu.RLock()
+	keepDirectoryEmbeddings(next.Config.Directories, a.Config.Directories)
+	a.mu.RUnlock()
+	if m := a.EmbeddingManager; m != nil {
+		if err := m.ConfigureDirectories(next.Config.Directories); err != nil {
+			return fmt.Errorf("failed to reload config: %w", err)
+		}
+	}
+
+	a.mu.Lock()
+	keepRestartSettings(&next.Config, a.Config)
+	a.Config = next.Config
+	a.IgnoreRegexes = next.IgnoreRegexes
+	a.IgnoreGlobs = next.IgnoreGlobs
+	a.FileRegexes = next.FileRegexes
+	listeners := a.reloadListeners
+	a.mu.Unlock()
+	configureLogging(a.Config.Logging)
+
+	stats, err := a.Reindex(ctx, "", false)
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	if a.routes.Load() != nil {
+		a.SetupRoutes()
+	}
+
+	for _, fn := range listeners {
+		fn()
+	}
+
+	slog.Info("Reloaded config", "path", a.ConfigFile, "directories", len(next.Config.Directories), "documents", stats.Documents)
+	return nil
+}
+
+// OnConfigReloaded registers fn to be called after every ReloadConfig
+func (a *App) OnConfigReloaded(fn func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.reloadListeners = append(a.reloadListeners, fn)
+}
+
+// keepRestartSettings copies the settings that only take effect on startup
+// from current to next, warning about those that changed
+func keepRestartSettings(next *Config, current Config) {
+	keepSetting("port", &next.Port, current.Port)
+	keepSetting("tls", &next.TLS, current.TLS)
+	keepSetting("watch", &next.Watch, current.Watch)
+	keepSetting("embeddings", &next.Embeddings, current.Embeddings)
+	keepSetting("llm", &next.LLM, current.LLM)
+	keepSetting("search", &next.Search, current.Search)
+	keepSetting("mcp", &next.MCP, current.MCP)
+	keepSetting("analytics", &next.Analytics, current.Analytics)
+	keepSetting("secrets", &next.Secrets, current.Secrets)
+	keepSetting("auth.oidc", &next.Auth.OIDC, current.Auth.OIDC)
+}
+
+// keepDirectoryEmbeddings keeps the embeddings of directories that stay
+// configured, since moving their documents to another database needs a
+// restart. Added directories get their own embeddings right away.
+func keepDirectoryEmbeddings(next, current []DirectoryConfig) {
+	for i := range next {
+		for _, dirConfig := range current {
+			if dirConfig.Path == next[i].Path {
+				keepSetting("directories."+next[i].Name+".embeddings", &next[i].Embeddings, dirConfig.Embeddings)
+				break
+			}
+		}
+	}
+}
+
+// keepSetting sets next to current, warning if they differ
+func keepSetting[T any](name string, next *T, current T) {
+	if !reflect.DeepEqual(*next, current) {
+		slog.Warn("Setting changed in the config, restart to apply it", "setting", name)
+	}
+	*next = current
+}
+
+// ConfigReloader reloads the config on SIGHUP and, optionally, when the
+// config file changes
+type ConfigReloader struct {
+	app     *App
+	signals chan os.Signal
+	watcher *fsnotify.Watcher // Nil unless the config file is watched
+	timer   *time.Timer       // Debounces file changes
+	mu      sync.Mutex        // Guards timer
+	reloads sync.Mutex        // Serializes reloads
+	done    chan struct{}
+	ctx     context.Context // Cancelled by Close, to stop a reload in progress
+	cancel  context.CancelFunc
+}
+
+// NewConfigReloader creates a reloader for the config file of the app. With
+// watchFile set, changes to the file trigger a reload as well.
+func NewConfigReloader(app *App, watchFile bool) (*ConfigReloader, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &ConfigReloader{
+		app:     app,
+		signals: make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	if !watchFile {
+		return r, nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	// Watch the directory, since editors often replace the file when saving
+	if err := watcher.Add(filepath.Dir(app.ConfigFile)); err != nil {
+		cancel()
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch config file %s: %w", app.ConfigFile, err)
+	}
+	r.watcher = watcher
+	return r, nil
+}
+
+// Start handles reload triggers in the background until Close is called
+func (r *ConfigReloader) Start() {
+	signal.Notify(r.signals, syscall.SIGHUP)
+	go r.run()
+}
+
+// Close stops the reloader, cancelling a reload in progress and waiting for
+// it to return
+func (r *ConfigReloader) Close() error {
+	signal.Stop(r.signals)
+	close(r.done)
+	r.cancel()
+
+	r.mu.Lock()
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.mu.Unlock()
+
+	r.reloads.Lock()
+	defer r.reloads.Unlock()
+
+	if r.watcher != nil {
+		return r.watcher.Close()
+	}
+	return nil
+}
+
+// run dispatches signals and file system events
+func (r *ConfigReloader) run() {
+	// A nil channel blocks forever when the file is not watched
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if r.watcher != nil {
+		events, errs = r.watcher.Events, r.watcher.Errors
+	}
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-r.signals:
+			slog.Info("Received SIGHUP, reloading config")
+			go r.reload()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if samePath(event.Name, r.app.ConfigFile) && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
+				r.schedule()
+			}
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+			slog.Warn("Config watcher error", "error", err)
+		}
+	}
+}
+
+// schedule debounces reloads after file changes
+func (r *ConfigReloader) schedule() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timer != nil {
+		r.timer.Reset(watchDebounce)
+		return
+	}
+	r.timer = time.AfterFunc(watchDebounce, func() {
+		slog.Info("Config file changed, reloading", "path", r.app.ConfigFile)
+		r.reload()
+	})
+}
+
+// reload reloads the config, keeping the current one on errors
+func (r *ConfigReloader) reload() {
+	r.reloads.Lock()
+	defer r.reloads.Unlock()
+	if r.ctx.Err() != nil {
+		return
+	}
+
+	if err := r.app.ReloadConfig(r.ctx); err != nil {
+		slog.Warn("Failed to reload config", "error", err)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"dimandocs/sources"
+)
+
+// gitRepo returns the checkout of a git source in the cache directory
+func (a *App) gitRepo(cfg *GitSourceConfig) *sources.GitRepo {
+	return &sources.GitRepo{
+		URL:    cfg.URL,
+		Branch: cfg.Branch,
+		Dir:    sources.CheckoutDir(a.Config.CacheDir, cfg.URL, cfg.Branch),
+	}
+}
+
+// githubRepo returns the mirror of a GitHub source in the cache directory
+func (a *App) githubRepo(dirConfig DirectoryConfig) *sources.GitHubRepo {
+	cfg := dirConfig.GitHub
+	return &sources.GitHubRepo{
+		Repo:    cfg.Repo,
+		Branch:  cfg.Branch,
+		Subpath: cfg.Subpath,
+		Token:   cfg.Token,
+		BaseURL: cfg.BaseURL,
+		Dir:     sources.CheckoutDir(a.Config.CacheDir, "github.com/"+cfg.Repo, cfg.Branch),
+		Pattern: a.FileRegexes[dirConfig.Path],
+	}
+}
+
+// gitlabWiki returns the mirror of a GitLab wiki source in the cache directory
+func (a *App) gitlabWiki(cfg *GitLabWikiSourceConfig) *sources.GitLabWiki {
+	return &sources.GitLabWiki{
+		Project: cfg.Project,
+		BaseURL: cfg.BaseURL,
+		Token:   cfg.Token,
+		Dir:     sources.CheckoutDir(a.Config.CacheDir, cfg.BaseURL+"/"+cfg.Project+".wiki", ""),
+	}
+}
+
+// confluenceSpace returns the mirror of a Confluence source in the cache
+// directory
+func (a *App) confluenceSpace(cfg *ConfluenceSourceConfig) *sources.ConfluenceSpace {
+	return &sources.ConfluenceSpace{
+		BaseURL: cfg.BaseURL,
+		Space:   cfg.Space,
+		Email:   cfg.Email,
+		Token:   cfg.Token,
+		Dir:     sources.CheckoutDir(a.Config.CacheDir, cfg.BaseURL+"/"+cfg.Space, ""),
+	}
+}
+
+// s3Bucket returns the mirror of an S3 source in the cache directory
+func (a *App) s3Bucket(dirConfig DirectoryConfig) *sources.S3Bucket {
+	cfg := dirConfig.S3
+	bucket, prefix, _ := sources.ParseS3URL(cfg.URL)
+	return &sources.S3Bucket{
+		Bucket:       bucket,
+		Prefix:       prefix,
+		Endpoint:     cfg.Endpoint,
+		Region:       cfg.Region,
+		AccessKey:    cfg.AccessKey,
+		SecretKey:    cfg.SecretKey,
+		SessionToken: cfg.SessionToken,
+		Dir:          sources.CheckoutDir(a.Config.CacheDir, cfg.Endpoint+"/"+cfg.URL, ""),
+		Pattern:      a.FileRegexes[dirConfig.Path],
+	}
+}
+
+// website returns the mirror of a website source in the cache directory
+func (a *App) website(cfg *WebsiteSourceConfig) *sources.Website {
+	return &sources.Website{
+		URL:      cfg.URL,
+		Sitemap:  cfg.Sitemap,
+		MaxDepth: cfg.MaxDepth,
+		MaxPages: cfg.MaxPages,
+		Dir:      sources.CheckoutDir(a.Config.CacheDir, cfg.URL, ""),
+	}
+}
+
+// remoteSource returns the remote source of a directory and its refresh
+// interval, or nil for local directories
+func (a *App) remoteSource(dirConfig DirectoryConfig) (sources.Source, string) {
+	switch {
+	case dirConfig.Git != nil:
+		return a.gitRepo(dirConfig.Git), dirConfig.Git.RefreshInterval
+	case dirConfig.GitHub != nil:
+		return a.githubRepo(dirConfig), dirConfig.GitHub.RefreshInterval
+	case dirConfig.GitLabWiki != nil:
+		return a.gitlabWiki(dirConfig.GitLabWiki), dirConfig.GitLabWiki.RefreshInterval
+	case dirConfig.Confluence != nil:
+		return a.confluenceSpace(dirConfig.Confluence), dirConfig.Confluence.RefreshInterval
+	case dirConfig.S3 != nil:
+		return a.s3Bucket(dirConfig), dirConfig.S3.RefreshInterval
+	case dirConfig.Website != nil:
+		return a.website(dirConfig.Website), dirConfig.Website.RefreshInterval
+	}
+	return nil, ""
+}
+
+// SyncSources mirrors the remote sources into the cache directory. A source
+// that cannot be synced is served from its previous mirror.
+func (a *App) SyncSources(ctx context.Context) error {
+	for _, dirConfig := range a.Config.Directories {
+		source, _ := a.remoteSource(dirConfig)
+		if source == nil {
+			continue
+		}
+
+		slog.Info("Syncing source", "directory", dirConfig.Name, "source", source)
+		if _, err := source.Sync(ctx); err != nil {
+			if !source.Exists() {
+				return fmt.Errorf("failed to sync source %s: %w", dirConfig.Name, err)
+			}
+			slog.Warn("Failed to sync source, using previous copy", "directory", dirConfig.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// SourceRefresher periodically syncs remote sources and reloads the
+// documents of the sources that changed
+type SourceRefresher struct {
+	app  *App
+	mu   sync.Mutex // Guards done
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSourceRefresher creates a refresher for the remote sources of the app
+func NewSourceRefresher(app *App) *SourceRefresher {
+	return &SourceRefresher{app: app, done: make(chan struct{})}
+}
+
+// Start refreshes every remote source with a refresh interval in the
+// background until Close is called
+func (r *SourceRefresher) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.start()
+}
+
+// start starts a refresh loop per remote source
+func (r *SourceRefresher) start() {
+	for _, dirConfig := range r.app.Config.Directories {
+		source, refreshInterval := r.app.remoteSource(dirConfig)
+		if source == nil || refreshInterval == "" {
+			continue
+		}
+		interval, err := time.ParseDuration(refreshInterval)
+		if err != nil || interval <= 0 {
+			continue
+		}
+
+		slog.Info("Refreshing source periodically", "directory", dirConfig.Name, "interval", interval)
+		r.wg.Add(1)
+		go r.run(dirConfig, source, interval, r.done)
+	}
+}
+
+// Restart stops refreshing and starts again with the current sources, after
+// the config was reloaded
+func (r *SourceRefresher) Restart() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	close(r.done)
+	r.wg.Wait()
+	r.done = make(chan struct{})
+	r.start()
+}
+
+// Close stops refreshing and waits for running refreshes to finish
+func (r *SourceRefresher) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	close(r.done)
+	r.wg.Wait()
+	return nil
+}
+
+// run syncs a remote source on every tick
+func (r *SourceRefresher) run(dirConfig DirectoryConfig, source sources.Source, interval time.Duration, done <-chan struct{}) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-done
+		cancel()
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			changed, err := source.Sync(ctx)
+			if err != nil {
+				slog.Warn("Failed to refresh source", "directory", dirConfig.Name, "error", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if err := r.app.ReloadDirectory(ctx, dirConfig); err != nil {
+				slog.Warn("Failed to reload source", "directory", dirConfig.Name, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// mermaidOpen starts the block of a mermaid diagram, which the mermaid
+// script renders in the browser
+const mermaidOpen = `<pre class="mermaid">`
+
+// plantUMLEncoding is the base64 variant of PlantUML server URLs
+var plantUMLEncoding = base64.NewEncoding("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_").WithPadding(base64.NoPadding)
+
+// HasMermaid reports whether rendered HTML contains mermaid diagrams
+func HasMermaid(rendered []byte) bool {
+	return bytes.Contains(rendered, []byte(mermaidOpen))
+}
+
+// diagram writes a mermaid or PlantUML code block as a diagram. It returns
+// false for other code blocks, and for diagrams that are not enabled.
+func (r *renderer) diagram(w io.Writer, node *blackfriday.Node, language string) bool {
+	switch strings.ToLower(language) {
+	case "mermaid":
+		if !r.opts.Mermaid {
+			return false
+		}
+		io.WriteString(w, mermaidOpen)
+		io.WriteString(w, html.EscapeString(string(node.Literal)))
+		io.WriteString(w, "</pre>\n")
+		return true
+	case "plantuml", "puml":
+		if r.opts.PlantUMLServer == "" {
+			return false
+		}
+		encoded, err := encodePlantUML(string(node.Literal))
+		if err != nil {
+			return false
+		}
+		src := strings.TrimSuffix(r.opts.PlantUMLServer, "/") + "/svg/" + encoded
+		io.WriteString(w, `<p><img class="plantuml" src="`+html.EscapeString(src)+`" alt="PlantUML diagram"></p>`+"\n")
+		return true
+	}
+	return false
+}
+
+// encodePlantUML encodes diagram source for a PlantUML server URL: deflated,
+// then base64 encoded with PlantUML's alphabet
+func encodePlantUML(source string) (string, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write([]byte(source)); err != nil {
+		return "", err
+	}
+	if err := fw.Close(); err != nil {
+		return "", err
+	}
+	// PlantUML encodes whole groups of 3 bytes, padding with zeros
+	for buf.Len()%3 != 0 {
+		buf.WriteByte(0)
+	}
+	return plantUMLEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,95 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/russross/blackfriday/v2"
+)
+
+// DefaultCodeTheme is the chroma style used for code blocks by default
+const DefaultCodeTheme = "github"
+
+// IsCodeTheme reports whether name is a chroma style
+func IsCodeTheme(name string) bool {
+	_, ok := styles.Registry[strings.ToLower(name)]
+	return ok
+}
+
+// renderer renders markdown like blackfriday's HTML renderer, highlighting
+// the code blocks
+type renderer struct {
+	*blackfriday.HTMLRenderer
+	opts      Options
+	headings  []Heading     // Table of contents, collected while rendering
+	style     *chroma.Style // Nil to render code blocks as plain <pre> blocks
+	formatter *chromahtml.Formatter
+}
+
+func newRenderer(opts Options) *renderer {
+	r := &renderer{
+		HTMLRenderer: blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+			Flags: blackfriday.CommonHTMLFlags,
+		}),
+		opts: opts,
+	}
+	if opts.CodeTheme != "" {
+		r.style = styles.Get(opts.CodeTheme)
+		r.formatter = chromahtml.New(chromahtml.TabWidth(4))
+	}
+	return r
+}
+
+func (r *renderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	if node.Type == blackfriday.Heading && entering {
+		return r.renderHeading(w, node)
+	}
+	if (node.Type == blackfriday.Link || node.Type == blackfriday.Image) && entering && r.opts.RewriteLink != nil {
+		dest := r.opts.RewriteLink(string(node.LinkData.Destination), node.Type == blackfriday.Image)
+		node.LinkData.Destination = []byte(dest)
+	}
+	if node.Type == blackfriday.CodeBlock {
+		language, _, _ := strings.Cut(strings.TrimSpace(string(node.CodeBlockData.Info)), " ")
+		if r.diagram(w, node, language) {
+			return blackfriday.GoToNext
+		}
+		if r.style != nil && r.highlight(w, node, language) == nil {
+			return blackfriday.GoToNext
+		}
+	}
+	return r.HTMLRenderer.RenderNode(w, node, entering)
+}
+
+// highlight writes a code block with inline styles, in the given language or
+// the one detected from the code
+func (r *renderer) highlight(w io.Writer, node *blackfriday.Node, language string) error {
+	code := string(node.Literal)
+
+	var lexer chroma.Lexer
+	if language != "" {
+		lexer = lexers.Get(language)
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return err
+	}
+	// Render into a buffer first, so a failure falls back to a plain block
+	var buf bytes.Buffer
+	if err := r.formatter.Format(&buf, r.style, iterator); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,70 @@
+package render
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// Extensions are the markdown extensions used for rendering documents.
+// AutoHeadingIDs gives every heading a stable anchor derived from its text.
+const Extensions = blackfriday.CommonExtensions | blackfriday.AutoHeadingIDs
+
+// explicitIDRegex matches an explicit heading ID such as "Title {#custom-id}"
+var explicitIDRegex = regexp.MustCompile(`\{#([^}\s]+)\}\s*$`)
+
+// Options control how markdown is rendered
+type Options struct {
+	CodeTheme      string // Chroma style highlighting code blocks, "" for plain blocks
+	Mermaid        bool   // Render mermaid blocks for the mermaid script
+	PlantUMLServer string // Render plantuml blocks as images from this server, "" to show their source
+
+	// RewriteLink, if set, returns the destination to render for a link or
+	// image destination
+	RewriteLink func(dest string, image bool) string
+}
+
+// Markdown renders markdown content to HTML with heading anchors
+func Markdown(content string, opts Options) []byte {
+	html, _ := MarkdownWithTOC(content, opts)
+	return html
+}
+
+// MarkdownWithTOC renders markdown content to HTML with heading anchors, and
+// returns the headings as a table of contents
+func MarkdownWithTOC(content string, opts Options) ([]byte, []Heading) {
+	r := newRenderer(opts)
+	html := blackfriday.Run([]byte(content),
+		blackfriday.WithExtensions(Extensions),
+		blackfriday.WithRenderer(r),
+	)
+	return html, r.headings
+}
+
+// Anchor returns the anchor ID the renderer generates for a heading with the
+// given raw markdown text
+func Anchor(heading string) string {
+	heading = strings.TrimSpace(heading)
+	if match := explicitIDRegex.FindStringSubmatch(heading); match != nil {
+		return match[1]
+	}
+	heading = strings.TrimSpace(strings.TrimRight(heading, "#"))
+	return blackfriday.SanitizedAnchorName(heading)
+}
+
+// DocumentURL returns the web UI link to a document, pointing at the given
+// section when sectionTitle is not empty
+func DocumentURL(relPath, sectionTitle string) string {
+	segments := strings.Split(relPath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	link := "/doc/" + strings.Join(segments, "/")
+	if anchor := Anchor(sectionTitle); anchor != "" {
+		link += "#" + anchor
+	}
+	return link
+}
@@ -0,0 +1,48 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// Heading is an entry of a document's table of contents
+type Heading struct {
+	Level int    `json:"Level"`
+	Text  string `json:"Text"`
+	ID    string `json:"ID"` // Anchor of the heading in the rendered document
+}
+
+// headingIDRegex matches the id attribute of a rendered heading tag
+var headingIDRegex = regexp.MustCompile(`^\s*<h[1-6][^>]* id="([^"]+)"`)
+
+// renderHeading renders the opening tag of a heading and adds the heading to
+// the table of contents, with the ID made unique by the HTML renderer
+func (r *renderer) renderHeading(w io.Writer, node *blackfriday.Node) blackfriday.WalkStatus {
+	var buf bytes.Buffer
+	status := r.HTMLRenderer.RenderNode(&buf, node, true)
+	if match := headingIDRegex.FindSubmatch(buf.Bytes()); match != nil {
+		r.headings = append(r.headings, Heading{
+			Level: node.Level,
+			Text:  headingText(node),
+			ID:    string(match[1]),
+		})
+	}
+	w.Write(buf.Bytes())
+	return status
+}
+
+// headingText returns the plain text of a heading, without markup
+func headingText(node *blackfriday.Node) string {
+	var b strings.Builder
+	node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && (n.Type == blackfriday.Text || n.Type == blackfriday.Code) {
+			b.Write(n.Literal)
+		}
+		return blackfriday.GoToNext
+	})
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,88 @@
+package rerank
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultCohereURL is the default Cohere rerank API endpoint
+	DefaultCohereURL = "https://api.cohere.com/v2/rerank"
+	// DefaultCohereModel is the default rerank model for Cohere
+	DefaultCohereModel = "rerank-v3.5"
+	// CohereTimeout is the timeout for Cohere requests
+	CohereTimeout = 30 * time.Second
+)
+
+// CohereReranker implements Reranker using the Cohere rerank API
+type CohereReranker struct {
+	apiKey  string
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+// CohereConfig holds configuration for the Cohere reranker
+type CohereConfig struct {
+	APIKey  string
+	BaseURL string // Default: https://api.cohere.com/v2/rerank
+	Model   string // Default: rerank-v3.5
+}
+
+// cohereRequest represents the request body for the Cohere rerank API
+type cohereRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+}
+
+// cohereResponse represents the response from the Cohere rerank API
+type cohereResponse struct {
+	Results []rerankResult `json:"results"`
+}
+
+// NewCohereReranker creates a new Cohere reranker
+func NewCohereReranker(cfg CohereConfig) (*CohereReranker, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("Cohere API key is required")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultCohereURL
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = DefaultCohereModel
+	}
+
+	return &CohereReranker{
+		apiKey:  cfg.APIKey,
+		baseURL: baseURL,
+		model:   model,
+		client: &http.Client{
+			Timeout: CohereTimeout,
+		},
+	}, nil
+}
+
+// Rerank returns the relevance score of each document to the query
+func (r *CohereReranker) Rerank(ctx context.Context, query string, documents []string) ([]float32, error) {
+	if len(documents) == 0 {
+		return nil, nil
+	}
+
+	var resp cohereResponse
+	err := postJSON(ctx, r.client, r.baseURL, r.apiKey, "Cohere", cohereRequest{
+		Model:     r.model,
+		Query:     query,
+		Documents: documents,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return scoresByIndex(resp.Results, len(documents))
+}
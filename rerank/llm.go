@@ -0,0 +1,82 @@
+package rerank
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"dimandocs/llm"
+)
+
+// llmMaxPassage is the maximum number of characters of a document shown to
+// the chat model, which keeps the prompt within small context windows
+const llmMaxPassage = 1500
+
+// LLMReranker implements Reranker by asking a chat model to grade the
+// relevance of each document
+type LLMReranker struct {
+	client llm.Client
+}
+
+// NewLLMReranker creates a reranker using a chat model
+func NewLLMReranker(client llm.Client) *LLMReranker {
+	return &LLMReranker{client: client}
+}
+
+// Rerank returns the relevance score of each document to the query, from 0
+// for unrelated to 1 for a direct answer
+func (r *LLMReranker) Rerank(ctx context.Context, query string, documents []string) ([]float32, error) {
+	if len(documents) == 0 {
+		return nil, nil
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Query: %s\n\n", query)
+	for i, doc := range documents {
+		if len(doc) > llmMaxPassage {
+			doc = doc[:llmMaxPassage]
+		}
+		fmt.Fprintf(&prompt, "[%d]\n%s\n\n", i+1, doc)
+	}
+	fmt.Fprintf(&prompt, "Grade each of the %d passages.", len(documents))
+
+	reply, err := r.client.Complete(ctx, []llm.Message{
+		{
+			Role:    llm.RoleSystem,
+			Content: "You grade how well documentation passages answer a search query, from 0 for unrelated to 10 for a direct answer. Reply with a JSON array of the grades only, one number per passage in the given order.",
+		},
+		{
+			Role:    llm.RoleUser,
+			Content: prompt.String(),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to grade passages: %w", err)
+	}
+	return parseGrades(reply, len(documents))
+}
+
+// parseGrades reads the JSON array of grades from a reply, ignoring any text
+// or code fence around it, and returns them as scores
+func parseGrades(reply string, documents int) ([]float32, error) {
+	start := strings.Index(reply, "[")
+	end := strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no grades in reply: %q", reply)
+	}
+
+	var grades []float32
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &grades); err != nil {
+		return nil, fmt.Errorf("failed to parse grades: %w", err)
+	}
+	if len(grades) != documents {
+		return nil, fmt.Errorf("expected %d grades, got %d", documents, len(grades))
+	}
+
+	// Grades are out of 10, scores from 0 to 1 like those of rerank APIs
+	for i, grade := range grades {
+		grades[i] = min(max(grade, 0), 10) / 10
+	}
+	return grades, nil
+}
@@ -0,0 +1,127 @@
+// Package rerank reorders search results by scoring each candidate against
+// the query with a cross-encoder rerank API or a chat model, which is more
+// precise than the similarity of embeddings.
+package rerank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"dimandocs/vector"
+)
+
+// Reranker scores documents by their relevance to a query
+type Reranker interface {
+	// Rerank returns a relevance score for each document, higher is better
+	Rerank(ctx context.Context, query string, documents []string) ([]float32, error)
+}
+
+// Results scores search results against the query and returns the limit
+// most relevant of them, with the reranker's scores
+func Results(ctx context.Context, r Reranker, query string, results []vector.SearchResult, limit int) ([]vector.SearchResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	documents := make([]string, len(results))
+	for i, result := range results {
+		documents[i] = passage(result)
+	}
+	scores, err := r.Rerank(ctx, query, documents)
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) != len(results) {
+		return nil, fmt.Errorf("expected %d rerank scores, got %d", len(results), len(scores))
+	}
+
+	reranked := make([]vector.SearchResult, len(results))
+	copy(reranked, results)
+	for i := range reranked {
+		reranked[i].Score = scores[i]
+	}
+	// Stable, so equally relevant results keep their retrieval order
+	sort.SliceStable(reranked, func(i, j int) bool { return reranked[i].Score > reranked[j].Score })
+	if len(reranked) > limit {
+		reranked = reranked[:limit]
+	}
+	return reranked, nil
+}
+
+// passage returns the text of a result that is scored, with the document
+// title and section giving the chunk its context
+func passage(result vector.SearchResult) string {
+	var b strings.Builder
+	if result.Document.Title != "" {
+		b.WriteString(result.Document.Title)
+		b.WriteString("\n")
+	}
+	if result.Chunk.Breadcrumb != "" {
+		b.WriteString(result.Chunk.Breadcrumb)
+		b.WriteString("\n")
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(result.Chunk.ChunkText)
+	return b.String()
+}
+
+// rerankResult is a scored document of the Cohere and Voyage AI rerank APIs
+type rerankResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+// postJSON sends a JSON request with a bearer token and decodes the JSON
+// response into v
+func postJSON(ctx context.Context, client *http.Client, url, apiKey, service string, body, v any) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to %s: %w", service, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		return fmt.Errorf("%s API error (status %d): %s", service, resp.StatusCode, string(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// scoresByIndex orders the scores of rerank results by document index
+func scoresByIndex(results []rerankResult, documents int) ([]float32, error) {
+	scores := make([]float32, documents)
+	seen := make([]bool, documents)
+	for _, r := range results {
+		if r.Index < 0 || r.Index >= documents || seen[r.Index] {
+			return nil, fmt.Errorf("invalid document index %d in rerank response", r.Index)
+		}
+		seen[r.Index] = true
+		scores[r.Index] = float32(r.RelevanceScore)
+	}
+	if len(results) != documents {
+		return nil, fmt.Errorf("expected %d rerank results, got %d", documents, len(results))
+	}
+	return scores, nil
+}
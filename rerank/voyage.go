@@ -0,0 +1,90 @@
+package rerank
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultVoyageURL is the default Voyage AI rerank API endpoint
+	DefaultVoyageURL = "https://api.voyageai.com/v1/rerank"
+	// DefaultVoyageModel is the default rerank model for Voyage AI
+	DefaultVoyageModel = "rerank-2"
+	// VoyageTimeout is the timeout for Voyage AI requests
+	VoyageTimeout = 30 * time.Second
+)
+
+// VoyageReranker implements Reranker using the Voyage AI rerank API
+type VoyageReranker struct {
+	apiKey  string
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+// VoyageConfig holds configuration for the Voyage AI reranker
+type VoyageConfig struct {
+	APIKey  string
+	BaseURL string // Default: https://api.voyageai.com/v1/rerank
+	Model   string // Default: rerank-2
+}
+
+// voyageRequest represents the request body for the Voyage AI rerank API
+type voyageRequest struct {
+	Query      string   `json:"query"`
+	Documents  []string `json:"documents"`
+	Model      string   `json:"model"`
+	Truncation bool     `json:"truncation"`
+}
+
+// voyageResponse represents the response from the Voyage AI rerank API
+type voyageResponse struct {
+	Data []rerankResult `json:"data"`
+}
+
+// NewVoyageReranker creates a new Voyage AI reranker
+func NewVoyageReranker(cfg VoyageConfig) (*VoyageReranker, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("Voyage AI API key is required")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultVoyageURL
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = DefaultVoyageModel
+	}
+
+	return &VoyageReranker{
+		apiKey:  cfg.APIKey,
+		baseURL: baseURL,
+		model:   model,
+		client: &http.Client{
+			Timeout: VoyageTimeout,
+		},
+	}, nil
+}
+
+// Rerank returns the relevance score of each document to the query
+func (r *VoyageReranker) Rerank(ctx context.Context, query string, documents []string) ([]float32, error) {
+	if len(documents) == 0 {
+		return nil, nil
+	}
+
+	var resp voyageResponse
+	err := postJSON(ctx, r.client, r.baseURL, r.apiKey, "Voyage AI", voyageRequest{
+		Query:      query,
+		Documents:  documents,
+		Model:      r.model,
+		Truncation: true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return scoresByIndex(resp.Data, len(documents))
+}
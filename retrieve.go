@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"dimandocs/frontmatter"
+	"dimandocs/vector"
+)
+
+const (
+	// defaultRetrieveK is the number of documents retrieved without k, as
+	// by LangChain retrievers
+	defaultRetrieveK = 4
+
+	// maxRetrieveK caps k of the retriever API
+	maxRetrieveK = 50
+)
+
+// RetrieveRequest is the body of POST /api/retrieve. Its fields are
+// snake_case, as retriever clients of RAG frameworks send them.
+type RetrieveRequest struct {
+	Query   string          `json:"query"`
+	Message string          `json:"message"` // Alias of query, sent by LangChain's RemoteLangChainRetriever
+	K       int             `json:"k"`
+	Mode    string          `json:"mode"` // hybrid (default), vector or keyword
+	Filters RetrieveFilters `json:"filters"`
+}
+
+// RetrieveFilters scopes a retrieval like the parameters of
+// /api/semantic-search
+type RetrieveFilters struct {
+	Source      string   `json:"source"` // Directory name
+	Collections []string `json:"collections"`
+	PathPrefix  string   `json:"path_prefix"`
+	Tags        []string `json:"tags"` // Documents must have all of them
+	Section     string   `json:"section"`
+}
+
+// RetrievedDocument is a chunk in the document format of RAG frameworks
+type RetrievedDocument struct {
+	PageContent string         `json:"page_content"`
+	Metadata    map[string]any `json:"metadata"`
+}
+
+// handleRetrieve serves POST /api/retrieve, which returns the chunks best
+// matching a query as documents with page_content and metadata, the format
+// LangChain and LlamaIndex retrievers work with
+func (a *App) handleRetrieve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.EmbeddingManager == nil || !a.EmbeddingManager.IsEnabled() {
+		http.Error(w, "Retrieval requires embeddings to be enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req RetrieveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		query = strings.TrimSpace(req.Message)
+	}
+	if query == "" {
+		http.Error(w, "Missing query", http.StatusBadRequest)
+		return
+	}
+	k := defaultRetrieveK
+	if req.K != 0 {
+		if req.K < 1 {
+			http.Error(w, fmt.Sprintf("Invalid k %d", req.K), http.StatusBadRequest)
+			return
+		}
+		k = min(req.K, maxRetrieveK)
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = a.searchMode()
+	}
+
+	filter := vector.Filter{
+		Source:       req.Filters.Source,
+		Collections:  req.Filters.Collections,
+		PathPrefix:   req.Filters.PathPrefix,
+		Tags:         frontmatter.NormalizeTags(req.Filters.Tags),
+		SectionTitle: req.Filters.Section,
+	}
+	results, err := a.searchChunks(r.Context(), query, mode, filter, k)
+	switch {
+	case errors.Is(err, errInvalidMode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, vector.ErrFullTextUnavailable):
+		http.Error(w, "Keyword search is unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// The documents are in "response", where RemoteLangChainRetriever looks
+	// for them by default
+	data := struct {
+		Response []RetrievedDocument `json:"response"`
+	}{Response: []RetrievedDocument{}}
+	for _, match := range a.resultDocuments(results) {
+		result, doc := match.Result, match.Doc
+		metadata := map[string]any{
+			"title":      doc.Title,
+			"path":       doc.RelPath,
+			"url":        resultURL(result),
+			"source":     doc.SourceName,
+			"collection": doc.Collection,
+			"score":      result.Score,
+		}
+		if result.FusionScore != 0 {
+			metadata["fusion_score"] = result.FusionScore
+		}
+		if result.Chunk.SectionTitle != "" {
+			metadata["section"] = result.Chunk.SectionTitle
+		}
+		if result.Chunk.Breadcrumb != "" {
+			metadata["breadcrumb"] = result.Chunk.Breadcrumb
+		}
+		if len(doc.Tags) > 0 {
+			metadata["tags"] = doc.Tags
+		}
+		data.Response = append(data.Response, RetrievedDocument{PageContent: result.Chunk.ChunkText, Metadata: metadata})
+	}
+	a.recordSearch(query, mode, len(data.Response))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Warn("Failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,297 @@
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// ModeRedact replaces detected secrets before content is indexed or served
+	ModeRedact = "redact"
+	// ModeFlag keeps content unchanged and only reports findings
+	ModeFlag = "flag"
+
+	// DefaultEntropyThreshold is the Shannon entropy (bits per character)
+	// above which a long random-looking token is reported
+	DefaultEntropyThreshold = 4.5
+	// MinEntropyTokenLength is the minimum length of tokens checked for entropy
+	MinEntropyTokenLength = 20
+
+	// EntropyRuleName is the rule name used for high-entropy findings
+	EntropyRuleName = "high-entropy-string"
+)
+
+// Rule is a named regular expression matching a kind of secret. If the
+// pattern has a capture group, only the first group is treated as the secret.
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// DefaultRules are the built-in rules for common credential formats
+var DefaultRules = []Rule{
+	{Name: "aws-access-key-id", Pattern: `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`},
+	{Name: "github-token", Pattern: `\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,})\b`},
+	{Name: "github-fine-grained-token", Pattern: `\b(github_pat_[A-Za-z0-9_]{60,})\b`},
+	{Name: "gitlab-token", Pattern: `\b(glpat-[A-Za-z0-9_\-]{20,})\b`},
+	{Name: "slack-token", Pattern: `\b(xox[abposr]-[A-Za-z0-9\-]{10,})\b`},
+	{Name: "slack-webhook", Pattern: `(https://hooks\.slack\.com/services/[A-Za-z0-9/]+)`},
+	{Name: "openai-api-key", Pattern: `\b(sk-(?:proj-)?[A-Za-z0-9_\-]{32,})\b`},
+	{Name: "stripe-key", Pattern: `\b((?:sk|rk)_live_[A-Za-z0-9]{20,})\b`},
+	{Name: "google-api-key", Pattern: `\b(AIza[0-9A-Za-z_\-]{35})\b`},
+	{Name: "jwt", Pattern: `\b(eyJ[A-Za-z0-9_\-]{10,}\.eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,})\b`},
+	{Name: "private-key", Pattern: `(-----BEGIN [A-Z ]*PRIVATE KEY-----)`},
+	{Name: "url-credentials", Pattern: `[a-zA-Z][a-zA-Z0-9+.\-]*://[^\s:/@]+:([^\s:/@]{3,})@`},
+	{Name: "generic-credential", Pattern: `(?i)(?:password|passwd|secret|api[_\-]?key|access[_\-]?token|auth[_\-]?token|client[_\-]?secret)["']?\s*[:=]\s*["']?([^\s"'<>]{8,})`},
+}
+
+// tokenRegex matches candidate tokens for entropy checks
+var tokenRegex = regexp.MustCompile(fmt.Sprintf(`[A-Za-z0-9+/_\-]{%d,}={0,2}`, MinEntropyTokenLength))
+
+// placeholderRegex matches values that are obviously not real secrets
+var placeholderRegex = regexp.MustCompile(`^(?:\$\{?[A-Za-z_][A-Za-z0-9_]*\}?|<[^>]*>|\[REDACTED[^\]]*\]|(?i:x+|\*+|your[_\-].*|changeme|example.*|placeholder.*))$`)
+
+// Config configures secret detection
+type Config struct {
+	Mode             string  // ModeRedact or ModeFlag
+	Rules            []Rule  // Additional rules, applied after the defaults
+	DisableDefaults  bool    // Only use the configured rules
+	EntropyThreshold float64 // 0 uses the default, negative disables entropy checks
+}
+
+// Finding represents a detected secret. The secret itself is never stored,
+// only a masked preview.
+type Finding struct {
+	Rule    string `json:"rule"`
+	Line    int    `json:"line"`   // 1-based line number
+	Column  int    `json:"column"` // 1-based byte offset within the line
+	Preview string `json:"preview"`
+}
+
+type compiledRule struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+// Scanner detects secrets in text using regex rules and an entropy heuristic
+type Scanner struct {
+	mode             string
+	rules            []compiledRule
+	entropyThreshold float64
+}
+
+// match is a located secret within content
+type match struct {
+	rule       string
+	start, end int
+}
+
+// NewScanner compiles the configured rules
+func NewScanner(cfg Config) (*Scanner, error) {
+	mode := strings.ToLower(cfg.Mode)
+	switch mode {
+	case "":
+		mode = ModeRedact
+	case ModeRedact, ModeFlag:
+	default:
+		return nil, fmt.Errorf("unsupported secrets mode: %s", cfg.Mode)
+	}
+
+	var rules []Rule
+	if !cfg.DisableDefaults {
+		rules = append(rules, DefaultRules...)
+	}
+	rules = append(rules, cfg.Rules...)
+
+	s := &Scanner{
+		mode:             mode,
+		entropyThreshold: cfg.EntropyThreshold,
+	}
+	if s.entropyThreshold == 0 {
+		s.entropyThreshold = DefaultEntropyThreshold
+	}
+
+	for _, rule := range rules {
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile secret rule '%s': %w", rule.Name, err)
+		}
+		s.rules = append(s.rules, compiledRule{name: rule.Name, regex: regex})
+	}
+
+	return s, nil
+}
+
+// Mode returns the configured mode
+func (s *Scanner) Mode() string {
+	return s.mode
+}
+
+// Scan returns all secrets found in content
+func (s *Scanner) Scan(content string) []Finding {
+	return toFindings(content, s.find(content))
+}
+
+// toFindings converts located matches into findings with masked previews
+func toFindings(content string, matches []match) []Finding {
+	findings := make([]Finding, 0, len(matches))
+	for _, m := range matches {
+		line := strings.Count(content[:m.start], "\n") + 1
+		lineStart := strings.LastIndex(content[:m.start], "\n") + 1
+		findings = append(findings, Finding{
+			Rule:    m.rule,
+			Line:    line,
+			Column:  m.start - lineStart + 1,
+			Preview: Mask(content[m.start:m.end]),
+		})
+	}
+	return findings
+}
+
+// Redact replaces all secrets in content with a [REDACTED:rule] marker and
+// returns the redacted content along with the findings
+func (s *Scanner) Redact(content string) (string, []Finding) {
+	matches := s.find(content)
+	findings := toFindings(content, matches)
+	if len(matches) == 0 {
+		return content, findings
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, m := range matches {
+		out.WriteString(content[last:m.start])
+		out.WriteString("[REDACTED:" + m.rule + "]")
+		last = m.end
+	}
+	out.WriteString(content[last:])
+	return out.String(), findings
+}
+
+// Process applies the configured mode to content. In redact mode the
+// returned content has secrets replaced; in flag mode it is unchanged.
+func (s *Scanner) Process(content string) (string, []Finding) {
+	if s.mode == ModeRedact {
+		return s.Redact(content)
+	}
+	return content, s.Scan(content)
+}
+
+// find locates all non-overlapping secret matches, ordered by position
+func (s *Scanner) find(content string) []match {
+	var matches []match
+
+	for _, rule := range s.rules {
+		for _, loc := range rule.regex.FindAllStringSubmatchIndex(content, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) >= 4 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			if placeholderRegex.MatchString(content[start:end]) {
+				continue
+			}
+			matches = append(matches, match{rule: rule.name, start: start, end: end})
+		}
+	}
+
+	result := dropOverlaps(matches)
+
+	// Entropy findings only fill gaps left by the named rules
+	if s.entropyThreshold > 0 {
+		for _, loc := range tokenRegex.FindAllStringIndex(content, -1) {
+			token := content[loc[0]:loc[1]]
+			if !looksRandom(token) || ShannonEntropy(token) < s.entropyThreshold {
+				continue
+			}
+			if !overlapsAny(result, loc[0], loc[1]) {
+				matches = append(matches, match{rule: EntropyRuleName, start: loc[0], end: loc[1]})
+			}
+		}
+		result = dropOverlaps(matches)
+	}
+
+	return result
+}
+
+// dropOverlaps orders matches by position, preferring the earliest and
+// longest match, and drops matches overlapping a previous one
+func dropOverlaps(matches []match) []match {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+
+	var result []match
+	for _, m := range matches {
+		if len(result) > 0 && m.start < result[len(result)-1].end {
+			continue
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// overlapsAny reports whether the range [start, end) overlaps any match
+func overlapsAny(matches []match, start, end int) bool {
+	for _, m := range matches {
+		if start < m.end && m.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// looksRandom filters out tokens that are unlikely to be secrets, such as
+// paths, identifiers and words joined with dashes
+func looksRandom(token string) bool {
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range token {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			hasUpper = true
+		case r >= 'a' && r <= 'z':
+			hasLower = true
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		}
+	}
+	return hasDigit && (hasUpper || hasLower) && !strings.Contains(token, "--")
+}
+
+// ShannonEntropy returns the Shannon entropy of s in bits per character
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// Mask returns a preview of a secret that keeps only its first characters
+func Mask(secret string) string {
+	visible := 4
+	if len(secret) <= 8 {
+		visible = 1
+	}
+	if len(secret) <= visible {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:visible] + strings.Repeat("*", min(len(secret)-visible, 16))
+}
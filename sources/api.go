@@ -0,0 +1,131 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// APITimeout is the timeout of a single API request
+	APITimeout = 60 * time.Second
+	// APIMaxRetries is the maximum number of retries for rate-limited requests
+	APIMaxRetries = 3
+	// APIMaxRateLimitWait is the longest wait for a rate limit reset before a
+	// sync fails instead
+	APIMaxRateLimitWait = 5 * time.Minute
+)
+
+// linkNextRegex matches the next page URL in a Link header
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// apiClient sends authenticated requests to a REST API, waiting out rate
+// limits
+type apiClient struct {
+	name    string            // API name for messages, e.g. "GitHub"
+	headers map[string]string // Sent with every request, e.g. credentials
+	client  *http.Client
+
+	// sign, if set, authenticates a request right before it is sent
+	sign func(req *http.Request)
+}
+
+// newAPIClient creates an API client sending the headers with every request
+func newAPIClient(name string, headers map[string]string) *apiClient {
+	return &apiClient{
+		name:    name,
+		headers: headers,
+		client:  &http.Client{Timeout: APITimeout},
+	}
+}
+
+// get sends a GET request. Rate-limited requests are retried once the limit
+// resets, if that is soon enough. A non-empty etag makes the request
+// conditional; the caller handles 304 Not Modified.
+func (c *apiClient) get(ctx context.Context, reqURL, etag, accept string) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		for key, value := range c.headers {
+			req.Header.Set(key, value)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if c.sign != nil {
+			c.sign(req)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request to %s: %w", c.name, err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotModified:
+			return resp, nil
+		case isRateLimited(resp) && retry < APIMaxRetries:
+			wait := rateLimitWait(resp, time.Now())
+			resp.Body.Close()
+			if wait > APIMaxRateLimitWait {
+				return nil, fmt.Errorf("%s rate limit exceeded, resets in %v", c.name, wait.Round(time.Second))
+			}
+			slog.Warn("Rate limit hit, retrying", "api", c.name, "backoff", wait.Round(time.Second), "attempt", retry+1, "max_attempts", APIMaxRetries)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		default:
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s API error (status %d): %s", c.name, resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+	}
+}
+
+// isRateLimited reports whether a response was rejected by a primary or
+// secondary rate limit
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return resp.StatusCode == http.StatusForbidden &&
+		(resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != "")
+}
+
+// rateLimitWait returns how long to wait before retrying a rate-limited
+// request, from the Retry-After or rate limit reset headers
+func rateLimitWait(resp *http.Response, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	for _, header := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+		if reset, err := strconv.ParseInt(resp.Header.Get(header), 10, 64); err == nil {
+			if wait := time.Unix(reset, 0).Sub(now) + time.Second; wait > 0 {
+				return wait
+			}
+		}
+	}
+	// Secondary rate limits without headers ask to wait at least a minute
+	return time.Minute
+}
+
+// nextPage returns the URL of the next page from a Link header, or ""
+func nextPage(link string) string {
+	if m := linkNextRegex.FindStringSubmatch(link); m != nil {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,242 @@
+package sources
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"dimandocs/htmlmd"
+)
+
+// confluencePageSize is the page size of content listings
+const confluencePageSize = 100
+
+// Storage format elements converted before the HTML conversion
+var (
+	cdataRegex         = regexp.MustCompile(`(?s)<!\[CDATA\[(.*?)\]\]>`)
+	codeMacroRegex     = regexp.MustCompile(`(?s)<ac:structured-macro[^>]*ac:name="(?:code|noformat)"[^>]*>.*?</ac:structured-macro>`)
+	languageParamRegex = regexp.MustCompile(`<ac:parameter[^>]*ac:name="language"[^>]*>([^<]*)</ac:parameter>`)
+	plainTextBodyRegex = regexp.MustCompile(`(?s)<ac:plain-text-body>\s*<!\[CDATA\[(.*?)\]\]>\s*</ac:plain-text-body>`)
+	macroParamRegex    = regexp.MustCompile(`(?s)<ac:parameter[^>]*>.*?</ac:parameter>`)
+	pageLinkRegex      = regexp.MustCompile(`(?s)<ac:link[^>]*>\s*<ri:page[^>]*ri:content-title="([^"]*)"[^>]*/>\s*</ac:link>`)
+	unsafeTitleRegex   = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]+`)
+)
+
+// ConfluenceSpace mirrors the pages of a Confluence space through the REST
+// API. Pages are converted from storage format to markdown and written below
+// the titles of their ancestors, so the page tree becomes the directory tree.
+type ConfluenceSpace struct {
+	BaseURL string // e.g. "https://example.atlassian.net/wiki"
+	Space   string // Space key
+	Email   string // Confluence Cloud account, for basic authentication
+	Token   string // API token (Cloud) or personal access token (Data Center)
+	Dir     string // Local mirror directory
+
+	api *apiClient
+}
+
+// confluencePage is a page of a content listing
+type confluencePage struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Version struct {
+		Number int `json:"number"`
+	} `json:"version"`
+	Ancestors []struct {
+		Title string `json:"title"`
+	} `json:"ancestors"`
+	Body struct {
+		Storage struct {
+			Value string `json:"value"`
+		} `json:"storage"`
+	} `json:"body"`
+}
+
+// confluenceList is a content listing response
+type confluenceList struct {
+	Results []confluencePage `json:"results"`
+	Links   struct {
+		Base string `json:"base"`
+		Next string `json:"next"`
+	} `json:"_links"`
+}
+
+// String describes the space for logging
+func (c *ConfluenceSpace) String() string {
+	return c.baseURL() + "/spaces/" + c.Space
+}
+
+// Exists reports whether the space has been mirrored
+func (c *ConfluenceSpace) Exists() bool {
+	return mirrorExists(c.Dir)
+}
+
+// Sync lists the pages of the space and downloads the pages that were added,
+// changed or moved since the last sync, removing deleted ones
+func (c *ConfluenceSpace) Sync(ctx context.Context) (bool, error) {
+	state, err := loadManifest(c.Dir)
+	if err != nil {
+		return false, err
+	}
+
+	pages, err := c.listPages(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	seen := make(map[string]bool)
+	for _, page := range pages {
+		file := pageFile(page)
+		version := fmt.Sprintf("%s:%d", page.ID, page.Version.Number)
+		seen[file] = true
+		if state.Files[file] == version {
+			continue
+		}
+
+		content, err := c.pageMarkdown(ctx, page)
+		if err != nil {
+			return false, err
+		}
+		if err := writeMirrorFile(c.Dir, file, []byte(content)); err != nil {
+			return false, err
+		}
+		state.Files[file] = version
+		changed = true
+	}
+	for file := range state.Files {
+		if !seen[file] {
+			if err := removeMirrorFile(c.Dir, file); err != nil {
+				return false, err
+			}
+			delete(state.Files, file)
+			changed = true
+		}
+	}
+
+	if err := state.save(c.Dir); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// listPages returns the current pages of the space with their versions and
+// ancestors, following the listing's pagination
+func (c *ConfluenceSpace) listPages(ctx context.Context) ([]confluencePage, error) {
+	pageURL := fmt.Sprintf("%s/rest/api/content?spaceKey=%s&type=page&status=current&expand=version,ancestors&limit=%d",
+		c.baseURL(), url.QueryEscape(c.Space), confluencePageSize)
+
+	var pages []confluencePage
+	for pageURL != "" {
+		var list confluenceList
+		if err := c.getJSON(ctx, pageURL, &list); err != nil {
+			return nil, err
+		}
+		pages = append(pages, list.Results...)
+
+		pageURL = ""
+		if list.Links.Next != "" {
+			base := list.Links.Base
+			if base == "" {
+				base = c.baseURL()
+			}
+			pageURL = strings.TrimRight(base, "/") + list.Links.Next
+		}
+	}
+	return pages, nil
+}
+
+// pageMarkdown downloads the body of a page and converts it to markdown
+func (c *ConfluenceSpace) pageMarkdown(ctx context.Context, page confluencePage) (string, error) {
+	var full confluencePage
+	pageURL := fmt.Sprintf("%s/rest/api/content/%s?expand=body.storage", c.baseURL(), url.PathEscape(page.ID))
+	if err := c.getJSON(ctx, pageURL, &full); err != nil {
+		return "", err
+	}
+
+	body, err := htmlmd.ConvertFragment(strings.NewReader(storageToHTML(full.Body.Storage.Value)))
+	if err != nil {
+		return "", fmt.Errorf("failed to convert page %s: %w", page.Title, err)
+	}
+	return "# " + page.Title + "\n\n" + body, nil
+}
+
+// getJSON sends an authenticated GET request and decodes the JSON response
+func (c *ConfluenceSpace) getJSON(ctx context.Context, reqURL string, v any) error {
+	if c.api == nil {
+		headers := map[string]string{}
+		switch {
+		case c.Email != "":
+			credentials := base64.StdEncoding.EncodeToString([]byte(c.Email + ":" + c.Token))
+			headers["Authorization"] = "Basic " + credentials
+		case c.Token != "":
+			headers["Authorization"] = "Bearer " + c.Token
+		}
+		c.api = newAPIClient("Confluence", headers)
+	}
+
+	resp, err := c.api.get(ctx, reqURL, "", "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode Confluence response: %w", err)
+	}
+	return nil
+}
+
+// pageFile returns the mirror path of a page below the titles of its
+// ancestors
+func pageFile(page confluencePage) string {
+	var parts []string
+	for _, ancestor := range page.Ancestors {
+		parts = append(parts, safeTitle(ancestor.Title))
+	}
+	parts = append(parts, safeTitle(page.Title)+".md")
+	return path.Join(parts...)
+}
+
+// safeTitle makes a page title usable as a file name
+func safeTitle(title string) string {
+	title = strings.TrimSpace(unsafeTitleRegex.ReplaceAllString(title, "-"))
+	title = strings.TrimLeft(title, ".")
+	if title == "" {
+		return "untitled"
+	}
+	return title
+}
+
+// storageToHTML turns Confluence storage format into plain HTML: code macros
+// become code blocks, page links their titles and CDATA sections text, while
+// other macro parameters are dropped
+func storageToHTML(storage string) string {
+	storage = codeMacroRegex.ReplaceAllStringFunc(storage, func(macro string) string {
+		class := ""
+		if m := languageParamRegex.FindStringSubmatch(macro); m != nil {
+			class = ` class="language-` + html.EscapeString(strings.TrimSpace(m[1])) + `"`
+		}
+		code := ""
+		if m := plainTextBodyRegex.FindStringSubmatch(macro); m != nil {
+			code = m[1]
+		}
+		return "<pre><code" + class + ">" + html.EscapeString(code) + "</code></pre>"
+	})
+	storage = pageLinkRegex.ReplaceAllString(storage, "$1")
+	storage = macroParamRegex.ReplaceAllString(storage, "")
+	return cdataRegex.ReplaceAllStringFunc(storage, func(cdata string) string {
+		return html.EscapeString(cdataRegex.FindStringSubmatch(cdata)[1])
+	})
+}
+
+// baseURL returns the Confluence URL without a trailing slash
+func (c *ConfluenceSpace) baseURL() string {
+	return strings.TrimRight(c.BaseURL, "/")
+}
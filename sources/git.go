@@ -0,0 +1,150 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// unsafeNameRegex matches characters replaced in checkout directory names
+var unsafeNameRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// GitRepo is a remote git repository mirrored into a local checkout. Only
+// the latest commit of the branch is fetched.
+type GitRepo struct {
+	URL    string
+	Branch string // Default: the remote's default branch
+	Dir    string // Local checkout directory
+}
+
+// CheckoutDir returns a stable, readable directory name for a repository
+// branch below cacheDir. Credentials in the URL do not affect the name.
+func CheckoutDir(cacheDir, repoURL, branch string) string {
+	repoURL = redactURL(repoURL)
+	name := strings.TrimSuffix(repoURL[strings.LastIndexAny(repoURL, "/:")+1:], ".git")
+	if branch != "" {
+		name += "-" + branch
+	}
+	sum := sha256.Sum256([]byte(repoURL + "#" + branch))
+	name = unsafeNameRegex.ReplaceAllString(name, "_") + "-" + hex.EncodeToString(sum[:4])
+	return filepath.Join(cacheDir, name)
+}
+
+// String returns the repository URL without credentials, for logging
+func (g *GitRepo) String() string {
+	return redactURL(g.URL)
+}
+
+// redactURL removes the credentials of a URL
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// Exists reports whether the repository has been checked out
+func (g *GitRepo) Exists() bool {
+	info, err := os.Stat(filepath.Join(g.Dir, ".git"))
+	return err == nil && info.IsDir()
+}
+
+// Sync clones the repository or, if it is already checked out, pulls the
+// latest commit. changed reports whether the checkout was updated.
+func (g *GitRepo) Sync(ctx context.Context) (changed bool, err error) {
+	if !g.Exists() {
+		if err := os.MkdirAll(filepath.Dir(g.Dir), 0755); err != nil {
+			return false, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		// Remove leftovers of an interrupted clone
+		if err := os.RemoveAll(g.Dir); err != nil {
+			return false, fmt.Errorf("failed to clean checkout directory: %w", err)
+		}
+
+		args := []string{"clone", "--depth", "1", "--single-branch"}
+		if g.Branch != "" {
+			args = append(args, "--branch", g.Branch)
+		}
+		if _, err := g.git(ctx, "", append(args, "--", g.URL, g.Dir)...); err != nil {
+			return false, fmt.Errorf("failed to clone %s: %w", g, err)
+		}
+		return true, nil
+	}
+
+	before, err := g.head(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	ref := g.Branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := g.git(ctx, g.Dir, "fetch", "--depth", "1", "origin", ref); err != nil {
+		return false, fmt.Errorf("failed to fetch %s: %w", g, err)
+	}
+	// The checkout is a mirror, so local changes are discarded
+	if _, err := g.git(ctx, g.Dir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+		return false, fmt.Errorf("failed to update checkout of %s: %w", g, err)
+	}
+
+	after, err := g.head(ctx)
+	if err != nil {
+		return false, err
+	}
+	return before != after, nil
+}
+
+// head returns the commit checked out
+func (g *GitRepo) head(ctx context.Context) (string, error) {
+	out, err := g.git(ctx, g.Dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read checkout of %s: %w", g, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// git runs a git command in dir and returns its output. The repository URL
+// is redacted from error messages, since it may carry credentials.
+func (g *GitRepo) git(ctx context.Context, dir string, args ...string) (string, error) {
+	return runGit(ctx, dir, strings.NewReplacer(g.URL, g.String()), args...)
+}
+
+// Git runs a git command in dir and returns its output. Errors include git's
+// error message.
+func Git(ctx context.Context, dir string, args ...string) (string, error) {
+	return runGit(ctx, dir, nil, args...)
+}
+
+// runGit runs a git command in dir, with redact applied to error messages
+// if set
+func runGit(ctx context.Context, dir string, redact *strings.Replacer, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never block on a credential prompt
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			if redact != nil {
+				msg = redact.Replace(msg)
+			}
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
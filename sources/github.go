@@ -0,0 +1,270 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultGitHubAPI is the GitHub REST API base URL
+	DefaultGitHubAPI = "https://api.github.com"
+	// githubPageSize is the page size of paginated listings
+	githubPageSize = 100
+)
+
+// GitHubRepo mirrors the matching files of a GitHub repository through the
+// REST API, without a local clone. Refreshes use conditional requests, so an
+// unchanged repository costs a single request that does not count against
+// the rate limit, and only files whose blob changed are downloaded.
+type GitHubRepo struct {
+	Repo    string // "owner/name"
+	Branch  string // Default: the repository's default branch
+	Subpath string // Directory within the repository
+	Token   string
+	BaseURL string // Default: DefaultGitHubAPI, set for GitHub Enterprise
+	Dir     string // Local mirror directory
+
+	Pattern *regexp.Regexp // Selects the files to mirror by file name
+
+	api *apiClient
+}
+
+// githubTree is a Git Trees API response
+type githubTree struct {
+	Tree []struct {
+		Path string `json:"path"`
+		Type string `json:"type"`
+		SHA  string `json:"sha"`
+	} `json:"tree"`
+	Truncated bool `json:"truncated"`
+}
+
+// githubContent is an entry of a Contents API directory listing
+type githubContent struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+}
+
+// String describes the repository for logging
+func (g *GitHubRepo) String() string {
+	if g.Branch != "" {
+		return "github.com/" + g.Repo + "@" + g.Branch
+	}
+	return "github.com/" + g.Repo
+}
+
+// Exists reports whether the repository has been mirrored
+func (g *GitHubRepo) Exists() bool {
+	return mirrorExists(g.Dir)
+}
+
+// Sync lists the repository files and downloads the matching files that
+// were added or changed since the last sync, removing deleted ones
+func (g *GitHubRepo) Sync(ctx context.Context) (bool, error) {
+	state, err := loadManifest(g.Dir)
+	if err != nil {
+		return false, err
+	}
+	// An unchanged listing must not skip the download of files that were
+	// never mirrored or did not match before
+	if filter := g.filter(); !g.Exists() || state.Filter != filter {
+		state.ETag = ""
+		state.Filter = filter
+	}
+
+	files, etag, err := g.listFiles(ctx, state.ETag)
+	if err != nil {
+		return false, err
+	}
+	if files == nil {
+		return false, nil // Not modified
+	}
+
+	changed := false
+	for filePath, sha := range files {
+		if state.Files[filePath] == sha {
+			continue
+		}
+		data, err := g.download(ctx, filePath)
+		if err != nil {
+			return false, err
+		}
+		if err := writeMirrorFile(g.Dir, filePath, data); err != nil {
+			return false, err
+		}
+		state.Files[filePath] = sha
+		changed = true
+	}
+	for filePath := range state.Files {
+		if _, ok := files[filePath]; !ok {
+			if err := removeMirrorFile(g.Dir, filePath); err != nil {
+				return false, err
+			}
+			delete(state.Files, filePath)
+			changed = true
+		}
+	}
+
+	state.ETag = etag
+	if err := state.save(g.Dir); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// listFiles returns the blob SHA of every matching file, or nil if the tree
+// has not changed since the listing with the given ETag
+func (g *GitHubRepo) listFiles(ctx context.Context, etag string) (map[string]string, string, error) {
+	ref := g.Branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	treeURL := fmt.Sprintf("%s/repos/%s/git/trees/%s?recursive=1", g.baseURL(), g.Repo, url.PathEscape(ref))
+
+	resp, err := g.get(ctx, treeURL, etag, "application/vnd.github+json")
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+
+	var tree githubTree
+	if err := json.NewDecoder(resp.Body).Decode(&tree); err != nil {
+		return nil, "", fmt.Errorf("failed to decode tree of %s: %w", g, err)
+	}
+
+	files := make(map[string]string)
+	if tree.Truncated {
+		// Very large trees are listed directory by directory instead
+		slog.Info("Tree is truncated, listing directories", "source", g.String())
+		if err := g.listDirectory(ctx, g.subpath(), ref, files); err != nil {
+			return nil, "", err
+		}
+	} else {
+		for _, entry := range tree.Tree {
+			if entry.Type == "blob" {
+				g.addFile(files, entry.Path, entry.SHA)
+			}
+		}
+	}
+	return files, resp.Header.Get("ETag"), nil
+}
+
+// listDirectory adds the matching files below a directory, following the
+// pagination of the Contents API
+func (g *GitHubRepo) listDirectory(ctx context.Context, dir, ref string, files map[string]string) error {
+	pageURL := fmt.Sprintf("%s/repos/%s/contents/%s?ref=%s&per_page=%d",
+		g.baseURL(), g.Repo, escapePath(dir), url.QueryEscape(ref), githubPageSize)
+
+	for pageURL != "" {
+		resp, err := g.get(ctx, pageURL, "", "application/vnd.github+json")
+		if err != nil {
+			return err
+		}
+
+		var entries []githubContent
+		err = json.NewDecoder(resp.Body).Decode(&entries)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode contents of %s/%s: %w", g, dir, err)
+		}
+
+		for _, entry := range entries {
+			switch entry.Type {
+			case "file":
+				g.addFile(files, entry.Path, entry.SHA)
+			case "dir":
+				if err := g.listDirectory(ctx, entry.Path, ref, files); err != nil {
+					return err
+				}
+			}
+		}
+		pageURL = nextPage(resp.Header.Get("Link"))
+	}
+	return nil
+}
+
+// addFile records a file if it is below the subpath and matches
+func (g *GitHubRepo) addFile(files map[string]string, filePath, sha string) {
+	if sub := g.subpath(); sub != "" && !strings.HasPrefix(filePath, sub+"/") {
+		return
+	}
+	if g.Pattern != nil && !g.Pattern.MatchString(path.Base(filePath)) {
+		return
+	}
+	files[filePath] = sha
+}
+
+// download returns the raw content of a file
+func (g *GitHubRepo) download(ctx context.Context, filePath string) ([]byte, error) {
+	fileURL := fmt.Sprintf("%s/repos/%s/contents/%s", g.baseURL(), g.Repo, escapePath(filePath))
+	if g.Branch != "" {
+		fileURL += "?ref=" + url.QueryEscape(g.Branch)
+	}
+
+	resp, err := g.get(ctx, fileURL, "", "application/vnd.github.raw+json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s from %s: %w", filePath, g, err)
+	}
+	return data, nil
+}
+
+// get sends an authenticated GET request to the GitHub API
+func (g *GitHubRepo) get(ctx context.Context, reqURL, etag, accept string) (*http.Response, error) {
+	if g.api == nil {
+		headers := map[string]string{"X-GitHub-Api-Version": "2022-11-28"}
+		if g.Token != "" {
+			headers["Authorization"] = "Bearer " + g.Token
+		}
+		g.api = newAPIClient("GitHub", headers)
+	}
+	return g.api.get(ctx, reqURL, etag, accept)
+}
+
+// escapePath escapes the segments of a repository path for use in a URL
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// baseURL returns the API base URL without a trailing slash
+func (g *GitHubRepo) baseURL() string {
+	if g.BaseURL == "" {
+		return DefaultGitHubAPI
+	}
+	return strings.TrimRight(g.BaseURL, "/")
+}
+
+// filter identifies the selection of mirrored files
+func (g *GitHubRepo) filter() string {
+	filter := g.subpath()
+	if g.Pattern != nil {
+		filter += "\x00" + g.Pattern.String()
+	}
+	return filter
+}
+
+// subpath returns the subpath without surrounding slashes
+func (g *GitHubRepo) subpath() string {
+	return strings.Trim(g.Subpath, "/")
+}
@@ -0,0 +1,165 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"dimandocs/frontmatter"
+)
+
+// DefaultGitLabURL is the GitLab instance used when no base URL is set
+const DefaultGitLabURL = "https://gitlab.com"
+
+// GitLabWiki mirrors the pages of a GitLab project wiki through the API.
+// Each page is written to "<slug>.md", with its wiki title as frontmatter if
+// the page has no title of its own.
+type GitLabWiki struct {
+	Project string // Project ID or path, e.g. "group/project"
+	BaseURL string // Default: DefaultGitLabURL
+	Token   string
+	Dir     string // Local mirror directory
+
+	api *apiClient
+}
+
+// gitlabWikiPage is a page of the Wikis API
+type gitlabWikiPage struct {
+	Slug    string `json:"slug"`
+	Title   string `json:"title"`
+	Format  string `json:"format"`
+	Content string `json:"content"`
+}
+
+// String describes the wiki for logging
+func (w *GitLabWiki) String() string {
+	return strings.TrimPrefix(strings.TrimPrefix(w.baseURL(), "https://"), "http://") + "/" + w.Project + "/-/wikis"
+}
+
+// Exists reports whether the wiki has been mirrored
+func (w *GitLabWiki) Exists() bool {
+	return mirrorExists(w.Dir)
+}
+
+// Sync fetches the wiki pages, writing the pages that were added or changed
+// since the last sync and removing deleted ones
+func (w *GitLabWiki) Sync(ctx context.Context) (bool, error) {
+	state, err := loadManifest(w.Dir)
+	if err != nil {
+		return false, err
+	}
+	if !w.Exists() {
+		state.ETag = ""
+	}
+
+	pages, etag, err := w.listPages(ctx, state.ETag)
+	if err != nil {
+		return false, err
+	}
+	if pages == nil {
+		return false, nil // Not modified
+	}
+
+	changed := false
+	seen := make(map[string]bool)
+	for _, page := range pages {
+		if page.Slug == "" {
+			continue
+		}
+		file := page.Slug + ".md"
+		seen[file] = true
+
+		content := pageMarkdown(page)
+		version := hashContent(content)
+		if state.Files[file] == version {
+			continue
+		}
+		if err := writeMirrorFile(w.Dir, file, []byte(content)); err != nil {
+			return false, err
+		}
+		state.Files[file] = version
+		changed = true
+	}
+	for file := range state.Files {
+		if !seen[file] {
+			if err := removeMirrorFile(w.Dir, file); err != nil {
+				return false, err
+			}
+			delete(state.Files, file)
+			changed = true
+		}
+	}
+
+	state.ETag = etag
+	if err := state.save(w.Dir); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// listPages returns the wiki pages with their content, or nil if the wiki
+// has not changed since the listing with the given ETag
+func (w *GitLabWiki) listPages(ctx context.Context, etag string) ([]gitlabWikiPage, string, error) {
+	if w.api == nil {
+		headers := map[string]string{}
+		if w.Token != "" {
+			headers["PRIVATE-TOKEN"] = w.Token
+		}
+		w.api = newAPIClient("GitLab", headers)
+	}
+
+	pageURL := fmt.Sprintf("%s/api/v4/projects/%s/wikis?with_content=1", w.baseURL(), url.PathEscape(w.Project))
+	var pages []gitlabWikiPage
+	var listETag string
+	for pageURL != "" {
+		resp, err := w.api.get(ctx, pageURL, etag, "application/json")
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			return nil, etag, nil
+		}
+		if listETag == "" {
+			listETag = resp.Header.Get("ETag")
+		}
+
+		var batch []gitlabWikiPage
+		err = json.NewDecoder(resp.Body).Decode(&batch)
+		resp.Body.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode pages of %s: %w", w, err)
+		}
+		pages = append(pages, batch...)
+
+		// Only the first page is conditional
+		etag = ""
+		pageURL = nextPage(resp.Header.Get("Link"))
+	}
+	return pages, listETag, nil
+}
+
+// pageMarkdown returns the mirrored content of a wiki page. The wiki title
+// becomes the document title unless the page has its own frontmatter or
+// heading.
+func pageMarkdown(page gitlabWikiPage) string {
+	if _, _, ok := frontmatter.Split(page.Content); ok || page.Title == "" {
+		return page.Content
+	}
+	if strings.HasPrefix(page.Content, "# ") || strings.Contains(page.Content, "\n# ") {
+		return page.Content
+	}
+	title, _ := json.Marshal(page.Title) // JSON strings are valid YAML
+	return "---\ntitle: " + string(title) + "\n---\n\n" + page.Content
+}
+
+// baseURL returns the instance URL without a trailing slash
+func (w *GitLabWiki) baseURL() string {
+	if w.BaseURL == "" {
+		return DefaultGitLabURL
+	}
+	return strings.TrimRight(w.BaseURL, "/")
+}
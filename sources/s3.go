@@ -0,0 +1,319 @@
+package sources
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultS3Region is the region used when none is configured
+	DefaultS3Region = "us-east-1"
+	// emptyPayloadHash is the SHA-256 of an empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Bucket mirrors the matching objects below a prefix of an S3 or
+// S3-compatible (e.g. MinIO) bucket. Refreshes compare object ETags, so only
+// changed objects are downloaded.
+type S3Bucket struct {
+	Bucket   string
+	Prefix   string // Key prefix, e.g. "docs/"
+	Endpoint string // S3-compatible endpoint, e.g. "http://localhost:9000"; default: AWS
+	Region   string // Default: DefaultS3Region
+
+	// Credentials; requests are anonymous without an access key
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	Dir     string         // Local mirror directory
+	Pattern *regexp.Regexp // Selects the objects to mirror by file name
+
+	api *apiClient
+}
+
+// s3ListResult is a ListObjectsV2 response
+type s3ListResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		ETag string `xml:"ETag"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// ParseS3URL splits an "s3://bucket/prefix" URL into bucket and prefix,
+// which is empty or ends with a slash
+func ParseS3URL(rawURL string) (bucket, prefix string, err error) {
+	rest, ok := strings.CutPrefix(rawURL, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("invalid S3 URL %q: expected s3://bucket/prefix", rawURL)
+	}
+	bucket, prefix, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid S3 URL %q: missing bucket", rawURL)
+	}
+	// The prefix is a directory, so keys map to paths below it
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return bucket, prefix, nil
+}
+
+// String describes the bucket for logging
+func (s *S3Bucket) String() string {
+	return "s3://" + s.Bucket + "/" + s.Prefix
+}
+
+// Exists reports whether the bucket has been mirrored
+func (s *S3Bucket) Exists() bool {
+	return mirrorExists(s.Dir)
+}
+
+// Sync lists the objects below the prefix and downloads the matching objects
+// whose ETag changed since the last sync, removing deleted ones
+func (s *S3Bucket) Sync(ctx context.Context) (bool, error) {
+	state, err := loadManifest(s.Dir)
+	if err != nil {
+		return false, err
+	}
+
+	objects, err := s.listObjects(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for file, etag := range objects {
+		if state.Files[file] == etag {
+			continue
+		}
+		data, err := s.getObject(ctx, s.Prefix+file)
+		if err != nil {
+			return false, err
+		}
+		if err := writeMirrorFile(s.Dir, file, data); err != nil {
+			return false, err
+		}
+		state.Files[file] = etag
+		changed = true
+	}
+	for file := range state.Files {
+		if _, ok := objects[file]; !ok {
+			if err := removeMirrorFile(s.Dir, file); err != nil {
+				return false, err
+			}
+			delete(state.Files, file)
+			changed = true
+		}
+	}
+
+	if err := state.save(s.Dir); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// listObjects returns the ETags of the matching objects by their key
+// relative to the prefix, following the listing's continuation tokens
+func (s *S3Bucket) listObjects(ctx context.Context) (map[string]string, error) {
+	objects := make(map[string]string)
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.get(ctx, "", query)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode listing of %s: %w", s, err)
+		}
+
+		for _, object := range result.Contents {
+			file := strings.TrimPrefix(object.Key, s.Prefix)
+			if file == "" || strings.HasSuffix(file, "/") {
+				continue // Directory placeholder
+			}
+			if s.Pattern != nil && !s.Pattern.MatchString(path.Base(file)) {
+				continue
+			}
+			objects[file] = strings.Trim(object.ETag, `"`)
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// getObject downloads an object
+func (s *S3Bucket) getObject(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.get(ctx, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s from %s: %w", key, s, err)
+	}
+	return data, nil
+}
+
+// get sends a signed GET request for an object key, or for the bucket if
+// key is empty
+func (s *S3Bucket) get(ctx context.Context, key string, query url.Values) (*http.Response, error) {
+	if s.api == nil {
+		s.api = newAPIClient("S3", nil)
+		if s.AccessKey != "" {
+			s.api.sign = func(req *http.Request) { s.sign(req, time.Now().UTC()) }
+		}
+	}
+
+	reqURL := s.objectURL(key)
+	if len(query) > 0 {
+		reqURL += "?" + canonicalQuery(query)
+	}
+	return s.api.get(ctx, reqURL, "", "")
+}
+
+// objectURL returns the URL of an object: path-style for custom endpoints,
+// virtual-hosted-style for AWS
+func (s *S3Bucket) objectURL(key string) string {
+	if s.Endpoint != "" {
+		return strings.TrimRight(s.Endpoint, "/") + "/" + s.Bucket + "/" + uriEncode(key, false)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.region(), uriEncode(key, false))
+}
+
+// sign adds an AWS Signature Version 4 authorization to a request
+func (s *S3Bucket) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	// Canonical headers: host and all x-amz-* headers, sorted
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region() + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+// region returns the configured or default region
+func (s *S3Bucket) region() string {
+	if s.Region == "" {
+		return DefaultS3Region
+	}
+	return s.Region
+}
+
+// canonicalQuery encodes query parameters sorted by name, as required by
+// Signature Version 4
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters and, unless
+// encodeSlash is set, slashes
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hashHex returns the hex-encoded SHA-256 of s
+func hashHex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,130 @@
+package sources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Source is a remote document source mirrored into a local directory, so
+// its documents are loaded like those of any other directory
+type Source interface {
+	// Sync brings the local mirror up to date. changed reports whether any
+	// file was added, updated or removed.
+	Sync(ctx context.Context) (changed bool, err error)
+
+	// Exists reports whether the source has been mirrored before
+	Exists() bool
+
+	// String describes the source for logging, without credentials
+	String() string
+}
+
+// manifestName is the file recording the state of a mirrored source
+const manifestName = ".dimandocs-source.json"
+
+// manifest records the versions of mirrored files, so a refresh only
+// downloads what changed
+type manifest struct {
+	ETag   string            `json:"etag,omitempty"`   // Of the file listing
+	Filter string            `json:"filter,omitempty"` // Selection of mirrored files
+	Files  map[string]string `json:"files"`            // Path to version, e.g. a blob SHA
+}
+
+// loadManifest reads the manifest of a mirror directory. A missing manifest
+// yields an empty one.
+func loadManifest(dir string) (*manifest, error) {
+	m := &manifest{Files: make(map[string]string)}
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse source manifest: %w", err)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]string)
+	}
+	return m, nil
+}
+
+// save writes the manifest into a mirror directory
+func (m *manifest) save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode source manifest: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write source manifest: %w", err)
+	}
+	return nil
+}
+
+// hashContent returns the version of mirrored content that has no remote
+// version identifier
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// mirrorExists reports whether a mirror directory has a manifest
+func mirrorExists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, manifestName))
+	return err == nil
+}
+
+// mirrorPath returns the local path of a mirrored file, rejecting paths that
+// would escape the mirror directory
+func mirrorPath(dir, rel string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if path == dir || !strings.HasPrefix(path, filepath.Clean(dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid file path %q", rel)
+	}
+	return path, nil
+}
+
+// writeMirrorFile writes a mirrored file, creating its directories
+func writeMirrorFile(dir, rel string, data []byte) error {
+	path, err := mirrorPath(dir, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", rel, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", rel, err)
+	}
+	return nil
+}
+
+// removeMirrorFile removes a mirrored file that no longer exists remotely
+func removeMirrorFile(dir, rel string) error {
+	path, err := mirrorPath(dir, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", rel, err)
+	}
+
+	// Drop directories left empty, e.g. by a moved page
+	root := filepath.Clean(dir)
+	for parent := filepath.Dir(path); parent != root && strings.HasPrefix(parent, root); parent = filepath.Dir(parent) {
+		if os.Remove(parent) != nil {
+			break // Not empty
+		}
+	}
+	return nil
+}
@@ -0,0 +1,417 @@
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"dimandocs/htmlmd"
+)
+
+const (
+	// DefaultCrawlDepth is the link depth crawled from the base URL when the
+	// site has no sitemap
+	DefaultCrawlDepth = 3
+	// DefaultCrawlPages is the maximum number of pages mirrored from a site
+	DefaultCrawlPages = 500
+	// maxPageSize limits the size of a downloaded page or sitemap
+	maxPageSize = 10 << 20
+)
+
+// assetRegex matches links to files that are not pages
+var assetRegex = regexp.MustCompile(`(?i)\.(png|jpe?g|gif|svg|webp|ico|pdf|zip|gz|tar|tgz|css|js|json|xml|txt|mp4|webm|mp3|woff2?|ttf|eot)$`)
+
+// Website mirrors the pages of a website below a base URL, converted to
+// markdown with the page boilerplate stripped. The pages are taken from the
+// site's sitemap or, without one, found by following links up to MaxDepth.
+type Website struct {
+	URL      string // Base URL; only pages below it are mirrored
+	Sitemap  string // Default: sitemap.xml below the base URL or at the site root
+	MaxDepth int    // Default: DefaultCrawlDepth
+	MaxPages int    // Default: DefaultCrawlPages
+	Dir      string // Local mirror directory
+
+	api *apiClient
+}
+
+// sitemapPage is a page listed in a sitemap
+type sitemapPage struct {
+	URL     string
+	LastMod string
+}
+
+// sitemapDocument is a sitemap or a sitemap index
+type sitemapDocument struct {
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// String describes the website for logging
+func (w *Website) String() string {
+	return w.URL
+}
+
+// Exists reports whether the website has been mirrored
+func (w *Website) Exists() bool {
+	return mirrorExists(w.Dir)
+}
+
+// Sync fetches the pages of the website, writing the pages that were added or
+// changed since the last sync and removing the ones no longer found
+func (w *Website) Sync(ctx context.Context) (bool, error) {
+	base, err := w.base()
+	if err != nil {
+		return false, err
+	}
+	state, err := loadManifest(w.Dir)
+	if err != nil {
+		return false, err
+	}
+	if w.api == nil {
+		w.api = newAPIClient("website", map[string]string{"User-Agent": "dimandocs"})
+	}
+	disallowed := w.robotsRules(ctx, base)
+
+	pages, err := w.sitemapPages(ctx, base)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	seen := make(map[string]bool)
+	update := func(pageURL *url.URL, version, markdown string) error {
+		file := websiteFile(base, pageURL)
+		seen[file] = true
+		if version == "" {
+			version = hashContent(markdown)
+		}
+		if state.Files[file] == version {
+			return nil
+		}
+		content := "---\nsource_url: " + yamlString(pageURL.String()) + "\n---\n\n" + markdown
+		if err := writeMirrorFile(w.Dir, file, []byte(content)); err != nil {
+			return err
+		}
+		state.Files[file] = version
+		changed = true
+		return nil
+	}
+
+	if pages != nil {
+		// The sitemap bounds the site; pages with an unchanged modification
+		// date are not downloaded again
+		for _, page := range pages {
+			pageURL, ok := w.inScope(base, page.URL, disallowed)
+			if !ok {
+				continue
+			}
+			if len(seen) >= w.maxPages() {
+				slog.Warn("Website has too many pages, ignoring the rest", "source", w.String(), "max_pages", w.maxPages())
+				break
+			}
+			version := ""
+			if page.LastMod != "" {
+				version = "lastmod:" + page.LastMod
+				if file := websiteFile(base, pageURL); state.Files[file] == version {
+					seen[file] = true
+					continue
+				}
+			}
+			markdown, _, err := w.fetchPage(ctx, pageURL)
+			if err != nil {
+				w.keepPage(state, seen, base, pageURL, err)
+				continue
+			}
+			if err := update(pageURL, version, markdown); err != nil {
+				return false, err
+			}
+		}
+	} else {
+		// Without a sitemap, follow links breadth-first from the base URL
+		queued := map[string]bool{base.String(): true}
+		queue := []*url.URL{base}
+		for depth := 0; len(queue) > 0 && depth <= w.maxDepth(); depth++ {
+			var next []*url.URL
+			for _, pageURL := range queue {
+				if len(seen) >= w.maxPages() {
+					slog.Warn("Website has too many pages, ignoring the rest", "source", w.String(), "max_pages", w.maxPages())
+					next = nil
+					break
+				}
+				markdown, links, err := w.fetchPage(ctx, pageURL)
+				if err != nil {
+					w.keepPage(state, seen, base, pageURL, err)
+					continue
+				}
+				if err := update(pageURL, "", markdown); err != nil {
+					return false, err
+				}
+				for _, link := range links {
+					if linkURL, ok := w.inScope(base, link, disallowed); ok && !queued[linkURL.String()] {
+						queued[linkURL.String()] = true
+						next = append(next, linkURL)
+					}
+				}
+			}
+			queue = next
+		}
+	}
+
+	for file := range state.Files {
+		if !seen[file] {
+			if err := removeMirrorFile(w.Dir, file); err != nil {
+				return false, err
+			}
+			delete(state.Files, file)
+			changed = true
+		}
+	}
+
+	if err := state.save(w.Dir); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// keepPage keeps the previous copy of a page that could not be fetched
+func (w *Website) keepPage(state *manifest, seen map[string]bool, base, pageURL *url.URL, err error) {
+	slog.Warn("Failed to fetch page", "url", pageURL, "error", err)
+	if file := websiteFile(base, pageURL); state.Files[file] != "" {
+		seen[file] = true
+	}
+}
+
+// fetchPage downloads an HTML page and returns its content as markdown along
+// with the links it contains
+func (w *Website) fetchPage(ctx context.Context, pageURL *url.URL) (string, []string, error) {
+	resp, err := w.api.get(ctx, pageURL.String(), "", "text/html")
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return "", nil, fmt.Errorf("not an HTML page: %s", contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	page, err := htmlmd.Convert(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, err
+	}
+	return page.Markdown, pageLinks(data, resp.Request.URL), nil
+}
+
+// sitemapPages returns the pages listed in the sitemap, or nil if the site
+// has none. A configured sitemap must exist.
+func (w *Website) sitemapPages(ctx context.Context, base *url.URL) ([]sitemapPage, error) {
+	if w.Sitemap != "" {
+		pages, err := w.readSitemap(ctx, w.Sitemap, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sitemap of %s: %w", w, err)
+		}
+		return pages, nil
+	}
+
+	candidates := []string{base.ResolveReference(&url.URL{Path: "sitemap.xml"}).String()}
+	if root := base.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String(); root != candidates[0] {
+		candidates = append(candidates, root)
+	}
+	for _, candidate := range candidates {
+		// An empty sitemap is more likely an error page than an empty site
+		if pages, err := w.readSitemap(ctx, candidate, 0); err == nil && len(pages) > 0 {
+			slog.Info("Using sitemap", "url", candidate)
+			return pages, nil
+		}
+	}
+	return nil, nil
+}
+
+// readSitemap returns the pages of a sitemap, following sitemap indexes
+func (w *Website) readSitemap(ctx context.Context, sitemapURL string, depth int) ([]sitemapPage, error) {
+	resp, err := w.api.get(ctx, sitemapURL, "", "application/xml")
+	if err != nil {
+		return nil, err
+	}
+	var doc sitemapDocument
+	err = xml.NewDecoder(io.LimitReader(resp.Body, maxPageSize)).Decode(&doc)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sitemap %s: %w", sitemapURL, err)
+	}
+
+	pages := []sitemapPage{}
+	for _, u := range doc.URLs {
+		pages = append(pages, sitemapPage{URL: strings.TrimSpace(u.Loc), LastMod: strings.TrimSpace(u.LastMod)})
+	}
+	// Sitemap indexes list sitemaps, which do not nest any further
+	if depth == 0 {
+		for _, s := range doc.Sitemaps {
+			nested, err := w.readSitemap(ctx, strings.TrimSpace(s.Loc), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			pages = append(pages, nested...)
+		}
+	}
+	return pages, nil
+}
+
+// robotsRules returns the path prefixes that robots.txt disallows for all
+// user agents. A missing robots.txt allows everything.
+func (w *Website) robotsRules(ctx context.Context, base *url.URL) []string {
+	resp, err := w.api.get(ctx, base.ResolveReference(&url.URL{Path: "/robots.txt"}).String(), "", "text/plain")
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var rules []string
+	applies := false
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxPageSize))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "user-agent":
+			applies = value == "*"
+		case "disallow":
+			if applies && value != "" {
+				rules = append(rules, value)
+			}
+		}
+	}
+	return rules
+}
+
+// inScope resolves a page URL and reports whether it is below the base URL
+// and allowed by robots.txt. Fragments and queries are dropped.
+func (w *Website) inScope(base *url.URL, rawURL string, disallowed []string) (*url.URL, bool) {
+	pageURL, err := base.Parse(rawURL)
+	if err != nil || (pageURL.Scheme != "http" && pageURL.Scheme != "https") {
+		return nil, false
+	}
+	pageURL.Fragment = ""
+	pageURL.RawQuery = ""
+	if pageURL.Host != base.Host || assetRegex.MatchString(pageURL.Path) {
+		return nil, false
+	}
+	if pageURL.Path != strings.TrimSuffix(base.Path, "/") && !strings.HasPrefix(pageURL.Path, base.Path) {
+		return nil, false
+	}
+	for _, rule := range disallowed {
+		if strings.HasPrefix(pageURL.Path, rule) {
+			return nil, false
+		}
+	}
+	return pageURL, true
+}
+
+// base returns the base URL, with its path ending in a slash
+func (w *Website) base() (*url.URL, error) {
+	base, err := url.Parse(w.URL)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid website URL %q", w.URL)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	base.RawPath = ""
+	base.Fragment = ""
+	base.RawQuery = ""
+	return base, nil
+}
+
+// maxDepth returns the configured or default crawl depth
+func (w *Website) maxDepth() int {
+	if w.MaxDepth <= 0 {
+		return DefaultCrawlDepth
+	}
+	return w.MaxDepth
+}
+
+// maxPages returns the configured or default page limit
+func (w *Website) maxPages() int {
+	if w.MaxPages <= 0 {
+		return DefaultCrawlPages
+	}
+	return w.MaxPages
+}
+
+// websiteFile returns the mirror path of a page relative to the base URL:
+// "guide/install.html" becomes "guide/install.md" and directory pages such
+// as "guide/" become "guide/index.md"
+func websiteFile(base, pageURL *url.URL) string {
+	rel := strings.TrimPrefix(pageURL.Path, base.Path)
+	if pageURL.Path == strings.TrimSuffix(base.Path, "/") {
+		rel = ""
+	}
+	if rel == "" || strings.HasSuffix(rel, "/") {
+		return rel + "index.md"
+	}
+	if ext := path.Ext(rel); strings.EqualFold(ext, ".html") || strings.EqualFold(ext, ".htm") {
+		rel = strings.TrimSuffix(rel, ext)
+	}
+	return rel + ".md"
+}
+
+// pageLinks returns the link targets of an HTML page, resolved against the
+// page URL and its <base> element
+func pageLinks(data []byte, pageURL *url.URL) []string {
+	var links []string
+	base := pageURL
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			for _, a := range token.Attr {
+				if a.Key != "href" {
+					continue
+				}
+				switch token.DataAtom {
+				case atom.Base:
+					if u, err := pageURL.Parse(a.Val); err == nil {
+						base = u
+					}
+				case atom.A:
+					if u, err := base.Parse(a.Val); err == nil {
+						links = append(links, u.String())
+					}
+				}
+			}
+		}
+	}
+}
+
+// yamlString quotes a string for YAML frontmatter
+func yamlString(s string) string {
+	quoted, _ := json.Marshal(s) // JSON strings are valid YAML
+	return string(quoted)
+}
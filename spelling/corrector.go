@@ -0,0 +1,206 @@
+package spelling
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// MinWordLength is the minimum length of a word to be corrected
+	MinWordLength = 3
+	// MaxEditDistance is the maximum edit distance for long words
+	MaxEditDistance = 2
+)
+
+// wordRegex matches words and identifiers (letters, digits, underscores, dashes)
+var wordRegex = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{N}_\-]*`)
+
+// Corrector suggests corrections for misspelled query terms using a
+// vocabulary built from the document corpus
+type Corrector struct {
+	words map[string]int // word -> frequency
+	mu    sync.RWMutex
+}
+
+// NewCorrector creates an empty corrector
+func NewCorrector() *Corrector {
+	return &Corrector{
+		words: make(map[string]int),
+	}
+}
+
+// Add adds all words of text to the vocabulary
+func (c *Corrector) Add(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, word := range Tokenize(text) {
+		if len(word) >= MinWordLength {
+			c.words[word]++
+		}
+	}
+}
+
+// Size returns the number of distinct words in the vocabulary
+func (c *Corrector) Size() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.words)
+}
+
+// Known reports whether word is part of the vocabulary
+func (c *Corrector) Known(word string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.words[strings.ToLower(word)]
+	return ok
+}
+
+// Correct returns the best vocabulary match for word and whether it differs
+// from the input. Words already in the vocabulary are returned unchanged.
+func (c *Corrector) Correct(word string) (string, bool) {
+	word = strings.ToLower(word)
+	if len([]rune(word)) < MinWordLength {
+		return word, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.words[word]; ok {
+		return word, false
+	}
+
+	maxDist := MaxEditDistance
+	if len([]rune(word)) <= 4 {
+		maxDist = 1
+	}
+
+	best := ""
+	bestDist := maxDist + 1
+	bestFreq := 0
+	for candidate, freq := range c.words {
+		if abs(len(candidate)-len(word)) > maxDist {
+			continue
+		}
+		dist := editDistance(word, candidate, maxDist)
+		if dist > maxDist {
+			continue
+		}
+		if dist < bestDist || (dist == bestDist && (freq > bestFreq || (freq == bestFreq && candidate < best))) {
+			best = candidate
+			bestDist = dist
+			bestFreq = freq
+		}
+	}
+
+	if best == "" {
+		return word, false
+	}
+	return best, true
+}
+
+// CorrectQuery corrects every term of query and returns the corrected query
+// and whether any term was changed
+func (c *Corrector) CorrectQuery(query string) (string, bool) {
+	terms := Tokenize(query)
+	changed := false
+	for i, term := range terms {
+		if corrected, ok := c.Correct(term); ok {
+			terms[i] = corrected
+			changed = true
+		}
+	}
+	return strings.Join(terms, " "), changed
+}
+
+// Tokenize splits text into lowercase words, also splitting camelCase and
+// snake_case identifiers into their parts
+func Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range wordRegex.FindAllString(text, -1) {
+		lower := strings.ToLower(word)
+		tokens = append(tokens, lower)
+
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			for _, part := range parts {
+				tokens = append(tokens, strings.ToLower(part))
+			}
+		}
+	}
+	return tokens
+}
+
+// splitIdentifier splits camelCase, snake_case and kebab-case identifiers
+func splitIdentifier(word string) []string {
+	var parts []string
+	var current []rune
+	runes := []rune(word)
+
+	for i, r := range runes {
+		if r == '_' || r == '-' {
+			if len(current) > 0 {
+				parts = append(parts, string(current))
+				current = nil
+			}
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) && unicode.IsLower(runes[i-1]) && len(current) > 0 {
+			parts = append(parts, string(current))
+			current = nil
+		}
+		current = append(current, r)
+	}
+	if len(current) > 0 {
+		parts = append(parts, string(current))
+	}
+	return parts
+}
+
+// editDistance computes the Damerau-Levenshtein (optimal string alignment)
+// distance between a and b, stopping early once it exceeds maxDist
+func editDistance(a, b string, maxDist int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > maxDist {
+		return maxDist + 1
+	}
+
+	prevPrev := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prevPrev[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > maxDist {
+			return maxDist + 1
+		}
+		prevPrev, prev, curr = prev, curr, prevPrev
+	}
+
+	return prev[len(rb)]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
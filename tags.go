@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"dimandocs/frontmatter"
+)
+
+// TagCount represents a tag and the number of documents having it
+type TagCount struct {
+	Tag   string `json:"Tag"`
+	Count int    `json:"Count"`
+}
+
+// Tags returns all document tags, most used first
+func (a *App) Tags() []TagCount {
+	counts := make(map[string]int)
+	for _, doc := range a.GetDocuments() {
+		for _, tag := range doc.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags
+}
+
+// handleTags returns the tag taxonomy as JSON
+func (a *App) handleTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.Tags()); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// requestTags returns the tags of repeated or comma-separated tag parameters
+func requestTags(r *http.Request) []string {
+	var tags []string
+	for _, value := range r.URL.Query()["tag"] {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	return frontmatter.NormalizeTags(tags)
+}
+
+// hasTags reports whether a document has all of the tags
+func hasTags(doc Document, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, docTag := range doc.Tags {
+			if docTag == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// filterByTags keeps the search results of documents having all of the tags
+func filterByTags(results []SearchResultJSON, tags []string) []SearchResultJSON {
+	if len(tags) == 0 {
+		return results
+	}
+
+	var filtered []SearchResultJSON
+	for _, result := range results {
+		if hasTags(result.Document, tags) {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,38 @@
+// Package tokenauth checks the API tokens of requests, shared by the web
+// interface and the MCP HTTP transport so both accept them the same way.
+package tokenauth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// FromRequest returns the token of a request, sent as
+// "Authorization: Bearer <token>" or in the X-API-Key header, or "" if it
+// has none
+func FromRequest(r *http.Request) string {
+	token := r.Header.Get("X-API-Key")
+	if header := r.Header.Get("Authorization"); header != "" {
+		if scheme, value, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(value)
+		}
+	}
+	return token
+}
+
+// Valid reports whether token is one of tokens. It is compared against every
+// token in constant time, so timing does not reveal which token matched or
+// how much of it.
+func Valid(token string, tokens []string) bool {
+	if token == "" {
+		return false
+	}
+	valid := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// maxUploadSize is the largest request accepted by POST /api/documents
+const maxUploadSize = 10 << 20
+
+// UploadedDocument reports a document stored by POST /api/documents
+type UploadedDocument struct {
+	Title      string `json:"Title"`
+	RelPath    string `json:"RelPath"`
+	SourceName string `json:"SourceName"`
+	Created    bool   `json:"Created"` // False if the file of an existing document was replaced
+	Failed     bool   `json:"Failed"`  // Embedding failed, so semantic search misses the document
+}
+
+// uploadsDirectory returns the directory that stores uploaded documents
+func (a *App) uploadsDirectory() (DirectoryConfig, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, dirConfig := range a.Config.Directories {
+		if dirConfig.Uploads {
+			return dirConfig, true
+		}
+	}
+	return DirectoryConfig{}, false
+}
+
+// createUploadsDirectory creates the directory that stores uploaded
+// documents, so that it can be scanned before the first upload
+func (a *App) createUploadsDirectory() error {
+	dirConfig, ok := a.uploadsDirectory()
+	if !ok {
+		return nil
+	}
+	if err := os.MkdirAll(dirConfig.Path, 0755); err != nil {
+		return fmt.Errorf("failed to create uploads directory %s: %w", dirConfig.Path, err)
+	}
+	return nil
+}
+
+// uploadPath returns the path of the file an upload named name is stored in,
+// checking that it stays within the uploads directory and that scans of the
+// directory pick it up
+func (a *App) uploadPath(dirConfig DirectoryConfig, name string) (string, error) {
+	name = filepath.FromSlash(name)
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("path %q must be relative and stay within the uploads directory", name)
+	}
+	if !a.FileRegexes[dirConfig.Path].MatchString(filepath.Base(name)) {
+		return "", fmt.Errorf("path %q does not match the file pattern of the uploads directory", name)
+	}
+
+	path := filepath.Join(dirConfig.Path, name)
+	if a.pathIgnored(dirConfig.Path, path) {
+		return "", fmt.Errorf("path %q is ignored", name)
+	}
+	return path, nil
+}
+
+// UploadDocument writes content to the file at path within the uploads
+// directory, replacing any existing file, and loads and embeds the document
+func (a *App) UploadDocument(ctx context.Context, dirConfig DirectoryConfig, path string, content []byte) (UploadedDocument, error) {
+	_, err := os.Stat(path)
+	created := errors.Is(err, os.ErrNotExist)
+
+	if err := writeFileAtomic(path, content); err != nil {
+		return UploadedDocument{}, err
+	}
+	doc, err := a.loadDocument(path, dirConfig.Path, dirConfig.Name)
+	if err != nil {
+		return UploadedDocument{}, err
+	}
+
+	_, failed := a.replaceDocuments(ctx, []Document{doc}, func(d Document) bool { return d.Path == path }, false)
+	slog.Info("Uploaded document", "path", doc.RelPath, "created", created)
+	return UploadedDocument{
+		Title:      doc.Title,
+		RelPath:    doc.RelPath,
+		SourceName: doc.SourceName,
+		Created:    created,
+		Failed:     failed > 0,
+	}, nil
+}
+
+// writeFileAtomic writes a file through a temporary file in the same
+// directory, so that scans never see it half written
+func writeFileAtomic(path string, content []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+	return nil
+}
+
+// handleUpload serves POST /api/documents, which stores a document in the
+// uploads directory and indexes it. The document is JSON or a form with its
+// path and content, or the file field of a multipart form, named by its path
+// field or else by the file name.
+func (a *App) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if !a.requireAuthConfigured(w) {
+		return
+	}
+	dirConfig, ok := a.uploadsDirectory()
+	if !ok {
+		http.Error(w, "Uploading documents requires a directory with uploads enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	var name string
+	var content []byte
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		file, header, err := r.FormFile("file")
+		if errors.Is(err, http.ErrMissingFile) {
+			name, content = r.FormValue("path"), []byte(r.FormValue("content"))
+			break
+		}
+		if err != nil {
+			uploadError(w, fmt.Errorf("failed to read file field: %w", err))
+			return
+		}
+		defer file.Close()
+		if content, err = io.ReadAll(file); err != nil {
+			uploadError(w, fmt.Errorf("failed to read file field: %w", err))
+			return
+		}
+		name = r.FormValue("path")
+		if name == "" {
+			name = header.Filename
+		}
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			uploadError(w, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		name, content = r.PostFormValue("path"), []byte(r.PostFormValue("content"))
+	case "application/json", "":
+		var req struct {
+			Path    string `json:"path"`
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			uploadError(w, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		name, content = req.Path, []byte(req.Content)
+	default:
+		http.Error(w, fmt.Sprintf("Unsupported content type %q, use application/json or a form", mediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	path, err := a.uploadPath(dirConfig, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	relPath, _ := filepath.Rel(dirConfig.Path, path)
+	if doc := a.findDocument(relPath); doc != nil && doc.SourceDir != dirConfig.Path {
+		http.Error(w, fmt.Sprintf("Document %s of source %s has the same path", relPath, doc.SourceName), http.StatusConflict)
+		return
+	}
+
+	uploaded, err := a.UploadDocument(r.Context(), dirConfig, path, content)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store document: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/documents/"+filepath.ToSlash(uploaded.RelPath))
+	if uploaded.Created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	if err := json.NewEncoder(w).Encode(uploaded); err != nil {
+		slog.Warn("Failed to encode response", "error", err)
+	}
+}
+
+// uploadError answers 413 for uploads over maxUploadSize and 400 for other
+// unreadable requests
+func uploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Upload exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
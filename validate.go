@@ -0,0 +1,338 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"dimandocs/chunking"
+	"dimandocs/embedding"
+	"dimandocs/vector"
+)
+
+// Severities of configuration issues
+const (
+	SeverityError   = "error"   // The configuration does not work as intended
+	SeverityWarning = "warning" // The configuration works, but likely not as expected
+)
+
+// ConfigIssue represents a problem found in the configuration
+type ConfigIssue struct {
+	Severity string `json:"severity"`
+	Field    string `json:"field"` // Config path, e.g. "directories[0].path"
+	Message  string `json:"message"`
+}
+
+// configIssues collects issues during validation
+type configIssues []ConfigIssue
+
+// errorf records an error
+func (c *configIssues) errorf(field, format string, args ...any) {
+	*c = append(*c, ConfigIssue{SeverityError, field, fmt.Sprintf(format, args...)})
+}
+
+// warnf records a warning
+func (c *configIssues) warnf(field, format string, args ...any) {
+	*c = append(*c, ConfigIssue{SeverityWarning, field, fmt.Sprintf(format, args...)})
+}
+
+// apiKeyEnvVars are the environment variables providers read API keys from
+var apiKeyEnvVars = map[string]string{
+	"openai":   "OPENAI_API_KEY",
+	"voyage":   "VOYAGE_API_KEY",
+	"voyageai": "VOYAGE_API_KEY",
+	"cohere":   "COHERE_API_KEY",
+}
+
+// ValidateConfig checks a loaded configuration for problems that would
+// otherwise only surface at runtime, such as missing directories, missing API
+// keys or a model whose dimension does not match the index
+func (a *App) ValidateConfig() []ConfigIssue {
+	var issues configIssues
+	a.validateDirectories(&issues)
+	a.validateEmbeddings(&issues)
+	a.validateLLM(&issues)
+	a.validateServer(&issues)
+	return issues
+}
+
+// validateDirectories checks the configured document directories
+func (a *App) validateDirectories(issues *configIssues) {
+	if len(a.Config.Directories) == 0 {
+		issues.errorf("directories", "no directories configured, there is nothing to serve")
+		return
+	}
+
+	names := make(map[string]int)
+	for i, dirConfig := range a.Config.Directories {
+		field := fmt.Sprintf("directories[%d]", i)
+		if dirConfig.Name == "" {
+			issues.warnf(field+".name", "directory %s has no name, which is shown in the navigation and used by source filters", dirConfig.Path)
+		} else if first, ok := names[dirConfig.Name]; ok {
+			issues.warnf(field+".name", "name %q is also used by directories[%d], source filters cannot tell them apart", dirConfig.Name, first)
+		} else {
+			names[dirConfig.Name] = i
+		}
+
+		// Remote sources are mirrored on startup, so their directory may not
+		// exist yet
+		if isRemoteSource(dirConfig) {
+			continue
+		}
+
+		info, err := os.Stat(dirConfig.Path)
+		switch {
+		case os.IsNotExist(err):
+			issues.errorf(field+".path", "directory %s does not exist", dirConfig.Path)
+			continue
+		case err != nil:
+			issues.errorf(field+".path", "cannot read directory %s: %v", dirConfig.Path, err)
+			continue
+		case !info.IsDir():
+			issues.errorf(field+".path", "%s is not a directory", dirConfig.Path)
+			continue
+		}
+
+		if !a.hasMatchingFile(dirConfig) {
+			issues.warnf(field+".file_pattern", "no files in %s match %q, the directory contributes no documents", dirConfig.Path, a.FileRegexes[dirConfig.Path].String())
+		}
+	}
+}
+
+// isRemoteSource reports whether a directory is read from a remote source
+func isRemoteSource(dirConfig DirectoryConfig) bool {
+	return dirConfig.Git != nil || dirConfig.GitHub != nil || dirConfig.GitLabWiki != nil ||
+		dirConfig.Confluence != nil || dirConfig.S3 != nil || dirConfig.Website != nil
+}
+
+// errFound stops a directory walk at the first match
+var errFound = errors.New("found")
+
+// hasMatchingFile reports whether a directory contains a file matching its
+// pattern outside the ignored paths
+func (a *App) hasMatchingFile(dirConfig DirectoryConfig) bool {
+	regex := a.FileRegexes[dirConfig.Path]
+	err := filepath.WalkDir(dirConfig.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if a.shouldIgnorePath(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && regex.MatchString(d.Name()) {
+			return errFound
+		}
+		return nil
+	})
+	return err == errFound
+}
+
+// validateEmbeddings checks the embedding provider, model and vector store
+func (a *App) validateEmbeddings(issues *configIssues) {
+	cfg := a.Config.Embeddings
+	if !cfg.Enabled {
+		if a.Config.MCP.Enabled {
+			issues.errorf("mcp.enabled", "the MCP server requires embeddings, set embeddings.enabled")
+		}
+		return
+	}
+
+	// Embeddings, globally and per directory
+	validateIndex(issues, "embeddings", cfg)
+	for i, dirConfig := range a.Config.Directories {
+		if dirConfig.Embeddings != nil {
+			validateIndex(issues, fmt.Sprintf("directories[%d].embeddings", i), mergeEmbeddingsConfig(cfg, *dirConfig.Embeddings))
+		}
+	}
+
+	// Chunking, globally and per directory
+	validateChunking(issues, "embeddings.chunking", cfg.Chunking)
+	for i, dirConfig := range a.Config.Directories {
+		if dirConfig.Chunking != nil {
+			validateChunking(issues, fmt.Sprintf("directories[%d].chunking", i), mergeChunkingConfig(cfg.Chunking, *dirConfig.Chunking))
+		}
+	}
+
+	if cfg.Summaries.Enabled && a.Config.LLM.Provider == "openai" && a.Config.LLM.APIKey == "" {
+		issues.errorf("embeddings.summaries", "summaries need an API key for the llm provider openai, set llm.api_key or OPENAI_API_KEY")
+	}
+}
+
+// validateIndex checks the provider, model and vector store of the embeddings
+// config at field
+func validateIndex(issues *configIssues, field string, cfg EmbeddingsConfig) {
+	provider := strings.ToLower(cfg.Provider)
+	switch provider {
+	case "openai", "ollama", "voyage", "voyageai", "tei", "huggingface", "cohere":
+	default:
+		issues.errorf(field+".provider", "unsupported provider %q, expected openai, ollama, voyage, cohere or tei", cfg.Provider)
+		return
+	}
+
+	if envVar, ok := apiKeyEnvVars[provider]; ok && cfg.APIKey == "" {
+		issues.errorf(field+".api_key", "provider %s needs an API key, set api_key or %s", cfg.Provider, envVar)
+	}
+
+	// Model and dimension compatibility
+	dim, known := embedding.ModelDimension(provider, cfg.Model)
+	switch provider {
+	case "tei", "huggingface":
+		dim, known = cfg.Dimension, cfg.Dimension > 0
+	case "openai":
+		if known && dim < embedding.DefaultDimension {
+			issues.errorf(field+".model", "model %s returns at most %d dimensions, but %d are requested; use text-embedding-3-large", cfg.Model, dim, embedding.DefaultDimension)
+		}
+		dim, known = embedding.DefaultDimension, true
+	default:
+		if cfg.Dimension > 0 {
+			dim, known = cfg.Dimension, true
+		} else if !known {
+			issues.warnf(field+".model", "unknown %s model %q, its dimension is detected on first use", cfg.Provider, cfg.Model)
+		}
+	}
+	if cfg.Dimension > 0 && provider == "openai" {
+		issues.warnf(field+".dimension", "dimension is ignored for %s", cfg.Provider)
+	}
+
+	// Vector store
+	switch {
+	case !vector.IsBackend(cfg.VectorStore.Type):
+		issues.errorf(field+".vector_store", "unsupported vector store %q, expected %s", cfg.VectorStore.Type, strings.Join(vector.Backends(), ", "))
+	case cfg.VectorStore.Type == "sqlite":
+		if known {
+			validateStoredDimension(issues, field, cfg, dim)
+		}
+	default:
+		// Creating a store checks its options without connecting
+		if store, err := vector.Open(vectorStoreConfig(cfg)); err != nil {
+			issues.errorf(field+".vector_store", "%v", err)
+		} else {
+			store.Close()
+		}
+		if cfg.Quantization != vector.QuantizationFloat {
+			issues.warnf(field+".quantization", "quantization is only supported by the sqlite vector store and is ignored for %s", cfg.VectorStore.Type)
+		}
+	}
+	if cfg.Quantization == vector.QuantizationBit && known && dim%8 != 0 {
+		issues.errorf(field+".quantization", "bit quantization needs a dimension divisible by 8, but the model produces %d", dim)
+	}
+	if cfg.Rescore && cfg.Quantization == vector.QuantizationFloat {
+		issues.warnf(field+".rescore", "rescore only applies to int8 or bit quantization and is ignored")
+	}
+}
+
+// validateChunking checks chunking options
+func validateChunking(issues *configIssues, field string, cfg ChunkingConfig) {
+	switch cfg.Strategy {
+	case "", chunking.StrategyMarkdown, chunking.StrategySemantic:
+	default:
+		issues.errorf(field+".strategy", "unsupported strategy %q, expected markdown or semantic", cfg.Strategy)
+	}
+	if cfg.MaxTokens > 0 && cfg.OverlapTokens >= cfg.MaxTokens {
+		issues.errorf(field+".overlap_tokens", "overlap of %d tokens must be smaller than max_tokens (%d)", cfg.OverlapTokens, cfg.MaxTokens)
+	}
+}
+
+// validateStoredDimension warns when the existing SQLite index was built with
+// another dimension, distance metric or quantization, since starting would
+// rebuild it
+func validateStoredDimension(issues *configIssues, field string, cfg EmbeddingsConfig, dim int) {
+	if _, err := os.Stat(cfg.DBPath); err != nil {
+		return
+	}
+	store := vector.NewSQLiteStore(cfg.DBPath, cfg.Metric, cfg.Quantization, cfg.Rescore)
+	if err := store.Initialize(); err != nil {
+		issues.errorf(field+".db_path", "cannot open index %s: %v", cfg.DBPath, err)
+		return
+	}
+	defer store.Close()
+
+	stored, err := store.StoredDimension()
+	if err != nil {
+		issues.errorf(field+".db_path", "cannot read index %s: %v", cfg.DBPath, err)
+		return
+	}
+	if stored > 0 && stored != dim {
+		issues.warnf(field+".model", "the index in %s has dimension %d but the model produces %d, every document will be re-embedded on the next start", cfg.DBPath, stored, dim)
+		return
+	}
+
+	metric, err := store.StoredMetric()
+	if err != nil {
+		issues.errorf(field+".db_path", "cannot read index %s: %v", cfg.DBPath, err)
+		return
+	}
+	if metric != "" && metric != cfg.Metric {
+		issues.warnf(field+".metric", "the index in %s was built with the %s metric, every document will be re-indexed from the embedding cache on the next start", cfg.DBPath, metric)
+	}
+
+	quantization, err := store.StoredQuantization()
+	if err != nil {
+		issues.errorf(field+".db_path", "cannot read index %s: %v", cfg.DBPath, err)
+		return
+	}
+	if quantization != "" && quantization != cfg.Quantization {
+		issues.warnf(field+".quantization", "the index in %s was built with %s quantization, every document will be re-indexed from the embedding cache on the next start", cfg.DBPath, quantization)
+	}
+}
+
+// validateLLM checks the chat model configuration
+func (a *App) validateLLM(issues *configIssues) {
+	switch a.Config.LLM.Provider {
+	case "openai", "ollama":
+	default:
+		issues.errorf("llm.provider", "unsupported provider %q, expected openai or ollama", a.Config.LLM.Provider)
+		return
+	}
+	if a.Config.LLM.Provider == "openai" && a.Config.LLM.APIKey == "" && a.Config.LLM.Model != "" {
+		issues.warnf("llm.api_key", "provider openai has no API key, so the answer_question MCP tool is disabled; set api_key or OPENAI_API_KEY")
+	}
+}
+
+// validateServer checks the web and MCP server settings
+func (a *App) validateServer(issues *configIssues) {
+	webPort := a.Config.Port
+	if webPort == "" {
+		webPort = "8080"
+	}
+	if port, err := strconv.Atoi(webPort); err != nil || port < 1 || port > 65535 {
+		issues.errorf("port", "invalid port %q", webPort)
+	}
+
+	if tlsCfg := a.Config.TLS; tlsCfg.CertFile != "" {
+		if _, err := tls.LoadX509KeyPair(tlsCfg.CertFile, tlsCfg.KeyFile); err != nil {
+			issues.errorf("tls.cert_file", "cannot load certificate: %v", err)
+		}
+	}
+	if a.Config.TLS.Autocert != nil && webPort != "443" {
+		issues.warnf("port", "Let's Encrypt validates domains on port 443, serve on it or forward it to port %s", webPort)
+	}
+	if oidcCfg := a.Config.Auth.OIDC; oidcCfg != nil && !strings.HasPrefix(oidcCfg.RedirectURL, "https://") {
+		issues.warnf("auth.oidc.redirect_url", "the redirect URL is not HTTPS, session cookies are sent in the clear")
+	}
+
+	mcpCfg := a.Config.MCP
+	if mcpCfg.Port < 1 || mcpCfg.Port > 65535 {
+		issues.errorf("mcp.port", "invalid port %d", mcpCfg.Port)
+	}
+	if mcpCfg.Transport != "http" {
+		return
+	}
+	if len(mcpCfg.AuthTokens) == 0 {
+		issues.warnf("mcp.auth_tokens", "the HTTP transport has no auth_tokens, anyone reaching port %d can read the documentation", mcpCfg.Port)
+		if mcpCfg.AllowReindex {
+			issues.warnf("mcp.allow_reindex", "the reindex_docs tool is not offered over HTTP without auth_tokens")
+		}
+	}
+	if mcpCfg.Enabled && strconv.Itoa(mcpCfg.Port) == webPort {
+		issues.errorf("mcp.port", "port %d is also used by the web interface", mcpCfg.Port)
+	}
+}
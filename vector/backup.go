@@ -0,0 +1,148 @@
+package vector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// backupRetryDelay is how long a backup or restore waits before retrying
+// while the database is locked by a writer
+const backupRetryDelay = 50 * time.Millisecond
+
+// errRemoteBackup is returned when backing up or restoring a database on a
+// libSQL server, which keeps its own backups
+var errRemoteBackup = errors.New("libSQL databases are backed up by their server")
+
+// BackupStore is implemented by stores that can copy their database to a
+// file while in use, and replace it with such a copy
+type BackupStore interface {
+	// Backup writes a consistent copy of the database to path, replacing
+	// any existing file
+	Backup(path string) error
+
+	// Restore replaces the contents of the database with the backup at path
+	Restore(path string) error
+}
+
+// Backup writes a consistent copy of the database to path with the SQLite
+// online backup API, so searches and indexing may continue meanwhile. The
+// copy is written next to path and renamed, so an existing backup is only
+// replaced by a complete one.
+func (s *SQLiteStore) Backup(path string) error {
+	if s.remote {
+		return errRemoteBackup
+	}
+
+	// Writes through this store would restart the backup
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+	dst, err := sql.Open("sqlite3", tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	err = copyDatabase(dst, s.db)
+	if err == nil {
+		// The copy inherits WAL mode, which would leave -wal and -shm files
+		// next to the backup whenever it is opened
+		if _, err = dst.Exec("PRAGMA journal_mode = DELETE"); err != nil {
+			err = fmt.Errorf("failed to switch backup out of WAL mode: %w", err)
+		}
+	}
+	dst.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to move backup into place: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the contents of the database with the backup at path,
+// after checking that it is a vector database. Documents are re-indexed on
+// the next start if the backup was made with another embedding dimension or
+// distance metric.
+func (s *SQLiteStore) Restore(path string) error {
+	if s.remote {
+		return errRemoteBackup
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer src.Close()
+
+	var documents int
+	if err := src.QueryRow("SELECT COUNT(*) FROM documents").Scan(&documents); err != nil {
+		return fmt.Errorf("%s is not a vector database backup: %w", path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyDatabase(s.db, src)
+}
+
+// copyDatabase copies the main database of src over that of dst with the
+// SQLite online backup API
+func copyDatabase(dst, src *sql.DB) error {
+	ctx := context.Background()
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to backup destination: %w", err)
+	}
+	defer dstConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to backup source: %w", err)
+	}
+	defer srcConn.Close()
+
+	return dstConn.Raw(func(dstDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+			dstSQLite, ok := dstDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("backup destination is not a SQLite connection")
+			}
+			srcSQLite, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("backup source is not a SQLite connection")
+			}
+
+			backup, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+			for {
+				// Copy all pages at once, retrying while a writer holds a lock
+				done, err := backup.Step(-1)
+				if err != nil {
+					backup.Close()
+					return fmt.Errorf("failed to copy database: %w", err)
+				}
+				if done {
+					break
+				}
+				time.Sleep(backupRetryDelay)
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
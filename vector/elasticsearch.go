@@ -0,0 +1,926 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// elasticQueryLimit is the largest number of hits a search returns,
+	// the default index.max_result_window
+	elasticQueryLimit = 10000
+
+	// elasticPageSize is the number of documents listed per page
+	elasticPageSize = 1000
+
+	// elasticBulkBatch is the number of chunks indexed per bulk request
+	elasticBulkBatch = 100
+
+	// elasticRequestTimeout bounds every request to Elasticsearch
+	elasticRequestTimeout = 30 * time.Second
+
+	// elasticCandidateFactor is how many candidates per requested result
+	// kNN search considers on each shard, trading speed for recall
+	elasticCandidateFactor = 10
+
+	// defaultElasticIndex is the default prefix of index names
+	defaultElasticIndex = "dimandocs"
+)
+
+// elasticIndexRegex matches a valid index name prefix
+var elasticIndexRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// elasticKeywordFields are the chunk fields searched by BM25; breadcrumbs
+// hold the section titles
+var elasticKeywordFields = []string{"chunk_text", "breadcrumb"}
+
+// ElasticsearchStore implements Store using the Elasticsearch or OpenSearch
+// REST API. Documents and chunks are kept in two indices named after a
+// prefix, chunks with a dense vector field for kNN search and analyzed text
+// for BM25. Writes refresh the indices, so they are searchable right away.
+// There are no transactions: chunks of a document are replaced by deleting
+// and indexing them, so a failure midway leaves the document failed until
+// re-indexed.
+type ElasticsearchStore struct {
+	client     *http.Client
+	address    string // Base URL, may carry user:password for basic authentication
+	apiKey     string // Elasticsearch API key, empty without one
+	openSearch bool   // OpenSearch uses knn_vector fields and kNN queries
+	documents  string // Index names
+	chunks     string
+	dimension  int
+	metric     string // MetricCosine or MetricL2
+	mu         sync.Mutex
+	upsertMu   sync.Mutex // Serializes lookups and creation of documents by path
+}
+
+func init() {
+	Register("elasticsearch", func(cfg Config) (Store, error) { return openElasticsearch(cfg, false) })
+	Register("opensearch", func(cfg Config) (Store, error) { return openElasticsearch(cfg, true) })
+}
+
+// openElasticsearch creates an Elasticsearch or OpenSearch store from its
+// config
+func openElasticsearch(cfg Config, openSearch bool) (Store, error) {
+	address, err := cfg.RequireOption("url")
+	if err != nil {
+		return nil, err
+	}
+	index := cfg.Option("index", defaultElasticIndex)
+	if !elasticIndexRegex.MatchString(index) {
+		return nil, fmt.Errorf("invalid %s index '%s': use lowercase letters, digits, hyphens and underscores", cfg.Type, index)
+	}
+
+	// The address may carry a password for basic authentication
+	logged := address
+	if u, err := url.Parse(address); err == nil {
+		logged = u.Redacted()
+	}
+	if openSearch {
+		slog.Info("Using OpenSearch vector store", "address", logged, "index", index)
+	} else {
+		slog.Info("Using Elasticsearch vector store", "address", logged, "index", index)
+	}
+	return NewElasticsearchStore(address, cfg.Options["api_key"], index, cfg.Metric, openSearch), nil
+}
+
+// NewElasticsearchStore creates a new Elasticsearch vector store, or an
+// OpenSearch one if openSearch is set, searching with the given distance
+// metric in the indices named prefix-documents and prefix-chunks
+func NewElasticsearchStore(address, apiKey, prefix, metric string, openSearch bool) *ElasticsearchStore {
+	return &ElasticsearchStore{
+		client:     &http.Client{Timeout: elasticRequestTimeout},
+		address:    strings.TrimSuffix(address, "/"),
+		apiKey:     apiKey,
+		openSearch: openSearch,
+		documents:  prefix + "-documents",
+		chunks:     prefix + "-chunks",
+		dimension:  DefaultEmbeddingDimension,
+		metric:     metric,
+	}
+}
+
+// name returns the name of the search engine for messages
+func (s *ElasticsearchStore) name() string {
+	if s.openSearch {
+		return "opensearch"
+	}
+	return "elasticsearch"
+}
+
+// request sends a request to the REST API, such as "GET /index/_mapping",
+// and decodes the response into out unless out is nil. A []byte body is
+// sent as newline-delimited JSON, other bodies are encoded as JSON. Missing
+// indices and documents are reported by found.
+func (s *ElasticsearchStore) request(method, path string, body, out any) (found bool, err error) {
+	var reader io.Reader
+	contentType := "application/json"
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(body)
+		contentType = "application/x-ndjson"
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode %s request: %w", s.name(), err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.address+path, reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s request: %w", s.name(), err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to reach %s: %w", s.name(), err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s response: %w", s.name(), err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode >= 300 {
+		return false, fmt.Errorf("%s %s %s returned status %d: %s", s.name(), method, path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if out == nil || len(respBody) == 0 {
+		return true, nil
+	}
+	// Numbers are decoded exactly, since document IDs exceed float32
+	decoder := json.NewDecoder(bytes.NewReader(respBody))
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return false, fmt.Errorf("failed to decode %s response: %w", s.name(), err)
+	}
+	return true, nil
+}
+
+// elasticHit is a search hit
+type elasticHit struct {
+	ID     string         `json:"_id"`
+	Score  float64        `json:"_score"`
+	Source map[string]any `json:"_source"`
+	Sort   []any          `json:"sort"`
+}
+
+// search runs a search request on an index and returns the hits
+func (s *ElasticsearchStore) search(index string, request map[string]any) ([]elasticHit, error) {
+	var resp struct {
+		Hits struct {
+			Hits []elasticHit `json:"hits"`
+		} `json:"hits"`
+	}
+	found, err := s.request(http.MethodPost, "/"+index+"/_search", request, &resp)
+	if err == nil && !found {
+		err = fmt.Errorf("index %s not found", index)
+	}
+	return resp.Hits.Hits, err
+}
+
+// count returns the number of entries of an index matching query, which
+// may be nil
+func (s *ElasticsearchStore) count(index string, query map[string]any) (int, error) {
+	request := map[string]any{}
+	if query != nil {
+		request["query"] = query
+	}
+	var resp struct {
+		Count json.Number `json:"count"`
+	}
+	if _, err := s.request(http.MethodPost, "/"+index+"/_count", request, &resp); err != nil {
+		return 0, err
+	}
+	return int(jsonInt(resp.Count)), nil
+}
+
+// elasticTerm matches entries whose field equals value exactly
+func elasticTerm(field string, value any) map[string]any {
+	return map[string]any{"term": map[string]any{field: value}}
+}
+
+// elasticFilter matches entries matching all filter clauses, without scoring
+func elasticFilter(clauses []map[string]any) map[string]any {
+	if len(clauses) == 0 {
+		return map[string]any{"match_all": map[string]any{}}
+	}
+	return map[string]any{"bool": map[string]any{"filter": clauses}}
+}
+
+// similarityName returns the name of the store's distance metric in index
+// mappings
+func (s *ElasticsearchStore) similarityName() string {
+	switch {
+	case s.openSearch && s.metric == MetricL2:
+		return "l2"
+	case s.openSearch:
+		return "cosinesimil"
+	case s.metric == MetricL2:
+		return "l2_norm"
+	default:
+		return "cosine"
+	}
+}
+
+// Initialize checks the connection and creates the documents index
+func (s *ElasticsearchStore) Initialize() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, err := s.request(http.MethodHead, "/"+s.documents, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", s.name(), err)
+	}
+	if !found {
+		return s.createDocumentsIndex()
+	}
+
+	// Documents indexes created before documents had collections lack the
+	// field, which dynamic mapping would make a text field
+	_, err = s.request(http.MethodPut, "/"+s.documents+"/_mapping", map[string]any{
+		"properties": map[string]any{"collection": map[string]any{"type": "keyword"}},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to map collection field: %w", err)
+	}
+	return nil
+}
+
+// createDocumentsIndex creates the index of document records
+func (s *ElasticsearchStore) createDocumentsIndex() error {
+	_, err := s.request(http.MethodPut, "/"+s.documents, map[string]any{
+		"mappings": map[string]any{"properties": map[string]any{
+			"doc_id":       map[string]any{"type": "long"},
+			"path":         map[string]any{"type": "keyword"},
+			"title":        map[string]any{"type": "text"},
+			"content_hash": map[string]any{"type": "keyword"},
+			"source":       map[string]any{"type": "keyword"},
+			"collection":   map[string]any{"type": "keyword"},
+			"status":       map[string]any{"type": "keyword"},
+			"error":        map[string]any{"type": "text", "index": false},
+			"updated_at":   map[string]any{"type": "date"},
+			"tags":         map[string]any{"type": "keyword"},
+		}},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create documents index: %w", err)
+	}
+	return nil
+}
+
+// createChunksIndex creates the index of chunks for the store's dimension,
+// with a vector index for its distance metric
+func (s *ElasticsearchStore) createChunksIndex() error {
+	embedding := map[string]any{"type": "dense_vector", "dims": s.dimension, "index": true, "similarity": s.similarityName()}
+	request := map[string]any{}
+	if s.openSearch {
+		embedding = map[string]any{
+			"type":      "knn_vector",
+			"dimension": s.dimension,
+			"method":    map[string]any{"name": "hnsw", "engine": "lucene", "space_type": s.similarityName()},
+		}
+		request["settings"] = map[string]any{"index": map[string]any{"knn": true}}
+	}
+	request["mappings"] = map[string]any{"properties": map[string]any{
+		"doc_id":        map[string]any{"type": "long"},
+		"chunk_index":   map[string]any{"type": "integer"},
+		"chunk_text":    map[string]any{"type": "text"},
+		"section_title": map[string]any{"type": "keyword"},
+		"breadcrumb":    map[string]any{"type": "text"},
+		"embedding":     embedding,
+	}}
+	if _, err := s.request(http.MethodPut, "/"+s.chunks, request, nil); err != nil {
+		return fmt.Errorf("failed to create chunks index: %w", err)
+	}
+	return nil
+}
+
+// chunksLayout returns the embedding dimension and the similarity of the
+// chunks index, or 0 and "" if it does not exist
+func (s *ElasticsearchStore) chunksLayout() (int, string, error) {
+	var resp map[string]struct {
+		Mappings struct {
+			Properties struct {
+				Embedding struct {
+					Dims       json.Number `json:"dims"`
+					Dimension  json.Number `json:"dimension"`
+					Similarity string      `json:"similarity"`
+					Method     struct {
+						SpaceType string `json:"space_type"`
+					} `json:"method"`
+				} `json:"embedding"`
+			} `json:"properties"`
+		} `json:"mappings"`
+	}
+	found, err := s.request(http.MethodGet, "/"+s.chunks+"/_mapping", nil, &resp)
+	if err != nil || !found {
+		return 0, "", err
+	}
+	for _, index := range resp {
+		embedding := index.Mappings.Properties.Embedding
+		if s.openSearch {
+			return int(jsonInt(embedding.Dimension)), embedding.Method.SpaceType, nil
+		}
+		return int(jsonInt(embedding.Dims)), embedding.Similarity, nil
+	}
+	return 0, "", nil
+}
+
+// SetDimension sets the embedding dimension and recreates the indices if
+// the dimension or distance metric changed
+func (s *ElasticsearchStore) SetDimension(dim int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	storedDim, storedSimilarity, err := s.chunksLayout()
+	if err != nil {
+		return fmt.Errorf("failed to read chunks index mapping: %w", err)
+	}
+	s.dimension = dim
+	if storedDim == dim && storedSimilarity == s.similarityName() {
+		return nil
+	}
+
+	if storedDim == dim {
+		slog.Info("Distance metric changed, re-indexing all documents", "metric", s.metric)
+	} else {
+		slog.Info("Embedding dimension changed, re-indexing all documents", "dimension", dim)
+	}
+	for _, index := range []string{s.chunks, s.documents} {
+		if _, err := s.request(http.MethodDelete, "/"+index, nil, nil); err != nil {
+			return fmt.Errorf("failed to delete index %s: %w", index, err)
+		}
+	}
+	if err := s.createDocumentsIndex(); err != nil {
+		return err
+	}
+	return s.createChunksIndex()
+}
+
+// Close releases idle connections
+func (s *ElasticsearchStore) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// parseElasticDocument converts the source of a document record
+func parseElasticDocument(source map[string]any) DocumentRecord {
+	updatedAt, _ := time.Parse(time.RFC3339Nano, jsonString(source["updated_at"]))
+	return DocumentRecord{
+		ID:          jsonInt(source["doc_id"]),
+		Path:        jsonString(source["path"]),
+		Title:       jsonString(source["title"]),
+		ContentHash: jsonString(source["content_hash"]),
+		UpdatedAt:   updatedAt,
+		Status:      jsonString(source["status"]),
+		Error:       jsonString(source["error"]),
+	}
+}
+
+// documentPath returns the API path of the document record with the given
+// ID, followed by action, such as "_update"
+func (s *ElasticsearchStore) documentPath(action string, docID int64) string {
+	return "/" + s.documents + "/" + action + "/" + strconv.FormatInt(docID, 10) + "?refresh=true"
+}
+
+// updateDocument sets fields of the document with the given ID
+func (s *ElasticsearchStore) updateDocument(docID int64, fields map[string]any) error {
+	found, err := s.request(http.MethodPost, s.documentPath("_update", docID), map[string]any{"doc": fields}, nil)
+	if err == nil && !found {
+		err = fmt.Errorf("document %d not found", docID)
+	}
+	return err
+}
+
+// UpsertDocument inserts or updates a document record
+func (s *ElasticsearchStore) UpsertDocument(path, title, contentHash string) (int64, error) {
+	s.upsertMu.Lock()
+	defer s.upsertMu.Unlock()
+
+	fields := map[string]any{
+		"title":        title,
+		"content_hash": contentHash,
+		"updated_at":   time.Now().UTC().Format(time.RFC3339Nano),
+		"status":       documentStatus(contentHash),
+		"error":        "",
+	}
+	doc, err := s.GetDocument(path)
+	if err != nil {
+		return 0, err
+	}
+	if doc != nil {
+		if err := s.updateDocument(doc.ID, fields); err != nil {
+			return 0, fmt.Errorf("failed to upsert document: %w", err)
+		}
+		return doc.ID, nil
+	}
+
+	docID := newDocumentID()
+	fields["doc_id"] = docID
+	fields["path"] = path
+	fields["source"] = ""
+	fields["collection"] = ""
+	fields["tags"] = []string{}
+	if _, err := s.request(http.MethodPut, s.documentPath("_create", docID), fields, nil); err != nil {
+		return 0, fmt.Errorf("failed to upsert document: %w", err)
+	}
+	return docID, nil
+}
+
+// SetDocumentSource records the source directory name and the collection
+// of a document
+func (s *ElasticsearchStore) SetDocumentSource(docID int64, source, collection string) error {
+	if err := s.updateDocument(docID, map[string]any{"source": source, "collection": collection}); err != nil {
+		return fmt.Errorf("failed to set document source: %w", err)
+	}
+	return nil
+}
+
+// SetDocumentStatus records the indexing status of a document and, for
+// failed documents, the error
+func (s *ElasticsearchStore) SetDocumentStatus(docID int64, status, message string) error {
+	if err := s.updateDocument(docID, map[string]any{"status": status, "error": message}); err != nil {
+		return fmt.Errorf("failed to set document status: %w", err)
+	}
+	return nil
+}
+
+// SetDocumentTags replaces the tags of a document
+func (s *ElasticsearchStore) SetDocumentTags(docID int64, tags []string) error {
+	if tags == nil {
+		tags = []string{}
+	}
+	if err := s.updateDocument(docID, map[string]any{"tags": tags}); err != nil {
+		return fmt.Errorf("failed to set document tags: %w", err)
+	}
+	return nil
+}
+
+// elasticTagClauses returns the filter clauses matching documents having all of
+// the tags
+func elasticTagClauses(tags []string) []map[string]any {
+	clauses := make([]map[string]any, len(tags))
+	for i, tag := range tags {
+		clauses[i] = elasticTerm("tags", tag)
+	}
+	return clauses
+}
+
+// DocumentsWithTags returns the IDs of documents having all of the tags
+func (s *ElasticsearchStore) DocumentsWithTags(tags []string) (map[int64]bool, error) {
+	hits, err := s.search(s.documents, map[string]any{
+		"query":   elasticFilter(elasticTagClauses(tags)),
+		"size":    elasticQueryLimit,
+		"_source": []string{"doc_id"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document tags: %w", err)
+	}
+	docIDs := make(map[int64]bool, len(hits))
+	for _, hit := range hits {
+		docIDs[jsonInt(hit.Source["doc_id"])] = true
+	}
+	return docIDs, nil
+}
+
+// GetDocument retrieves a document by path
+func (s *ElasticsearchStore) GetDocument(path string) (*DocumentRecord, error) {
+	hits, err := s.search(s.documents, map[string]any{"query": elasticTerm("path", path), "size": 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	if len(hits) == 0 {
+		return nil, nil
+	}
+	doc := parseElasticDocument(hits[0].Source)
+	return &doc, nil
+}
+
+// ListDocuments retrieves all document records ordered by path
+func (s *ElasticsearchStore) ListDocuments() ([]DocumentRecord, error) {
+	var docs []DocumentRecord
+	var after []any
+	for {
+		request := map[string]any{
+			"query": elasticFilter(nil),
+			"size":  elasticPageSize,
+			"sort":  []map[string]any{{"path": "asc"}},
+		}
+		if after != nil {
+			request["search_after"] = after
+		}
+		hits, err := s.search(s.documents, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list documents: %w", err)
+		}
+		for _, hit := range hits {
+			docs = append(docs, parseElasticDocument(hit.Source))
+		}
+		if len(hits) < elasticPageSize {
+			return docs, nil
+		}
+		after = hits[len(hits)-1].Sort
+	}
+}
+
+// DeleteDocument removes a document and its chunks
+func (s *ElasticsearchStore) DeleteDocument(path string) error {
+	doc, err := s.GetDocument(path)
+	if err != nil || doc == nil {
+		return err
+	}
+	if err := s.deleteChunks(doc.ID); err != nil {
+		return err
+	}
+	if _, err := s.request(http.MethodDelete, s.documentPath("_doc", doc.ID), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	return nil
+}
+
+// deleteChunks removes the chunks of a document
+func (s *ElasticsearchStore) deleteChunks(docID int64) error {
+	path := "/" + s.chunks + "/_delete_by_query?refresh=true&conflicts=proceed"
+	if _, err := s.request(http.MethodPost, path, map[string]any{"query": elasticTerm("doc_id", docID)}, nil); err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	return nil
+}
+
+// InsertChunks replaces the chunks of a document, indexing them with bulk
+// requests
+func (s *ElasticsearchStore) InsertChunks(docID int64, chunks []Chunk) error {
+	if err := s.deleteChunks(docID); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(chunks); start += elasticBulkBatch {
+		var body bytes.Buffer
+		encoder := json.NewEncoder(&body)
+		for _, chunk := range chunks[start:min(start+elasticBulkBatch, len(chunks))] {
+			action := map[string]any{"index": map[string]any{
+				"_index": s.chunks,
+				"_id":    fmt.Sprintf("%d-%d", docID, chunk.ChunkIndex),
+			}}
+			source := map[string]any{
+				"doc_id":        docID,
+				"chunk_index":   chunk.ChunkIndex,
+				"chunk_text":    chunk.ChunkText,
+				"section_title": chunk.SectionTitle,
+				"breadcrumb":    chunk.Breadcrumb,
+				"embedding":     chunk.Embedding,
+			}
+			if err := encoder.Encode(action); err != nil {
+				return fmt.Errorf("failed to encode chunk: %w", err)
+			}
+			if err := encoder.Encode(source); err != nil {
+				return fmt.Errorf("failed to encode chunk: %w", err)
+			}
+		}
+
+		// Bulk requests succeed as a whole and report failed items
+		var resp struct {
+			Errors bool `json:"errors"`
+			Items  []map[string]struct {
+				Error *struct {
+					Reason string `json:"reason"`
+				} `json:"error"`
+			} `json:"items"`
+		}
+		if _, err := s.request(http.MethodPost, "/_bulk?refresh=true", body.Bytes(), &resp); err != nil {
+			return fmt.Errorf("failed to insert chunks: %w", err)
+		}
+		if resp.Errors {
+			for _, item := range resp.Items {
+				for _, result := range item {
+					if result.Error != nil {
+						return fmt.Errorf("failed to insert chunk: %s", result.Error.Reason)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseElasticChunk converts a chunk hit. Chunk IDs are derived from the
+// hit IDs.
+func parseElasticChunk(hit elasticHit) Chunk {
+	return Chunk{
+		ID:           keyChunkID(hit.ID),
+		DocID:        jsonInt(hit.Source["doc_id"]),
+		ChunkIndex:   int(jsonInt(hit.Source["chunk_index"])),
+		ChunkText:    jsonString(hit.Source["chunk_text"]),
+		SectionTitle: jsonString(hit.Source["section_title"]),
+		Breadcrumb:   jsonString(hit.Source["breadcrumb"]),
+	}
+}
+
+// chunkClauses returns the filter clauses restricting chunks to filter.
+// Document conditions are resolved to document IDs first. ok is false when
+// no document matches.
+func (s *ElasticsearchStore) chunkClauses(filter Filter) (clauses []map[string]any, ok bool, err error) {
+	clauses = []map[string]any{}
+	if filter.hasDocumentFilter() {
+		docClauses := elasticTagClauses(filter.Tags)
+		if filter.Source != "" {
+			docClauses = append(docClauses, elasticTerm("source", filter.Source))
+		}
+		if len(filter.Collections) > 0 {
+			docClauses = append(docClauses, map[string]any{"terms": map[string]any{"collection": filter.Collections}})
+		}
+		if filter.PathPrefix != "" {
+			docClauses = append(docClauses, map[string]any{"prefix": map[string]any{"path": filter.PathPrefix}})
+		}
+		hits, err := s.search(s.documents, map[string]any{
+			"query":   elasticFilter(docClauses),
+			"size":    elasticQueryLimit,
+			"_source": []string{"doc_id"},
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to filter documents: %w", err)
+		}
+		if len(hits) == 0 {
+			return nil, false, nil
+		}
+		docIDs := make([]int64, len(hits))
+		for i, hit := range hits {
+			docIDs[i] = jsonInt(hit.Source["doc_id"])
+		}
+		clauses = append(clauses, map[string]any{"terms": map[string]any{"doc_id": docIDs}})
+	}
+	if filter.SectionTitle != "" {
+		clauses = append(clauses, elasticTerm("section_title", filter.SectionTitle))
+	}
+	return clauses, true, nil
+}
+
+// knnSearch returns the kNN search of the k chunks nearest to
+// queryEmbedding that match the filter clauses. Elasticsearch takes it as
+// the knn section of a search request, OpenSearch as a query.
+func (s *ElasticsearchStore) knnSearch(queryEmbedding []float32, k int, clauses []map[string]any) map[string]any {
+	knn := map[string]any{"k": k}
+	if len(clauses) > 0 {
+		knn["filter"] = elasticFilter(clauses)
+	}
+	if s.openSearch {
+		knn["vector"] = queryEmbedding
+		return map[string]any{"knn": map[string]any{"embedding": knn}}
+	}
+	knn["field"] = "embedding"
+	knn["query_vector"] = queryEmbedding
+	knn["num_candidates"] = min(max(k*elasticCandidateFactor, 100), elasticQueryLimit)
+	return knn
+}
+
+// elasticKeywordQuery returns the BM25 query of chunk text and breadcrumbs for
+// query
+func elasticKeywordQuery(query string) map[string]any {
+	return map[string]any{"multi_match": map[string]any{"query": query, "fields": elasticKeywordFields}}
+}
+
+// searchChunks runs a search request on the chunks index restricted to
+// filter, built by build from the filter clauses, and converts the hit
+// scores with toScore
+func (s *ElasticsearchStore) searchChunks(limit int, filter Filter, build func(clauses []map[string]any) map[string]any, toScore func(float64) float32) ([]SearchResult, error) {
+	clauses, ok, err := s.chunkClauses(filter)
+	if err != nil || !ok {
+		return nil, err
+	}
+	request := build(clauses)
+	request["size"] = limit
+	request["_source"] = map[string]any{"excludes": []string{"embedding"}}
+	hits, err := s.search(s.chunks, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	if len(hits) == 0 {
+		return nil, nil
+	}
+
+	// Attach the documents of the found chunks
+	seen := make(map[int64]bool)
+	var docIDs []int64
+	for _, hit := range hits {
+		if id := jsonInt(hit.Source["doc_id"]); !seen[id] {
+			seen[id] = true
+			docIDs = append(docIDs, id)
+		}
+	}
+	docHits, err := s.search(s.documents, map[string]any{
+		"query": map[string]any{"terms": map[string]any{"doc_id": docIDs}},
+		"size":  len(docIDs),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+	docs := make(map[int64]DocumentRecord, len(docHits))
+	for _, hit := range docHits {
+		doc := parseElasticDocument(hit.Source)
+		docs[doc.ID] = doc
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		chunk := parseElasticChunk(hit)
+		doc, ok := docs[chunk.DocID]
+		if !ok {
+			continue
+		}
+		results = append(results, SearchResult{Chunk: chunk, Document: doc, Score: toScore(hit.Score)})
+	}
+	return results, nil
+}
+
+// vectorScore converts the score of a kNN hit into a similarity. Both
+// engines score cosine similarity c as (1 + c) / 2 and squared Euclidean
+// distance d as 1 / (1 + d).
+func (s *ElasticsearchStore) vectorScore(score float64) float32 {
+	if s.metric == MetricL2 {
+		return similarity(s.metric, math.Sqrt(max(1/score-1, 0)))
+	}
+	return similarity(s.metric, 2-2*score)
+}
+
+// Search performs semantic similarity search with the store's distance
+// metric over the chunks matching filter. Scores are similarities from 0 to
+// 1, higher is better.
+func (s *ElasticsearchStore) Search(queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error) {
+	return s.searchChunks(limit, filter, func(clauses []map[string]any) map[string]any {
+		knn := s.knnSearch(queryEmbedding, limit, clauses)
+		if s.openSearch {
+			return map[string]any{"query": knn}
+		}
+		return map[string]any{"knn": knn}
+	}, s.vectorScore)
+}
+
+// KeywordSearch performs BM25 keyword search over chunk text and
+// breadcrumbs of the chunks matching filter. Scores are from 0 to 1, higher
+// is better.
+func (s *ElasticsearchStore) KeywordSearch(query string, limit int, filter Filter) ([]SearchResult, error) {
+	if len(queryTerms(query)) == 0 {
+		return nil, nil
+	}
+	return s.searchChunks(limit, filter, func(clauses []map[string]any) map[string]any {
+		return map[string]any{"query": map[string]any{"bool": map[string]any{
+			"must":   elasticKeywordQuery(query),
+			"filter": clauses,
+		}}}
+	}, keywordScore)
+}
+
+// NativeHybridSearch ranks the chunks matching filter by the sum of their
+// kNN and BM25 scores in a single search request. Scores are the summed
+// scores divided by the best one, so the top result scores 1.
+func (s *ElasticsearchStore) NativeHybridSearch(query string, queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error) {
+	if len(queryTerms(query)) == 0 {
+		return s.Search(queryEmbedding, limit, filter)
+	}
+	var best float64
+	results, err := s.searchChunks(limit, filter, func(clauses []map[string]any) map[string]any {
+		knn := s.knnSearch(queryEmbedding, limit, clauses)
+		if s.openSearch {
+			// The kNN clause filters itself, the bool filter restricts keyword
+			// matches
+			return map[string]any{"query": map[string]any{"bool": map[string]any{
+				"should":               []map[string]any{knn, elasticKeywordQuery(query)},
+				"minimum_should_match": 1,
+				"filter":               clauses,
+			}}}
+		}
+		return map[string]any{
+			"knn": knn,
+			"query": map[string]any{"bool": map[string]any{
+				"must":   elasticKeywordQuery(query),
+				"filter": clauses,
+			}},
+		}
+	}, func(score float64) float32 {
+		// Hits are ordered by score, so the first is the best
+		if best == 0 {
+			best = score
+		}
+		return float32(score / best)
+	})
+	return results, err
+}
+
+// GetChunksByDocument retrieves all chunks for a document
+func (s *ElasticsearchStore) GetChunksByDocument(docID int64) ([]Chunk, error) {
+	hits, err := s.search(s.chunks, map[string]any{
+		"query":   elasticTerm("doc_id", docID),
+		"size":    elasticQueryLimit,
+		"sort":    []map[string]any{{"chunk_index": "asc"}},
+		"_source": map[string]any{"excludes": []string{"embedding"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks: %w", err)
+	}
+	chunks := make([]Chunk, len(hits))
+	for i, hit := range hits {
+		chunks[i] = parseElasticChunk(hit)
+	}
+	return chunks, nil
+}
+
+// GetChunkEmbeddings retrieves the embeddings of all chunks for a document
+func (s *ElasticsearchStore) GetChunkEmbeddings(docID int64) ([][]float32, error) {
+	hits, err := s.search(s.chunks, map[string]any{
+		"query":   elasticTerm("doc_id", docID),
+		"size":    elasticQueryLimit,
+		"_source": []string{"embedding"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk embeddings: %w", err)
+	}
+	embeddings := make([][]float32, len(hits))
+	for i, hit := range hits {
+		values, _ := hit.Source["embedding"].([]any)
+		embedding := make([]float32, len(values))
+		for j, v := range values {
+			embedding[j] = float32(jsonFloat(v))
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+// NeedsUpdate checks if document needs re-embedding based on content hash
+func (s *ElasticsearchStore) NeedsUpdate(path, contentHash string) (bool, error) {
+	doc, err := s.GetDocument(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to check content hash: %w", err)
+	}
+	return doc == nil || doc.ContentHash != contentHash, nil
+}
+
+// Stats reports the number of documents and chunks, the embedding
+// dimension, the size of both indices and the last indexing time
+func (s *ElasticsearchStore) Stats() (StoreStats, error) {
+	var stats StoreStats
+	var err error
+	if stats.Documents, err = s.count(s.documents, nil); err != nil {
+		return stats, fmt.Errorf("failed to count documents: %w", err)
+	}
+	if stats.Pending, err = s.count(s.documents, elasticTerm("status", StatusPending)); err != nil {
+		return stats, fmt.Errorf("failed to count documents: %w", err)
+	}
+	if stats.Failed, err = s.count(s.documents, elasticTerm("status", StatusFailed)); err != nil {
+		return stats, fmt.Errorf("failed to count documents: %w", err)
+	}
+	if stats.Chunks, err = s.count(s.chunks, nil); err != nil {
+		return stats, fmt.Errorf("failed to count chunks: %w", err)
+	}
+
+	hits, err := s.search(s.documents, map[string]any{
+		"size":    1,
+		"sort":    []map[string]any{{"updated_at": "desc"}},
+		"_source": []string{"updated_at"},
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to read last indexing time: %w", err)
+	}
+	if len(hits) > 0 {
+		stats.LastIndexed, _ = time.Parse(time.RFC3339Nano, jsonString(hits[0].Source["updated_at"]))
+	}
+
+	var sizes struct {
+		All struct {
+			Primaries struct {
+				Store struct {
+					SizeInBytes json.Number `json:"size_in_bytes"`
+				} `json:"store"`
+			} `json:"primaries"`
+		} `json:"_all"`
+	}
+	if _, err := s.request(http.MethodGet, "/"+s.documents+","+s.chunks+"/_stats/store", nil, &sizes); err != nil {
+		return stats, fmt.Errorf("failed to read index sizes: %w", err)
+	}
+	stats.SizeBytes = jsonInt(sizes.All.Primaries.Store.SizeInBytes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stats.Dimension, _, err = s.chunksLayout(); err != nil {
+		return stats, fmt.Errorf("failed to read chunks index mapping: %w", err)
+	}
+	return stats, nil
+}
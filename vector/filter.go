@@ -0,0 +1,107 @@
+package vector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Filter restricts a search to chunks of matching documents. Zero fields do
+// not restrict the search.
+type Filter struct {
+	Source       string   // Source directory name of the document
+	Collections  []string // Collections the document may be in, any of them
+	PathPrefix   string   // Prefix of the document path
+	Tags         []string // Tags the document must all have
+	SectionTitle string   // Title of the chunk's section
+}
+
+// hasDocumentFilter reports whether the filter restricts documents, as
+// opposed to only sections
+func (f Filter) hasDocumentFilter() bool {
+	return f.Source != "" || len(f.Collections) > 0 || f.PathPrefix != "" || len(f.Tags) > 0
+}
+
+// sqliteFilter returns the conditions restricting the chunks aliased c to
+// the filter, each preceded by AND, and their arguments. sqlite-vec applies
+// conditions on doc_id and section_title, which are metadata columns of the
+// chunks table, during the KNN search, so k results are found even when few
+// chunks match.
+func sqliteFilter(f Filter) (string, []any) {
+	var where strings.Builder
+	var args []any
+
+	if f.hasDocumentFilter() {
+		var docWhere []string
+		if f.Source != "" {
+			docWhere = append(docWhere, "source = ?")
+			args = append(args, f.Source)
+		}
+		if len(f.Collections) > 0 {
+			docWhere = append(docWhere, "collection IN (?"+strings.Repeat(", ?", len(f.Collections)-1)+")")
+			for _, collection := range f.Collections {
+				args = append(args, collection)
+			}
+		}
+		if f.PathPrefix != "" {
+			docWhere = append(docWhere, "substr(path, 1, length(?)) = ?")
+			args = append(args, f.PathPrefix, f.PathPrefix)
+		}
+		if len(f.Tags) > 0 {
+			docWhere = append(docWhere, `id IN (
+				SELECT doc_id FROM document_tags
+				WHERE tag IN (?`+strings.Repeat(", ?", len(f.Tags)-1)+`)
+				GROUP BY doc_id
+				HAVING COUNT(DISTINCT tag) = ?
+			)`)
+			for _, tag := range f.Tags {
+				args = append(args, tag)
+			}
+			args = append(args, len(f.Tags))
+		}
+		where.WriteString(" AND c.doc_id IN (SELECT id FROM documents WHERE " + strings.Join(docWhere, " AND ") + ")")
+	}
+
+	if f.SectionTitle != "" {
+		where.WriteString(" AND c.section_title = ?")
+		args = append(args, f.SectionTitle)
+	}
+
+	return where.String(), args
+}
+
+// postgresFilter returns the conditions restricting the chunks aliased c and
+// documents aliased d to the filter, each preceded by AND, and their
+// arguments, numbered from $next
+func postgresFilter(f Filter, next int) (string, []any) {
+	var where strings.Builder
+	var args []any
+	param := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", next+len(args)-1)
+	}
+
+	if f.Source != "" {
+		where.WriteString(" AND d.source = " + param(f.Source))
+	}
+	if len(f.Collections) > 0 {
+		where.WriteString(" AND d.collection = ANY(" + param(pq.Array(f.Collections)) + ")")
+	}
+	if f.PathPrefix != "" {
+		where.WriteString(" AND starts_with(d.path, " + param(f.PathPrefix) + ")")
+	}
+	if len(f.Tags) > 0 {
+		where.WriteString(` AND c.doc_id IN (
+			SELECT doc_id FROM document_tags
+			WHERE tag = ANY(` + param(pq.Array(f.Tags)) + `)
+			GROUP BY doc_id
+			HAVING COUNT(DISTINCT tag) = ` + param(len(f.Tags)) + `
+		)`)
+	}
+	if f.SectionTitle != "" {
+		where.WriteString(" AND c.section_title = " + param(f.SectionTitle))
+	}
+
+	return where.String(), args
+}
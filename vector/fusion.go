@@ -0,0 +1,219 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// RRFConstant is the k constant of reciprocal rank fusion. Larger values
+// flatten the contribution of top ranks.
+const RRFConstant = 60
+
+// FuseRRF merges several ranked result lists with reciprocal rank fusion and
+// removes duplicate chunks. Results are ordered by fused rank; each result
+// keeps the best (highest) score it was returned with as its Score.
+func FuseRRF(lists [][]SearchResult, limit int) []SearchResult {
+	merged, _ := fuseRRF(lists, limit)
+	return merged
+}
+
+// fuseRRF implements FuseRRF and also returns the fused score of each result
+func fuseRRF(lists [][]SearchResult, limit int) ([]SearchResult, []float64) {
+	type fused struct {
+		result SearchResult
+		score  float64
+	}
+
+	byChunk := make(map[int64]*fused)
+	var order []int64
+
+	for _, list := range lists {
+		for rank, r := range list {
+			entry, ok := byChunk[r.Chunk.ID]
+			if !ok {
+				entry = &fused{result: r}
+				byChunk[r.Chunk.ID] = entry
+				order = append(order, r.Chunk.ID)
+			} else if r.Score > entry.result.Score {
+				entry.result.Score = r.Score
+			}
+			entry.score += 1.0 / float64(RRFConstant+rank+1)
+		}
+	}
+
+	results := make([]fused, 0, len(order))
+	for _, id := range order {
+		results = append(results, *byChunk[id])
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	merged := make([]SearchResult, len(results))
+	scores := make([]float64, len(results))
+	for i, r := range results {
+		merged[i] = r.result
+		scores[i] = r.score
+	}
+	return merged, scores
+}
+
+// FuseDisjoint merges the result lists of separate stores, whose chunk IDs
+// may coincide, by interleaving them rank by rank. This is the order of
+// reciprocal rank fusion for lists without common results. Each result keeps
+// its Score, although scores of stores using other embedding models are not
+// comparable.
+func FuseDisjoint(lists [][]SearchResult, limit int) []SearchResult {
+	var merged []SearchResult
+	for rank := 0; ; rank++ {
+		added := false
+		for _, list := range lists {
+			if rank < len(list) {
+				merged = append(merged, list[rank])
+				added = true
+			}
+		}
+		if !added || (limit > 0 && len(merged) >= limit) {
+			break
+		}
+	}
+	if limit > 0 && len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// MultiSearch runs one similarity search per query embedding concurrently
+// and fuses the result lists with reciprocal rank fusion. Each result keeps
+// its best similarity as its Score. Only chunks matching filter are returned.
+func MultiSearch(store Store, queryEmbeddings [][]float32, limit int, filter Filter) ([]SearchResult, error) {
+	lists := make([][]SearchResult, len(queryEmbeddings))
+	errs := make([]error, len(queryEmbeddings))
+
+	var wg sync.WaitGroup
+	for i, embedding := range queryEmbeddings {
+		wg.Add(1)
+		go func(i int, embedding []float32) {
+			defer wg.Done()
+			lists[i], errs[i] = store.Search(embedding, limit, filter)
+		}(i, embedding)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to search: %w", err)
+		}
+	}
+
+	results := FuseRRF(lists, limit)
+	for i := range results {
+		results[i].FusionScore = results[i].Score
+	}
+	return results, nil
+}
+
+// NativeHybridStore is implemented by stores that combine similarity and
+// keyword search in a single query
+type NativeHybridStore interface {
+	// NativeHybridSearch ranks the chunks matching filter by both the query
+	// embedding and the query's keywords. Scores are from 0 to 1, higher is
+	// better.
+	NativeHybridSearch(query string, queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error)
+}
+
+// HybridMultiSearch combines similarity search with BM25 keyword search for
+// each query and fuses all result lists with reciprocal rank fusion. Keyword
+// search is skipped for stores without full-text support. Each result keeps
+// its best similarity or keyword score as its Score, as in the other search
+// modes; its FusionScore is the fused RRF score normalized to 0 to 1, where 1
+// is the top rank in every result list. Stores with native hybrid search
+// rank each query themselves and only report their fused score, which is
+// both scores of their results. Only chunks matching filter are returned.
+func HybridMultiSearch(store Store, queries []string, queryEmbeddings [][]float32, limit int, filter Filter) ([]SearchResult, error) {
+	if len(queries) != len(queryEmbeddings) {
+		return nil, fmt.Errorf("got %d queries but %d embeddings", len(queries), len(queryEmbeddings))
+	}
+	if native, ok := store.(NativeHybridStore); ok {
+		return nativeHybridMultiSearch(native, queries, queryEmbeddings, limit, filter)
+	}
+
+	// Fetch deeper candidate lists so fusion has overlap to work with
+	depth := limit * 2
+	keywordStore, hasKeyword := store.(KeywordStore)
+
+	lists := make([][]SearchResult, 2*len(queries))
+	errs := make([]error, 2*len(queries))
+
+	var wg sync.WaitGroup
+	for i := range queries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lists[2*i], errs[2*i] = store.Search(queryEmbeddings[i], depth, filter)
+		}(i)
+
+		if hasKeyword {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				lists[2*i+1], errs[2*i+1] = keywordStore.KeywordSearch(queries[i], depth, filter)
+				if errors.Is(errs[2*i+1], ErrFullTextUnavailable) {
+					errs[2*i+1] = nil
+				}
+			}(i)
+		}
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to search: %w", err)
+		}
+	}
+
+	// The best possible fused score is the top rank in every non-empty list
+	var best float64
+	for _, list := range lists {
+		if len(list) > 0 {
+			best += 1.0 / float64(RRFConstant+1)
+		}
+	}
+
+	results, scores := fuseRRF(lists, limit)
+	for i := range results {
+		results[i].FusionScore = float32(scores[i] / best)
+	}
+	return results, nil
+}
+
+// nativeHybridMultiSearch runs one native hybrid search per query
+// concurrently and fuses the result lists with reciprocal rank fusion
+func nativeHybridMultiSearch(store NativeHybridStore, queries []string, queryEmbeddings [][]float32, limit int, filter Filter) ([]SearchResult, error) {
+	lists := make([][]SearchResult, len(queries))
+	errs := make([]error, len(queries))
+
+	var wg sync.WaitGroup
+	for i := range queries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lists[i], errs[i] = store.NativeHybridSearch(queries[i], queryEmbeddings[i], limit, filter)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to search: %w", err)
+		}
+	}
+
+	return FuseRRF(lists, limit), nil
+}
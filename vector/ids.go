@@ -0,0 +1,24 @@
+package vector
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// maxDocumentID keeps document IDs within the integers JSON numbers hold
+// exactly (2^53)
+const maxDocumentID = 1<<53 - 1
+
+// newDocumentID returns a random document ID for stores that do not assign
+// them. IDs are random rather than sequential so that several processes
+// writing to the same store, such as the server and "dimandocs index", do
+// not hand out the same ID.
+func newDocumentID() int64 {
+	var b [8]byte
+	for {
+		rand.Read(b[:])
+		if id := int64(binary.BigEndian.Uint64(b[:]) & maxDocumentID); id != 0 {
+			return id
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package vector
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"strconv"
+)
+
+// jsonInt converts a JSON number decoded with UseNumber, or a numeric
+// string, to an int64
+func jsonInt(v any) int64 {
+	switch v := v.(type) {
+	case json.Number:
+		n, _ := v.Int64()
+		return n
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// jsonFloat converts a JSON number decoded with UseNumber, or a numeric
+// string, to a float64
+func jsonFloat(v any) float64 {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
+
+// jsonString converts a decoded JSON string, or "" for other values
+func jsonString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+// keyChunkID derives a positive chunk ID from the string key of a chunk in
+// stores without integer IDs
+func keyChunkID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64() & math.MaxInt64)
+}
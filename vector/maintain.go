@@ -0,0 +1,21 @@
+package vector
+
+// MaintenanceStats reports the outcome of database maintenance
+type MaintenanceStats struct {
+	OrphanChunks int   // Chunks removed because their document no longer exists
+	SizeBefore   int64 // Database size in bytes before maintenance
+	SizeAfter    int64 // Database size in bytes after maintenance
+}
+
+// Reclaimed returns the number of bytes freed by maintenance
+func (s MaintenanceStats) Reclaimed() int64 {
+	return max(s.SizeBefore-s.SizeAfter, 0)
+}
+
+// Maintainer is implemented by stores that can clean up and compact their
+// storage
+type Maintainer interface {
+	// Maintain removes chunks whose document no longer exists, compacts the
+	// database and refreshes the query planner statistics
+	Maintain() (MaintenanceStats, error)
+}
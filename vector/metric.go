@@ -0,0 +1,32 @@
+package vector
+
+// Distance metrics of similarity search
+const (
+	// MetricCosine ranks by the angle between embeddings, ignoring their length
+	MetricCosine = "cosine"
+	// MetricL2 ranks by Euclidean distance
+	MetricL2 = "l2"
+)
+
+// IsMetric reports whether metric is a supported distance metric
+func IsMetric(metric string) bool {
+	return metric == MetricCosine || metric == MetricL2
+}
+
+// similarity converts a distance of the metric into a score from 0 to 1,
+// higher is more similar. Cosine distance is one minus the cosine similarity,
+// which is clamped to 0 for opposing embeddings; L2 distances map to
+// 1 / (1 + distance).
+func similarity(metric string, distance float64) float32 {
+	if metric == MetricL2 {
+		return float32(1 / (1 + max(distance, 0)))
+	}
+	return float32(min(max(1-distance, 0), 1))
+}
+
+// keywordScore converts a keyword relevance rank, such as a BM25 score, into
+// a score from 0 to 1, higher is more relevant
+func keywordScore(rank float64) float32 {
+	rank = max(rank, 0)
+	return float32(rank / (1 + rank))
+}
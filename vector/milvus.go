@@ -0,0 +1,858 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// milvusQueryLimit is the largest number of entities a Milvus query
+	// returns, which bounds the documents the Milvus store can hold
+	milvusQueryLimit = 16384
+
+	// milvusInsertBatch is the number of chunks inserted per request, small
+	// enough that batches of large embeddings stay well below the request
+	// size limit
+	milvusInsertBatch = 100
+
+	// milvusRequestTimeout bounds every request to Milvus
+	milvusRequestTimeout = 30 * time.Second
+
+	// Maximum lengths of VarChar fields in bytes, and of the tags array
+	milvusMaxText    = 65535
+	milvusMaxPath    = 4096
+	milvusMaxError   = 8192
+	milvusMaxTags    = 256
+	milvusMaxTagSize = 256
+
+	// defaultMilvusCollection is the default prefix of collection names
+	defaultMilvusCollection = "dimandocs"
+)
+
+// milvusCollectionRegex matches a valid collection name prefix
+var milvusCollectionRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// MilvusStore implements Store using the Milvus RESTful API. Documents and
+// chunks are kept in two collections named after a prefix. Milvus has no
+// transactions: chunks of a document are replaced by deleting and inserting
+// them, so a failure midway leaves the document failed until re-indexed.
+type MilvusStore struct {
+	client     *http.Client
+	address    string // Base URL, e.g. http://localhost:19530
+	token      string // "user:password" or an API key, empty without authentication
+	database   string // Empty for the default database
+	documents  string // Collection names
+	chunks     string
+	dimension  int
+	metric     string // MetricCosine or MetricL2
+	mu         sync.Mutex
+	documentMu sync.Mutex // Serializes read-modify-write of document entities
+}
+
+func init() {
+	Register("milvus", func(cfg Config) (Store, error) {
+		address, err := cfg.RequireOption("url")
+		if err != nil {
+			return nil, err
+		}
+		collection := cfg.Option("collection", defaultMilvusCollection)
+		if !milvusCollectionRegex.MatchString(collection) {
+			return nil, fmt.Errorf("invalid milvus collection '%s': use letters, digits and underscores", collection)
+		}
+		slog.Info("Using Milvus vector store", "address", address, "collection", collection)
+		return NewMilvusStore(address, cfg.Options["token"], cfg.Options["database"], collection, cfg.Metric), nil
+	})
+}
+
+// NewMilvusStore creates a new Milvus vector store searching with the given
+// distance metric in the collections named prefix_documents and
+// prefix_chunks
+func NewMilvusStore(address, token, database, prefix, metric string) *MilvusStore {
+	return &MilvusStore{
+		client:    &http.Client{Timeout: milvusRequestTimeout},
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		database:  database,
+		documents: prefix + "_documents",
+		chunks:    prefix + "_chunks",
+		dimension: DefaultEmbeddingDimension,
+		metric:    metric,
+	}
+}
+
+// milvusResponse is the envelope of Milvus RESTful API responses
+type milvusResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// call posts a request to a Milvus RESTful API endpoint, such as
+// "entities/query", and decodes its data into out unless out is nil
+func (s *MilvusStore) call(endpoint string, request map[string]any, out any) error {
+	if s.database != "" {
+		request["dbName"] = s.database
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode milvus request: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.address+"/v2/vectordb/"+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create milvus request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach milvus: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read milvus response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("milvus %s returned status %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result milvusResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("failed to decode milvus response: %w", err)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("milvus %s failed: %s (code %d)", endpoint, result.Message, result.Code)
+	}
+	if out == nil || len(result.Data) == 0 {
+		return nil
+	}
+	// Numbers are decoded exactly, since int64 IDs exceed float precision
+	decoder := json.NewDecoder(bytes.NewReader(result.Data))
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("failed to decode milvus %s data: %w", endpoint, err)
+	}
+	return nil
+}
+
+// query returns the entities of a collection matching filter
+func (s *MilvusStore) query(collection, filter string, fields []string) ([]map[string]any, error) {
+	var rows []map[string]any
+	err := s.call("entities/query", map[string]any{
+		"collectionName": collection,
+		"filter":         filter,
+		"outputFields":   fields,
+		"limit":          milvusQueryLimit,
+	}, &rows)
+	return rows, err
+}
+
+// count returns the number of entities of a collection matching filter
+func (s *MilvusStore) count(collection, filter string) (int, error) {
+	var rows []map[string]any
+	err := s.call("entities/query", map[string]any{
+		"collectionName": collection,
+		"filter":         filter,
+		"outputFields":   []string{"count(*)"},
+	}, &rows)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	return int(jsonInt(rows[0]["count(*)"])), nil
+}
+
+// hasCollection reports whether a collection exists
+func (s *MilvusStore) hasCollection(name string) (bool, error) {
+	var data struct {
+		Has bool `json:"has"`
+	}
+	err := s.call("collections/has", map[string]any{"collectionName": name}, &data)
+	return data.Has, err
+}
+
+// milvusField describes a field of a collection
+type milvusField struct {
+	Name   string `json:"name"`
+	Params []struct {
+		Key   string `json:"key"`
+		Value any    `json:"value"`
+	} `json:"params"`
+}
+
+// milvusCollection describes the fields and indexes of a collection
+type milvusCollection struct {
+	Fields  []milvusField `json:"fields"`
+	Indexes []struct {
+		FieldName  string `json:"fieldName"`
+		MetricType string `json:"metricType"`
+	} `json:"indexes"`
+}
+
+// chunksLayout returns the embedding dimension and metric type of the chunks
+// collection, or zero values if it does not exist
+func (s *MilvusStore) chunksLayout() (int, string, error) {
+	exists, err := s.hasCollection(s.chunks)
+	if err != nil || !exists {
+		return 0, "", err
+	}
+	var desc milvusCollection
+	if err := s.call("collections/describe", map[string]any{"collectionName": s.chunks}, &desc); err != nil {
+		return 0, "", err
+	}
+	var dim int
+	for _, field := range desc.Fields {
+		if field.Name != "embedding" {
+			continue
+		}
+		for _, param := range field.Params {
+			if param.Key == "dim" {
+				dim = int(jsonInt(param.Value))
+			}
+		}
+	}
+	var metricType string
+	for _, index := range desc.Indexes {
+		if index.FieldName == "embedding" {
+			metricType = index.MetricType
+		}
+	}
+	return dim, metricType, nil
+}
+
+// metricType returns the Milvus metric type of the store's distance metric
+func (s *MilvusStore) metricType() string {
+	if s.metric == MetricL2 {
+		return "L2"
+	}
+	return "COSINE"
+}
+
+// Initialize checks the connection and creates the documents collection
+func (s *MilvusStore) Initialize() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exists, err := s.hasCollection(s.documents)
+	if err != nil {
+		return fmt.Errorf("failed to connect to milvus: %w", err)
+	}
+	if exists {
+		// Fields cannot be added to collections, so documents collections
+		// created before documents had collections are recreated
+		var desc milvusCollection
+		if err := s.call("collections/describe", map[string]any{"collectionName": s.documents}, &desc); err != nil {
+			return fmt.Errorf("failed to describe documents collection: %w", err)
+		}
+		if slices.ContainsFunc(desc.Fields, func(f milvusField) bool { return f.Name == "collection" }) {
+			return nil
+		}
+		slog.Info("Documents collection has no collection field, re-indexing all documents")
+		for _, name := range []string{s.chunks, s.documents} {
+			if err := s.call("collections/drop", map[string]any{"collectionName": name}, nil); err != nil {
+				return fmt.Errorf("failed to drop collection %s: %w", name, err)
+			}
+		}
+	}
+	return s.createDocumentsCollection()
+}
+
+// createDocumentsCollection creates the collection of document records.
+// Milvus collections need a vector field, so documents carry a constant
+// placeholder vector.
+func (s *MilvusStore) createDocumentsCollection() error {
+	err := s.call("collections/create", map[string]any{
+		"collectionName": s.documents,
+		"schema": map[string]any{
+			"autoId": false,
+			"fields": []map[string]any{
+				{"fieldName": "id", "dataType": "Int64", "isPrimary": true},
+				varcharField("path", milvusMaxPath),
+				varcharField("title", milvusMaxPath),
+				varcharField("content_hash", 128),
+				varcharField("source", milvusMaxPath),
+				varcharField("collection", milvusMaxPath),
+				varcharField("status", 32),
+				varcharField("error", milvusMaxError),
+				{"fieldName": "updated_at", "dataType": "Int64"},
+				{
+					"fieldName":         "tags",
+					"dataType":          "Array",
+					"elementDataType":   "VarChar",
+					"elementTypeParams": map[string]any{"max_capacity": milvusMaxTags, "max_length": milvusMaxTagSize},
+				},
+				{"fieldName": "placeholder", "dataType": "FloatVector", "elementTypeParams": map[string]any{"dim": 2}},
+			},
+		},
+		"indexParams": []map[string]any{
+			{"fieldName": "placeholder", "indexName": "placeholder", "metricType": "L2"},
+		},
+		"params": map[string]any{"consistencyLevel": "Strong"},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create documents collection: %w", err)
+	}
+	return nil
+}
+
+// createChunksCollection creates the collection of chunks for the store's
+// dimension, indexed for its distance metric
+func (s *MilvusStore) createChunksCollection() error {
+	err := s.call("collections/create", map[string]any{
+		"collectionName": s.chunks,
+		"schema": map[string]any{
+			"autoId": true,
+			"fields": []map[string]any{
+				{"fieldName": "id", "dataType": "Int64", "isPrimary": true},
+				{"fieldName": "doc_id", "dataType": "Int64"},
+				{"fieldName": "chunk_index", "dataType": "Int64"},
+				varcharField("chunk_text", milvusMaxText),
+				varcharField("section_title", milvusMaxPath),
+				varcharField("breadcrumb", milvusMaxPath),
+				{"fieldName": "embedding", "dataType": "FloatVector", "elementTypeParams": map[string]any{"dim": s.dimension}},
+			},
+		},
+		"indexParams": []map[string]any{
+			{"fieldName": "embedding", "indexName": "embedding", "metricType": s.metricType()},
+		},
+		"params": map[string]any{"consistencyLevel": "Strong"},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create chunks collection: %w", err)
+	}
+	return nil
+}
+
+// varcharField describes a VarChar field of at most maxLength bytes
+func varcharField(name string, maxLength int) map[string]any {
+	return map[string]any{
+		"fieldName":         name,
+		"dataType":          "VarChar",
+		"elementTypeParams": map[string]any{"max_length": maxLength},
+	}
+}
+
+// SetDimension sets the embedding dimension and recreates the collections if
+// the dimension or distance metric changed
+func (s *MilvusStore) SetDimension(dim int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	storedDim, storedMetric, err := s.chunksLayout()
+	if err != nil {
+		return fmt.Errorf("failed to describe chunks collection: %w", err)
+	}
+	s.dimension = dim
+	if storedDim == dim && storedMetric == s.metricType() {
+		return nil
+	}
+
+	if storedDim == dim {
+		slog.Info("Distance metric changed, re-indexing all documents", "metric", s.metric)
+	} else {
+		slog.Info("Embedding dimension changed, re-indexing all documents", "dimension", dim)
+	}
+	for _, name := range []string{s.chunks, s.documents} {
+		if err := s.call("collections/drop", map[string]any{"collectionName": name}, nil); err != nil {
+			return fmt.Errorf("failed to drop collection %s: %w", name, err)
+		}
+	}
+	if err := s.createDocumentsCollection(); err != nil {
+		return err
+	}
+	return s.createChunksCollection()
+}
+
+// Close releases idle connections
+func (s *MilvusStore) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// milvusDocument is a document entity with the fields not kept in
+// DocumentRecord
+type milvusDocument struct {
+	DocumentRecord
+	Source     string
+	Collection string
+	Tags       []string
+}
+
+// documentFields are the output fields of document queries
+var documentFields = []string{"id", "path", "title", "content_hash", "source", "collection", "status", "error", "updated_at", "tags"}
+
+// parseDocument converts a queried document entity
+func parseDocument(row map[string]any) milvusDocument {
+	doc := milvusDocument{
+		DocumentRecord: DocumentRecord{
+			ID:          jsonInt(row["id"]),
+			Path:        jsonString(row["path"]),
+			Title:       jsonString(row["title"]),
+			ContentHash: jsonString(row["content_hash"]),
+			UpdatedAt:   time.UnixMilli(jsonInt(row["updated_at"])),
+			Status:      jsonString(row["status"]),
+			Error:       jsonString(row["error"]),
+		},
+		Source:     jsonString(row["source"]),
+		Collection: jsonString(row["collection"]),
+	}
+	if tags, ok := row["tags"].([]any); ok {
+		for _, tag := range tags {
+			doc.Tags = append(doc.Tags, jsonString(tag))
+		}
+	}
+	return doc
+}
+
+// findDocument returns the document entity matching filter, or nil
+func (s *MilvusStore) findDocument(filter string) (*milvusDocument, error) {
+	rows, err := s.query(s.documents, filter, documentFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	doc := parseDocument(rows[0])
+	return &doc, nil
+}
+
+// saveDocument writes a whole document entity
+func (s *MilvusStore) saveDocument(doc milvusDocument) error {
+	tags := doc.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return s.call("entities/upsert", map[string]any{
+		"collectionName": s.documents,
+		"data": []map[string]any{{
+			"id":           doc.ID,
+			"path":         doc.Path,
+			"title":        truncateBytes(doc.Title, milvusMaxPath),
+			"content_hash": doc.ContentHash,
+			"source":       doc.Source,
+			"collection":   doc.Collection,
+			"status":       doc.Status,
+			"error":        truncateBytes(doc.Error, milvusMaxError),
+			"updated_at":   doc.UpdatedAt.UnixMilli(),
+			"tags":         tags,
+			"placeholder":  []float32{0, 0},
+		}},
+	}, nil)
+}
+
+// updateDocument applies update to the document with the given ID
+func (s *MilvusStore) updateDocument(docID int64, update func(doc *milvusDocument)) error {
+	s.documentMu.Lock()
+	defer s.documentMu.Unlock()
+
+	doc, err := s.findDocument(fmt.Sprintf("id == %d", docID))
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		return fmt.Errorf("document %d not found", docID)
+	}
+	update(doc)
+	return s.saveDocument(*doc)
+}
+
+// UpsertDocument inserts or updates a document record
+func (s *MilvusStore) UpsertDocument(path, title, contentHash string) (int64, error) {
+	s.documentMu.Lock()
+	defer s.documentMu.Unlock()
+
+	doc, err := s.findDocument("path == " + milvusQuote(path))
+	if err != nil {
+		return 0, err
+	}
+	if doc == nil {
+		doc = &milvusDocument{DocumentRecord: DocumentRecord{ID: newDocumentID(), Path: path}}
+	}
+	doc.Title = title
+	doc.ContentHash = contentHash
+	doc.UpdatedAt = time.Now()
+	doc.Status = documentStatus(contentHash)
+	doc.Error = ""
+	if err := s.saveDocument(*doc); err != nil {
+		return 0, fmt.Errorf("failed to upsert document: %w", err)
+	}
+	return doc.ID, nil
+}
+
+// SetDocumentSource records the source directory name and the collection
+// of a document
+func (s *MilvusStore) SetDocumentSource(docID int64, source, collection string) error {
+	err := s.updateDocument(docID, func(doc *milvusDocument) { doc.Source, doc.Collection = source, collection })
+	if err != nil {
+		return fmt.Errorf("failed to set document source: %w", err)
+	}
+	return nil
+}
+
+// SetDocumentStatus records the indexing status of a document and, for
+// failed documents, the error
+func (s *MilvusStore) SetDocumentStatus(docID int64, status, message string) error {
+	err := s.updateDocument(docID, func(doc *milvusDocument) {
+		doc.Status = status
+		doc.Error = message
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set document status: %w", err)
+	}
+	return nil
+}
+
+// SetDocumentTags replaces the tags of a document
+func (s *MilvusStore) SetDocumentTags(docID int64, tags []string) error {
+	tags = tags[:min(len(tags), milvusMaxTags)]
+	err := s.updateDocument(docID, func(doc *milvusDocument) { doc.Tags = tags })
+	if err != nil {
+		return fmt.Errorf("failed to set document tags: %w", err)
+	}
+	return nil
+}
+
+// DocumentsWithTags returns the IDs of documents having all of the tags
+func (s *MilvusStore) DocumentsWithTags(tags []string) (map[int64]bool, error) {
+	rows, err := s.query(s.documents, "array_contains_all(tags, "+milvusList(tags)+")", []string{"id"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document tags: %w", err)
+	}
+	docIDs := make(map[int64]bool, len(rows))
+	for _, row := range rows {
+		docIDs[jsonInt(row["id"])] = true
+	}
+	return docIDs, nil
+}
+
+// GetDocument retrieves a document by path
+func (s *MilvusStore) GetDocument(path string) (*DocumentRecord, error) {
+	doc, err := s.findDocument("path == " + milvusQuote(path))
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return &doc.DocumentRecord, nil
+}
+
+// listDocuments returns the document entities matching filter
+func (s *MilvusStore) listDocuments(filter string) ([]milvusDocument, error) {
+	rows, err := s.query(s.documents, filter, documentFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	if len(rows) >= milvusQueryLimit {
+		return nil, fmt.Errorf("failed to list documents: more than %d documents", milvusQueryLimit)
+	}
+	docs := make([]milvusDocument, len(rows))
+	for i, row := range rows {
+		docs[i] = parseDocument(row)
+	}
+	return docs, nil
+}
+
+// ListDocuments retrieves all document records ordered by path
+func (s *MilvusStore) ListDocuments() ([]DocumentRecord, error) {
+	entities, err := s.listDocuments("id >= 0")
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]DocumentRecord, len(entities))
+	for i, entity := range entities {
+		docs[i] = entity.DocumentRecord
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Path < docs[j].Path })
+	return docs, nil
+}
+
+// DeleteDocument removes a document and its chunks
+func (s *MilvusStore) DeleteDocument(path string) error {
+	doc, err := s.GetDocument(path)
+	if err != nil || doc == nil {
+		return err
+	}
+	if err := s.deleteChunks(doc.ID); err != nil {
+		return err
+	}
+	err = s.call("entities/delete", map[string]any{
+		"collectionName": s.documents,
+		"filter":         fmt.Sprintf("id == %d", doc.ID),
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	return nil
+}
+
+// deleteChunks removes the chunks of a document
+func (s *MilvusStore) deleteChunks(docID int64) error {
+	err := s.call("entities/delete", map[string]any{
+		"collectionName": s.chunks,
+		"filter":         fmt.Sprintf("doc_id == %d", docID),
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	return nil
+}
+
+// InsertChunks replaces the chunks of a document, inserting them in batches
+func (s *MilvusStore) InsertChunks(docID int64, chunks []Chunk) error {
+	if err := s.deleteChunks(docID); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(chunks); start += milvusInsertBatch {
+		batch := chunks[start:min(start+milvusInsertBatch, len(chunks))]
+		data := make([]map[string]any, len(batch))
+		for i, chunk := range batch {
+			data[i] = map[string]any{
+				"doc_id":        docID,
+				"chunk_index":   chunk.ChunkIndex,
+				"chunk_text":    truncateBytes(chunk.ChunkText, milvusMaxText),
+				"section_title": truncateBytes(chunk.SectionTitle, milvusMaxPath),
+				"breadcrumb":    truncateBytes(chunk.Breadcrumb, milvusMaxPath),
+				"embedding":     chunk.Embedding,
+			}
+		}
+		err := s.call("entities/insert", map[string]any{"collectionName": s.chunks, "data": data}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to insert chunks: %w", err)
+		}
+	}
+	return nil
+}
+
+// chunkFields are the output fields of chunk queries and searches
+var chunkFields = []string{"id", "doc_id", "chunk_index", "chunk_text", "section_title", "breadcrumb"}
+
+// parseChunk converts a queried or found chunk entity
+func parseChunk(row map[string]any) Chunk {
+	return Chunk{
+		ID:           jsonInt(row["id"]),
+		DocID:        jsonInt(row["doc_id"]),
+		ChunkIndex:   int(jsonInt(row["chunk_index"])),
+		ChunkText:    jsonString(row["chunk_text"]),
+		SectionTitle: jsonString(row["section_title"]),
+		Breadcrumb:   jsonString(row["breadcrumb"]),
+	}
+}
+
+// chunkFilter returns the Milvus filter expression restricting chunks to
+// filter. Document conditions are resolved to document IDs first, path
+// prefixes in Go since Milvus patterns have wildcards. ok is false when no
+// document matches.
+func (s *MilvusStore) chunkFilter(filter Filter) (expr string, ok bool, err error) {
+	var conditions []string
+	if filter.hasDocumentFilter() {
+		docFilter := []string{"id >= 0"}
+		if filter.Source != "" {
+			docFilter = append(docFilter, "source == "+milvusQuote(filter.Source))
+		}
+		if len(filter.Collections) > 0 {
+			docFilter = append(docFilter, "collection in "+milvusList(filter.Collections))
+		}
+		if len(filter.Tags) > 0 {
+			docFilter = append(docFilter, "array_contains_all(tags, "+milvusList(filter.Tags)+")")
+		}
+		rows, err := s.query(s.documents, strings.Join(docFilter, " && "), []string{"id", "path"})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to filter documents: %w", err)
+		}
+		var ids []string
+		for _, row := range rows {
+			if strings.HasPrefix(jsonString(row["path"]), filter.PathPrefix) {
+				ids = append(ids, strconv.FormatInt(jsonInt(row["id"]), 10))
+			}
+		}
+		if len(ids) == 0 {
+			return "", false, nil
+		}
+		conditions = append(conditions, "doc_id in ["+strings.Join(ids, ", ")+"]")
+	}
+	if filter.SectionTitle != "" {
+		conditions = append(conditions, "section_title == "+milvusQuote(filter.SectionTitle))
+	}
+	return strings.Join(conditions, " && "), true, nil
+}
+
+// Search performs semantic similarity search with the store's distance
+// metric over the chunks matching filter. Scores are similarities from 0 to
+// 1, higher is better.
+func (s *MilvusStore) Search(queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error) {
+	expr, ok, err := s.chunkFilter(filter)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var hits []map[string]any
+	err = s.call("entities/search", map[string]any{
+		"collectionName": s.chunks,
+		"data":           [][]float32{queryEmbedding},
+		"annsField":      "embedding",
+		"filter":         expr,
+		"limit":          limit,
+		"outputFields":   chunkFields,
+		"searchParams":   map[string]any{"metricType": s.metricType()},
+	}, &hits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	if len(hits) == 0 {
+		return nil, nil
+	}
+
+	// Attach the documents of the found chunks
+	docIDs := make(map[int64]bool)
+	for _, hit := range hits {
+		docIDs[jsonInt(hit["doc_id"])] = true
+	}
+	ids := make([]string, 0, len(docIDs))
+	for id := range docIDs {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	docs, err := s.listDocuments("id in [" + strings.Join(ids, ", ") + "]")
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]DocumentRecord, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc.DocumentRecord
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		chunk := parseChunk(hit)
+		doc, ok := byID[chunk.DocID]
+		if !ok {
+			continue
+		}
+		// Milvus reports cosine similarity and squared Euclidean distance
+		distance := jsonFloat(hit["distance"])
+		if s.metric == MetricL2 {
+			distance = math.Sqrt(max(distance, 0))
+		} else {
+			distance = 1 - distance
+		}
+		results = append(results, SearchResult{Chunk: chunk, Document: doc, Score: similarity(s.metric, distance)})
+	}
+	return results, nil
+}
+
+// GetChunksByDocument retrieves all chunks for a document
+func (s *MilvusStore) GetChunksByDocument(docID int64) ([]Chunk, error) {
+	rows, err := s.query(s.chunks, fmt.Sprintf("doc_id == %d", docID), chunkFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks: %w", err)
+	}
+	chunks := make([]Chunk, len(rows))
+	for i, row := range rows {
+		chunks[i] = parseChunk(row)
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].ChunkIndex < chunks[j].ChunkIndex })
+	return chunks, nil
+}
+
+// GetChunkEmbeddings retrieves the embeddings of all chunks for a document
+func (s *MilvusStore) GetChunkEmbeddings(docID int64) ([][]float32, error) {
+	rows, err := s.query(s.chunks, fmt.Sprintf("doc_id == %d", docID), []string{"embedding"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk embeddings: %w", err)
+	}
+	embeddings := make([][]float32, 0, len(rows))
+	for _, row := range rows {
+		values, _ := row["embedding"].([]any)
+		embedding := make([]float32, len(values))
+		for i, v := range values {
+			embedding[i] = float32(jsonFloat(v))
+		}
+		embeddings = append(embeddings, embedding)
+	}
+	return embeddings, nil
+}
+
+// NeedsUpdate checks if document needs re-embedding based on content hash
+func (s *MilvusStore) NeedsUpdate(path, contentHash string) (bool, error) {
+	doc, err := s.GetDocument(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to check content hash: %w", err)
+	}
+	return doc == nil || doc.ContentHash != contentHash, nil
+}
+
+// Stats reports the number of documents and chunks, the embedding dimension
+// and the last indexing time. Milvus does not report the size of its
+// collections, so SizeBytes is 0.
+func (s *MilvusStore) Stats() (StoreStats, error) {
+	var stats StoreStats
+	docs, err := s.listDocuments("id >= 0")
+	if err != nil {
+		return stats, err
+	}
+	stats.Documents = len(docs)
+	for _, doc := range docs {
+		switch doc.Status {
+		case StatusPending:
+			stats.Pending++
+		case StatusFailed:
+			stats.Failed++
+		}
+		if doc.UpdatedAt.After(stats.LastIndexed) {
+			stats.LastIndexed = doc.UpdatedAt
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stats.Dimension, _, err = s.chunksLayout(); err != nil {
+		return stats, fmt.Errorf("failed to describe chunks collection: %w", err)
+	}
+	if stats.Dimension > 0 {
+		if stats.Chunks, err = s.count(s.chunks, ""); err != nil {
+			return stats, fmt.Errorf("failed to count chunks: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// milvusQuote formats a string literal for a Milvus filter expression
+func milvusQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// milvusList formats strings as a list literal for a Milvus filter
+// expression
+func milvusList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = milvusQuote(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// truncateBytes shortens s to at most n bytes without splitting a character,
+// as Milvus rejects VarChar values longer than their field
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
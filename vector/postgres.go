@@ -0,0 +1,778 @@
+package vector
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// maxIndexedDimension is the largest dimension pgvector can build an HNSW
+	// index for. Larger embeddings are searched exactly.
+	maxIndexedDimension = 2000
+
+	// schemaLockID is the advisory lock held while creating the schema, so
+	// several instances can start against the same database
+	schemaLockID = 7303725
+
+	// postgresInsertBatch is the number of chunks inserted per statement,
+	// six parameters each, well below the limit of 65535 parameters
+	postgresInsertBatch = 200
+)
+
+// PostgresStore implements Store using PostgreSQL with the pgvector extension.
+// Unlike SQLiteStore it can be shared by several application instances.
+type PostgresStore struct {
+	db        *sql.DB
+	dsn       string
+	dimension int
+	metric    string // MetricCosine or MetricL2
+	mu        sync.RWMutex
+}
+
+func init() {
+	open := func(cfg Config) (Store, error) {
+		dsn, err := cfg.RequireOption("url")
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Using PostgreSQL vector store (pgvector)")
+		return NewPostgresStore(dsn, cfg.Metric), nil
+	}
+	Register("postgres", open)
+	Register("pgvector", open)
+}
+
+// NewPostgresStore creates a new PostgreSQL vector store searching with the
+// given distance metric
+func NewPostgresStore(dsn, metric string) *PostgresStore {
+	return &PostgresStore{
+		dsn:       dsn,
+		dimension: DefaultEmbeddingDimension,
+		metric:    metric,
+	}
+}
+
+// Initialize connects to the database and creates the schema
+func (s *PostgresStore) Initialize() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := sql.Open("postgres", s.dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	s.db = db
+
+	return s.withSchemaLock(func(tx *sql.Tx) error {
+		statements := []struct {
+			sql  string
+			what string
+		}{
+			{`CREATE EXTENSION IF NOT EXISTS vector`, "pgvector extension"},
+			{`
+				CREATE TABLE IF NOT EXISTS metadata (
+					key TEXT PRIMARY KEY,
+					value TEXT NOT NULL
+				)
+			`, "metadata table"},
+			{`
+				CREATE TABLE IF NOT EXISTS documents (
+					id BIGSERIAL PRIMARY KEY,
+					path TEXT UNIQUE NOT NULL,
+					title TEXT NOT NULL,
+					content_hash TEXT NOT NULL,
+					updated_at TIMESTAMPTZ DEFAULT now(),
+					source TEXT NOT NULL DEFAULT '',
+					collection TEXT NOT NULL DEFAULT '',
+					status TEXT NOT NULL DEFAULT 'embedded',
+					error TEXT NOT NULL DEFAULT ''
+				)
+			`, "documents table"},
+			{`ALTER TABLE documents ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT ''`, "document source column"},
+			{`ALTER TABLE documents ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'embedded'`, "document status column"},
+			{`ALTER TABLE documents ADD COLUMN IF NOT EXISTS error TEXT NOT NULL DEFAULT ''`, "document error column"},
+			{`ALTER TABLE documents ADD COLUMN IF NOT EXISTS collection TEXT NOT NULL DEFAULT ''`, "document collection column"},
+			{`
+				CREATE TABLE IF NOT EXISTS summaries (
+					content_hash TEXT PRIMARY KEY,
+					summary TEXT NOT NULL,
+					created_at TIMESTAMPTZ DEFAULT now()
+				)
+			`, "summaries table"},
+			{`
+				CREATE TABLE IF NOT EXISTS document_tags (
+					doc_id BIGINT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+					tag TEXT NOT NULL,
+					PRIMARY KEY (doc_id, tag)
+				)
+			`, "document tags table"},
+			{`CREATE INDEX IF NOT EXISTS idx_document_tags_tag ON document_tags(tag)`, "tag index"},
+		}
+		for _, stmt := range statements {
+			if _, err := tx.Exec(stmt.sql); err != nil {
+				return fmt.Errorf("failed to create %s: %w", stmt.what, err)
+			}
+		}
+		return createPostgresChunksTable(tx, s.dimension, s.metric)
+	})
+}
+
+// withSchemaLock runs fn in a transaction holding the schema advisory lock
+func (s *PostgresStore) withSchemaLock(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", schemaLockID); err != nil {
+		return fmt.Errorf("failed to acquire schema lock: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit schema changes: %w", err)
+	}
+	return nil
+}
+
+// createPostgresChunksTable creates the chunks table for the given dimension,
+// indexed for the given distance metric
+func createPostgresChunksTable(tx *sql.Tx, dim int, metric string) error {
+	_, err := tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS chunks (
+			id BIGSERIAL PRIMARY KEY,
+			doc_id BIGINT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+			chunk_index INTEGER NOT NULL,
+			chunk_text TEXT NOT NULL,
+			section_title TEXT NOT NULL DEFAULT '',
+			breadcrumb TEXT NOT NULL DEFAULT '',
+			embedding vector(%d) NOT NULL,
+			tsv tsvector GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', section_title), 'A') ||
+				setweight(to_tsvector('simple', chunk_text), 'B')
+			) STORED
+		)
+	`, dim))
+	if err != nil {
+		return fmt.Errorf("failed to create chunks table: %w", err)
+	}
+
+	// Tables created before breadcrumbs were stored get the column on startup;
+	// their chunks have no breadcrumb until re-indexed
+	if _, err := tx.Exec(`ALTER TABLE chunks ADD COLUMN IF NOT EXISTS breadcrumb TEXT NOT NULL DEFAULT ''`); err != nil {
+		return fmt.Errorf("failed to add breadcrumb column: %w", err)
+	}
+
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_chunks_doc_id ON chunks(doc_id)`); err != nil {
+		return fmt.Errorf("failed to create chunk document index: %w", err)
+	}
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_chunks_tsv ON chunks USING GIN (tsv)`); err != nil {
+		return fmt.Errorf("failed to create full-text index: %w", err)
+	}
+
+	if dim <= maxIndexedDimension {
+		opclass := "vector_cosine_ops"
+		if metric == MetricL2 {
+			opclass = "vector_l2_ops"
+		}
+		_, err = tx.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_chunks_embedding ON chunks USING hnsw (embedding %s)`, opclass))
+		if err != nil {
+			return fmt.Errorf("failed to create vector index: %w", err)
+		}
+	} else {
+		slog.Info("Embedding dimension exceeds pgvector index limit, using exact search", "dimension", dim, "limit", maxIndexedDimension)
+	}
+
+	return nil
+}
+
+// SetDimension sets the embedding dimension and recreates the chunks table if needed
+func (s *PostgresStore) SetDimension(dim int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.withSchemaLock(func(tx *sql.Tx) error {
+		// Indexes built before the metric was stored use L2 distance
+		var stored string
+		storedMetric := MetricL2
+		err := tx.QueryRow("SELECT value FROM metadata WHERE key = 'dimension'").Scan(&stored)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to read dimension: %w", err)
+		}
+		if err := tx.QueryRow("SELECT value FROM metadata WHERE key = 'metric'").Scan(&storedMetric); err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to read distance metric: %w", err)
+		}
+		if err == nil && stored == strconv.Itoa(dim) && storedMetric == s.metric {
+			s.dimension = dim
+			return nil
+		}
+
+		if err == nil && stored == strconv.Itoa(dim) {
+			slog.Info("Distance metric changed, re-indexing all documents", "metric", s.metric)
+		} else {
+			slog.Info("Embedding dimension changed, re-indexing all documents", "dimension", dim)
+		}
+		s.dimension = dim
+
+		if _, err := tx.Exec("DROP TABLE IF EXISTS chunks"); err != nil {
+			return fmt.Errorf("failed to drop chunks table: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM documents"); err != nil {
+			return fmt.Errorf("failed to clear documents table: %w", err)
+		}
+		if err := createPostgresChunksTable(tx, dim, s.metric); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO metadata (key, value) VALUES ('dimension', $1)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value
+		`, strconv.Itoa(dim))
+		if err != nil {
+			return fmt.Errorf("failed to store dimension: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO metadata (key, value) VALUES ('metric', $1)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value
+		`, s.metric)
+		if err != nil {
+			return fmt.Errorf("failed to store distance metric: %w", err)
+		}
+		return nil
+	})
+}
+
+// Close closes the database connection
+func (s *PostgresStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+// UpsertDocument inserts or updates a document record
+func (s *PostgresStore) UpsertDocument(path, title, contentHash string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`
+		INSERT INTO documents (path, title, content_hash, updated_at, status, error)
+		VALUES ($1, $2, $3, now(), $4, '')
+		ON CONFLICT (path) DO UPDATE SET
+			title = excluded.title,
+			content_hash = excluded.content_hash,
+			updated_at = now(),
+			status = excluded.status,
+			error = ''
+		RETURNING id
+	`, path, title, contentHash, documentStatus(contentHash)).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to upsert document: %w", err)
+	}
+	return id, nil
+}
+
+// SetDocumentSource records the source directory name and the collection
+// of a document
+func (s *PostgresStore) SetDocumentSource(docID int64, source, collection string) error {
+	if _, err := s.db.Exec("UPDATE documents SET source = $1, collection = $2 WHERE id = $3", source, collection, docID); err != nil {
+		return fmt.Errorf("failed to set document source: %w", err)
+	}
+	return nil
+}
+
+// SetDocumentStatus records the indexing status of a document and, for
+// failed documents, the error
+func (s *PostgresStore) SetDocumentStatus(docID int64, status, message string) error {
+	if _, err := s.db.Exec("UPDATE documents SET status = $1, error = $2 WHERE id = $3", status, message, docID); err != nil {
+		return fmt.Errorf("failed to set document status: %w", err)
+	}
+	return nil
+}
+
+// GetDocument retrieves a document by path
+func (s *PostgresStore) GetDocument(path string) (*DocumentRecord, error) {
+	var doc DocumentRecord
+	err := s.db.QueryRow(`
+		SELECT id, path, title, content_hash, updated_at, status, error
+		FROM documents WHERE path = $1
+	`, path).Scan(&doc.ID, &doc.Path, &doc.Title, &doc.ContentHash, &doc.UpdatedAt, &doc.Status, &doc.Error)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	return &doc, nil
+}
+
+// ListDocuments retrieves all document records ordered by path
+func (s *PostgresStore) ListDocuments() ([]DocumentRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT id, path, title, content_hash, updated_at, status, error
+		FROM documents ORDER BY path
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []DocumentRecord
+	for rows.Next() {
+		var doc DocumentRecord
+		if err := rows.Scan(&doc.ID, &doc.Path, &doc.Title, &doc.ContentHash, &doc.UpdatedAt, &doc.Status, &doc.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// DeleteDocument removes a document and its chunks
+func (s *PostgresStore) DeleteDocument(path string) error {
+	// Chunks are removed by ON DELETE CASCADE
+	if _, err := s.db.Exec("DELETE FROM documents WHERE path = $1", path); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	return nil
+}
+
+// InsertChunks replaces the chunks of a document in a single transaction,
+// inserting them in batches of multi-row statements
+func (s *PostgresStore) InsertChunks(docID int64, chunks []Chunk) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM chunks WHERE doc_id = $1", docID); err != nil {
+		return fmt.Errorf("failed to delete existing chunks: %w", err)
+	}
+
+	for start := 0; start < len(chunks); start += postgresInsertBatch {
+		batch := chunks[start:min(start+postgresInsertBatch, len(chunks))]
+
+		var query strings.Builder
+		query.WriteString("INSERT INTO chunks (doc_id, chunk_index, chunk_text, section_title, breadcrumb, embedding) VALUES ")
+		args := make([]any, 0, len(batch)*6)
+		for i, chunk := range batch {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d::vector)", n+1, n+2, n+3, n+4, n+5, n+6)
+			args = append(args, docID, chunk.ChunkIndex, chunk.ChunkText, chunk.SectionTitle, chunk.Breadcrumb, vectorLiteral(chunk.Embedding))
+		}
+		if _, err := tx.Exec(query.String(), args...); err != nil {
+			return fmt.Errorf("failed to insert chunks: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit chunks: %w", err)
+	}
+	return nil
+}
+
+// Search performs semantic similarity search with the store's distance
+// metric over the chunks matching filter. Scores are similarities from 0 to
+// 1, higher is better.
+func (s *PostgresStore) Search(queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error) {
+	operator := "<=>"
+	if s.metric == MetricL2 {
+		operator = "<->"
+	}
+	where, filterArgs := postgresFilter(filter, 3)
+	args := append([]any{vectorLiteral(queryEmbedding), limit}, filterArgs...)
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT
+			c.id,
+			c.doc_id,
+			c.chunk_index,
+			c.chunk_text,
+			c.section_title,
+			c.breadcrumb,
+			c.embedding %s $1::vector AS distance,
+			d.id,
+			d.path,
+			d.title,
+			d.content_hash,
+			d.updated_at
+		FROM chunks c
+		JOIN documents d ON c.doc_id = d.id
+		WHERE TRUE%s
+		ORDER BY distance
+		LIMIT $2
+	`, operator, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPostgresResults(rows, func(distance float64) float32 {
+		return similarity(s.metric, distance)
+	})
+}
+
+// KeywordSearch performs full-text search over chunk text and section titles
+// of the chunks matching filter. Scores are from 0 to 1, higher is better.
+func (s *PostgresStore) KeywordSearch(query string, limit int, filter Filter) ([]SearchResult, error) {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	where, filterArgs := postgresFilter(filter, 3)
+	args := append([]any{strings.Join(terms, " | "), limit}, filterArgs...)
+
+	rows, err := s.db.Query(`
+		SELECT
+			c.id,
+			c.doc_id,
+			c.chunk_index,
+			c.chunk_text,
+			c.section_title,
+			c.breadcrumb,
+			ts_rank_cd(c.tsv, q) AS score,
+			d.id,
+			d.path,
+			d.title,
+			d.content_hash,
+			d.updated_at
+		FROM chunks c
+		JOIN documents d ON c.doc_id = d.id,
+			to_tsquery('simple', $1) q
+		WHERE c.tsv @@ q`+where+`
+		ORDER BY score DESC
+		LIMIT $2
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search keywords: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPostgresResults(rows, keywordScore)
+}
+
+// SetDocumentTags replaces the tags of a document
+func (s *PostgresStore) SetDocumentTags(docID int64, tags []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM document_tags WHERE doc_id = $1", docID); err != nil {
+		return fmt.Errorf("failed to delete document tags: %w", err)
+	}
+	for _, tag := range tags {
+		_, err := tx.Exec("INSERT INTO document_tags (doc_id, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING", docID, tag)
+		if err != nil {
+			return fmt.Errorf("failed to insert document tag: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit document tags: %w", err)
+	}
+	return nil
+}
+
+// DocumentsWithTags returns the IDs of documents having all of the tags
+func (s *PostgresStore) DocumentsWithTags(tags []string) (map[int64]bool, error) {
+	rows, err := s.db.Query(`
+		SELECT doc_id FROM document_tags
+		WHERE tag = ANY($1)
+		GROUP BY doc_id
+		HAVING COUNT(DISTINCT tag) = $2
+	`, pq.Array(tags), len(tags))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document tags: %w", err)
+	}
+	defer rows.Close()
+
+	docIDs := make(map[int64]bool)
+	for rows.Next() {
+		var docID int64
+		if err := rows.Scan(&docID); err != nil {
+			return nil, fmt.Errorf("failed to scan document id: %w", err)
+		}
+		docIDs[docID] = true
+	}
+	return docIDs, rows.Err()
+}
+
+// Stats reports the number of documents and chunks, the embedding
+// dimension, the size of the store's tables and the last indexing time
+func (s *PostgresStore) Stats() (StoreStats, error) {
+	var stats StoreStats
+	if err := s.db.QueryRow(documentCountsQuery).Scan(&stats.Documents, &stats.Pending, &stats.Failed); err != nil {
+		return stats, fmt.Errorf("failed to count documents: %w", err)
+	}
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM chunks").Scan(&stats.Chunks); err != nil {
+		return stats, fmt.Errorf("failed to count chunks: %w", err)
+	}
+	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = 'dimension'").Scan(&stats.Dimension)
+	if err != nil && err != sql.ErrNoRows {
+		return stats, fmt.Errorf("failed to read dimension: %w", err)
+	}
+
+	var lastIndexed sql.NullTime
+	if err := s.db.QueryRow("SELECT MAX(updated_at) FROM documents").Scan(&lastIndexed); err != nil {
+		return stats, fmt.Errorf("failed to read last indexing time: %w", err)
+	}
+	stats.LastIndexed = lastIndexed.Time
+
+	if stats.SizeBytes, err = s.size(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// Maintain removes chunks whose document no longer exists, then vacuums and
+// analyzes the tables. Chunks are normally removed with their document by
+// ON DELETE CASCADE, and plain VACUUM makes space reusable without returning
+// it to the operating system, so the reclaimed size is often small.
+func (s *PostgresStore) Maintain() (MaintenanceStats, error) {
+	var stats MaintenanceStats
+	var err error
+	if stats.SizeBefore, err = s.size(); err != nil {
+		return stats, err
+	}
+
+	result, err := s.db.Exec("DELETE FROM chunks WHERE doc_id NOT IN (SELECT id FROM documents)")
+	if err != nil {
+		return stats, fmt.Errorf("failed to delete orphan chunks: %w", err)
+	}
+	orphans, err := result.RowsAffected()
+	if err != nil {
+		return stats, fmt.Errorf("failed to count orphan chunks: %w", err)
+	}
+	stats.OrphanChunks = int(orphans)
+
+	if _, err := s.db.Exec("VACUUM ANALYZE documents, chunks, document_tags, summaries, metadata"); err != nil {
+		return stats, fmt.Errorf("failed to vacuum tables: %w", err)
+	}
+
+	if stats.SizeAfter, err = s.size(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// size returns the size of the store's tables and indexes in bytes
+func (s *PostgresStore) size() (int64, error) {
+	var size int64
+	err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(pg_total_relation_size(c.oid)), 0)::BIGINT
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema()
+			AND c.relkind = 'r'
+			AND c.relname IN ('documents', 'chunks', 'document_tags', 'summaries', 'metadata')
+	`).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get database size: %w", err)
+	}
+	return size, nil
+}
+
+// HybridSearch fuses similarity search and keyword search with reciprocal
+// rank fusion. Scores are normalized RRF scores from 0 to 1, higher is better.
+// Only chunks matching filter are searched.
+func (s *PostgresStore) HybridSearch(query string, queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error) {
+	return HybridMultiSearch(s, []string{query}, [][]float32{queryEmbedding}, limit, filter)
+}
+
+// scanPostgresResults reads search result rows, converting the raw score
+// column with toScore
+func scanPostgresResults(rows *sql.Rows, toScore func(float64) float32) ([]SearchResult, error) {
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		var score float64
+		err := rows.Scan(
+			&result.Chunk.ID,
+			&result.Chunk.DocID,
+			&result.Chunk.ChunkIndex,
+			&result.Chunk.ChunkText,
+			&result.Chunk.SectionTitle,
+			&result.Chunk.Breadcrumb,
+			&score,
+			&result.Document.ID,
+			&result.Document.Path,
+			&result.Document.Title,
+			&result.Document.ContentHash,
+			&result.Document.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan result: %w", err)
+		}
+		result.Score = toScore(score)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+	return results, nil
+}
+
+// GetChunksByDocument retrieves all chunks for a document
+func (s *PostgresStore) GetChunksByDocument(docID int64) ([]Chunk, error) {
+	rows, err := s.db.Query(`
+		SELECT id, doc_id, chunk_index, chunk_text, section_title, breadcrumb
+		FROM chunks
+		WHERE doc_id = $1
+		ORDER BY chunk_index
+	`, docID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []Chunk
+	for rows.Next() {
+		var chunk Chunk
+		if err := rows.Scan(&chunk.ID, &chunk.DocID, &chunk.ChunkIndex, &chunk.ChunkText, &chunk.SectionTitle, &chunk.Breadcrumb); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, nil
+}
+
+// GetChunkEmbeddings retrieves the embeddings of all chunks for a document
+func (s *PostgresStore) GetChunkEmbeddings(docID int64) ([][]float32, error) {
+	rows, err := s.db.Query(`SELECT embedding::text FROM chunks WHERE doc_id = $1`, docID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var embeddings [][]float32
+	for rows.Next() {
+		var literal string
+		if err := rows.Scan(&literal); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk embedding: %w", err)
+		}
+		embedding, err := parseVectorLiteral(literal)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, embedding)
+	}
+
+	return embeddings, nil
+}
+
+// NeedsUpdate checks if document needs re-embedding based on content hash
+func (s *PostgresStore) NeedsUpdate(path, contentHash string) (bool, error) {
+	var existingHash string
+	err := s.db.QueryRow("SELECT content_hash FROM documents WHERE path = $1", path).Scan(&existingHash)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check content hash: %w", err)
+	}
+
+	return existingHash != contentHash, nil
+}
+
+// GetMetadata returns the value stored for a key, if any
+func (s *PostgresStore) GetMetadata(key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = $1", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	return value, true, nil
+}
+
+// SetMetadata stores a value for a key
+func (s *PostgresStore) SetMetadata(key, value string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO metadata (key, value) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value
+	`, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to store metadata: %w", err)
+	}
+	return nil
+}
+
+// GetSummary returns the cached summary for a content hash, if any
+func (s *PostgresStore) GetSummary(contentHash string) (string, bool, error) {
+	var summary string
+	err := s.db.QueryRow("SELECT summary FROM summaries WHERE content_hash = $1", contentHash).Scan(&summary)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get summary: %w", err)
+	}
+	return summary, true, nil
+}
+
+// SaveSummary caches a summary for a content hash
+func (s *PostgresStore) SaveSummary(contentHash, summary string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO summaries (content_hash, summary) VALUES ($1, $2)
+		ON CONFLICT (content_hash) DO UPDATE SET summary = excluded.summary
+	`, contentHash, summary)
+	if err != nil {
+		return fmt.Errorf("failed to save summary: %w", err)
+	}
+	return nil
+}
+
+// vectorLiteral formats an embedding as a pgvector text literal
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(f), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// parseVectorLiteral parses the pgvector text representation of a vector
+func parseVectorLiteral(literal string) ([]float32, error) {
+	literal = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(literal), "["), "]")
+	if literal == "" {
+		return nil, nil
+	}
+	fields := strings.Split(literal, ",")
+	v := make([]float32, len(fields))
+	for i, field := range fields {
+		f, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse vector: %w", err)
+		}
+		v[i] = float32(f)
+	}
+	return v, nil
+}
@@ -0,0 +1,111 @@
+package vector
+
+import "math"
+
+// Precisions of embeddings stored in the SQLite vector index
+const (
+	// QuantizationFloat stores 32-bit floats
+	QuantizationFloat = "float"
+	// QuantizationInt8 stores one signed byte per dimension, 4x smaller
+	QuantizationInt8 = "int8"
+	// QuantizationBit stores one bit per dimension, 32x smaller
+	QuantizationBit = "bit"
+)
+
+// rescoreOversampling is how many times more candidates than requested a
+// quantized search retrieves to rescore with full-precision embeddings
+const rescoreOversampling = 8
+
+// IsQuantization reports whether quantization is a supported precision
+func IsQuantization(quantization string) bool {
+	switch quantization {
+	case QuantizationFloat, QuantizationInt8, QuantizationBit:
+		return true
+	}
+	return false
+}
+
+// quantize encodes an embedding in the stored precision. int8 maps values
+// from -1 to 1, the range of normalized embeddings, onto -127 to 127 and
+// clamps values outside it; bit keeps whether each value is positive,
+// least significant bit first as sqlite-vec expects.
+func quantize(quantization string, embedding []float32) []byte {
+	switch quantization {
+	case QuantizationInt8:
+		blob := make([]byte, len(embedding))
+		for i, v := range embedding {
+			q := math.Round(float64(v) * 127)
+			blob[i] = byte(int8(min(max(q, -127), 127)))
+		}
+		return blob
+	case QuantizationBit:
+		blob := make([]byte, (len(embedding)+7)/8)
+		for i, v := range embedding {
+			if v > 0 {
+				blob[i/8] |= 1 << (i % 8)
+			}
+		}
+		return blob
+	default:
+		return float32SliceToBlob(embedding)
+	}
+}
+
+// dequantize approximates the embedding encoded in a stored blob. Bits
+// become 1 or -1, which preserves the angles between embeddings well enough
+// to rank by.
+func dequantize(quantization string, blob []byte) []float32 {
+	switch quantization {
+	case QuantizationInt8:
+		embedding := make([]float32, len(blob))
+		for i, b := range blob {
+			embedding[i] = float32(int8(b)) / 127
+		}
+		return embedding
+	case QuantizationBit:
+		embedding := make([]float32, len(blob)*8)
+		for i := range embedding {
+			embedding[i] = -1
+			if blob[i/8]&(1<<(i%8)) != 0 {
+				embedding[i] = 1
+			}
+		}
+		return embedding
+	default:
+		return blobToFloat32Slice(blob)
+	}
+}
+
+// decodeEmbedding decodes an embedding of dim dimensions stored in any
+// precision, telling them apart by their size
+func decodeEmbedding(blob []byte, dim int) []float32 {
+	switch len(blob) {
+	case dim:
+		return dequantize(QuantizationInt8, blob)
+	case (dim + 7) / 8:
+		return dequantize(QuantizationBit, blob)[:dim]
+	default:
+		return blobToFloat32Slice(blob)
+	}
+}
+
+// vectorDistance returns the distance between two embeddings in a metric,
+// as sqlite-vec measures it: one minus the cosine similarity, or the
+// Euclidean distance
+func vectorDistance(metric string, a, b []float32) float64 {
+	var dot, normA, normB, sum float64
+	for i := range min(len(a), len(b)) {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		normA += x * x
+		normB += y * y
+		sum += (x - y) * (x - y)
+	}
+	if metric == MetricL2 {
+		return math.Sqrt(sum)
+	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/math.Sqrt(normA*normB)
+}
@@ -0,0 +1,94 @@
+package vector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Config configures a vector store backend
+type Config struct {
+	Type         string            // Name of a registered backend
+	DBPath       string            // Path of the SQLite database
+	Metric       string            // MetricCosine or MetricL2
+	Quantization string            // Precision of SQLite index vectors
+	Rescore      bool              // Keep float vectors to rescore quantized search candidates
+	Options      map[string]string // Connection options of the backend, such as "url"
+}
+
+// Option returns a connection option, or fallback if it is unset
+func (c Config) Option(name, fallback string) string {
+	if value := c.Options[name]; value != "" {
+		return value
+	}
+	return fallback
+}
+
+// RequireOption returns a connection option, or an error if it is unset
+func (c Config) RequireOption(name string) (string, error) {
+	value := c.Options[name]
+	if value == "" {
+		return "", fmt.Errorf("vector store %s requires the %s option", c.Type, name)
+	}
+	return value, nil
+}
+
+// Factory creates a vector store from its config. Factories check the
+// options but do not connect; Initialize does.
+type Factory func(cfg Config) (Store, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a vector store backend available under a name, to be
+// selected by the vector_store config. Backends register themselves from
+// init functions, including backends compiled in by third parties.
+// Register panics if the name is taken or factory is nil.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("vector: Register factory is nil for " + name)
+	}
+	if _, taken := registry[name]; taken {
+		panic("vector: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// Backends returns the sorted names of the registered backends
+func Backends() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsBackend reports whether a backend is registered under name
+func IsBackend(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[name]
+	return ok
+}
+
+// Open creates a vector store with the backend registered as cfg.Type. The
+// store still needs to be initialized.
+func Open(cfg Config) (Store, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Type]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported vector store %q, expected %s", cfg.Type, strings.Join(Backends(), ", "))
+	}
+	return factory(cfg)
+}
@@ -0,0 +1,137 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// candidateFetchFactor is how many more candidates fetchCandidates
+	// fetches than requested, since some of them are dropped
+	candidateFetchFactor = 4
+	// maxCandidates bounds the candidates fetched by fetchCandidates
+	maxCandidates = 2000
+)
+
+// ChunkEmbeddingStore is implemented by stores that can return the stored
+// embeddings of a document's chunks
+type ChunkEmbeddingStore interface {
+	// GetChunkEmbeddings returns the embeddings of all chunks of a document
+	GetChunkEmbeddings(docID int64) ([][]float32, error)
+}
+
+// ErrEmbeddingsUnavailable is returned by RelatedDocuments for stores that
+// cannot return stored embeddings
+var ErrEmbeddingsUnavailable = errors.New("vector store cannot return stored embeddings")
+
+// RelatedDocuments returns the documents most similar to the document at
+// path: the mean of the document's chunk embeddings is searched, and every
+// other document is ranked by its closest chunk, which is returned with it
+func RelatedDocuments(store Store, path string, limit int) ([]SearchResult, error) {
+	embeddingStore, ok := store.(ChunkEmbeddingStore)
+	if !ok {
+		return nil, ErrEmbeddingsUnavailable
+	}
+
+	doc, err := store.GetDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("document not indexed: %s", path)
+	}
+
+	embeddings, err := embeddingStore.GetChunkEmbeddings(doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	centroid := meanEmbedding(embeddings)
+	if centroid == nil {
+		return nil, fmt.Errorf("document has no embedded chunks: %s", path)
+	}
+
+	// Keep the closest chunk of every other document
+	search := func(n int) ([]SearchResult, error) {
+		return store.Search(centroid, n, Filter{})
+	}
+	return fetchCandidates(limit, search, func(r SearchResult, kept []SearchResult) bool {
+		if r.Document.ID == doc.ID {
+			return false
+		}
+		for _, k := range kept {
+			if k.Document.ID == r.Document.ID {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// fetchCandidates fetches growing candidate lists until limit of them are
+// kept or no more candidates are available. keep decides whether a
+// candidate is kept after those kept before it.
+func fetchCandidates(limit int, fetch func(n int) ([]SearchResult, error), keep func(r SearchResult, kept []SearchResult) bool) ([]SearchResult, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	n := limit * candidateFetchFactor
+	for {
+		if n > maxCandidates {
+			n = maxCandidates
+		}
+
+		candidates, err := fetch(n)
+		if err != nil {
+			return nil, err
+		}
+
+		var results []SearchResult
+		for _, r := range candidates {
+			if keep(r, results) {
+				results = append(results, r)
+				if len(results) == limit {
+					break
+				}
+			}
+		}
+
+		if len(results) == limit || len(candidates) < n || n == maxCandidates {
+			return results, nil
+		}
+		n *= candidateFetchFactor
+	}
+}
+
+// meanEmbedding returns the normalized mean of embeddings, or nil if there
+// are none
+func meanEmbedding(embeddings [][]float32) []float32 {
+	if len(embeddings) == 0 {
+		return nil
+	}
+
+	mean := make([]float32, len(embeddings[0]))
+	for _, embedding := range embeddings {
+		for i := range mean {
+			if i < len(embedding) {
+				mean[i] += embedding[i]
+			}
+		}
+	}
+
+	// Normalize, so that stores using the L2 metric rank like cosine
+	// similarity, which they match for unit vectors only
+	var norm float64
+	for _, v := range mean {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return nil
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range mean {
+		mean[i] *= scale
+	}
+	return mean
+}
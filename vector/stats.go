@@ -0,0 +1,23 @@
+package vector
+
+import "time"
+
+// StoreStats describes the contents of a vector store
+type StoreStats struct {
+	Documents   int       // Indexed documents
+	Pending     int       // Documents being indexed or whose indexing was interrupted
+	Failed      int       // Documents that could not be indexed
+	Chunks      int       // Embedded chunks, including summary chunks
+	Dimension   int       // Embedding dimension, 0 if nothing was indexed yet
+	SizeBytes   int64     // Size of the database on disk
+	LastIndexed time.Time // When a document was last indexed, zero if never
+}
+
+// documentCountsQuery counts all, pending and failed documents
+const documentCountsQuery = `
+	SELECT
+		COUNT(*),
+		COUNT(*) FILTER (WHERE status = 'pending'),
+		COUNT(*) FILTER (WHERE status = 'failed')
+	FROM documents
+`
@@ -2,18 +2,43 @@ package vector
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
 	"unsafe"
 
 	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
 	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/tursodatabase/libsql-client-go/libsql"
 )
 
 const (
 	DefaultEmbeddingDimension = 3072 // text-embedding-3-large
+
+	// SummaryChunkIndex is the chunk index of a document's summary chunk
+	SummaryChunkIndex = -1
+
+	// chunksSchemaVersion is bumped when the chunks table layout changes,
+	// which rebuilds the table and re-indexes all documents
+	chunksSchemaVersion = 2
+
+	// sqliteBusyTimeout is how long a connection waits for a lock held by
+	// another connection or process, such as the index command next to a
+	// running server, before failing with "database is locked"
+	sqliteBusyTimeout = 10 * time.Second
+
+	// sqliteMaxOpenConns bounds the connection pool. In WAL mode readers do
+	// not block each other or the writer, so searches run concurrently with
+	// indexing.
+	sqliteMaxOpenConns = 8
 )
 
 // Chunk represents a document chunk with its embedding
@@ -23,6 +48,7 @@ type Chunk struct {
 	ChunkIndex   int
 	ChunkText    string
 	SectionTitle string
+	Breadcrumb   string // Heading hierarchy, e.g. "Install > Linux > Debian"
 	Embedding    []float32
 }
 
@@ -33,13 +59,38 @@ type DocumentRecord struct {
 	Title       string
 	ContentHash string
 	UpdatedAt   time.Time
+	Status      string // StatusPending, StatusEmbedded or StatusFailed
+	Error       string // Why indexing failed, for StatusFailed
+}
+
+// Indexing status of a document
+const (
+	// StatusPending documents are being indexed, or indexing was interrupted
+	StatusPending = "pending"
+	// StatusEmbedded documents have their chunks stored
+	StatusEmbedded = "embedded"
+	// StatusFailed documents could not be indexed, see DocumentRecord.Error
+	StatusFailed = "failed"
+)
+
+// documentStatus returns the status of a document upserted with a content
+// hash: the hash is only recorded once its chunks are stored
+func documentStatus(contentHash string) string {
+	if contentHash == "" {
+		return StatusPending
+	}
+	return StatusEmbedded
 }
 
 // SearchResult represents a search result with similarity score
 type SearchResult struct {
 	Chunk    Chunk
 	Document DocumentRecord
-	Score    float32
+	Score    float32 // Similarity or keyword score from 0 to 1, higher is better
+	// FusionScore is the normalized reciprocal rank fusion score of a hybrid
+	// search result, from 0 to 1, which the results are ordered by; zero for
+	// other searches
+	FusionScore float32
 }
 
 // Store defines the interface for vector storage operations
@@ -50,58 +101,203 @@ type Store interface {
 	// Close closes the database connection
 	Close() error
 
-	// UpsertDocument inserts or updates a document record
+	// SetDimension sets the embedding dimension, clearing the index if it changed
+	SetDimension(dim int) error
+
+	// UpsertDocument inserts or updates a document record. Without a
+	// content hash the document is pending, with one it is embedded.
 	UpsertDocument(path, title, contentHash string) (int64, error)
 
+	// SetDocumentSource records the source directory name and the
+	// collection of a document
+	SetDocumentSource(docID int64, source, collection string) error
+
+	// SetDocumentStatus records the indexing status of a document and, for
+	// failed documents, the error
+	SetDocumentStatus(docID int64, status, message string) error
+
 	// GetDocument retrieves a document by path
 	GetDocument(path string) (*DocumentRecord, error)
 
+	// ListDocuments retrieves all document records ordered by path
+	ListDocuments() ([]DocumentRecord, error)
+
 	// DeleteDocument removes a document and its chunks
 	DeleteDocument(path string) error
 
 	// InsertChunks inserts chunks for a document (deletes existing first)
 	InsertChunks(docID int64, chunks []Chunk) error
 
-	// Search performs semantic similarity search
-	Search(queryEmbedding []float32, limit int) ([]SearchResult, error)
+	// Search performs semantic similarity search over the chunks matching
+	// filter. Scores are similarities from 0 to 1, higher is better.
+	Search(queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error)
 
 	// GetChunksByDocument retrieves all chunks for a document
 	GetChunksByDocument(docID int64) ([]Chunk, error)
 
 	// NeedsUpdate checks if document needs re-embedding based on content hash
 	NeedsUpdate(path, contentHash string) (bool, error)
+
+	// Stats reports the number of documents and chunks, the embedding
+	// dimension, the database size and the last indexing time
+	Stats() (StoreStats, error)
 }
 
-// SQLiteStore implements Store using SQLite with sqlite-vec
+// SummaryStore is implemented by stores that can cache generated document
+// summaries keyed by document content hash
+type SummaryStore interface {
+	// GetSummary returns the cached summary for a content hash, if any
+	GetSummary(contentHash string) (string, bool, error)
+
+	// SaveSummary caches a summary for a content hash
+	SaveSummary(contentHash, summary string) error
+}
+
+// EmbeddingCache is implemented by stores that can cache computed embeddings
+// keyed by the hash of the embedded text and the model that produced them
+type EmbeddingCache interface {
+	// GetCachedEmbeddings returns the cached embeddings for the given text
+	// hashes; hashes without a cached embedding are missing from the result
+	GetCachedEmbeddings(model string, textHashes []string) (map[string][]float32, error)
+
+	// CacheEmbeddings stores embeddings keyed by text hash
+	CacheEmbeddings(model string, embeddings map[string][]float32) error
+}
+
+// MetadataStore is implemented by stores that can keep settings detected at
+// runtime, such as the embedding dimension of a model
+type MetadataStore interface {
+	// GetMetadata returns the value stored for a key, if any
+	GetMetadata(key string) (string, bool, error)
+
+	// SetMetadata stores a value for a key
+	SetMetadata(key, value string) error
+}
+
+// KeywordStore is implemented by stores with a full-text index over chunks
+type KeywordStore interface {
+	// KeywordSearch performs BM25 keyword search over the chunks matching
+	// filter. Scores are from 0 to 1, higher is better.
+	KeywordSearch(query string, limit int, filter Filter) ([]SearchResult, error)
+}
+
+// ErrFullTextUnavailable is returned by KeywordSearch when SQLite was built
+// without FTS5 support
+var ErrFullTextUnavailable = errors.New("full-text search unavailable: build with -tags sqlite_fts5")
+
+// SQLiteStore implements Store using SQLite with sqlite-vec, or a libSQL
+// server with its native vectors
 type SQLiteStore struct {
-	db        *sql.DB
-	path      string
-	dimension int
-	mu        sync.RWMutex
+	db           *sql.DB
+	path         string // Database file, or URL of a libSQL database
+	remote       bool   // Database on a libSQL server
+	dimension    int
+	metric       string // MetricCosine or MetricL2
+	quantization string // QuantizationFloat, QuantizationInt8 or QuantizationBit
+	rescore      bool   // Full-precision embeddings are kept to rescore quantized search results
+	fts          bool   // FTS5 keyword index available
+	mu           sync.RWMutex
+}
+
+func init() {
+	Register("sqlite", func(cfg Config) (Store, error) {
+		return NewSQLiteStore(cfg.DBPath, cfg.Metric, cfg.Quantization, cfg.Rescore), nil
+	})
+	Register("libsql", func(cfg Config) (Store, error) {
+		address, err := cfg.RequireOption("url")
+		if err != nil {
+			return nil, err
+		}
+		store, err := NewLibSQLStore(address, cfg.Options["auth_token"], cfg.Metric)
+		if err != nil {
+			return nil, err
+		}
+		// The address may carry an authentication token
+		host := address
+		if u, err := url.Parse(address); err == nil {
+			host = u.Host
+		}
+		slog.Info("Using libSQL vector store", "host", host)
+		return store, nil
+	})
 }
 
-// NewSQLiteStore creates a new SQLite vector store
-func NewSQLiteStore(dbPath string) *SQLiteStore {
+// NewSQLiteStore creates a new SQLite vector store searching with the given
+// distance metric. Embeddings are indexed in the given quantization; with
+// rescore, full-precision copies are kept to rank the best candidates of
+// quantized searches exactly.
+func NewSQLiteStore(dbPath, metric, quantization string, rescore bool) *SQLiteStore {
+	if quantization == "" {
+		quantization = QuantizationFloat
+	}
 	return &SQLiteStore{
-		path:      dbPath,
-		dimension: DefaultEmbeddingDimension,
+		path:         dbPath,
+		dimension:    DefaultEmbeddingDimension,
+		metric:       metric,
+		quantization: quantization,
+		rescore:      rescore && quantization != QuantizationFloat,
 	}
 }
 
+// NewLibSQLStore creates a new vector store in the libSQL database at a
+// libsql://, https:// or http:// URL, such as a Turso database, searching
+// with the given distance metric. Several servers may share the database.
+// Embeddings are stored as floats in a native libSQL vector column.
+func NewLibSQLStore(address, authToken, metric string) (*SQLiteStore, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid libsql url: %w", err)
+	}
+	switch u.Scheme {
+	case "libsql", "https", "http":
+	default:
+		return nil, fmt.Errorf("invalid libsql url '%s': use libsql://, https:// or http://", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid libsql url '%s': missing host", u.Redacted())
+	}
+	if authToken != "" {
+		query := u.Query()
+		query.Set("authToken", authToken)
+		u.RawQuery = query.Encode()
+		address = u.String()
+	}
+	store := NewSQLiteStore(address, metric, QuantizationFloat, false)
+	store.remote = true
+	return store, nil
+}
+
 // SetDimension sets the embedding dimension and recreates the chunks table if needed
 func (s *SQLiteStore) SetDimension(dim int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Check stored dimension in metadata
-	var storedDim int
+	// Check stored dimension, distance metric, quantization and chunks
+	// schema version in metadata. Indexes built before the metric was stored
+	// use L2 distance, those built before quantization was stored floats.
+	var storedDim, storedVersion int
+	storedMetric, storedQuantization, storedRescore := MetricL2, QuantizationFloat, false
 	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = 'dimension'").Scan(&storedDim)
-	if err == nil && storedDim == dim {
+	s.db.QueryRow("SELECT value FROM metadata WHERE key = 'schema_version'").Scan(&storedVersion)
+	s.db.QueryRow("SELECT value FROM metadata WHERE key = 'metric'").Scan(&storedMetric)
+	s.db.QueryRow("SELECT value FROM metadata WHERE key = 'quantization'").Scan(&storedQuantization)
+	s.db.QueryRow("SELECT value = 'true' FROM metadata WHERE key = 'rescore'").Scan(&storedRescore)
+	if err == nil && storedDim == dim && storedVersion == chunksSchemaVersion && storedMetric == s.metric &&
+		storedQuantization == s.quantization && storedRescore == s.rescore {
 		s.dimension = dim
 		return nil
 	}
 
-	log.Printf("Embedding dimension changed to %d, re-indexing all documents...", dim)
+	switch {
+	case err != nil || storedDim != dim:
+		slog.Info("Embedding dimension changed, re-indexing all documents", "dimension", dim)
+	case storedMetric != s.metric:
+		slog.Info("Distance metric changed, re-indexing all documents", "metric", s.metric)
+	case storedQuantization != s.quantization || storedRescore != s.rescore:
+		slog.Info("Vector quantization changed, re-indexing all documents", "quantization", s.quantization, "rescore", s.rescore)
+	default:
+		slog.Info("Chunk storage format changed, re-indexing all documents")
+	}
 	s.dimension = dim
 
 	// Drop existing chunks table and recreate with new dimension
@@ -109,25 +305,28 @@ func (s *SQLiteStore) SetDimension(dim int) error {
 	if err != nil {
 		return fmt.Errorf("failed to drop chunks table: %w", err)
 	}
+	if _, err := s.db.Exec("DELETE FROM chunk_vectors"); err != nil {
+		return fmt.Errorf("failed to clear full-precision embeddings: %w", err)
+	}
 
 	// Clear documents table to force re-indexing with new dimension
 	_, err = s.db.Exec("DELETE FROM documents")
 	if err != nil {
 		return fmt.Errorf("failed to clear documents table: %w", err)
 	}
+	if _, err := s.db.Exec("DELETE FROM document_tags"); err != nil {
+		return fmt.Errorf("failed to clear document tags: %w", err)
+	}
+
+	if s.fts {
+		if _, err := s.db.Exec("DELETE FROM chunks_fts"); err != nil {
+			return fmt.Errorf("failed to clear full-text index: %w", err)
+		}
+	}
 
 	// Create virtual table for vector search with new dimension
-	_, err = s.db.Exec(fmt.Sprintf(`
-		CREATE VIRTUAL TABLE IF NOT EXISTS chunks USING vec0 (
-			embedding float[%d],
-			doc_id INTEGER,
-			chunk_index INTEGER,
-			chunk_text TEXT,
-			section_title TEXT
-		)
-	`, dim))
-	if err != nil {
-		return fmt.Errorf("failed to create chunks virtual table: %w", err)
+	if err := s.createChunksTable(); err != nil {
+		return err
 	}
 
 	// Store dimension in metadata
@@ -139,21 +338,119 @@ func (s *SQLiteStore) SetDimension(dim int) error {
 		return fmt.Errorf("failed to store dimension: %w", err)
 	}
 
+	_, err = s.db.Exec(`
+		INSERT INTO metadata (key, value) VALUES ('schema_version', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, chunksSchemaVersion)
+	if err != nil {
+		return fmt.Errorf("failed to store schema version: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO metadata (key, value) VALUES ('metric', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, s.metric)
+	if err != nil {
+		return fmt.Errorf("failed to store distance metric: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO metadata (key, value) VALUES ('quantization', ?), ('rescore', ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, s.quantization, strconv.FormatBool(s.rescore))
+	if err != nil {
+		return fmt.Errorf("failed to store quantization: %w", err)
+	}
+
 	return nil
 }
 
+// StoredDimension returns the embedding dimension the index was built with,
+// or 0 if nothing was indexed yet
+func (s *SQLiteStore) StoredDimension() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var dim int
+	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = 'dimension'").Scan(&dim)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read stored dimension: %w", err)
+	}
+	return dim, nil
+}
+
+// StoredMetric returns the distance metric the index was built with, or ""
+// if nothing was indexed yet
+func (s *SQLiteStore) StoredMetric() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var metric string
+	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = 'metric'").Scan(&metric)
+	if err == sql.ErrNoRows {
+		// Indexes built before the metric was stored use L2 distance
+		var dim int
+		if s.db.QueryRow("SELECT value FROM metadata WHERE key = 'dimension'").Scan(&dim) == nil {
+			return MetricL2, nil
+		}
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read stored distance metric: %w", err)
+	}
+	return metric, nil
+}
+
+// StoredQuantization returns the quantization the index was built with, or
+// "" if nothing was indexed yet
+func (s *SQLiteStore) StoredQuantization() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var quantization string
+	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = 'quantization'").Scan(&quantization)
+	if err == sql.ErrNoRows {
+		// Indexes built before quantization was stored hold floats
+		var dim int
+		if s.db.QueryRow("SELECT value FROM metadata WHERE key = 'dimension'").Scan(&dim) == nil {
+			return QuantizationFloat, nil
+		}
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read stored quantization: %w", err)
+	}
+	return quantization, nil
+}
+
 // Initialize creates the database and tables
 func (s *SQLiteStore) Initialize() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Register sqlite-vec extension
-	sqlite_vec.Auto()
-
-	db, err := sql.Open("sqlite3", s.path)
+	driverName, dsn := "libsql", s.path
+	if !s.remote {
+		// Register sqlite-vec extension
+		sqlite_vec.Auto()
+
+		// Every pooled connection uses the write-ahead log, waits for locks
+		// instead of failing at once, and takes the write lock when a
+		// transaction begins, so that concurrent writers queue up rather
+		// than deadlock when upgrading a read lock
+		driverName = "sqlite3"
+		dsn = fmt.Sprintf("%s?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=%d&_txlock=immediate",
+			s.path, sqliteBusyTimeout.Milliseconds())
+	}
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
+	db.SetMaxOpenConns(sqliteMaxOpenConns)
+	db.SetMaxIdleConns(sqliteMaxOpenConns)
+	db.SetConnMaxIdleTime(5 * time.Minute)
 	s.db = db
 
 	// Create metadata table for storing configuration like dimension
@@ -174,32 +471,234 @@ func (s *SQLiteStore) Initialize() error {
 			path TEXT UNIQUE NOT NULL,
 			title TEXT NOT NULL,
 			content_hash TEXT NOT NULL,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			source TEXT NOT NULL DEFAULT '',
+			collection TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'embedded',
+			error TEXT NOT NULL DEFAULT ''
 		)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to create documents table: %w", err)
 	}
 
+	// Add the columns of databases created before they existed. Sources and
+	// collections are filled in when documents are next indexed; documents
+	// without content hash were being indexed when the status was added.
+	for _, column := range []struct{ name, definition, backfill string }{
+		{"source", "TEXT NOT NULL DEFAULT ''", ""},
+		{"status", "TEXT NOT NULL DEFAULT 'embedded'", "UPDATE documents SET status = 'pending' WHERE content_hash = ''"},
+		{"error", "TEXT NOT NULL DEFAULT ''", ""},
+		{"collection", "TEXT NOT NULL DEFAULT ''", ""},
+	} {
+		var exists bool
+		err = db.QueryRow("SELECT COUNT(*) > 0 FROM pragma_table_info('documents') WHERE name = ?", column.name).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to inspect documents table: %w", err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE documents ADD COLUMN " + column.name + " " + column.definition); err != nil {
+			return fmt.Errorf("failed to add %s column: %w", column.name, err)
+		}
+		if column.backfill != "" {
+			if _, err := db.Exec(column.backfill); err != nil {
+				return fmt.Errorf("failed to fill %s column: %w", column.name, err)
+			}
+		}
+	}
+
 	// Create index on path
 	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_documents_path ON documents(path)`)
 	if err != nil {
 		return fmt.Errorf("failed to create path index: %w", err)
 	}
 
+	// Create summaries table, keyed by content hash so unchanged documents
+	// never need to be summarized again
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS summaries (
+			content_hash TEXT PRIMARY KEY,
+			summary TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create summaries table: %w", err)
+	}
+
+	// Create document tags table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS document_tags (
+			doc_id INTEGER NOT NULL,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (doc_id, tag)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create document tags table: %w", err)
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_document_tags_tag ON document_tags(tag)`)
+	if err != nil {
+		return fmt.Errorf("failed to create tag index: %w", err)
+	}
+
+	// Create embedding cache table. It survives dimension changes and
+	// re-indexing, so unchanged chunk text is never embedded twice per model.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS embedding_cache (
+			text_hash TEXT NOT NULL,
+			model TEXT NOT NULL,
+			embedding BLOB NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (text_hash, model)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create embedding cache table: %w", err)
+	}
+
+	// Create table of the full-precision embeddings of chunks, used to
+	// rescore quantized search results
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS chunk_vectors (
+			chunk_id INTEGER PRIMARY KEY,
+			doc_id INTEGER NOT NULL,
+			embedding BLOB NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create chunk vectors table: %w", err)
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_chunk_vectors_doc ON chunk_vectors(doc_id)`)
+	if err != nil {
+		return fmt.Errorf("failed to create chunk vectors index: %w", err)
+	}
+
 	// Create virtual table for vector search
-	_, err = db.Exec(fmt.Sprintf(`
+	if err := s.createChunksTable(); err != nil {
+		return err
+	}
+
+	return s.initFullText()
+}
+
+// createChunksTable creates the vector search table for the store's
+// dimension, metric and quantization. sqlite-vec measures L2 distance unless
+// told otherwise, and Hamming distance between bit vectors.
+func (s *SQLiteStore) createChunksTable() error {
+	if s.remote {
+		return s.createLibSQLChunksTable()
+	}
+	metric := ""
+	if s.metric == MetricCosine && s.quantization != QuantizationBit {
+		metric = " distance_metric=cosine"
+	}
+	_, err := s.db.Exec(fmt.Sprintf(`
 		CREATE VIRTUAL TABLE IF NOT EXISTS chunks USING vec0 (
-			embedding float[%d],
+			embedding %s[%d]%s,
 			doc_id INTEGER,
 			chunk_index INTEGER,
 			chunk_text TEXT,
-			section_title TEXT
+			section_title TEXT,
+			breadcrumb TEXT
 		)
-	`, s.dimension))
+	`, s.quantization, s.dimension, metric))
 	if err != nil {
 		return fmt.Errorf("failed to create chunks virtual table: %w", err)
 	}
+	return nil
+}
+
+// createLibSQLChunksTable creates the chunks table of a libSQL database
+// with a native vector column. The integer primary key keeps rowids stable,
+// as the keyword index refers to them.
+func (s *SQLiteStore) createLibSQLChunksTable() error {
+	_, err := s.db.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS chunks (
+			id INTEGER PRIMARY KEY,
+			embedding F32_BLOB(%d),
+			doc_id INTEGER,
+			chunk_index INTEGER,
+			chunk_text TEXT,
+			section_title TEXT,
+			breadcrumb TEXT
+		)
+	`, s.dimension))
+	if err != nil {
+		return fmt.Errorf("failed to create chunks table: %w", err)
+	}
+	if _, err := s.db.Exec("CREATE INDEX IF NOT EXISTS idx_chunks_doc ON chunks(doc_id)"); err != nil {
+		return fmt.Errorf("failed to create chunks index: %w", err)
+	}
+	return nil
+}
+
+// vectorParam returns the SQL parameter for an embedding encoded with
+// quantize, typed so that sqlite-vec does not read it as floats
+func (s *SQLiteStore) vectorParam() string {
+	switch s.quantization {
+	case QuantizationInt8:
+		return "vec_int8(?)"
+	case QuantizationBit:
+		return "vec_bit(?)"
+	default:
+		return "?"
+	}
+}
+
+// chunkEmbedding returns the embedding of a chunk from its stored vector, or
+// from its full-precision copy if one is kept
+func (s *SQLiteStore) chunkEmbedding(stored, full []byte) []float32 {
+	if full != nil {
+		return blobToFloat32Slice(full)
+	}
+	return dequantize(s.quantization, stored)
+}
+
+// initFullText creates the FTS5 keyword index over chunk text and backfills
+// it from existing chunks. Without FTS5 support keyword search is disabled.
+func (s *SQLiteStore) initFullText() error {
+	_, err := s.db.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5 (
+			chunk_text,
+			section_title,
+			title,
+			doc_id UNINDEXED,
+			chunk_index UNINDEXED
+		)
+	`)
+	if err != nil && s.remote {
+		slog.Warn("Keyword search disabled, the libSQL server does not support FTS5", "error", err)
+		return nil
+	}
+	if err != nil {
+		slog.Warn("Keyword search disabled, FTS5 is not available (build with -tags sqlite_fts5)", "error", err)
+		return nil
+	}
+	s.fts = true
+
+	// Backfill databases created before the keyword index existed
+	var indexed, total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM chunks_fts").Scan(&indexed); err != nil {
+		return fmt.Errorf("failed to count full-text rows: %w", err)
+	}
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM chunks").Scan(&total); err != nil {
+		return fmt.Errorf("failed to count chunks: %w", err)
+	}
+	if indexed == 0 && total > 0 {
+		slog.Info("Building keyword index", "chunks", total)
+		_, err = s.db.Exec(`
+			INSERT INTO chunks_fts (rowid, chunk_text, section_title, title, doc_id, chunk_index)
+			SELECT c.rowid, c.chunk_text, c.section_title, d.title, c.doc_id, c.chunk_index
+			FROM chunks c
+			JOIN documents d ON c.doc_id = d.id
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to backfill full-text index: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -220,30 +719,48 @@ func (s *SQLiteStore) UpsertDocument(path, title, contentHash string) (int64, er
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	result, err := s.db.Exec(`
-		INSERT INTO documents (path, title, content_hash, updated_at)
-		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	// LastInsertId is not the document's id when the path already exists,
+	// so the id is returned by the statement itself
+	var id int64
+	err := s.db.QueryRow(`
+		INSERT INTO documents (path, title, content_hash, updated_at, status, error)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?, '')
 		ON CONFLICT(path) DO UPDATE SET
 			title = excluded.title,
 			content_hash = excluded.content_hash,
-			updated_at = CURRENT_TIMESTAMP
-	`, path, title, contentHash)
+			updated_at = CURRENT_TIMESTAMP,
+			status = excluded.status,
+			error = ''
+		RETURNING id
+	`, path, title, contentHash, documentStatus(contentHash)).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("failed to upsert document: %w", err)
 	}
+	return id, nil
+}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		// If LastInsertId fails, query for the ID
-		var docID int64
-		err = s.db.QueryRow("SELECT id FROM documents WHERE path = ?", path).Scan(&docID)
-		if err != nil {
-			return 0, fmt.Errorf("failed to get document id: %w", err)
-		}
-		return docID, nil
+// SetDocumentSource records the source directory name and the collection
+// of a document
+func (s *SQLiteStore) SetDocumentSource(docID int64, source, collection string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.Exec("UPDATE documents SET source = ?, collection = ? WHERE id = ?", source, collection, docID); err != nil {
+		return fmt.Errorf("failed to set document source: %w", err)
 	}
+	return nil
+}
 
-	return id, nil
+// SetDocumentStatus records the indexing status of a document and, for
+// failed documents, the error
+func (s *SQLiteStore) SetDocumentStatus(docID int64, status, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.Exec("UPDATE documents SET status = ?, error = ? WHERE id = ?", status, message, docID); err != nil {
+		return fmt.Errorf("failed to set document status: %w", err)
+	}
+	return nil
 }
 
 // GetDocument retrieves a document by path
@@ -253,9 +770,9 @@ func (s *SQLiteStore) GetDocument(path string) (*DocumentRecord, error) {
 
 	var doc DocumentRecord
 	err := s.db.QueryRow(`
-		SELECT id, path, title, content_hash, updated_at
+		SELECT id, path, title, content_hash, updated_at, status, error
 		FROM documents WHERE path = ?
-	`, path).Scan(&doc.ID, &doc.Path, &doc.Title, &doc.ContentHash, &doc.UpdatedAt)
+	`, path).Scan(&doc.ID, &doc.Path, &doc.Title, &doc.ContentHash, &doc.UpdatedAt, &doc.Status, &doc.Error)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -266,6 +783,31 @@ func (s *SQLiteStore) GetDocument(path string) (*DocumentRecord, error) {
 	return &doc, nil
 }
 
+// ListDocuments retrieves all document records ordered by path
+func (s *SQLiteStore) ListDocuments() ([]DocumentRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT id, path, title, content_hash, updated_at, status, error
+		FROM documents ORDER BY path
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []DocumentRecord
+	for rows.Next() {
+		var doc DocumentRecord
+		if err := rows.Scan(&doc.ID, &doc.Path, &doc.Title, &doc.ContentHash, &doc.UpdatedAt, &doc.Status, &doc.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
 // DeleteDocument removes a document and its chunks
 func (s *SQLiteStore) DeleteDocument(path string) error {
 	s.mu.Lock()
@@ -286,6 +828,18 @@ func (s *SQLiteStore) DeleteDocument(path string) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete chunks: %w", err)
 	}
+	if _, err := s.db.Exec("DELETE FROM chunk_vectors WHERE doc_id = ?", docID); err != nil {
+		return fmt.Errorf("failed to delete full-precision embeddings: %w", err)
+	}
+	if s.fts {
+		if _, err := s.db.Exec("DELETE FROM chunks_fts WHERE doc_id = ?", docID); err != nil {
+			return fmt.Errorf("failed to delete full-text rows: %w", err)
+		}
+	}
+
+	if _, err := s.db.Exec("DELETE FROM document_tags WHERE doc_id = ?", docID); err != nil {
+		return fmt.Errorf("failed to delete document tags: %w", err)
+	}
 
 	// Delete document
 	_, err = s.db.Exec("DELETE FROM documents WHERE id = ?", docID)
@@ -296,47 +850,128 @@ func (s *SQLiteStore) DeleteDocument(path string) error {
 	return nil
 }
 
-// InsertChunks inserts chunks for a document (deletes existing first)
+// InsertChunks replaces the chunks of a document in a single transaction,
+// so a failure or crash midway leaves the previous chunks in place
 func (s *SQLiteStore) InsertChunks(docID int64, chunks []Chunk) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Delete existing chunks for this document
-	_, err := s.db.Exec("DELETE FROM chunks WHERE doc_id = ?", docID)
+	tx, err := s.db.Begin()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Delete existing chunks for this document
+	if _, err := tx.Exec("DELETE FROM chunks WHERE doc_id = ?", docID); err != nil {
 		return fmt.Errorf("failed to delete existing chunks: %w", err)
 	}
+	if _, err := tx.Exec("DELETE FROM chunk_vectors WHERE doc_id = ?", docID); err != nil {
+		return fmt.Errorf("failed to delete existing full-precision embeddings: %w", err)
+	}
+
+	var ftsStmt *sql.Stmt
+	var title string
+	if s.fts {
+		if _, err := tx.Exec("DELETE FROM chunks_fts WHERE doc_id = ?", docID); err != nil {
+			return fmt.Errorf("failed to delete existing full-text rows: %w", err)
+		}
+		if err := tx.QueryRow("SELECT title FROM documents WHERE id = ?", docID).Scan(&title); err != nil {
+			return fmt.Errorf("failed to get document title: %w", err)
+		}
+		ftsStmt, err = tx.Prepare(`
+			INSERT INTO chunks_fts (rowid, chunk_text, section_title, title, doc_id, chunk_index)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare full-text insert statement: %w", err)
+		}
+		defer ftsStmt.Close()
+	}
 
-	// Insert new chunks
-	stmt, err := s.db.Prepare(`
-		INSERT INTO chunks (embedding, doc_id, chunk_index, chunk_text, section_title)
-		VALUES (?, ?, ?, ?, ?)
+	// Insert new chunks. Within the transaction the statement is prepared
+	// once and nothing is synced to disk until the commit.
+	stmt, err := tx.Prepare(`
+		INSERT INTO chunks (embedding, doc_id, chunk_index, chunk_text, section_title, breadcrumb)
+		VALUES (` + s.vectorParam() + `, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare insert statement: %w", err)
 	}
 	defer stmt.Close()
 
+	var vectorStmt *sql.Stmt
+	if s.rescore {
+		vectorStmt, err = tx.Prepare("INSERT INTO chunk_vectors (chunk_id, doc_id, embedding) VALUES (?, ?, ?)")
+		if err != nil {
+			return fmt.Errorf("failed to prepare full-precision embedding insert statement: %w", err)
+		}
+		defer vectorStmt.Close()
+	}
+
 	for _, chunk := range chunks {
 		// Convert embedding to blob format for sqlite-vec
-		embeddingBlob := float32SliceToBlob(chunk.Embedding)
-		_, err = stmt.Exec(embeddingBlob, docID, chunk.ChunkIndex, chunk.ChunkText, chunk.SectionTitle)
+		embeddingBlob := quantize(s.quantization, chunk.Embedding)
+		res, err := stmt.Exec(embeddingBlob, docID, chunk.ChunkIndex, chunk.ChunkText, chunk.SectionTitle, chunk.Breadcrumb)
 		if err != nil {
 			return fmt.Errorf("failed to insert chunk: %w", err)
 		}
+		if ftsStmt == nil && vectorStmt == nil {
+			continue
+		}
+
+		rowID, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get chunk id: %w", err)
+		}
+		if ftsStmt != nil {
+			if _, err := ftsStmt.Exec(rowID, chunk.ChunkText, chunk.SectionTitle, title, docID, chunk.ChunkIndex); err != nil {
+				return fmt.Errorf("failed to index chunk text: %w", err)
+			}
+		}
+		if vectorStmt != nil {
+			if _, err := vectorStmt.Exec(rowID, docID, float32SliceToBlob(chunk.Embedding)); err != nil {
+				return fmt.Errorf("failed to insert full-precision embedding: %w", err)
+			}
+		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit chunks: %w", err)
+	}
 	return nil
 }
 
-// Search performs semantic similarity search
-func (s *SQLiteStore) Search(queryEmbedding []float32, limit int) ([]SearchResult, error) {
+// Search performs semantic similarity search over the chunks matching
+// filter. Scores are similarities from 0 to 1, higher is better. Quantized
+// indexes find candidates by their quantized embeddings and score them by
+// their full-precision or dequantized embeddings.
+func (s *SQLiteStore) Search(queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	queryBlob := float32SliceToBlob(queryEmbedding)
+	quantized := s.quantization != QuantizationFloat
+	k := limit
+	vectorColumns, vectorJoin := "", ""
+	if quantized {
+		vectorColumns = ", c.embedding, v.embedding"
+		vectorJoin = " LEFT JOIN chunk_vectors v ON v.chunk_id = c.rowid"
+		if s.rescore {
+			k = limit * rescoreOversampling
+		}
+	}
+
+	queryBlob := quantize(s.quantization, queryEmbedding)
+	where, filterArgs := sqliteFilter(filter)
 
-	// sqlite-vec requires k = ? for KNN queries
+	// sqlite-vec requires k = ? for KNN queries. libSQL measures the
+	// distance to every chunk matching the filter, so results are exact.
+	distance, match, limitClause := "c.distance", "c.embedding MATCH "+s.vectorParam()+" AND k = ?", ""
+	args := append([]any{queryBlob, k}, filterArgs...)
+	if s.remote {
+		distance, match, limitClause = libsqlDistance(s.metric)+"(c.embedding, ?)", "TRUE", " LIMIT ?"
+		args = append(append([]any{queryBlob}, filterArgs...), k)
+	}
 	rows, err := s.db.Query(`
 		SELECT
 			c.rowid,
@@ -344,17 +979,18 @@ func (s *SQLiteStore) Search(queryEmbedding []float32, limit int) ([]SearchResul
 			c.chunk_index,
 			c.chunk_text,
 			c.section_title,
-			c.distance,
+			c.breadcrumb,
+			`+distance+` AS distance,
 			d.id,
 			d.path,
 			d.title,
 			d.content_hash,
-			d.updated_at
+			d.updated_at`+vectorColumns+`
 		FROM chunks c
-		JOIN documents d ON c.doc_id = d.id
-		WHERE c.embedding MATCH ? AND k = ?
-		ORDER BY c.distance
-	`, queryBlob, limit)
+		JOIN documents d ON c.doc_id = d.id`+vectorJoin+`
+		WHERE `+match+where+`
+		ORDER BY distance`+limitClause+`
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
@@ -363,13 +999,108 @@ func (s *SQLiteStore) Search(queryEmbedding []float32, limit int) ([]SearchResul
 	var results []SearchResult
 	for rows.Next() {
 		var result SearchResult
+		var distance float64
+		var stored, full []byte
+		dest := []any{
+			&result.Chunk.ID,
+			&result.Chunk.DocID,
+			&result.Chunk.ChunkIndex,
+			&result.Chunk.ChunkText,
+			&result.Chunk.SectionTitle,
+			&result.Chunk.Breadcrumb,
+			&distance,
+			&result.Document.ID,
+			&result.Document.Path,
+			&result.Document.Title,
+			&result.Document.ContentHash,
+			&result.Document.UpdatedAt,
+		}
+		if quantized {
+			dest = append(dest, &stored, &full)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan result: %w", err)
+		}
+		if quantized {
+			distance = vectorDistance(s.metric, queryEmbedding, s.chunkEmbedding(stored, full))
+		}
+		result.Score = similarity(s.metric, distance)
+		results = append(results, result)
+	}
+
+	if quantized {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+		results = results[:min(len(results), limit)]
+	}
+	return results, nil
+}
+
+// libsqlDistance returns the libSQL function measuring the distance of a
+// metric
+func libsqlDistance(metric string) string {
+	if metric == MetricL2 {
+		return "vector_distance_l2"
+	}
+	return "vector_distance_cos"
+}
+
+// KeywordSearch performs BM25 keyword search over chunk text, section titles
+// and document titles. Any query term may match; chunks matching more and
+// rarer terms rank higher. Only chunks matching filter are searched. Scores
+// are from 0 to 1, higher is better.
+func (s *SQLiteStore) KeywordSearch(query string, limit int, filter Filter) ([]SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.fts {
+		return nil, ErrFullTextUnavailable
+	}
+
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	where, filterArgs := sqliteFilter(filter)
+	args := append(append([]any{match}, filterArgs...), limit)
+
+	rows, err := s.db.Query(`
+		SELECT
+			f.rowid,
+			f.doc_id,
+			f.chunk_index,
+			f.chunk_text,
+			f.section_title,
+			c.breadcrumb,
+			bm25(chunks_fts, 1.0, 2.0, 2.0),
+			d.id,
+			d.path,
+			d.title,
+			d.content_hash,
+			d.updated_at
+		FROM chunks_fts f
+		JOIN documents d ON f.doc_id = d.id
+		JOIN chunks c ON c.rowid = f.rowid
+		WHERE chunks_fts MATCH ?`+where+`
+		ORDER BY bm25(chunks_fts, 1.0, 2.0, 2.0)
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search keywords: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		var score float64
 		err := rows.Scan(
 			&result.Chunk.ID,
 			&result.Chunk.DocID,
 			&result.Chunk.ChunkIndex,
 			&result.Chunk.ChunkText,
 			&result.Chunk.SectionTitle,
-			&result.Score,
+			&result.Chunk.Breadcrumb,
+			&score,
 			&result.Document.ID,
 			&result.Document.Path,
 			&result.Document.Title,
@@ -379,19 +1110,55 @@ func (s *SQLiteStore) Search(queryEmbedding []float32, limit int) ([]SearchResul
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan result: %w", err)
 		}
+		// bm25 is negative, more negative is more relevant
+		result.Score = keywordScore(-score)
 		results = append(results, result)
 	}
 
 	return results, nil
 }
 
+// HybridSearch fuses similarity search and BM25 keyword search with
+// reciprocal rank fusion, see HybridMultiSearch. Only chunks matching filter
+// are searched.
+func (s *SQLiteStore) HybridSearch(query string, queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error) {
+	return HybridMultiSearch(s, []string{query}, [][]float32{queryEmbedding}, limit, filter)
+}
+
+// ftsQuery converts free text into an FTS5 query matching any of its terms.
+// Terms are quoted so that FTS5 operators and punctuation are taken literally.
+func ftsQuery(query string) string {
+	terms := queryTerms(query)
+	for i, term := range terms {
+		terms[i] = `"` + term + `"`
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// queryTerms splits free text into distinct lowercase words
+func queryTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		term = strings.ToLower(term)
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+	}
+	return terms
+}
+
 // GetChunksByDocument retrieves all chunks for a document
 func (s *SQLiteStore) GetChunksByDocument(docID int64) ([]Chunk, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	rows, err := s.db.Query(`
-		SELECT rowid, doc_id, chunk_index, chunk_text, section_title
+		SELECT rowid, doc_id, chunk_index, chunk_text, section_title, breadcrumb
 		FROM chunks
 		WHERE doc_id = ?
 		ORDER BY chunk_index
@@ -404,7 +1171,7 @@ func (s *SQLiteStore) GetChunksByDocument(docID int64) ([]Chunk, error) {
 	var chunks []Chunk
 	for rows.Next() {
 		var chunk Chunk
-		err := rows.Scan(&chunk.ID, &chunk.DocID, &chunk.ChunkIndex, &chunk.ChunkText, &chunk.SectionTitle)
+		err := rows.Scan(&chunk.ID, &chunk.DocID, &chunk.ChunkIndex, &chunk.ChunkText, &chunk.SectionTitle, &chunk.Breadcrumb)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan chunk: %w", err)
 		}
@@ -414,6 +1181,34 @@ func (s *SQLiteStore) GetChunksByDocument(docID int64) ([]Chunk, error) {
 	return chunks, nil
 }
 
+// GetChunkEmbeddings retrieves the embeddings of all chunks for a document
+func (s *SQLiteStore) GetChunkEmbeddings(docID int64) ([][]float32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT c.embedding, v.embedding
+		FROM chunks c
+		LEFT JOIN chunk_vectors v ON v.chunk_id = c.rowid
+		WHERE c.doc_id = ?
+	`, docID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var embeddings [][]float32
+	for rows.Next() {
+		var stored, full []byte
+		if err := rows.Scan(&stored, &full); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk embedding: %w", err)
+		}
+		embeddings = append(embeddings, s.chunkEmbedding(stored, full))
+	}
+
+	return embeddings, nil
+}
+
 // NeedsUpdate checks if document needs re-embedding based on content hash
 func (s *SQLiteStore) NeedsUpdate(path, contentHash string) (bool, error) {
 	s.mu.RLock()
@@ -431,6 +1226,319 @@ func (s *SQLiteStore) NeedsUpdate(path, contentHash string) (bool, error) {
 	return existingHash != contentHash, nil
 }
 
+// Maintain removes chunks, keyword index rows, full-precision embeddings
+// and tags whose document no longer exists, then vacuums and analyzes the
+// database. libSQL servers compact their databases themselves.
+func (s *SQLiteStore) Maintain() (MaintenanceStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stats MaintenanceStats
+	var err error
+	if stats.SizeBefore, err = s.size(); err != nil {
+		return stats, err
+	}
+
+	result, err := s.db.Exec("DELETE FROM chunks WHERE doc_id NOT IN (SELECT id FROM documents)")
+	if err != nil {
+		return stats, fmt.Errorf("failed to delete orphan chunks: %w", err)
+	}
+	orphans, err := result.RowsAffected()
+	if err != nil {
+		return stats, fmt.Errorf("failed to count orphan chunks: %w", err)
+	}
+	stats.OrphanChunks = int(orphans)
+
+	if s.fts {
+		if _, err := s.db.Exec("DELETE FROM chunks_fts WHERE rowid NOT IN (SELECT rowid FROM chunks)"); err != nil {
+			return stats, fmt.Errorf("failed to delete orphan keyword index rows: %w", err)
+		}
+	}
+	if _, err := s.db.Exec("DELETE FROM chunk_vectors WHERE chunk_id NOT IN (SELECT rowid FROM chunks)"); err != nil {
+		return stats, fmt.Errorf("failed to delete orphan full-precision embeddings: %w", err)
+	}
+	if _, err := s.db.Exec("DELETE FROM document_tags WHERE doc_id NOT IN (SELECT id FROM documents)"); err != nil {
+		return stats, fmt.Errorf("failed to delete orphan tags: %w", err)
+	}
+
+	if !s.remote {
+		if _, err := s.db.Exec("VACUUM"); err != nil {
+			return stats, fmt.Errorf("failed to vacuum database: %w", err)
+		}
+	}
+	if _, err := s.db.Exec("ANALYZE"); err != nil {
+		return stats, fmt.Errorf("failed to analyze database: %w", err)
+	}
+	if !s.remote {
+		// Move the vacuumed pages from the write-ahead log into the
+		// database file and truncate the log, which would otherwise keep
+		// its size
+		if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+			return stats, fmt.Errorf("failed to checkpoint database: %w", err)
+		}
+	}
+
+	if stats.SizeAfter, err = s.size(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// size returns the size of the database in bytes
+func (s *SQLiteStore) size() (int64, error) {
+	var size int64
+	err := s.db.QueryRow("SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get database size: %w", err)
+	}
+	return size, nil
+}
+
+// Stats reports the number of documents and chunks, the embedding
+// dimension, the size of the database file with its write-ahead log, or of
+// the libSQL database, and the last indexing time
+func (s *SQLiteStore) Stats() (StoreStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats StoreStats
+	if err := s.db.QueryRow(documentCountsQuery).Scan(&stats.Documents, &stats.Pending, &stats.Failed); err != nil {
+		return stats, fmt.Errorf("failed to count documents: %w", err)
+	}
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM chunks").Scan(&stats.Chunks); err != nil {
+		return stats, fmt.Errorf("failed to count chunks: %w", err)
+	}
+	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = 'dimension'").Scan(&stats.Dimension)
+	if err != nil && err != sql.ErrNoRows {
+		return stats, fmt.Errorf("failed to read dimension: %w", err)
+	}
+
+	// Ordering instead of MAX keeps the column type, so it scans as a time
+	err = s.db.QueryRow("SELECT updated_at FROM documents ORDER BY updated_at DESC LIMIT 1").Scan(&stats.LastIndexed)
+	if err != nil && err != sql.ErrNoRows {
+		return stats, fmt.Errorf("failed to read last indexing time: %w", err)
+	}
+
+	if s.remote {
+		stats.SizeBytes, err = s.size()
+		return stats, err
+	}
+	for _, path := range []string{s.path, s.path + "-wal"} {
+		if info, err := os.Stat(path); err == nil {
+			stats.SizeBytes += info.Size()
+		}
+	}
+	return stats, nil
+}
+
+// GetMetadata returns the value stored for a key, if any
+func (s *SQLiteStore) GetMetadata(key string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var value string
+	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	return value, true, nil
+}
+
+// SetMetadata stores a value for a key
+func (s *SQLiteStore) SetMetadata(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`
+		INSERT INTO metadata (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to store metadata: %w", err)
+	}
+	return nil
+}
+
+// GetSummary returns the cached summary for a content hash, if any
+func (s *SQLiteStore) GetSummary(contentHash string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var summary string
+	err := s.db.QueryRow("SELECT summary FROM summaries WHERE content_hash = ?", contentHash).Scan(&summary)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get summary: %w", err)
+	}
+
+	return summary, true, nil
+}
+
+// SaveSummary caches a summary for a content hash
+func (s *SQLiteStore) SaveSummary(contentHash, summary string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`
+		INSERT INTO summaries (content_hash, summary, created_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(content_hash) DO UPDATE SET
+			summary = excluded.summary,
+			created_at = CURRENT_TIMESTAMP
+	`, contentHash, summary)
+	if err != nil {
+		return fmt.Errorf("failed to save summary: %w", err)
+	}
+
+	return nil
+}
+
+// SetDocumentTags replaces the tags of a document
+func (s *SQLiteStore) SetDocumentTags(docID int64, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM document_tags WHERE doc_id = ?", docID); err != nil {
+		return fmt.Errorf("failed to delete document tags: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO document_tags (doc_id, tag) VALUES (?, ?)", docID, tag); err != nil {
+			return fmt.Errorf("failed to insert document tag: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit document tags: %w", err)
+	}
+	return nil
+}
+
+// DocumentsWithTags returns the IDs of documents having all of the tags
+func (s *SQLiteStore) DocumentsWithTags(tags []string) (map[int64]bool, error) {
+	if len(tags) == 0 {
+		return map[int64]bool{}, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	args := make([]any, 0, len(tags)+1)
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	args = append(args, len(tags))
+
+	rows, err := s.db.Query(`
+		SELECT doc_id FROM document_tags
+		WHERE tag IN (?`+strings.Repeat(", ?", len(tags)-1)+`)
+		GROUP BY doc_id
+		HAVING COUNT(DISTINCT tag) = ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document tags: %w", err)
+	}
+	defer rows.Close()
+
+	docIDs := make(map[int64]bool)
+	for rows.Next() {
+		var docID int64
+		if err := rows.Scan(&docID); err != nil {
+			return nil, fmt.Errorf("failed to scan document id: %w", err)
+		}
+		docIDs[docID] = true
+	}
+	return docIDs, rows.Err()
+}
+
+// GetCachedEmbeddings returns the cached embeddings for the given text hashes
+func (s *SQLiteStore) GetCachedEmbeddings(model string, textHashes []string) (map[string][]float32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stmt, err := s.db.Prepare("SELECT embedding FROM embedding_cache WHERE text_hash = ? AND model = ?")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare cache lookup: %w", err)
+	}
+	defer stmt.Close()
+
+	cached := make(map[string][]float32)
+	for _, hash := range textHashes {
+		var blob []byte
+		err := stmt.QueryRow(hash, model).Scan(&blob)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cached embedding: %w", err)
+		}
+		cached[hash] = decodeEmbedding(blob, s.dimension)
+	}
+
+	return cached, nil
+}
+
+// CacheEmbeddings stores embeddings keyed by text hash. Quantized indexes
+// cache them in the same precision, unless float copies are kept for
+// rescoring, so the cache does not outgrow the index.
+func (s *SQLiteStore) CacheEmbeddings(model string, embeddings map[string][]float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO embedding_cache (text_hash, model, embedding, created_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(text_hash, model) DO UPDATE SET
+			embedding = excluded.embedding,
+			created_at = CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare cache insert: %w", err)
+	}
+	defer stmt.Close()
+
+	quantization := s.quantization
+	if s.rescore {
+		quantization = QuantizationFloat
+	}
+	for hash, embedding := range embeddings {
+		if _, err := stmt.Exec(hash, model, quantize(quantization, embedding)); err != nil {
+			return fmt.Errorf("failed to cache embedding: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit cached embeddings: %w", err)
+	}
+	return nil
+}
+
+// blobToFloat32Slice converts a little-endian sqlite-vec blob to a float32 slice
+func blobToFloat32Slice(blob []byte) []float32 {
+	vec := make([]float32, len(blob)/4)
+	for i := range vec {
+		bits := uint32(blob[i*4]) | uint32(blob[i*4+1])<<8 | uint32(blob[i*4+2])<<16 | uint32(blob[i*4+3])<<24
+		vec[i] = *(*float32)(unsafe.Pointer(&bits))
+	}
+	return vec
+}
+
 // float32SliceToBlob converts a float32 slice to a byte slice for sqlite-vec
 func float32SliceToBlob(vec []float32) []byte {
 	blob := make([]byte, len(vec)*4)
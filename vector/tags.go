@@ -0,0 +1,10 @@
+package vector
+
+// TagStore is implemented by stores that keep document tags for filtering
+type TagStore interface {
+	// SetDocumentTags replaces the tags of a document
+	SetDocumentTags(docID int64, tags []string) error
+
+	// DocumentsWithTags returns the IDs of documents having all of the tags
+	DocumentsWithTags(tags []string) (map[int64]bool, error)
+}
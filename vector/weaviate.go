@@ -0,0 +1,914 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// weaviateQueryLimit is the largest number of objects a filtered
+	// Weaviate query returns, Weaviate's default maximum
+	weaviateQueryLimit = 10000
+
+	// weaviatePageSize is the number of documents listed per cursor page
+	weaviatePageSize = 1000
+
+	// weaviateInsertBatch is the number of chunks inserted per batch request
+	weaviateInsertBatch = 100
+
+	// weaviateRequestTimeout bounds every request to Weaviate
+	weaviateRequestTimeout = 30 * time.Second
+
+	// weaviateHybridAlpha weighs vector search against BM25 in hybrid search,
+	// from 0 (keywords only) to 1 (vectors only)
+	weaviateHybridAlpha = 0.5
+
+	// defaultWeaviateClass is the default prefix of class names
+	defaultWeaviateClass = "Dimandocs"
+)
+
+// weaviateClassRegex matches a valid class name prefix
+var weaviateClassRegex = regexp.MustCompile(`^[A-Z][A-Za-z0-9_]*$`)
+
+// weaviateKeywordProperties are the chunk properties searched by BM25;
+// breadcrumbs hold the section titles
+var weaviateKeywordProperties = []string{"chunk_text", "breadcrumb"}
+
+// WeaviateStore implements Store using the Weaviate REST and GraphQL APIs.
+// Documents and chunks are objects of two classes named after a prefix, and
+// chunks carry the embeddings. Weaviate has no transactions: chunks of a
+// document are replaced by deleting and inserting them, so a failure midway
+// leaves the document failed until re-indexed.
+type WeaviateStore struct {
+	client    *http.Client
+	address   string // Base URL, e.g. http://localhost:8080
+	apiKey    string // Empty without authentication
+	documents string // Class names
+	chunks    string
+	dimension int
+	metric    string // MetricCosine or MetricL2
+	mu        sync.Mutex
+	upsertMu  sync.Mutex // Serializes lookups and creation of documents by path
+}
+
+func init() {
+	Register("weaviate", func(cfg Config) (Store, error) {
+		address, err := cfg.RequireOption("url")
+		if err != nil {
+			return nil, err
+		}
+		class := cfg.Option("class", defaultWeaviateClass)
+		if !weaviateClassRegex.MatchString(class) {
+			return nil, fmt.Errorf("invalid weaviate class '%s': start with a capital letter and use letters, digits and underscores", class)
+		}
+		slog.Info("Using Weaviate vector store", "address", address, "class", class)
+		return NewWeaviateStore(address, cfg.Options["api_key"], class, cfg.Metric), nil
+	})
+}
+
+// NewWeaviateStore creates a new Weaviate vector store searching with the
+// given distance metric in the classes named prefix+"Document" and
+// prefix+"Chunk"
+func NewWeaviateStore(address, apiKey, prefix, metric string) *WeaviateStore {
+	return &WeaviateStore{
+		client:    &http.Client{Timeout: weaviateRequestTimeout},
+		address:   strings.TrimSuffix(address, "/"),
+		apiKey:    apiKey,
+		documents: prefix + "Document",
+		chunks:    prefix + "Chunk",
+		dimension: DefaultEmbeddingDimension,
+		metric:    metric,
+	}
+}
+
+// request sends a request to the Weaviate REST API, such as
+// "GET /v1/schema", and decodes the response into out unless out is nil.
+// Missing objects and classes are reported by found.
+func (s *WeaviateStore) request(method, path string, body, out any) (found bool, err error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode weaviate request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.address+path, reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to create weaviate request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to reach weaviate: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed to read weaviate response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode >= 300 {
+		return false, fmt.Errorf("weaviate %s %s returned status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if out == nil || len(respBody) == 0 {
+		return true, nil
+	}
+	// Numbers are decoded exactly, since document IDs exceed float32
+	decoder := json.NewDecoder(bytes.NewReader(respBody))
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return false, fmt.Errorf("failed to decode weaviate response: %w", err)
+	}
+	return true, nil
+}
+
+// get runs a GraphQL Get query on a class with the given arguments and
+// returns the objects with the requested fields
+func (s *WeaviateStore) get(class string, args map[string]any, fields string) ([]map[string]any, error) {
+	query := fmt.Sprintf("{ Get { %s%s { %s } } }", class, graphqlArgs(args), fields)
+	var resp struct {
+		Data struct {
+			Get map[string][]map[string]any `json:"Get"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := s.request(http.MethodPost, "/v1/graphql", map[string]string{"query": query}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("weaviate query failed: %s", resp.Errors[0].Message)
+	}
+	return resp.Data.Get[class], nil
+}
+
+// count returns the number of objects of a class matching where, which may
+// be nil
+func (s *WeaviateStore) count(class string, where map[string]any) (int, error) {
+	args := map[string]any{}
+	if where != nil {
+		args["where"] = where
+	}
+	query := fmt.Sprintf("{ Aggregate { %s%s { meta { count } } } }", class, graphqlArgs(args))
+	var resp struct {
+		Data struct {
+			Aggregate map[string][]struct {
+				Meta struct {
+					Count json.Number `json:"count"`
+				} `json:"meta"`
+			} `json:"Aggregate"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := s.request(http.MethodPost, "/v1/graphql", map[string]string{"query": query}, &resp); err != nil {
+		return 0, err
+	}
+	if len(resp.Errors) > 0 {
+		return 0, fmt.Errorf("weaviate query failed: %s", resp.Errors[0].Message)
+	}
+	groups := resp.Data.Aggregate[class]
+	if len(groups) == 0 {
+		return 0, nil
+	}
+	return int(jsonInt(groups[0].Meta.Count)), nil
+}
+
+// graphqlEnum is a GraphQL enum value, written without quotes
+type graphqlEnum string
+
+// graphqlArgs formats the arguments of a GraphQL field, or "" for none
+func graphqlArgs(args map[string]any) string {
+	if len(args) == 0 {
+		return ""
+	}
+	literal := graphqlValue(args)
+	return "(" + literal[1:len(literal)-1] + ")"
+}
+
+// graphqlValue formats a value as a GraphQL input literal. Object keys are
+// sorted, so queries are deterministic.
+func graphqlValue(v any) string {
+	switch v := v.(type) {
+	case graphqlEnum:
+		return string(v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]string, len(keys))
+		for i, k := range keys {
+			fields[i] = k + ": " + graphqlValue(v[k])
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case []map[string]any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = graphqlValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		// Strings, numbers and lists of them are formatted like JSON
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// whereEqual matches objects whose property equals value, an int64 or a
+// string
+func whereEqual(property string, value any) map[string]any {
+	where := map[string]any{"path": []string{property}, "operator": graphqlEnum("Equal")}
+	if n, ok := value.(int64); ok {
+		where["valueInt"] = n
+	} else {
+		where["valueText"] = value
+	}
+	return where
+}
+
+// whereAnd matches objects matching all conditions, or returns nil if there
+// are none
+func whereAnd(conditions ...map[string]any) map[string]any {
+	switch len(conditions) {
+	case 0:
+		return nil
+	case 1:
+		return conditions[0]
+	}
+	return map[string]any{"operator": graphqlEnum("And"), "operands": conditions}
+}
+
+// whereDocuments matches chunks of the given documents
+func whereDocuments(docIDs []int64) map[string]any {
+	return map[string]any{"path": []string{"doc_id"}, "operator": graphqlEnum("ContainsAny"), "valueInt": docIDs}
+}
+
+// whereAny matches objects whose text property has any of the values
+func whereAny(property string, values []string) map[string]any {
+	return map[string]any{"path": []string{property}, "operator": graphqlEnum("ContainsAny"), "valueText": values}
+}
+
+// whereTags matches documents having all of the tags
+func whereTags(tags []string) map[string]any {
+	return map[string]any{"path": []string{"tags"}, "operator": graphqlEnum("ContainsAll"), "valueText": tags}
+}
+
+// weaviateDistance returns the Weaviate distance of the store's metric
+func (s *WeaviateStore) weaviateDistance() string {
+	if s.metric == MetricL2 {
+		return "l2-squared"
+	}
+	return "cosine"
+}
+
+// Initialize checks the connection and creates the documents class
+func (s *WeaviateStore) Initialize() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var class struct {
+		Properties []struct {
+			Name string `json:"name"`
+		} `json:"properties"`
+	}
+	found, err := s.request(http.MethodGet, "/v1/schema/"+s.documents, nil, &class)
+	if err != nil {
+		return fmt.Errorf("failed to connect to weaviate: %w", err)
+	}
+	if !found {
+		return s.createDocumentsClass()
+	}
+
+	// Documents classes created before documents had collections lack the
+	// property, which is filled in when documents are next indexed
+	for _, property := range class.Properties {
+		if property.Name == "collection" {
+			return nil
+		}
+	}
+	if _, err := s.request(http.MethodPost, "/v1/schema/"+s.documents+"/properties", textProperty("collection", "text", "field"), nil); err != nil {
+		return fmt.Errorf("failed to add collection property: %w", err)
+	}
+	return nil
+}
+
+// textProperty describes a text property. Field tokenization keeps values
+// whole, for exact filters; word tokenization makes them searchable by
+// BM25.
+func textProperty(name, dataType, tokenization string) map[string]any {
+	return map[string]any{"name": name, "dataType": []string{dataType}, "tokenization": tokenization}
+}
+
+// createDocumentsClass creates the class of document records, which have
+// no vectors
+func (s *WeaviateStore) createDocumentsClass() error {
+	_, err := s.request(http.MethodPost, "/v1/schema", map[string]any{
+		"class":      s.documents,
+		"vectorizer": "none",
+		"properties": []map[string]any{
+			{"name": "doc_id", "dataType": []string{"int"}},
+			textProperty("path", "text", "field"),
+			textProperty("title", "text", "word"),
+			textProperty("content_hash", "text", "field"),
+			textProperty("source", "text", "field"),
+			textProperty("collection", "text", "field"),
+			textProperty("status", "text", "field"),
+			textProperty("error", "text", "word"),
+			{"name": "updated_at", "dataType": []string{"date"}},
+			textProperty("tags", "text[]", "field"),
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create documents class: %w", err)
+	}
+	return nil
+}
+
+// createChunksClass creates the class of chunks, indexed for the store's
+// distance metric
+func (s *WeaviateStore) createChunksClass() error {
+	_, err := s.request(http.MethodPost, "/v1/schema", map[string]any{
+		"class":             s.chunks,
+		"vectorizer":        "none",
+		"vectorIndexConfig": map[string]any{"distance": s.weaviateDistance()},
+		"properties": []map[string]any{
+			{"name": "doc_id", "dataType": []string{"int"}},
+			{"name": "chunk_index", "dataType": []string{"int"}},
+			textProperty("chunk_text", "text", "word"),
+			textProperty("section_title", "text", "field"),
+			textProperty("breadcrumb", "text", "word"),
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create chunks class: %w", err)
+	}
+	return nil
+}
+
+// chunksLayout returns the embedding dimension of the stored chunks, 0 if
+// there are none, and the distance of the chunks class, "" if it does not
+// exist
+func (s *WeaviateStore) chunksLayout() (int, string, error) {
+	var class struct {
+		VectorIndexConfig struct {
+			Distance string `json:"distance"`
+		} `json:"vectorIndexConfig"`
+	}
+	found, err := s.request(http.MethodGet, "/v1/schema/"+s.chunks, nil, &class)
+	if err != nil || !found {
+		return 0, "", err
+	}
+	rows, err := s.get(s.chunks, map[string]any{"limit": 1}, "_additional { vector }")
+	if err != nil || len(rows) == 0 {
+		return 0, class.VectorIndexConfig.Distance, err
+	}
+	return len(additionalVector(rows[0])), class.VectorIndexConfig.Distance, nil
+}
+
+// SetDimension sets the embedding dimension and recreates the classes if
+// the dimension or distance metric changed
+func (s *WeaviateStore) SetDimension(dim int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	storedDim, distance, err := s.chunksLayout()
+	if err != nil {
+		return fmt.Errorf("failed to read chunks class: %w", err)
+	}
+	s.dimension = dim
+	// Weaviate classes take the dimension of the first vector stored
+	if distance == s.weaviateDistance() && (storedDim == dim || storedDim == 0) {
+		return nil
+	}
+
+	if distance != "" && storedDim == dim {
+		slog.Info("Distance metric changed, re-indexing all documents", "metric", s.metric)
+	} else {
+		slog.Info("Embedding dimension changed, re-indexing all documents", "dimension", dim)
+	}
+	for _, class := range []string{s.chunks, s.documents} {
+		if _, err := s.request(http.MethodDelete, "/v1/schema/"+class, nil, nil); err != nil {
+			return fmt.Errorf("failed to delete class %s: %w", class, err)
+		}
+	}
+	if err := s.createDocumentsClass(); err != nil {
+		return err
+	}
+	return s.createChunksClass()
+}
+
+// Close releases idle connections
+func (s *WeaviateStore) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// documentUUID returns the object ID of a document, which encodes its
+// document ID
+func documentUUID(docID int64) string {
+	return fmt.Sprintf("00000000-0000-0000-%04x-%012x", uint64(docID)>>48, uint64(docID)&0xffffffffffff)
+}
+
+// weaviateDocumentFields are the fields of document queries
+const weaviateDocumentFields = "doc_id path title content_hash status error updated_at"
+
+// parseWeaviateDocument converts a queried document object
+func parseWeaviateDocument(obj map[string]any) DocumentRecord {
+	updatedAt, _ := time.Parse(time.RFC3339Nano, jsonString(obj["updated_at"]))
+	return DocumentRecord{
+		ID:          jsonInt(obj["doc_id"]),
+		Path:        jsonString(obj["path"]),
+		Title:       jsonString(obj["title"]),
+		ContentHash: jsonString(obj["content_hash"]),
+		UpdatedAt:   updatedAt,
+		Status:      jsonString(obj["status"]),
+		Error:       jsonString(obj["error"]),
+	}
+}
+
+// updateDocument merges properties into the document with the given ID
+func (s *WeaviateStore) updateDocument(docID int64, properties map[string]any) error {
+	found, err := s.request(http.MethodPatch, "/v1/objects/"+s.documents+"/"+documentUUID(docID), map[string]any{
+		"class":      s.documents,
+		"properties": properties,
+	}, nil)
+	if err == nil && !found {
+		err = fmt.Errorf("document %d not found", docID)
+	}
+	return err
+}
+
+// UpsertDocument inserts or updates a document record
+func (s *WeaviateStore) UpsertDocument(path, title, contentHash string) (int64, error) {
+	s.upsertMu.Lock()
+	defer s.upsertMu.Unlock()
+
+	properties := map[string]any{
+		"title":        title,
+		"content_hash": contentHash,
+		"updated_at":   time.Now().UTC().Format(time.RFC3339Nano),
+		"status":       documentStatus(contentHash),
+		"error":        "",
+	}
+	doc, err := s.GetDocument(path)
+	if err != nil {
+		return 0, err
+	}
+	if doc != nil {
+		if err := s.updateDocument(doc.ID, properties); err != nil {
+			return 0, fmt.Errorf("failed to upsert document: %w", err)
+		}
+		return doc.ID, nil
+	}
+
+	docID := newDocumentID()
+	properties["doc_id"] = docID
+	properties["path"] = path
+	properties["source"] = ""
+	properties["collection"] = ""
+	properties["tags"] = []string{}
+	_, err = s.request(http.MethodPost, "/v1/objects", map[string]any{
+		"class":      s.documents,
+		"id":         documentUUID(docID),
+		"properties": properties,
+	}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to upsert document: %w", err)
+	}
+	return docID, nil
+}
+
+// SetDocumentSource records the source directory name and the collection
+// of a document
+func (s *WeaviateStore) SetDocumentSource(docID int64, source, collection string) error {
+	if err := s.updateDocument(docID, map[string]any{"source": source, "collection": collection}); err != nil {
+		return fmt.Errorf("failed to set document source: %w", err)
+	}
+	return nil
+}
+
+// SetDocumentStatus records the indexing status of a document and, for
+// failed documents, the error
+func (s *WeaviateStore) SetDocumentStatus(docID int64, status, message string) error {
+	if err := s.updateDocument(docID, map[string]any{"status": status, "error": message}); err != nil {
+		return fmt.Errorf("failed to set document status: %w", err)
+	}
+	return nil
+}
+
+// SetDocumentTags replaces the tags of a document
+func (s *WeaviateStore) SetDocumentTags(docID int64, tags []string) error {
+	if tags == nil {
+		tags = []string{}
+	}
+	if err := s.updateDocument(docID, map[string]any{"tags": tags}); err != nil {
+		return fmt.Errorf("failed to set document tags: %w", err)
+	}
+	return nil
+}
+
+// DocumentsWithTags returns the IDs of documents having all of the tags
+func (s *WeaviateStore) DocumentsWithTags(tags []string) (map[int64]bool, error) {
+	rows, err := s.get(s.documents, map[string]any{"where": whereTags(tags), "limit": weaviateQueryLimit}, "doc_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document tags: %w", err)
+	}
+	docIDs := make(map[int64]bool, len(rows))
+	for _, row := range rows {
+		docIDs[jsonInt(row["doc_id"])] = true
+	}
+	return docIDs, nil
+}
+
+// GetDocument retrieves a document by path
+func (s *WeaviateStore) GetDocument(path string) (*DocumentRecord, error) {
+	rows, err := s.get(s.documents, map[string]any{"where": whereEqual("path", path), "limit": 1}, weaviateDocumentFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	doc := parseWeaviateDocument(rows[0])
+	return &doc, nil
+}
+
+// eachDocument calls fn with every document object, paging through the
+// class with a cursor
+func (s *WeaviateStore) eachDocument(fields string, fn func(obj map[string]any)) error {
+	after := ""
+	for {
+		args := map[string]any{"limit": weaviatePageSize}
+		if after != "" {
+			args["after"] = after
+		}
+		rows, err := s.get(s.documents, args, fields+" _additional { id }")
+		if err != nil {
+			return fmt.Errorf("failed to list documents: %w", err)
+		}
+		for _, row := range rows {
+			fn(row)
+		}
+		if len(rows) < weaviatePageSize {
+			return nil
+		}
+		after = additionalString(rows[len(rows)-1], "id")
+	}
+}
+
+// ListDocuments retrieves all document records ordered by path
+func (s *WeaviateStore) ListDocuments() ([]DocumentRecord, error) {
+	var docs []DocumentRecord
+	err := s.eachDocument(weaviateDocumentFields, func(obj map[string]any) {
+		docs = append(docs, parseWeaviateDocument(obj))
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Path < docs[j].Path })
+	return docs, nil
+}
+
+// DeleteDocument removes a document and its chunks
+func (s *WeaviateStore) DeleteDocument(path string) error {
+	doc, err := s.GetDocument(path)
+	if err != nil || doc == nil {
+		return err
+	}
+	if err := s.deleteChunks(doc.ID); err != nil {
+		return err
+	}
+	if _, err := s.request(http.MethodDelete, "/v1/objects/"+s.documents+"/"+documentUUID(doc.ID), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	return nil
+}
+
+// deleteChunks removes the chunks of a document
+func (s *WeaviateStore) deleteChunks(docID int64) error {
+	_, err := s.request(http.MethodDelete, "/v1/batch/objects", map[string]any{
+		"match": map[string]any{"class": s.chunks, "where": whereEqual("doc_id", docID)},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	return nil
+}
+
+// InsertChunks replaces the chunks of a document, inserting them in batches
+func (s *WeaviateStore) InsertChunks(docID int64, chunks []Chunk) error {
+	if err := s.deleteChunks(docID); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(chunks); start += weaviateInsertBatch {
+		batch := chunks[start:min(start+weaviateInsertBatch, len(chunks))]
+		objects := make([]map[string]any, len(batch))
+		for i, chunk := range batch {
+			objects[i] = map[string]any{
+				"class": s.chunks,
+				"properties": map[string]any{
+					"doc_id":        docID,
+					"chunk_index":   chunk.ChunkIndex,
+					"chunk_text":    chunk.ChunkText,
+					"section_title": chunk.SectionTitle,
+					"breadcrumb":    chunk.Breadcrumb,
+				},
+				"vector": chunk.Embedding,
+			}
+		}
+
+		// Batch requests succeed as a whole and report failed objects
+		var results []struct {
+			Result struct {
+				Errors *struct {
+					Error []struct {
+						Message string `json:"message"`
+					} `json:"error"`
+				} `json:"errors"`
+			} `json:"result"`
+		}
+		if _, err := s.request(http.MethodPost, "/v1/batch/objects", map[string]any{"objects": objects}, &results); err != nil {
+			return fmt.Errorf("failed to insert chunks: %w", err)
+		}
+		for _, result := range results {
+			if result.Result.Errors != nil && len(result.Result.Errors.Error) > 0 {
+				return fmt.Errorf("failed to insert chunk: %s", result.Result.Errors.Error[0].Message)
+			}
+		}
+	}
+	return nil
+}
+
+// weaviateChunkFields are the fields of chunk queries
+const weaviateChunkFields = "doc_id chunk_index chunk_text section_title breadcrumb"
+
+// parseWeaviateChunk converts a queried chunk object. Chunk IDs are derived
+// from the object IDs.
+func parseWeaviateChunk(obj map[string]any) Chunk {
+	return Chunk{
+		ID:           keyChunkID(additionalString(obj, "id")),
+		DocID:        jsonInt(obj["doc_id"]),
+		ChunkIndex:   int(jsonInt(obj["chunk_index"])),
+		ChunkText:    jsonString(obj["chunk_text"]),
+		SectionTitle: jsonString(obj["section_title"]),
+		Breadcrumb:   jsonString(obj["breadcrumb"]),
+	}
+}
+
+// additionalString returns a string from the _additional field of an object
+func additionalString(obj map[string]any, key string) string {
+	additional, _ := obj["_additional"].(map[string]any)
+	return jsonString(additional[key])
+}
+
+// additionalVector returns the vector from the _additional field of an
+// object
+func additionalVector(obj map[string]any) []float32 {
+	additional, _ := obj["_additional"].(map[string]any)
+	values, _ := additional["vector"].([]any)
+	vector := make([]float32, len(values))
+	for i, v := range values {
+		vector[i] = float32(jsonFloat(v))
+	}
+	return vector
+}
+
+// chunkWhere returns the condition restricting chunks to filter. Document
+// conditions are resolved to document IDs first, path prefixes in Go since
+// Weaviate patterns have wildcards. ok is false when no document matches.
+func (s *WeaviateStore) chunkWhere(filter Filter) (where map[string]any, ok bool, err error) {
+	var conditions []map[string]any
+	if filter.hasDocumentFilter() {
+		var docConditions []map[string]any
+		if filter.Source != "" {
+			docConditions = append(docConditions, whereEqual("source", filter.Source))
+		}
+		if len(filter.Collections) > 0 {
+			docConditions = append(docConditions, whereAny("collection", filter.Collections))
+		}
+		if len(filter.Tags) > 0 {
+			docConditions = append(docConditions, whereTags(filter.Tags))
+		}
+
+		var docIDs []int64
+		match := func(obj map[string]any) {
+			if strings.HasPrefix(jsonString(obj["path"]), filter.PathPrefix) {
+				docIDs = append(docIDs, jsonInt(obj["doc_id"]))
+			}
+		}
+		if len(docConditions) == 0 {
+			err = s.eachDocument("doc_id path", match)
+		} else {
+			var rows []map[string]any
+			rows, err = s.get(s.documents, map[string]any{"where": whereAnd(docConditions...), "limit": weaviateQueryLimit}, "doc_id path")
+			for _, row := range rows {
+				match(row)
+			}
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to filter documents: %w", err)
+		}
+		if len(docIDs) == 0 {
+			return nil, false, nil
+		}
+		conditions = append(conditions, whereDocuments(docIDs))
+	}
+	if filter.SectionTitle != "" {
+		conditions = append(conditions, whereEqual("section_title", filter.SectionTitle))
+	}
+	return whereAnd(conditions...), true, nil
+}
+
+// searchChunks runs a chunk query with the given search arguments, such as
+// nearVector, restricted to filter, and converts the raw score in the
+// _additional field named scoreField with toScore
+func (s *WeaviateStore) searchChunks(args map[string]any, limit int, filter Filter, scoreField string, toScore func(float64) float32) ([]SearchResult, error) {
+	where, ok, err := s.chunkWhere(filter)
+	if err != nil || !ok {
+		return nil, err
+	}
+	args["limit"] = limit
+	if where != nil {
+		args["where"] = where
+	}
+	rows, err := s.get(s.chunks, args, weaviateChunkFields+" _additional { id "+scoreField+" }")
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	// Attach the documents of the found chunks
+	seen := make(map[int64]bool)
+	var docIDs []int64
+	for _, row := range rows {
+		if id := jsonInt(row["doc_id"]); !seen[id] {
+			seen[id] = true
+			docIDs = append(docIDs, id)
+		}
+	}
+	docRows, err := s.get(s.documents, map[string]any{"where": whereDocuments(docIDs), "limit": len(docIDs)}, weaviateDocumentFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+	docs := make(map[int64]DocumentRecord, len(docRows))
+	for _, row := range docRows {
+		doc := parseWeaviateDocument(row)
+		docs[doc.ID] = doc
+	}
+
+	results := make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		chunk := parseWeaviateChunk(row)
+		doc, ok := docs[chunk.DocID]
+		if !ok {
+			continue
+		}
+		score := toScore(jsonFloat(row["_additional"].(map[string]any)[scoreField]))
+		results = append(results, SearchResult{Chunk: chunk, Document: doc, Score: score})
+	}
+	return results, nil
+}
+
+// Search performs semantic similarity search with the store's distance
+// metric over the chunks matching filter. Scores are similarities from 0 to
+// 1, higher is better.
+func (s *WeaviateStore) Search(queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error) {
+	args := map[string]any{"nearVector": map[string]any{"vector": queryEmbedding}}
+	return s.searchChunks(args, limit, filter, "distance", func(distance float64) float32 {
+		// Weaviate measures squared Euclidean distance
+		if s.metric == MetricL2 {
+			distance = math.Sqrt(max(distance, 0))
+		}
+		return similarity(s.metric, distance)
+	})
+}
+
+// KeywordSearch performs BM25 keyword search over chunk text and
+// breadcrumbs of the chunks matching filter. Scores are from 0 to 1, higher
+// is better.
+func (s *WeaviateStore) KeywordSearch(query string, limit int, filter Filter) ([]SearchResult, error) {
+	if len(queryTerms(query)) == 0 {
+		return nil, nil
+	}
+	args := map[string]any{"bm25": map[string]any{"query": query, "properties": weaviateKeywordProperties}}
+	return s.searchChunks(args, limit, filter, "score", keywordScore)
+}
+
+// NativeHybridSearch ranks the chunks matching filter with Weaviate's
+// hybrid search, which fuses vector and BM25 scores. Scores are from 0 to
+// 1, higher is better.
+func (s *WeaviateStore) NativeHybridSearch(query string, queryEmbedding []float32, limit int, filter Filter) ([]SearchResult, error) {
+	if len(queryTerms(query)) == 0 {
+		return s.Search(queryEmbedding, limit, filter)
+	}
+	args := map[string]any{"hybrid": map[string]any{
+		"query":      query,
+		"vector":     queryEmbedding,
+		"alpha":      weaviateHybridAlpha,
+		"properties": weaviateKeywordProperties,
+		"fusionType": graphqlEnum("relativeScoreFusion"),
+	}}
+	return s.searchChunks(args, limit, filter, "score", func(score float64) float32 {
+		return float32(min(max(score, 0), 1))
+	})
+}
+
+// GetChunksByDocument retrieves all chunks for a document
+func (s *WeaviateStore) GetChunksByDocument(docID int64) ([]Chunk, error) {
+	args := map[string]any{"where": whereEqual("doc_id", docID), "limit": weaviateQueryLimit}
+	rows, err := s.get(s.chunks, args, weaviateChunkFields+" _additional { id }")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks: %w", err)
+	}
+	chunks := make([]Chunk, len(rows))
+	for i, row := range rows {
+		chunks[i] = parseWeaviateChunk(row)
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].ChunkIndex < chunks[j].ChunkIndex })
+	return chunks, nil
+}
+
+// GetChunkEmbeddings retrieves the embeddings of all chunks for a document
+func (s *WeaviateStore) GetChunkEmbeddings(docID int64) ([][]float32, error) {
+	args := map[string]any{"where": whereEqual("doc_id", docID), "limit": weaviateQueryLimit}
+	rows, err := s.get(s.chunks, args, "_additional { vector }")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk embeddings: %w", err)
+	}
+	embeddings := make([][]float32, len(rows))
+	for i, row := range rows {
+		embeddings[i] = additionalVector(row)
+	}
+	return embeddings, nil
+}
+
+// NeedsUpdate checks if document needs re-embedding based on content hash
+func (s *WeaviateStore) NeedsUpdate(path, contentHash string) (bool, error) {
+	doc, err := s.GetDocument(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to check content hash: %w", err)
+	}
+	return doc == nil || doc.ContentHash != contentHash, nil
+}
+
+// Stats reports the number of documents and chunks, the embedding dimension
+// and the last indexing time. Weaviate does not report the size of its
+// classes, so SizeBytes is 0.
+func (s *WeaviateStore) Stats() (StoreStats, error) {
+	var stats StoreStats
+	var err error
+	if stats.Documents, err = s.count(s.documents, nil); err != nil {
+		return stats, fmt.Errorf("failed to count documents: %w", err)
+	}
+	if stats.Pending, err = s.count(s.documents, whereEqual("status", StatusPending)); err != nil {
+		return stats, fmt.Errorf("failed to count documents: %w", err)
+	}
+	if stats.Failed, err = s.count(s.documents, whereEqual("status", StatusFailed)); err != nil {
+		return stats, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	rows, err := s.get(s.documents, map[string]any{
+		"sort":  []map[string]any{{"path": []string{"updated_at"}, "order": graphqlEnum("desc")}},
+		"limit": 1,
+	}, "updated_at")
+	if err != nil {
+		return stats, fmt.Errorf("failed to read last indexing time: %w", err)
+	}
+	if len(rows) > 0 {
+		stats.LastIndexed, _ = time.Parse(time.RFC3339Nano, jsonString(rows[0]["updated_at"]))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stats.Dimension, _, err = s.chunksLayout(); err != nil {
+		return stats, fmt.Errorf("failed to read chunks class: %w", err)
+	}
+	if stats.Chunks, err = s.count(s.chunks, nil); err != nil {
+		return stats, fmt.Errorf("failed to count chunks: %w", err)
+	}
+	return stats, nil
+}
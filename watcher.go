@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// watchDebounce is how long a path must be quiet before it is reprocessed.
+	// Editors often write a file several times when saving.
+	watchDebounce = 300 * time.Millisecond
+)
+
+// DocumentWatcher watches the configured directories and keeps documents and
+// their embeddings up to date as markdown files change
+type DocumentWatcher struct {
+	app     *App
+	watcher *fsnotify.Watcher
+	pending map[string]*time.Timer
+	mu      sync.Mutex // Guards pending
+	update  sync.Mutex // Serializes document updates
+	done    chan struct{}
+	ctx     context.Context // Cancelled by Close, to stop indexing in progress
+	cancel  context.CancelFunc
+}
+
+// NewDocumentWatcher creates a watcher over all configured directories
+func NewDocumentWatcher(app *App) (*DocumentWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &DocumentWatcher{
+		app:     app,
+		watcher: watcher,
+		pending: make(map[string]*time.Timer),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	for _, dirConfig := range app.Config.Directories {
+		if err := w.addRecursive(dirConfig.Path); err != nil {
+			cancel()
+			watcher.Close()
+			return nil, fmt.Errorf("failed to watch directory %s: %w", dirConfig.Path, err)
+		}
+	}
+
+	return w, nil
+}
+
+// Start processes file system events in the background until Close is called
+func (w *DocumentWatcher) Start() {
+	go w.run()
+}
+
+// Close stops the watcher, cancelling an update in progress and waiting for
+// it to return
+func (w *DocumentWatcher) Close() error {
+	close(w.done)
+	w.cancel()
+
+	w.mu.Lock()
+	for path, timer := range w.pending {
+		timer.Stop()
+		delete(w.pending, path)
+	}
+	w.mu.Unlock()
+
+	w.update.Lock()
+	defer w.update.Unlock()
+	return w.watcher.Close()
+}
+
+// WatchDirectories brings the watched directories in line with the configured
+// ones, after the config was reloaded
+func (w *DocumentWatcher) WatchDirectories() {
+	for _, path := range w.watcher.WatchList() {
+		if _, ok := w.sourceFor(path); !ok && !w.isConfiguredDirectory(path) {
+			w.watcher.Remove(path)
+		}
+	}
+	for _, dirConfig := range w.app.Config.Directories {
+		if err := w.addRecursive(dirConfig.Path); err != nil {
+			slog.Warn("Failed to watch directory", "path", dirConfig.Path, "error", err)
+		}
+	}
+}
+
+// isConfiguredDirectory reports whether path is one of the configured
+// directories
+func (w *DocumentWatcher) isConfiguredDirectory(path string) bool {
+	for _, dirConfig := range w.app.Config.Directories {
+		if samePath(path, dirConfig.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// addRecursive watches dir and all its subdirectories that are not ignored
+func (w *DocumentWatcher) addRecursive(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if w.app.shouldIgnorePath(path, true) {
+			return filepath.SkipDir
+		}
+		return w.watcher.Add(path)
+	})
+}
+
+// run dispatches file system events
+func (w *DocumentWatcher) run() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("File watcher error", "error", err)
+		}
+	}
+}
+
+// handleEvent schedules reprocessing of the path an event refers to
+func (w *DocumentWatcher) handleEvent(event fsnotify.Event) {
+	info, err := os.Stat(event.Name)
+	isDir := err == nil && info.IsDir()
+	if w.app.shouldIgnorePath(event.Name, isDir) {
+		return
+	}
+
+	// Watch newly created directories and pick up files already inside them
+	if event.Has(fsnotify.Create) {
+		if isDir {
+			if err := w.addRecursive(event.Name); err != nil {
+				slog.Warn("Failed to watch directory", "path", event.Name, "error", err)
+			}
+			filepath.Walk(event.Name, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					w.schedule(path)
+				}
+				return nil
+			})
+			return
+		}
+	}
+
+	if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) ||
+		event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		w.schedule(event.Name)
+	}
+}
+
+// schedule debounces updates of a path
+func (w *DocumentWatcher) schedule(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if timer, ok := w.pending[path]; ok {
+		timer.Reset(watchDebounce)
+		return
+	}
+	w.pending[path] = time.AfterFunc(watchDebounce, func() {
+		w.mu.Lock()
+		delete(w.pending, path)
+		w.mu.Unlock()
+
+		w.sync(path)
+	})
+}
+
+// sync brings the document at path in line with the file on disk: it is
+// reloaded and re-indexed if the file exists, or removed otherwise
+func (w *DocumentWatcher) sync(path string) {
+	w.update.Lock()
+	defer w.update.Unlock()
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	dirConfig, ok := w.sourceFor(path)
+	if !ok {
+		return
+	}
+
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		w.remove(path)
+	case err == nil && !info.IsDir() && w.app.FileRegexes[dirConfig.Path].MatchString(filepath.Base(path)):
+		w.reload(path, dirConfig)
+	}
+}
+
+// reload reads the file at path and adds or replaces its document
+func (w *DocumentWatcher) reload(path string, dirConfig DirectoryConfig) {
+	doc, err := w.app.loadDocument(path, dirConfig.Path, dirConfig.Name)
+	if err != nil {
+		slog.Error("Failed to process file", "path", path, "error", err)
+		return
+	}
+
+	if m := w.app.EmbeddingManager; m != nil && m.IsEnabled() {
+		if err := m.IndexDocument(w.ctx, doc, false); err != nil {
+			slog.Warn("Failed to index document", "path", doc.RelPath, "error", err)
+		}
+		doc.Summary = m.Summary(doc)
+	}
+
+	replaced := false
+	w.app.UpdateDocuments(func(current []Document) ([]Document, bool) {
+		docs := make([]Document, 0, len(current)+1)
+		for _, d := range current {
+			if samePath(d.Path, path) {
+				docs = append(docs, doc)
+				replaced = true
+				continue
+			}
+			docs = append(docs, d)
+		}
+		if !replaced {
+			docs = append(docs, doc)
+		}
+		return docs, true
+	})
+	if replaced {
+		slog.Info("Reloaded document", "path", doc.RelPath)
+	} else {
+		slog.Info("Added document", "path", doc.RelPath)
+	}
+}
+
+// remove drops the documents at or below path (for removed or renamed
+// directories) and deletes their embeddings
+func (w *DocumentWatcher) remove(path string) {
+	var removed []Document
+	w.app.UpdateDocuments(func(current []Document) ([]Document, bool) {
+		docs := make([]Document, 0, len(current))
+		for _, d := range current {
+			if samePath(d.Path, path) || isWithin(d.Path, path) {
+				removed = append(removed, d)
+				continue
+			}
+			docs = append(docs, d)
+		}
+		return docs, len(removed) > 0
+	})
+
+	for _, d := range removed {
+		if m := w.app.EmbeddingManager; m != nil && m.IsEnabled() {
+			if err := m.DeleteDocument(d.RelPath); err != nil {
+				slog.Warn("Failed to delete embeddings", "path", d.RelPath, "error", err)
+			}
+		}
+		slog.Info("Removed document", "path", d.RelPath)
+	}
+}
+
+// sourceFor returns the configured directory containing path
+func (w *DocumentWatcher) sourceFor(path string) (DirectoryConfig, bool) {
+	for _, dirConfig := range w.app.Config.Directories {
+		if isWithin(path, dirConfig.Path) {
+			return dirConfig, true
+		}
+	}
+	return DirectoryConfig{}, false
+}
+
+// isWithin reports whether path is located below dir
+func isWithin(path, dir string) bool {
+	absPath, errPath := filepath.Abs(path)
+	absDir, errDir := filepath.Abs(dir)
+	if errPath != nil || errDir != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// samePath reports whether two paths refer to the same file location
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}